@@ -5,7 +5,12 @@ import (
 	"log/slog"
 
 	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	apcupsdups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/apcupsd"
 	directups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/direct"
+	snmpups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/snmp"
+	sourceups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/source"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
@@ -28,9 +33,16 @@ func NewJSONCommand(logger *slog.Logger) *cobra.Command {
 		Long: `Retrieve JSON from a NUT server and print it to stdout
 
 This is useful for testing the connection to a NUT server
-and for creating rego rules for waking a target`,
+and for creating rego rules for waking a target. With
+--type apcupsd or --type snmp the UPS is read from apcupsd
+or its SNMP management card instead, in the same shape as
+a NUT server's UPSes`,
 		Example: `  upswake json --host 192.168.1.66 --port 3493
-  upswake json -H ups.example.com -P 3493 -u myuser -p mypass`,
+  upswake json -H ups.example.com -P 3493 -u myuser -p mypass
+  upswake json -H ups.example.com -u myuser -p mypass --tls required --tls-ca-file /path/to/ca.pem
+  upswake json -H 192.168.1.67 --type apcupsd
+  upswake json -H 192.168.1.68 --type snmp --snmp-community private
+  upswake json -H 192.168.1.68 --type snmp --snmp-version 3 -u monitor -p authpass`,
 		RunE: jc.JSONRunE,
 	}
 	setupJSONFlags(cmd)
@@ -43,15 +55,52 @@ func (j *jsonCMD) JSONRunE(cmd *cobra.Command, _ []string) error {
 		j.logger.Error("could not get port", slog.Any("error", err))
 		return err
 	}
+	sourceType := entity.SourceType(cmd.Flag("type").Value.String())
+	if err := sourceType.Validate(); err != nil {
+		return err
+	}
+	if !cmd.Flags().Changed("port") {
+		switch sourceType.Kind() {
+		case entity.SourceTypeApcupsd:
+			port = entity.DefaultApcupsdPort
+		case entity.SourceTypeSNMP:
+			port = entity.DefaultSNMPPort
+		}
+	}
 	nutServer := &entity.NutServer{
 		Name:     "test",
+		Type:     sourceType,
 		Host:     cmd.Flag("host").Value.String(),
 		Port:     port,
 		Username: cmd.Flag("username").Value.String(),
 		Password: cmd.Flag("password").Value.String(),
+		TLS: entity.NutTLS{
+			Mode:       entity.NutTLSMode(cmd.Flag("tls").Value.String()),
+			CAFile:     cmd.Flag("tls-ca-file").Value.String(),
+			CertFile:   cmd.Flag("tls-cert-file").Value.String(),
+			KeyFile:    cmd.Flag("tls-key-file").Value.String(),
+			ServerName: cmd.Flag("tls-server-name").Value.String(),
+		},
+		SNMP: entity.NutSNMP{
+			Version:      entity.SNMPVersion(cmd.Flag("snmp-version").Value.String()),
+			Community:    cmd.Flag("snmp-community").Value.String(),
+			AuthProtocol: cmd.Flag("snmp-auth-protocol").Value.String(),
+			PrivProtocol: cmd.Flag("snmp-priv-protocol").Value.String(),
+			PrivPassword: cmd.Flag("snmp-priv-password").Value.String(),
+		},
+	}
+	if err := nutServer.TLS.Validate(); err != nil {
+		return err
+	}
+	if err := nutServer.SNMP.Validate(); err != nil {
+		return err
 	}
 
-	upsRepo := directups.NewDirectRepository()
+	upsRepo := sourceups.NewSourceRepository(map[entity.SourceType]repository.UPSRepository{
+		entity.SourceTypeNUT:     directups.NewDirectRepository(afero.NewOsFs()),
+		entity.SourceTypeApcupsd: apcupsdups.NewApcupsdRepository(apcupsdups.DefaultTimeout),
+		entity.SourceTypeSNMP:    snmpups.NewSNMPRepository(snmpups.DefaultTimeout),
+	})
 
 	upsData, err := upsRepo.GetJSON(nutServer)
 	if err != nil {
@@ -66,6 +115,17 @@ func setupJSONFlags(cmd *cobra.Command) {
 	cmd.Flags().StringP("username", "u", "anonymous", "Username for the NUT server")
 	cmd.Flags().StringP("password", "p", "anonymous", "Password for the NUT server")
 	cmd.Flags().StringP("host", "H", "", "Host address of the NUT server")
-	cmd.Flags().IntP("port", "P", entity.DefaultNUTServerPort, "Port number of the NUT server")
+	cmd.Flags().IntP("port", "P", entity.DefaultNUTServerPort, "Port number of the NUT server, or 3551 for apcupsd and 161 for snmp")
+	cmd.Flags().String("type", string(entity.SourceTypeNUT), "What the host runs: nut, apcupsd or snmp")
+	cmd.Flags().String("tls", string(entity.NutTLSOff), "Whether to use STARTTLS: off, optional or required")
+	cmd.Flags().String("tls-ca-file", "", "CA certificates to verify the NUT server's certificate against")
+	cmd.Flags().String("tls-cert-file", "", "Client certificate to present to the NUT server")
+	cmd.Flags().String("tls-key-file", "", "Key for the client certificate")
+	cmd.Flags().String("tls-server-name", "", "Name to verify the NUT server's certificate against, instead of its host")
+	cmd.Flags().String("snmp-version", string(entity.SNMPVersion2c), "SNMP version: 2c or 3, where 3 logs in with the username and password")
+	cmd.Flags().String("snmp-community", entity.DefaultSNMPCommunity, "SNMPv2c community")
+	cmd.Flags().String("snmp-auth-protocol", "", "SNMPv3 authentication protocol, SHA if unset")
+	cmd.Flags().String("snmp-priv-protocol", "", "SNMPv3 privacy protocol, AES if unset")
+	cmd.Flags().String("snmp-priv-password", "", "SNMPv3 privacy passphrase")
 	_ = cmd.MarkFlagRequired("host")
 }
@@ -20,6 +20,10 @@ func Test_NewJSONCommand(t *testing.T) {
 		assert.Equal(t, "anonymous", jsonCmd.Flags().Lookup("password").DefValue, "default password should be 'anonymous'")
 		assert.Empty(t, jsonCmd.Flags().Lookup("host").DefValue, "default host should be empty")
 		assert.Equal(t, "3493", jsonCmd.Flags().Lookup("port").DefValue, "default port should be '3493'")
+		assert.Equal(t, "off", jsonCmd.Flags().Lookup("tls").DefValue, "default tls should be 'off'")
+		assert.Equal(t, "nut", jsonCmd.Flags().Lookup("type").DefValue, "default type should be 'nut'")
+		assert.Equal(t, "2c", jsonCmd.Flags().Lookup("snmp-version").DefValue, "default snmp version should be '2c'")
+		assert.Equal(t, "public", jsonCmd.Flags().Lookup("snmp-community").DefValue, "default snmp community should be 'public'")
 		assert.NotNil(t, jsonCmd.RunE, "json command RunE function should not be nil")
 	})
 }
@@ -45,6 +49,21 @@ func Test_JSONRunE(t *testing.T) {
 			name: "valid cli args",
 			in:   []string{"json", "--host", "localhost", "--username", "testuser", "--password", "testpass", "--port", "3493"},
 		},
+		{
+			name: "invalid type",
+			in:   []string{"json", "--host", "127.0.0.1", "--type", "upsd"},
+			err:  "type is invalid, must be nut, apcupsd, snmp, command or http",
+		},
+		{
+			name: "apcupsd not reachable",
+			in:   []string{"json", "--host", "127.0.0.1", "--type", "apcupsd", "--port", "1234"},
+			err:  "could not connect to apcupsd: dial tcp 127.0.0.1:1234: connect: connection refused",
+		},
+		{
+			name: "invalid snmp version",
+			in:   []string{"json", "--host", "127.0.0.1", "--type", "snmp", "--snmp-version", "1"},
+			err:  "snmp version is invalid, must be 2c or 3",
+		},
 		{
 			name: "missing host",
 			in:   []string{},
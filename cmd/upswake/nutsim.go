@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutserver"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+const defaultNutSimLogInterval = 10 * time.Second
+
+type nutSimCMD struct {
+	logger *slog.Logger
+	fs     afero.Fs
+}
+
+func NewNutSimCommand(logger *slog.Logger, fs afero.Fs) *cobra.Command {
+	childLogger := logger.With(
+		slog.String("cmd", "nut-sim"),
+	)
+
+	nc := &nutSimCMD{
+		logger: childLogger,
+		fs:     fs,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "nut-sim",
+		Short: "Run a simulated NUT server",
+		Long: `Run a simulated NUT server that plays a scenario
+
+The scenario is a YAML file giving each UPS's initial variables
+and the events that change them over time, such as a power cut
+partway through. This is useful for trying out rules and the
+UPSWake server without a real UPS`,
+		Example: `  upswake nut-sim --scenario fixtures/nut/scenarios/power-cut.yaml
+  upswake nut-sim -s power-cut.yaml -H 0.0.0.0 -P 3493 -u upsmon -p secret
+  upswake nut-sim -s power-cut.yaml --tls-cert-file /path/to/cert.pem --tls-key-file /path/to/key.pem`,
+		RunE: nc.nutSimRunE,
+	}
+	cmd.Flags().StringP("scenario", "s", "", "Scenario file to play")
+	cmd.Flags().StringP("host", "H", "127.0.0.1", "Interface to listen on")
+	cmd.Flags().IntP("port", "P", entity.DefaultNUTServerPort, "Port to listen on")
+	cmd.Flags().StringP("username", "u", "", "Username clients must log in with, any if unset")
+	cmd.Flags().StringP("password", "p", "", "Password clients must log in with")
+	cmd.Flags().String("tls-cert-file", "", "Certificate to enable STARTTLS with")
+	cmd.Flags().String("tls-key-file", "", "Key for the STARTTLS certificate")
+	cmd.Flags().Duration("log-interval", defaultNutSimLogInterval, "How often to log the simulated UPS state, 0 to disable")
+	_ = cmd.MarkFlagRequired("scenario")
+	cmd.MarkFlagsRequiredTogether("username", "password")
+	cmd.MarkFlagsRequiredTogether("tls-cert-file", "tls-key-file")
+	return cmd
+}
+
+func (n *nutSimCMD) nutSimRunE(cmd *cobra.Command, _ []string) error {
+	ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	scenarioPath, _ := cmd.Flags().GetString("scenario")
+	host, _ := cmd.Flags().GetString("host")
+	username, _ := cmd.Flags().GetString("username")
+	password, _ := cmd.Flags().GetString("password")
+	certFile, _ := cmd.Flags().GetString("tls-cert-file")
+	keyFile, _ := cmd.Flags().GetString("tls-key-file")
+	logInterval, _ := cmd.Flags().GetDuration("log-interval")
+	port, err := cmd.Flags().GetInt("port")
+	if err != nil {
+		return err
+	}
+
+	scenario, err := nutserver.LoadScenario(n.fs, scenarioPath)
+	if err != nil {
+		return err
+	}
+	player := nutserver.NewPlayer(scenario)
+
+	opts := []nutserver.Option{nutserver.WithLogger(n.logger)}
+	if username != "" {
+		opts = append(opts, nutserver.WithUser(username, password))
+	}
+	if certFile != "" {
+		tlsConfig, err := n.tlsConfig(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, nutserver.WithTLS(tlsConfig))
+	}
+
+	server := nutserver.NewServer(player, opts...)
+	if err := server.Listen(net.JoinHostPort(host, strconv.Itoa(port))); err != nil {
+		return err
+	}
+	n.logger.Info("Simulated NUT server listening",
+		slog.String("address", server.Addr().String()),
+		slog.String("scenario", scenarioPath),
+		slog.Bool("tls", certFile != ""))
+
+	if logInterval > 0 {
+		go n.logState(ctx, player, logInterval)
+	}
+
+	err = server.Serve(ctx)
+	n.logger.Info("Simulated NUT server stopped")
+	return err
+}
+
+// tlsConfig loads the certificate the server presents to clients that
+// send STARTTLS.
+func (n *nutSimCMD) tlsConfig(certFile, keyFile string) (*tls.Config, error) {
+	certPEM, err := afero.ReadFile(n.fs, certFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", entity.ErrFailedReadCertFile, err)
+	}
+	keyPEM, err := afero.ReadFile(n.fs, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", entity.ErrFailedReadCertFile, err)
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", entity.ErrFailedParsePEM, err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// logState logs the state of the simulated UPSes every interval until ctx
+// is done.
+func (n *nutSimCMD) logState(ctx context.Context, player *nutserver.Player, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			elapsed := player.Elapsed().Truncate(time.Second)
+			for _, ups := range player.UPSes() {
+				n.logger.Info("Simulated UPS state",
+					slog.String("ups", ups.Name),
+					slog.Duration("elapsed", elapsed),
+					slog.Any("variables", ups.Variables))
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutserver"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nutSimScenario = `
+ups:
+  - name: cyberpower900
+    description: Simulated UPS for testing
+    vars:
+      battery.charge: 100
+      ups.status: OL
+    events:
+      - at: 1h
+        set:
+          ups.status: OB DISCHRG
+`
+
+func Test_NewNutSimCommand(t *testing.T) {
+	t.Run("nut-sim command", func(t *testing.T) {
+		nutSimCmd := NewNutSimCommand(newTestLogger(), afero.NewMemMapFs())
+		assert.Equal(t, "nut-sim", nutSimCmd.Use, "nut-sim command should be 'nut-sim'")
+		assert.NotEmpty(t, nutSimCmd.Short)
+		assert.NotEmpty(t, nutSimCmd.Long)
+		assert.NotEmpty(t, nutSimCmd.Example)
+		assert.Empty(t, nutSimCmd.Flags().Lookup("scenario").DefValue, "default scenario should be empty")
+		assert.Equal(t, "127.0.0.1", nutSimCmd.Flags().Lookup("host").DefValue, "default host should be '127.0.0.1'")
+		assert.Equal(t, "3493", nutSimCmd.Flags().Lookup("port").DefValue, "default port should be '3493'")
+		assert.Empty(t, nutSimCmd.Flags().Lookup("username").DefValue, "default username should be empty")
+		assert.Equal(t, "10s", nutSimCmd.Flags().Lookup("log-interval").DefValue)
+		assert.NotNil(t, nutSimCmd.RunE, "nut-sim command RunE function should not be nil")
+	})
+}
+
+func newNutSimTestCommand(t *testing.T) func(logger *slog.Logger) *cobra.Command {
+	t.Helper()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "scenario.yaml", []byte(nutSimScenario), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "invalid.yaml", []byte("ups: []"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "cert.pem", []byte("not a certificate"), 0o644))
+	return func(logger *slog.Logger) *cobra.Command {
+		return NewNutSimCommand(logger, fs)
+	}
+}
+
+func Test_nutSimRunE(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		err     string
+		in      []string
+	}{
+		{
+			name: "missing scenario",
+			in:   []string{},
+			err:  `required flag(s) "scenario" not set`,
+		},
+		{
+			name:    "scenario not found",
+			in:      []string{"--scenario", "missing.yaml", "--port", "0"},
+			wantErr: nutserver.ErrReadingScenario,
+		},
+		{
+			name:    "invalid scenario",
+			in:      []string{"--scenario", "invalid.yaml", "--port", "0"},
+			wantErr: nutserver.ErrInvalidScenario,
+		},
+		{
+			name: "username without password",
+			in:   []string{"--scenario", "scenario.yaml", "--port", "0", "--username", "upsmon"},
+			err:  "if any flags in the group [username password] are set they must all be set",
+		},
+		{
+			name:    "missing TLS key",
+			in:      []string{"--scenario", "scenario.yaml", "--port", "0", "--tls-cert-file", "cert.pem", "--tls-key-file", "key.pem"},
+			wantErr: entity.ErrFailedReadCertFile,
+		},
+		{
+			name:    "invalid TLS certificate",
+			in:      []string{"--scenario", "scenario.yaml", "--port", "0", "--tls-cert-file", "cert.pem", "--tls-key-file", "cert.pem"},
+			wantErr: entity.ErrFailedParsePEM,
+		},
+		{
+			name: "invalid port",
+			in:   []string{"--scenario", "scenario.yaml", "--port", "invalid"},
+			err:  `invalid argument "invalid" for "-P, --port" flag`,
+		},
+		{
+			name:    "serves until stopped",
+			in:      []string{"--scenario", "scenario.yaml", "--port", "0"},
+			wantErr: ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommandWithContext(t, newNutSimTestCommand(t), 100*time.Millisecond, tt.in)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_nutSimRunE_serves(t *testing.T) {
+	// find a free port for the simulator to listen on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	logBuf := new(bytes.Buffer)
+	cmd := newNutSimTestCommand(t)(slog.New(slog.NewJSONHandler(logBuf, nil)))
+	cmd.SetArgs([]string{"--scenario", "scenario.yaml", "--port", strconv.Itoa(port), "-u", "upsmon", "-p", "secret"})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+
+	var client *nutclient.Client
+	require.Eventually(t, func() bool {
+		client, err = nutclient.Dial(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), nutclient.Options{})
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	ok, err := client.Authenticate("upsmon", "secret")
+	require.NoError(t, err)
+	require.True(t, ok)
+	upsList, err := client.GetUPSList()
+	require.NoError(t, err)
+	require.NoError(t, client.Disconnect())
+
+	require.Len(t, upsList, 1)
+	assert.Equal(t, "cyberpower900", upsList[0].Name)
+	assert.Equal(t, "Simulated UPS for testing", upsList[0].Description)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Contains(t, logBuf.String(), "Simulated NUT server listening")
+	assert.Contains(t, logBuf.String(), "Simulated NUT server stopped")
+}
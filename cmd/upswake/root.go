@@ -61,6 +61,18 @@ func Execute(ctx context.Context, fs, regoFs afero.Fs, logDestination io.Writer,
 	healthCheckCmd := NewHealthCheckCommand(logger)
 	serveCmd.AddCommand(healthCheckCmd)
 
+	nutSimCmd := NewNutSimCommand(logger, fs)
+	rootCmd.AddCommand(nutSimCmd)
+
+	rulesCmd := NewRulesCommand()
+	rootCmd.AddCommand(rulesCmd)
+
+	rulesTestCmd := NewRulesTestCommand(logger, regoFs)
+	rulesCmd.AddCommand(rulesTestCmd)
+
+	rulesEvalCmd := NewRulesEvalCommand(logger, fs, regoFs)
+	rulesCmd.AddCommand(rulesEvalCmd)
+
 	err = rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		logger.Error(
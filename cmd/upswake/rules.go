@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/evaluator"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/config/viper"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/rules"
+	fileups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/file"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+var ErrRuleTestsFailed = errors.New("rego rule tests failed")
+
+type rulesCMD struct {
+	logger *slog.Logger
+	fs     afero.Fs
+	regoFs afero.Fs
+}
+
+// targetEvaluation is the outcome of evaluating a single target offline.
+type targetEvaluation struct {
+	Target   string                 `json:"target"`
+	MAC      string                 `json:"mac"`
+	Reason   string                 `json:"reason,omitempty"`
+	RuleMode string                 `json:"rule_mode"`
+	Rules    []*entity.RuleDecision `json:"rules"`
+	Woken    bool                   `json:"woken"`
+}
+
+func NewRulesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rules",
+		Short: "Work with the rego rules",
+		Long:  `Test and evaluate the rego rules used to decide whether to wake a target`,
+	}
+}
+
+func NewRulesTestCommand(logger *slog.Logger, regoFs afero.Fs) *cobra.Command {
+	childLogger := logger.With(
+		slog.String("cmd", "rules test"),
+	)
+
+	rc := &rulesCMD{
+		logger: childLogger,
+		regoFs: regoFs,
+	}
+
+	return &cobra.Command{
+		Use:   "test",
+		Short: "Run the rego rule unit tests",
+		Long: `Run the rego unit tests in the rules directory
+
+Tests for a rule are written in a file of the same name ending in
+'_test.rego', e.g. '80percentOn_test.rego' tests '80percentOn.rego'.
+Both files must be in the 'upswake' package.
+
+UPS snapshots in the 'fixtures' directory, in the same shape as the
+output of 'upswake json', are available to tests as
+'data.fixtures.<file name without .json>'`,
+		Example: `  upswake rules test`,
+		RunE:    rc.rulesTestRunE,
+	}
+}
+
+func NewRulesEvalCommand(logger *slog.Logger, fs, regoFs afero.Fs) *cobra.Command {
+	childLogger := logger.With(
+		slog.String("cmd", "rules eval"),
+	)
+
+	rc := &rulesCMD{
+		logger: childLogger,
+		fs:     fs,
+		regoFs: regoFs,
+	}
+
+	evalCmd := &cobra.Command{
+		Use:   "eval",
+		Short: "Evaluate the rules against UPS JSON without a NUT server",
+		Long: `Evaluate the rules for every target in the config file against a
+UPS JSON document, and print which targets would be woken and by which rule
+
+The document is either the output of 'upswake json', which is used for
+every NUT server in the config file, or an object mapping NUT server names
+to the output of 'upswake json' for that server.
+
+No NUT server is contacted and no Wake on LAN packets are sent`,
+		Example: `  upswake rules eval --input ups.json
+  upswake json -H 192.168.1.66 | upswake rules eval
+  upswake rules eval --config ./config.yaml -i ups.json --json`,
+		RunE: rc.rulesEvalRunE,
+	}
+	evalCmd.Flags().StringP("input", "i", "-", "UPS JSON document to evaluate, or '-' for stdin")
+	evalCmd.Flags().String("config", "./config.yaml", "The location of config file")
+	evalCmd.Flags().Bool("json", false, "Print the results as JSON")
+	return evalCmd
+}
+
+func (r *rulesCMD) rulesEvalRunE(cmd *cobra.Command, _ []string) error {
+	cfgPath, _ := cmd.Flags().GetString("config")
+	inputPath, _ := cmd.Flags().GetString("input")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	cfg, err := viper.NewConfigLoader(r.fs, cfgPath).Load()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	ruleRepo, err := newRuleRepository(r.fs, r.regoFs, cfg)
+	if err != nil {
+		return err
+	}
+
+	upsRepo, err := r.loadUPSDocument(cmd, inputPath)
+	if err != nil {
+		r.logger.Error("failed to read UPS JSON", slog.String("input", inputPath), slog.Any("error", err))
+		return err
+	}
+
+	// Offline evaluations are only recorded if the decision log is written
+	// to a file, as the in-memory entries would be lost on exit
+	var options []evaluator.Option
+	if cfg.DecisionLog != nil && cfg.DecisionLog.File != "" {
+		decisions, closeDecisions, err := newDecisionLog(r.fs, cfg.DecisionLog)
+		if err != nil {
+			return err
+		}
+		defer closeDecisions()
+		options = append(options, evaluator.WithDecisionLog(decisions))
+	}
+
+	var evaluations []*targetEvaluation
+	evaluated := make(map[string]bool)
+	for _, nutServer := range cfg.NutServers {
+		for _, target := range nutServer.Targets {
+			if evaluated[target.MAC] {
+				continue
+			}
+			evaluated[target.MAC] = true
+
+			result, err := evaluator.NewRegoEvaluator(cfg, target.MacAddress, upsRepo, ruleRepo, options...).EvaluateExpressions()
+			if err != nil {
+				return fmt.Errorf("error evaluating %s: %w", target.Name, err)
+			}
+			evaluations = append(evaluations, &targetEvaluation{
+				Target:   target.Name,
+				MAC:      target.MAC,
+				Reason:   result.Reason(),
+				RuleMode: result.Mode,
+				Rules:    result.Decisions,
+				Woken:    result.Allowed,
+			})
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(evaluations)
+	}
+	for _, evaluation := range evaluations {
+		printTargetEvaluation(out, evaluation)
+	}
+	return nil
+}
+
+// loadUPSDocument reads the UPS JSON document from inputPath, or from stdin
+// if inputPath is '-'.
+func (r *rulesCMD) loadUPSDocument(cmd *cobra.Command, inputPath string) (*fileups.FileRepository, error) {
+	if inputPath == "-" {
+		return fileups.NewFileRepository(cmd.InOrStdin())
+	}
+
+	file, err := r.fs.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return fileups.NewFileRepository(file)
+}
+
+func printTargetEvaluation(out io.Writer, evaluation *targetEvaluation) {
+	outcome := "not woken"
+	if evaluation.Woken {
+		var allowedBy []string
+		for _, decision := range evaluation.Rules {
+			if decision.Allowed {
+				allowedBy = append(allowedBy, decision.Rule)
+			}
+		}
+		outcome = "woken by " + strings.Join(allowedBy, ", ")
+	}
+	if evaluation.RuleMode != string(entity.RuleModeAny) {
+		outcome += " (rule mode " + evaluation.RuleMode + ")"
+	}
+	_, _ = fmt.Fprintf(out, "%s (%s): %s\n", evaluation.Target, evaluation.MAC, outcome)
+
+	for _, decision := range evaluation.Rules {
+		line := fmt.Sprintf("  %s: %t", decision.Rule, decision.Allowed)
+		if decision.Denied {
+			line += ", denied"
+		}
+		if decision.Reason != "" {
+			line += " (" + decision.Reason + ")"
+		}
+		_, _ = fmt.Fprintln(out, line)
+	}
+}
+
+func (r *rulesCMD) rulesTestRunE(cmd *cobra.Command, _ []string) error {
+	reports, err := rules.RunTests(cmd.Context(), r.regoFs)
+	if err != nil {
+		r.logger.Error("failed to run rego rule tests", slog.Any("error", err))
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(reports) == 0 {
+		_, _ = fmt.Fprintln(out, "no rego test files found")
+		return nil
+	}
+
+	passed, total := 0, 0
+	for _, report := range reports {
+		p, t := printTestReport(out, report)
+		passed += p
+		total += t
+	}
+
+	if passed != total {
+		_, _ = fmt.Fprintf(out, "FAIL: %d/%d tests passed\n", passed, total)
+		return fmt.Errorf("%w: %d of %d failed", ErrRuleTestsFailed, total-passed, total)
+	}
+	_, _ = fmt.Fprintf(out, "PASS: %d/%d tests passed\n", passed, total)
+	return nil
+}
+
+// printTestReport writes the results for a single rule file and returns the
+// number of tests that passed, and the number that were run.
+func printTestReport(out io.Writer, report *rules.TestReport) (passed, total int) {
+	_, _ = fmt.Fprintf(out, "%s (%s)\n", report.Rule, report.TestFile)
+	for _, result := range report.Results {
+		switch {
+		case result.Skipped:
+			_, _ = fmt.Fprintf(out, "  SKIP  %s\n", result.Name)
+			continue
+		case result.Passed:
+			passed++
+			_, _ = fmt.Fprintf(out, "  PASS  %s (%s)\n", result.Name, result.Duration)
+		case result.Error != nil:
+			_, _ = fmt.Fprintf(out, "  ERROR %s: %s\n", result.Name, result.Error)
+		default:
+			_, _ = fmt.Fprintf(out, "  FAIL  %s (%s)\n", result.Name, result.Duration)
+			if result.FailedAt != "" {
+				_, _ = fmt.Fprintf(out, "        failed at: %s\n", result.FailedAt)
+			}
+		}
+		total++
+	}
+	_, _ = fmt.Fprintf(out, "  coverage: %.1f%%\n", report.Coverage)
+	return passed, total
+}
+
+// newRuleRepository loads the rules from the bundle in the config if there
+// is one, or from regoFs if not, along with the config's inline rules.
+func newRuleRepository(fs, regoFs afero.Fs, cfg *entity.Config) (*rules.PreparedRepository, error) {
+	var ruleRepo *rules.PreparedRepository
+	var err error
+	if cfg.Bundle != nil {
+		var publicKey []byte
+		publicKey, err = afero.ReadFile(fs, cfg.Bundle.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("error reading bundle public key: %w", err)
+		}
+		ruleRepo, err = rules.NewBundleRepository(fs, cfg.Bundle.Path, rules.BundleVerification{
+			PublicKey: string(publicKey),
+			Algorithm: cfg.Bundle.Algorithm,
+			Scope:     cfg.Bundle.Scope,
+		})
+	} else {
+		ruleRepo, err = rules.NewPreparedRepository(regoFs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error compiling rego rules: %w", err)
+	}
+
+	if err = ruleRepo.LoadInlineRules(cfg.InlineRules()); err != nil {
+		return nil, fmt.Errorf("error compiling inline rego rules: %w", err)
+	}
+	ruleRepo.SetLocation(cfg.Location())
+	return ruleRepo, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/config/viper"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/rules"
+	fileups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/file"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rulesTestFixture = `[{"Name":"cyberpower900","Description":"Unavailable","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[{"Name":"battery.charge","Value":100,"Type":"INTEGER","Description":"Battery charge (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"}]}]`
+
+func Test_NewRulesCommand(t *testing.T) {
+	t.Run("rules command", func(t *testing.T) {
+		rulesCmd := NewRulesCommand()
+		assert.Equal(t, "rules", rulesCmd.Use, "rules command should be 'rules'")
+		assert.NotEmpty(t, rulesCmd.Short)
+		assert.NotEmpty(t, rulesCmd.Long)
+	})
+
+	t.Run("rules test command", func(t *testing.T) {
+		testCmd := NewRulesTestCommand(newTestLogger(), afero.NewMemMapFs())
+		assert.Equal(t, "test", testCmd.Use, "rules test command should be 'test'")
+		assert.NotEmpty(t, testCmd.Short)
+		assert.NotEmpty(t, testCmd.Long)
+		assert.NotEmpty(t, testCmd.Example)
+		assert.NotNil(t, testCmd.RunE, "rules test command RunE function should not be nil")
+	})
+
+	t.Run("rules eval command", func(t *testing.T) {
+		evalCmd := NewRulesEvalCommand(newTestLogger(), afero.NewMemMapFs(), afero.NewMemMapFs())
+		assert.Equal(t, "eval", evalCmd.Use, "rules eval command should be 'eval'")
+		assert.NotEmpty(t, evalCmd.Short)
+		assert.NotEmpty(t, evalCmd.Long)
+		assert.NotEmpty(t, evalCmd.Example)
+		assert.Equal(t, "-", evalCmd.Flags().Lookup("input").DefValue, "default input should be stdin")
+		assert.Equal(t, "./config.yaml", evalCmd.Flags().Lookup("config").DefValue)
+		assert.Equal(t, "false", evalCmd.Flags().Lookup("json").DefValue)
+		assert.NotNil(t, evalCmd.RunE, "rules eval command RunE function should not be nil")
+	})
+}
+
+const rulesEvalConfig = `
+nut_servers:
+  - name: test-nut-server
+    host: 127.0.0.1
+    port: 3493
+    username: username
+    password: password
+    targets:
+      - name: charged-target
+        mac: "00:00:00:00:00:01"
+        broadcast: 127.0.0.255
+        port: 9
+        interval: 15m
+        rules:
+          - charge.rego
+      - name: never-target
+        mac: "00:00:00:00:00:02"
+        broadcast: 127.0.0.255
+        port: 9
+        interval: 15m
+        rules:
+          - alwaysFalse.rego
+      - name: inline-target
+        mac: "00:00:00:00:00:03"
+        broadcast: 127.0.0.255
+        port: 9
+        interval: 15m
+        rules:
+          - name: always
+            inline: |
+              package upswake
+              default wake := true
+      - name: quorum-target
+        mac: "00:00:00:00:00:04"
+        broadcast: 127.0.0.255
+        port: 9
+        interval: 15m
+        rule_mode: quorum:2
+        rules:
+          - charge.rego
+          - alwaysFalse.rego
+          - name: always
+            inline: |
+              package upswake
+              default wake := true
+`
+
+func Test_rulesEvalRunE(t *testing.T) {
+	tests := []struct {
+		err     error
+		name    string
+		stdin   string
+		args    []string
+		outputs []string
+	}{
+		{
+			name: "input from file",
+			args: []string{"--config", "config.yaml", "--input", "ups.json"},
+			outputs: []string{
+				"charged-target (00:00:00:00:00:01): woken by charge.rego",
+				"  charge.rego: true (charge is at least 80%)",
+				"never-target (00:00:00:00:00:02): not woken",
+				"  alwaysFalse.rego: false",
+				"inline-target (00:00:00:00:00:03): woken by inline:always",
+				"quorum-target (00:00:00:00:00:04): woken by charge.rego, inline:always (rule mode quorum:2)",
+			},
+		},
+		{
+			name:  "input from stdin",
+			args:  []string{"--config", "config.yaml"},
+			stdin: `{"test-nut-server": []}`,
+			outputs: []string{
+				"charged-target (00:00:00:00:00:01): not woken",
+				"  charge.rego: false",
+			},
+		},
+		{
+			name: "json output",
+			args: []string{"--config", "config.yaml", "-i", "ups.json", "--json"},
+			outputs: []string{
+				`"target": "charged-target"`,
+				`"reason": "charge is at least 80%"`,
+				`"rule_mode": "quorum:2"`,
+				`"woken": true`,
+			},
+		},
+		{
+			name:  "input for a different server",
+			args:  []string{"--config", "config.yaml"},
+			stdin: `{"other-nut-server": []}`,
+			err:   fileups.ErrServerNotFound,
+		},
+		{
+			name:  "invalid input",
+			args:  []string{"--config", "config.yaml"},
+			stdin: `{"battery.charge": 100}`,
+			err:   fileups.ErrInvalidDocument,
+		},
+		{
+			name: "missing input file",
+			args: []string{"--config", "config.yaml", "--input", "missing.json"},
+			err:  os.ErrNotExist,
+		},
+		{
+			name: "missing config file",
+			args: []string{"--config", "missing.yaml", "--input", "ups.json"},
+			err:  viper.ErrReadingConfigFile,
+		},
+		{
+			name: "rules from missing bundle",
+			args: []string{"--config", "bundle.yaml", "--input", "ups.json"},
+			err:  rules.ErrReadBundle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "config.yaml", []byte(rulesEvalConfig), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "ups.json", []byte(rulesTestFixture), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "bundle.yaml", []byte("bundle:\n  path: bundle.tar.gz\n  public_key: bundle.pub.pem\n"+rulesEvalConfig), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "bundle.pub.pem", []byte("public key"), 0o644))
+
+			regoFs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(regoFs, "alwaysFalse.rego", []byte("package upswake\ndefault wake := false"), 0o644))
+			require.NoError(t, afero.WriteFile(regoFs, "charge.rego", []byte(`package upswake
+default wake := false
+wake if input[_].Variables[_].Value >= 80
+reason := "charge is at least 80%" if wake`), 0o644))
+
+			newCmd := func(logger *slog.Logger) *cobra.Command {
+				cmd := NewRulesEvalCommand(logger, fs, regoFs)
+				cmd.SetIn(strings.NewReader(tt.stdin))
+				return cmd
+			}
+			output, err := executeCommandWithContext(t, newCmd, 5*time.Second, tt.args)
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			for _, want := range tt.outputs {
+				assert.Contains(t, output, want)
+			}
+		})
+	}
+}
+
+func Test_rulesTestRunE(t *testing.T) {
+	tests := []struct {
+		files   map[string]string
+		err     error
+		name    string
+		outputs []string
+	}{
+		{
+			name:    "no test files",
+			files:   map[string]string{"alwaysTrue.rego": "package upswake\ndefault wake := true"},
+			outputs: []string{"no rego test files found"},
+		},
+		{
+			name: "passing tests",
+			files: map[string]string{
+				"charge.rego":        "package upswake\ndefault wake := false\nwake if input[_].Variables[_].Value >= 80",
+				"charge_test.rego":   "package upswake\ntest_full_charge if wake with input as data.fixtures.full",
+				"fixtures/full.json": rulesTestFixture,
+			},
+			outputs: []string{
+				"charge.rego (charge_test.rego)",
+				"PASS  upswake.test_full_charge",
+				"coverage: 50.0%",
+				"PASS: 1/1 tests passed",
+			},
+		},
+		{
+			name: "failing tests",
+			files: map[string]string{
+				"charge.rego":        "package upswake\ndefault wake := false\nwake if input[_].Variables[_].Value >= 80",
+				"charge_test.rego":   "package upswake\ntest_full_charge if not wake with input as data.fixtures.full",
+				"fixtures/full.json": rulesTestFixture,
+			},
+			err: ErrRuleTestsFailed,
+			outputs: []string{
+				"FAIL  upswake.test_full_charge",
+				"FAIL: 0/1 tests passed",
+			},
+		},
+		{
+			name: "invalid test file",
+			files: map[string]string{
+				"charge.rego":      "package upswake\ndefault wake := false",
+				"charge_test.rego": "package other\ntest_wake if true",
+			},
+			outputs: []string{"failed to run rego rule tests"},
+			err:     rules.ErrPackageName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regoFs := afero.NewMemMapFs()
+			for name, content := range tt.files {
+				require.NoError(t, afero.WriteFile(regoFs, name, []byte(content), 0o644))
+			}
+
+			newCmd := func(logger *slog.Logger) *cobra.Command {
+				return NewRulesTestCommand(logger, regoFs)
+			}
+			output, err := executeCommandWithContext(t, newCmd, 5*time.Second, []string{})
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			for _, want := range tt.outputs {
+				assert.Contains(t, output, want)
+			}
+		})
+	}
+}
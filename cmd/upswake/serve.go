@@ -6,15 +6,24 @@ import (
 	"log/slog"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/TheDarthMole/UPSWake/internal/api"
 	"github.com/TheDarthMole/UPSWake/internal/api/handlers"
 	config "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/TheDarthMole/UPSWake/internal/evaluator"
 	"github.com/TheDarthMole/UPSWake/internal/infrastructure/config/viper"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/decisionlog"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/history"
 	"github.com/TheDarthMole/UPSWake/internal/infrastructure/rules"
+	apcupsdups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/apcupsd"
 	cachedups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/cached"
 	directups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/direct"
+	failoverups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/failover"
+	pluginups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/plugin"
+	recordedups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/recorded"
+	snmpups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/snmp"
+	sourceups "github.com/TheDarthMole/UPSWake/internal/infrastructure/ups/source"
 	"github.com/TheDarthMole/UPSWake/internal/worker"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
@@ -59,6 +68,7 @@ func NewServeCommand(ctx context.Context, logger *slog.Logger, fs, regoFs afero.
 	serveCmd.Flags().BoolP("ssl", "s", false, "Enable SSL (HTTPS)")
 	serveCmd.Flags().StringP("certFile", "c", "", "SSL Certificate file (required if SSL is enabled)")
 	serveCmd.Flags().StringP("keyFile", "k", "", "SSL Key file (required if SSL is enabled)")
+	serveCmd.Flags().Duration("nut-idle-timeout", directups.DefaultIdleTimeout, "How long an unused connection to a NUT server is kept open")
 	serveCmd.Flags().String(
 		"config",
 		"./config.yaml",
@@ -77,6 +87,7 @@ func (j *serveCMD) serveCmdRunE(cmd *cobra.Command, _ []string) error {
 	keyFile, _ := cmd.Flags().GetString("keyFile")
 	host, _ := cmd.Flags().GetString("host")
 	port, _ := cmd.Flags().GetString("port")
+	nutIdleTimeout, _ := cmd.Flags().GetDuration("nut-idle-timeout")
 	useSSL, err := cmd.Flags().GetBool("ssl")
 	if err != nil {
 		return err
@@ -94,13 +105,35 @@ func (j *serveCMD) serveCmdRunE(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("error loading config: %w", err)
 	}
 
-	ruleRepo, err := rules.NewPreparedRepository(j.regoFs)
+	ruleRepo, err := newRuleRepository(j.fs, j.regoFs, cfg)
 	if err != nil {
-		return fmt.Errorf("error compiling rego rules: %w", err)
+		return err
+	}
+	if cfg.Bundle != nil {
+		j.logger.Info("Loaded rego rules from bundle",
+			slog.String("bundle", cfg.Bundle.Path),
+			slog.String("revision", ruleRepo.Revision()))
 	}
+	go ruleRepo.Watch(ctx, j.logger, rules.DefaultReloadInterval)
 
-	directUpsRepo := directups.NewDirectRepository()
-	cachedUpsRepo := cachedups.NewCachedRepository(directUpsRepo, 5*time.Minute)
+	upsHistory, err := history.NewRepository(j.fs, cfg.History.HistoryFile(), cfg.History.RetentionPeriod())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = upsHistory.Close() }()
+
+	pooledUpsRepo := directups.NewPooledRepository(j.fs, nutIdleTimeout)
+	defer pooledUpsRepo.Close()
+	sourceUpsRepo := sourceups.NewSourceRepository(map[config.SourceType]repository.UPSRepository{
+		config.SourceTypeNUT:     pooledUpsRepo,
+		config.SourceTypeApcupsd: apcupsdups.NewApcupsdRepository(apcupsdups.DefaultTimeout),
+		config.SourceTypeSNMP:    snmpups.NewSNMPRepository(snmpups.DefaultTimeout),
+		config.SourceTypeCommand: pluginups.NewCommandRepository(),
+		config.SourceTypeHTTP:    pluginups.NewHTTPRepository(nil),
+	})
+	failoverUpsRepo := failoverups.NewFailoverRepository(sourceUpsRepo, j.logger)
+	recordedUpsRepo := recordedups.NewRecordedRepository(failoverUpsRepo, upsHistory, cfg.History.RecordedVariables(), j.logger)
+	cachedUpsRepo := cachedups.NewCachedRepository(recordedUpsRepo, config.DefaultCacheTTL)
 
 	server := api.NewServer(cmd.Context(), j.logger)
 
@@ -110,21 +143,56 @@ func (j *serveCMD) serveCmdRunE(cmd *cobra.Command, _ []string) error {
 		profilerHandler.Register(server.Root().Group("/debug/pprof"))
 	}
 
-	rootHandler := handlers.NewRootHandler(cfg, j.regoFs, cachedUpsRepo)
+	rootHandler := handlers.NewRootHandler(cfg, j.regoFs, cachedUpsRepo, ruleRepo)
 	rootHandler.Register(server.Root())
 
 	serverHandler := handlers.NewServerHandler()
 	serverHandler.Register(server.API().Group("/servers"))
 
-	upsWakeHandler := handlers.NewUPSWakeHandler(cfg, cachedUpsRepo, ruleRepo)
+	decisions, closeDecisions, err := newDecisionLog(j.fs, cfg.DecisionLog)
+	if err != nil {
+		return err
+	}
+	defer closeDecisions()
+
+	upsWakeHandler := handlers.NewUPSWakeHandler(cfg, cachedUpsRepo, ruleRepo, evaluator.NewInputBuilder(), decisions)
 	upsWakeHandler.Register(server.API().Group("/upswake"))
 
+	decisionLogHandler := handlers.NewDecisionLogHandler(decisions)
+	decisionLogHandler.Register(server.API().Group("/decisions"))
+
+	upsGroup := server.API().Group("/ups")
+	historyHandler := handlers.NewHistoryHandler(cfg, upsHistory)
+	historyHandler.Register(upsGroup)
+
+	upsHandler := handlers.NewUPSHandler(cfg, cachedUpsRepo)
+	upsHandler.Register(upsGroup)
+
+	endpointHandler := handlers.NewEndpointHandler(cfg, failoverUpsRepo)
+	endpointHandler.Register(server.API().Group("/endpoints"))
+
+	cacheHandler := handlers.NewCacheHandler(cachedUpsRepo)
+	cacheHandler.Register(server.API().Group("/cache"))
+
 	workerPool, err := worker.NewWorkerPool(ctx, cfg, cliArgs.TLSConfig, j.logger, fmt.Sprintf("%s/api/upswake", cliArgs.URL()))
 	if err != nil {
 		return fmt.Errorf("error creating worker pool: %w", err)
 	}
 	workerPool.Start()
 
+	// the watcher reads below the cache so it sees changes straight away,
+	// and below the history so its polls aren't recorded. It drops the NUT
+	// server from the cache so the evaluations it triggers see the changes
+	// too
+	var watcher *worker.Watcher
+	if cfg.Watch != nil {
+		watcher = worker.NewWatcher(cfg, failoverUpsRepo, func(nutServer *config.NutServer, _ []worker.Transition) {
+			cachedUpsRepo.Invalidate(nutServer)
+			workerPool.Trigger(nutServer.Name)
+		}, j.logger)
+		watcher.Start(ctx)
+	}
+
 	err = server.Start(
 		j.fs,
 		cliArgs.ListenAddress(),
@@ -136,6 +204,9 @@ func (j *serveCMD) serveCmdRunE(cmd *cobra.Command, _ []string) error {
 	j.logger.Info("Server stopped, waiting for workers to finish")
 	cancel()
 	workerPool.Wait()
+	if watcher != nil {
+		watcher.Wait()
+	}
 	j.logger.Info("All workers stopped, exiting")
 
 	if err != nil {
@@ -144,3 +215,17 @@ func (j *serveCMD) serveCmdRunE(cmd *cobra.Command, _ []string) error {
 	}
 	return nil
 }
+
+// newDecisionLog creates the decision log described by cfg, which may be
+// nil. The returned function closes the decision log file, if there is one.
+func newDecisionLog(fs afero.Fs, cfg *config.DecisionLog) (*decisionlog.Repository, func(), error) {
+	if cfg == nil || cfg.File == "" {
+		return decisionlog.NewRepository(cfg.Buffer(), nil), func() {}, nil
+	}
+
+	file, err := decisionlog.NewRotatingFile(fs, cfg.File, cfg.MaxSize(), cfg.Backups())
+	if err != nil {
+		return nil, nil, err
+	}
+	return decisionlog.NewRepository(cfg.Buffer(), file), func() { _ = file.Close() }, nil
+}
@@ -31,6 +31,7 @@ func TestNewServeCommand(t *testing.T) {
 		"certFile",
 		"keyFile",
 		"config",
+		"nut-idle-timeout",
 	}
 
 	assert.Equal(t, "serve", got.Use)
@@ -29,12 +29,14 @@ func NewWakeCmd(logger *slog.Logger, broadcasts []net.IP) *cobra.Command {
 		Short: "Manually wake a computer",
 		Long:  `Manually wake a computer without using a UPS's status`,
 		Example: `  upswake wake -m 00:11:22:33:44:55
-  upswake wake -m 00:11:22:33:44:55 -b 192.168.1.255,192.168.2.255`,
+  upswake wake -m 00:11:22:33:44:55 -b 192.168.1.255,192.168.2.255
+  upswake wake -m 00:11:22:33:44:55 -p 0a:0b:0c:0d:0e:0f`,
 		RunE: wc.wakeCmdRunE,
 	}
 
 	wakeCmd.Flags().IPSliceP("broadcasts", "b", broadcasts, "Broadcast addresses to send the WoL packets to")
 	wakeCmd.Flags().StringP("mac", "m", "", "(required) MAC address of the computer to wake")
+	wakeCmd.Flags().StringP("secure-on", "p", "", "SecureOn password of the computer to wake, 4 bytes written like an IPv4 address or 6 bytes written like a MAC address")
 	_ = wakeCmd.MarkFlagRequired("mac")
 
 	return wakeCmd
@@ -46,6 +48,15 @@ func (wake *wakeCMD) wakeCmdRunE(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
+	secureOnFlag, err := cmd.Flags().GetString("secure-on")
+	if err != nil {
+		return err
+	}
+	secureOn := entity.SecureOn(secureOnFlag)
+	if err = secureOn.Validate(); err != nil {
+		return err
+	}
+
 	broadcasts, err := cmd.Flags().GetIPSlice("broadcasts")
 	if err != nil {
 		return err
@@ -71,6 +82,7 @@ func (wake *wakeCMD) wakeCmdRunE(cmd *cobra.Command, _ []string) error {
 			joinedErr = errors.Join(joinedErr, fmt.Errorf("invalid target for %s: %w", broadcast, err))
 			continue
 		}
+		ts.SecureOn = secureOn
 		wolClient := wol.NewWoLClient(ts)
 
 		if err = wolClient.Wake(); err != nil {
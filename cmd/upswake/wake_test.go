@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
@@ -32,6 +33,7 @@ func TestNewWakeCmd(t *testing.T) {
 				}
 				wakeCmd.Flags().IPSliceP("broadcasts", "b", []net.IP{}, "Broadcast addresses to send the WoL packets to")
 				wakeCmd.Flags().StringP("mac", "m", "", "MAC address of the computer to wake")
+				wakeCmd.Flags().StringP("secure-on", "p", "", "SecureOn password of the computer to wake")
 				_ = wakeCmd.MarkFlagRequired("mac")
 
 				return wakeCmd
@@ -47,6 +49,7 @@ func TestNewWakeCmd(t *testing.T) {
 				}
 				wakeCmd.Flags().IPSliceP("broadcasts", "b", []net.IP{{127, 0, 0, 255}}, "Broadcast addresses to send the WoL packets to")
 				wakeCmd.Flags().StringP("mac", "m", "", "MAC address of the computer to wake")
+				wakeCmd.Flags().StringP("secure-on", "p", "", "SecureOn password of the computer to wake")
 				_ = wakeCmd.MarkFlagRequired("mac")
 
 				return wakeCmd
@@ -62,6 +65,7 @@ func TestNewWakeCmd(t *testing.T) {
 				}
 				wakeCmd.Flags().IPSliceP("broadcasts", "b", []net.IP{{127, 0, 0, 255}, {192, 168, 1, 255}, {10, 0, 0, 255}}, "Broadcast addresses to send the WoL packets to")
 				wakeCmd.Flags().StringP("mac", "m", "", "MAC address of the computer to wake")
+				wakeCmd.Flags().StringP("secure-on", "p", "", "SecureOn password of the computer to wake")
 				_ = wakeCmd.MarkFlagRequired("mac")
 
 				return wakeCmd
@@ -129,6 +133,32 @@ func Test_wakeCmdRunE(t *testing.T) {
 				`"msg":"Sent WoL packet","cmd":"wake","broadcast":"127.0.0.255","mac":"00:00:00:00:00:00"`,
 			},
 		},
+		{
+			name: "valid secure on",
+			args: args{
+				cmdFunc: func(logger *slog.Logger) *cobra.Command {
+					return NewWakeCmd(logger, []net.IP{{127, 0, 0, 255}})
+				},
+				args: []string{"wake", "--mac", "00:00:00:00:00:00", "--secure-on", "0a:0b:0c:0d:0e:0f"},
+			},
+			wantErr: nil,
+			outputContains: []string{
+				`"msg":"Sent WoL packet","cmd":"wake","broadcast":"127.0.0.255","mac":"00:00:00:00:00:00"`,
+			},
+		},
+		{
+			name: "invalid secure on",
+			args: args{
+				cmdFunc: func(logger *slog.Logger) *cobra.Command {
+					return NewWakeCmd(logger, []net.IP{{127, 0, 0, 255}})
+				},
+				args: []string{"wake", "--mac", "00:00:00:00:00:00", "--secure-on", "password"},
+			},
+			wantErr: entity.ErrInvalidSecureOn,
+			outputNotContains: []string{
+				"Sent WoL packet",
+			},
+		},
 		{
 			name: "no broadcasts",
 			args: args{
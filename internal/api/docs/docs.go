@@ -31,6 +31,155 @@ const docTemplate = `{
                 "responses": {}
             }
         },
+        "/api/cache": {
+            "get": {
+                "description": "Get what is cached for each NUT server, ordered by NUT server, and how many reads have been served\nfrom the cache since the server started.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cache"
+                ],
+                "summary": "Get the UPS cache",
+                "responses": {
+                    "200": {
+                        "description": "Cache",
+                        "schema": {
+                            "$ref": "#/definitions/entity.CacheStats"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Clear the cache so every NUT server is read again the next time it is needed. The counters are kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cache"
+                ],
+                "summary": "Reset the UPS cache",
+                "responses": {
+                    "200": {
+                        "description": "Cache reset",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/decisions": {
+            "get": {
+                "description": "List the most recent rule decisions made by wake evaluations, newest first.\nOnly decisions still held in memory are returned, see decision_log.buffer_size.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "decisions"
+                ],
+                "summary": "List rule decisions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only decisions for this target MAC address",
+                        "name": "mac",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only decisions for this target name",
+                        "name": "target",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only decisions using input from this NUT server",
+                        "name": "nut_server",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only decisions made by this rule",
+                        "name": "rule",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "only decisions that did or didn't allow a wake",
+                        "name": "allowed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only decisions made at or after this RFC 3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "the most decisions to return",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Decisions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DecisionLogEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/endpoints": {
+            "get": {
+                "description": "List the health of each NUT server's host and its endpoints, in the order they are configured.\nactive marks the endpoint the NUT server's UPSes were last read from.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "endpoints"
+                ],
+                "summary": "List NUT server endpoints",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only the endpoints of this NUT server",
+                        "name": "nut_server",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Endpoints",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.EndpointHealth"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "NUT server not found in the config",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
         "/api/servers/broadcastwake": {
             "post": {
                 "description": "Wake a server using Wake on LAN by using the MAC and enumerating all available broadcast addresses",
@@ -123,6 +272,216 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/ups": {
+            "get": {
+                "description": "List every NUT server in the config, in the order they are configured, with the UPSes read from it.\nA NUT server that can't be read is listed with the error instead of its UPSes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ups"
+                ],
+                "summary": "List UPSes",
+                "responses": {
+                    "200": {
+                        "description": "NUT servers",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.UPSSource"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ups/{server}/{ups}/history": {
+            "get": {
+                "description": "Get the recorded values of a UPS's variables, oldest first, as JSON or CSV.\nOnly the variables chosen by history.variables are recorded, and only for history.retention.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "Get UPS history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the name of the NUT server in the config",
+                        "name": "server",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "the name of the UPS on the NUT server",
+                        "name": "ups",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "only these variables, repeated or comma separated",
+                        "name": "var",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only samples at or after this RFC 3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only samples at or before this RFC 3339 time",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "description": "json, or csv, which is also chosen by an Accept: text/csv header",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Samples",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.HistorySample"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "NUT server not found in the config",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ups/{source}/{ups}": {
+            "get": {
+                "description": "Get a UPS and all of its variables, with their descriptions and types.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ups"
+                ],
+                "summary": "Get a UPS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the name of the NUT server in the config",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "the name of the UPS on the NUT server",
+                        "name": "ups",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "UPS",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UPSDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "NUT server or UPS not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "NUT server couldn't be read",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ups/{source}/{ups}/vars/{name}": {
+            "get": {
+                "description": "Get one variable of a UPS, with its description and type.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ups"
+                ],
+                "summary": "Get a UPS variable",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the name of the NUT server in the config",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "the name of the UPS on the NUT server",
+                        "name": "ups",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "the name of the variable, e.g. battery.charge",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Variable",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UPSVariable"
+                        }
+                    },
+                    "404": {
+                        "description": "NUT server, UPS or variable not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "NUT server couldn't be read",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    }
+                }
+            }
+        },
         "/api/upswake": {
             "get": {
                 "description": "List NUT server mappings using the config stored in the server",
@@ -190,10 +549,10 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.UpsWakeResponse"
                         }
                     },
-                    "404": {
+                    "409": {
                         "description": "MAC address not found in the config",
                         "schema": {
-                            "$ref": "#/definitions/handlers.UpsWakeResponse"
+                            "$ref": "#/definitions/handlers.Response"
                         }
                     },
                     "500": {
@@ -205,6 +564,58 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/upswake/explain": {
+            "post": {
+                "description": "Evaluate every rule for the target with tracing enabled, without sending a Wake on LAN packet.\nReturns the input document, decision and evaluation trace of each rule, per NUT server.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UPSWake"
+                ],
+                "summary": "Explain wake evaluation",
+                "parameters": [
+                    {
+                        "description": "the mac address of the target to explain",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WakeEvaluationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rules evaluated",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UpsWakeExplainResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UpsWakeExplainResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "MAC address not found in the config",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UpsWakeExplainResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/health": {
             "get": {
                 "description": "Health check",
@@ -222,13 +633,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.Response"
+                            "$ref": "#/definitions/handlers.HealthResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/handlers.Response"
+                            "$ref": "#/definitions/handlers.HealthResponse"
                         }
                     }
                 }
@@ -236,6 +647,330 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "entity.CacheEntry": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address and LastError leave out the NUT server's credentials",
+                    "type": "string",
+                    "example": "192.168.1.133:3493"
+                },
+                "age_seconds": {
+                    "description": "AgeSeconds is how old the cached UPSes are",
+                    "type": "integer",
+                    "example": 42
+                },
+                "cached_at": {
+                    "description": "CachedAt is when the cached UPSes were read",
+                    "type": "string",
+                    "example": "2026-01-05T17:30:00Z"
+                },
+                "consecutive_failures": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the cached UPSes are read again",
+                    "type": "string",
+                    "example": "2026-01-05T17:35:00Z"
+                },
+                "failed_at": {
+                    "description": "FailedAt is when reading the NUT server last failed",
+                    "type": "string"
+                },
+                "fresh": {
+                    "description": "Fresh is true if the cached UPSes are served without reading the NUT\nserver",
+                    "type": "boolean",
+                    "example": true
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "nut_server": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                },
+                "retry_at": {
+                    "description": "RetryAt is when a failed NUT server is read again, until which the\nfailure is served from the cache",
+                    "type": "string"
+                }
+            }
+        },
+        "entity.CacheStats": {
+            "type": "object",
+            "properties": {
+                "coalesced": {
+                    "description": "Coalesced waited for another read of the same NUT server",
+                    "type": "integer",
+                    "example": 3
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.CacheEntry"
+                    }
+                },
+                "hits": {
+                    "description": "Hits were served fresh from the cache",
+                    "type": "integer",
+                    "example": 120
+                },
+                "misses": {
+                    "description": "Misses read the NUT server",
+                    "type": "integer",
+                    "example": 12
+                },
+                "negative_hits": {
+                    "description": "NegativeHits were served a cached failure",
+                    "type": "integer",
+                    "example": 2
+                },
+                "stale_hits": {
+                    "description": "StaleHits were served stale UPSes because reading the NUT server failed",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "entity.DecisionLogEntry": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "denied": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "details": {},
+                "error": {
+                    "type": "string"
+                },
+                "evaluation_id": {
+                    "type": "string",
+                    "example": "5f2b9c0e7d1a4c38"
+                },
+                "input": {
+                    "description": "Input is the input document, only logged if the decision log is\nconfigured to include it",
+                    "type": "object"
+                },
+                "input_hash": {
+                    "description": "InputHash is the hex encoded SHA-256 of the input the rule was given",
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "latency_ns": {
+                    "description": "Latency is how long the rule took to evaluate, in nanoseconds",
+                    "type": "integer",
+                    "example": 125000
+                },
+                "mac": {
+                    "type": "string",
+                    "example": "00:11:22:33:44:55"
+                },
+                "nut_server": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                },
+                "packet_sent": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "battery.charge 62 \u003c 80 on cyberpower900"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "80percentOn.rego"
+                },
+                "rule_mode": {
+                    "type": "string",
+                    "example": "any"
+                },
+                "target": {
+                    "type": "string",
+                    "example": "MyNAS"
+                },
+                "time": {
+                    "type": "string",
+                    "example": "2026-01-05T17:30:00Z"
+                }
+            }
+        },
+        "entity.EndpointHealth": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Active is whether the NUT server's UPSes were last read from this\nendpoint",
+                    "type": "boolean",
+                    "example": true
+                },
+                "address": {
+                    "type": "string",
+                    "example": "192.168.1.133:3493"
+                },
+                "consecutive_failures": {
+                    "description": "ConsecutiveFailures is how many reads have failed since the endpoint\nwas last read",
+                    "type": "integer",
+                    "example": 0
+                },
+                "healthy": {
+                    "description": "Healthy is whether the last read succeeded, true if it hasn't been\nread yet",
+                    "type": "boolean",
+                    "example": true
+                },
+                "last_error": {
+                    "description": "LastError is why reading the endpoint last failed",
+                    "type": "string"
+                },
+                "last_failure": {
+                    "description": "LastFailure is when reading the endpoint last failed, zero if never",
+                    "type": "string"
+                },
+                "last_success": {
+                    "description": "LastSuccess is when the endpoint was last read, zero if never",
+                    "type": "string",
+                    "example": "2026-01-05T17:30:00Z"
+                },
+                "nut_server": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                }
+            }
+        },
+        "entity.HistorySample": {
+            "type": "object",
+            "properties": {
+                "nut_server": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                },
+                "time": {
+                    "type": "string",
+                    "example": "2026-01-05T17:30:00Z"
+                },
+                "ups": {
+                    "type": "string",
+                    "example": "cyberpower900"
+                },
+                "value": {
+                    "description": "Value is a number for numeric variables and a string otherwise",
+                    "type": "string",
+                    "example": "85"
+                },
+                "variable": {
+                    "type": "string",
+                    "example": "battery.charge"
+                }
+            }
+        },
+        "entity.RuleDecision": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "denied": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "details": {},
+                "reason": {
+                    "type": "string",
+                    "example": "battery.charge 62 \u003c 80 on cyberpower900"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "80percentOn.rego"
+                }
+            }
+        },
+        "entity.RuleExplanation": {
+            "type": "object",
+            "properties": {
+                "decision": {
+                    "$ref": "#/definitions/entity.RuleDecision"
+                },
+                "failed_expressions": {
+                    "description": "FailedExpressions lists the location and text of each expression that\nevaluated to false or undefined",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "80percentOn.rego:8: input[i].Variables[j].Value \u003e= 80"
+                    ]
+                },
+                "trace": {
+                    "description": "Trace is the OPA evaluation trace, one line per event",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "evaluator.ServerExplanation": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "input": {
+                    "description": "Input is the JSON document the rules were evaluated against",
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
+                "nut_server": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                },
+                "rule_mode": {
+                    "type": "string",
+                    "example": "any"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.RuleExplanation"
+                    }
+                },
+                "target": {
+                    "type": "string",
+                    "example": "MyNAS"
+                }
+            }
+        },
+        "evaluator.UnavailableSource": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is why the NUT server couldn't be read, without its credentials",
+                    "type": "string",
+                    "example": "dial tcp 192.168.1.133:3493: connect: connection refused"
+                },
+                "nut_server": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                },
+                "skipped_targets": {
+                    "description": "SkippedTargets are the targets using legacy input, whose rules\nweren't evaluated as they have nothing to evaluate without the NUT\nserver's UPSes",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "MyNAS"
+                    ]
+                }
+            }
+        },
         "handlers.BroadcastWakeRequest": {
             "type": "object",
             "required": [
@@ -251,6 +986,24 @@ const docTemplate = `{
                     "maximum": 65535,
                     "minimum": 1,
                     "example": 9
+                },
+                "secure_on": {
+                    "description": "SecureOn is the password sent after the magic packet, 4 bytes written\nlike an IPv4 address or 6 bytes written like a MAC address",
+                    "type": "string",
+                    "example": "0a:0b:0c:0d:0e:0f"
+                }
+            }
+        },
+        "handlers.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "rules_revision": {
+                    "description": "RulesRevision is the revision of the bundle the rules were loaded from",
+                    "type": "string",
+                    "example": "v1.2.0"
                 }
             }
         },
@@ -262,6 +1015,130 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.UPSDetails": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "CyberPower CP900EPFCLCD"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "cyberpower900"
+                },
+                "source": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                },
+                "variables": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.UPSVariable"
+                    }
+                }
+            }
+        },
+        "handlers.UPSSource": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address is the host and port the UPSes are read from, the program\nrun for command sources or the URL, without credentials, of http\nsources",
+                    "type": "string",
+                    "example": "192.168.1.133:3493"
+                },
+                "error": {
+                    "description": "Error is why the UPSes couldn't be read. If UPSes last read from\nthe NUT server are served instead, they are listed too",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "raspberrypi"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "nut"
+                },
+                "upses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.UPSSummary"
+                    }
+                }
+            }
+        },
+        "handlers.UPSSummary": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "CyberPower CP900EPFCLCD"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "cyberpower900"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "OL CHRG"
+                }
+            }
+        },
+        "handlers.UPSVariable": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Battery charge (percent of full)"
+                },
+                "maximum_length": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "name": {
+                    "type": "string",
+                    "example": "battery.charge"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "INTEGER"
+                },
+                "value": {
+                    "description": "Value is a number for numeric variables and a string otherwise",
+                    "type": "string",
+                    "example": "100"
+                },
+                "writeable": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "handlers.UpsWakeExplainResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "Rules evaluated, no Wake on LAN sent"
+                },
+                "servers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/evaluator.ServerExplanation"
+                    }
+                },
+                "unavailable": {
+                    "description": "Unavailable are the NUT servers that couldn't be read",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/evaluator.UnavailableSource"
+                    }
+                },
+                "would_wake": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
         "handlers.UpsWakeResponse": {
             "type": "object",
             "properties": {
@@ -269,6 +1146,28 @@ const docTemplate = `{
                     "type": "string",
                     "example": "Wake on LAN sent"
                 },
+                "reason": {
+                    "type": "string",
+                    "example": "battery.charge 100 \u003e= 80 on cyberpower900"
+                },
+                "rule_mode": {
+                    "description": "RuleMode is how the decisions of the rules were combined",
+                    "type": "string",
+                    "example": "any"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.RuleDecision"
+                    }
+                },
+                "unavailable": {
+                    "description": "Unavailable are the NUT servers that couldn't be read",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/evaluator.UnavailableSource"
+                    }
+                },
                 "woken": {
                     "type": "boolean",
                     "example": true
@@ -304,12 +1203,76 @@ const docTemplate = `{
                     "maximum": 65535,
                     "minimum": 1,
                     "example": 9
+                },
+                "secure_on": {
+                    "description": "SecureOn is the password sent after the magic packet, 4 bytes written\nlike an IPv4 address or 6 bytes written like a MAC address",
+                    "type": "string",
+                    "example": "0a:0b:0c:0d:0e:0f"
+                }
+            }
+        },
+        "viper.NutCache": {
+            "type": "object",
+            "properties": {
+                "error_backoff": {
+                    "type": "string",
+                    "example": "10s"
+                },
+                "max_stale": {
+                    "type": "string",
+                    "example": "30m"
+                },
+                "ttl": {
+                    "type": "string",
+                    "example": "5m"
+                }
+            }
+        },
+        "viper.NutEndpoint": {
+            "type": "object",
+            "properties": {
+                "host": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "integer"
                 }
             }
         },
         "viper.NutServer": {
             "type": "object",
             "properties": {
+                "cache": {
+                    "description": "Cache configures how long the UPSes are cached for",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/viper.NutCache"
+                        }
+                    ]
+                },
+                "command": {
+                    "description": "Command is run for command sources, without a shell",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "/usr/local/bin/ups-status",
+                        "--json"
+                    ]
+                },
+                "endpoint_strategy": {
+                    "description": "EndpointStrategy is one of failover (the default) or first-healthy",
+                    "type": "string",
+                    "example": "failover"
+                },
+                "endpoints": {
+                    "description": "Endpoints are further hosts serving the same UPSes, tried after host\nand port",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/viper.NutEndpoint"
+                    }
+                },
                 "host": {
                     "type": "string"
                 },
@@ -322,12 +1285,63 @@ const docTemplate = `{
                 "port": {
                     "type": "integer"
                 },
+                "snmp_auth_protocol": {
+                    "type": "string",
+                    "example": "SHA"
+                },
+                "snmp_community": {
+                    "type": "string"
+                },
+                "snmp_priv_password": {
+                    "type": "string"
+                },
+                "snmp_priv_protocol": {
+                    "type": "string",
+                    "example": "AES"
+                },
+                "snmp_version": {
+                    "description": "SNMPVersion is one of 2c (the default) or 3",
+                    "type": "string",
+                    "example": "2c"
+                },
                 "targets": {
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/viper.TargetServer"
                     }
                 },
+                "timeout": {
+                    "description": "Timeout bounds the command or request of command and http sources",
+                    "type": "string",
+                    "example": "10s"
+                },
+                "tls": {
+                    "description": "TLS is one of off (the default), optional or required",
+                    "type": "string",
+                    "example": "required"
+                },
+                "tls_ca_file": {
+                    "type": "string"
+                },
+                "tls_cert_file": {
+                    "type": "string"
+                },
+                "tls_key_file": {
+                    "type": "string"
+                },
+                "tls_server_name": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type is one of nut (the default), apcupsd, snmp, command or http",
+                    "type": "string",
+                    "example": "nut"
+                },
+                "url": {
+                    "description": "URL is fetched for http sources",
+                    "type": "string",
+                    "example": "http://127.0.0.1:8080/ups.json"
+                },
                 "username": {
                     "type": "string"
                 }
@@ -339,6 +1353,11 @@ const docTemplate = `{
                 "broadcast": {
                     "type": "string"
                 },
+                "input_version": {
+                    "description": "InputVersion overrides the global input version for this target's rules",
+                    "type": "integer",
+                    "example": 2
+                },
                 "interval": {
                     "type": "string",
                     "default": "15m"
@@ -353,11 +1372,24 @@ const docTemplate = `{
                     "type": "integer",
                     "default": 9
                 },
+                "rule_mode": {
+                    "description": "RuleMode is one of any (the default), all, first-match or quorum:N",
+                    "type": "string",
+                    "example": "quorum:2"
+                },
                 "rules": {
                     "type": "array",
                     "items": {
                         "type": "string"
-                    }
+                    },
+                    "example": [
+                        "80percentOn.rego"
+                    ]
+                },
+                "secure_on": {
+                    "description": "SecureOn is the password sent after the magic packet, 4 bytes written\nlike an IPv4 address or 6 bytes written like a MAC address",
+                    "type": "string",
+                    "example": "0a:0b:0c:0d:0e:0f"
                 }
             }
         }
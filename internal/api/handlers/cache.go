@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/labstack/echo/v5"
+)
+
+type CacheHandler struct {
+	cache repository.CacheRepository
+}
+
+// NewCacheHandler creates a CacheHandler serving the entries and counters
+// of cache.
+func NewCacheHandler(cache repository.CacheRepository) *CacheHandler {
+	return &CacheHandler{
+		cache: cache,
+	}
+}
+
+func (h *CacheHandler) Register(g *echo.Group) {
+	g.GET("", h.GetCache)
+	g.DELETE("", h.ResetCache)
+}
+
+// GetCache godoc
+//
+//	@Summary		Get the UPS cache
+//	@Description	Get what is cached for each NUT server, ordered by NUT server, and how many reads have been served
+//	@Description	from the cache since the server started.
+//	@Tags			cache
+//	@Produce		json
+//	@Success		200	{object}	entity.CacheStats	"Cache"
+//	@Router			/api/cache [get]
+func (h *CacheHandler) GetCache(c *echo.Context) error {
+	return c.JSON(http.StatusOK, h.cache.Stats())
+}
+
+// ResetCache godoc
+//
+//	@Summary		Reset the UPS cache
+//	@Description	Clear the cache so every NUT server is read again the next time it is needed. The counters are kept.
+//	@Tags			cache
+//	@Produce		json
+//	@Success		200	{object}	Response	"Cache reset"
+//	@Router			/api/cache [delete]
+func (h *CacheHandler) ResetCache(c *echo.Context) error {
+	h.cache.Reset()
+	return c.JSON(http.StatusOK, Response{Message: "Cache reset"})
+}
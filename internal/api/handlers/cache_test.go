@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCacheHandler_Register(t *testing.T) {
+	e := echo.New()
+	h := NewCacheHandler(nil)
+	h.Register(e.Group("/cache"))
+
+	expectedRoutes := echo.Routes{
+		{
+			Name:   "GET:/cache",
+			Path:   "/cache",
+			Method: "GET",
+		},
+		{
+			Name:   "DELETE:/cache",
+			Path:   "/cache",
+			Method: "DELETE",
+		},
+	}
+
+	assert.Equal(t, expectedRoutes, e.Router().Routes())
+}
+
+func TestCacheHandler_GetCache(t *testing.T) {
+	at := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	e := echo.New()
+	mock := gomock.NewController(t)
+	cache := mocks.NewMockCacheRepository(mock)
+	cache.EXPECT().Stats().Return(&entity.CacheStats{
+		Entries: []*entity.CacheEntry{
+			{NutServer: "office", Address: "192.168.1.133:3493", CachedAt: at, ExpiresAt: at.Add(5 * time.Minute), AgeSeconds: 42, Fresh: true},
+		},
+		Hits:   3,
+		Misses: 1,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/cache", http.NoBody)
+	rec := httptest.NewRecorder()
+
+	h := NewCacheHandler(cache)
+	if assert.NoError(t, h.GetCache(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"entries":[{"cached_at":"2026-01-05T17:30:00Z","expires_at":"2026-01-05T17:35:00Z","nut_server":"office","address":"192.168.1.133:3493","age_seconds":42,"consecutive_failures":0,"fresh":true}],`+
+			`"hits":3,"misses":1,"coalesced":0,"stale_hits":0,"negative_hits":0}`, rec.Body.String())
+	}
+}
+
+func TestCacheHandler_ResetCache(t *testing.T) {
+	e := echo.New()
+	mock := gomock.NewController(t)
+	cache := mocks.NewMockCacheRepository(mock)
+	cache.EXPECT().Reset()
+
+	req := httptest.NewRequest(http.MethodDelete, "/cache", http.NoBody)
+	rec := httptest.NewRecorder()
+
+	h := NewCacheHandler(cache)
+	if assert.NoError(t, h.ResetCache(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"message":"Cache reset"}`, rec.Body.String())
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/labstack/echo/v5"
+)
+
+// DefaultDecisionLimit is how many decisions are returned if the request
+// doesn't set a limit.
+const DefaultDecisionLimit = 100
+
+var ErrorInvalidQuery = errors.New("invalid query parameter")
+
+type DecisionLogHandler struct {
+	decisions repository.DecisionLogRepository
+}
+
+// NewDecisionLogHandler creates a DecisionLogHandler serving the decisions
+// recorded in decisions.
+func NewDecisionLogHandler(decisions repository.DecisionLogRepository) *DecisionLogHandler {
+	return &DecisionLogHandler{
+		decisions: decisions,
+	}
+}
+
+func (h *DecisionLogHandler) Register(g *echo.Group) {
+	g.GET("", h.ListDecisions)
+}
+
+// ListDecisions godoc
+//
+//	@Summary		List rule decisions
+//	@Description	List the most recent rule decisions made by wake evaluations, newest first.
+//	@Description	Only decisions still held in memory are returned, see decision_log.buffer_size.
+//	@Tags			decisions
+//	@Produce		json
+//	@Param			mac			query		string						false	"only decisions for this target MAC address"
+//	@Param			target		query		string						false	"only decisions for this target name"
+//	@Param			nut_server	query		string						false	"only decisions using input from this NUT server"
+//	@Param			rule		query		string						false	"only decisions made by this rule"
+//	@Param			allowed		query		bool						false	"only decisions that did or didn't allow a wake"
+//	@Param			since		query		string						false	"only decisions made at or after this RFC 3339 time"
+//	@Param			limit		query		int							false	"the most decisions to return"	default(100)
+//	@Success		200			{object}	[]entity.DecisionLogEntry	"Decisions"
+//	@Failure		400			{object}	Response					"Invalid query parameter"
+//	@Router			/api/decisions [get]
+func (h *DecisionLogHandler) ListDecisions(c *echo.Context) error {
+	filter, err := decisionFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, h.decisions.Query(filter))
+}
+
+func decisionFilter(c *echo.Context) (entity.DecisionLogFilter, error) {
+	filter := entity.DecisionLogFilter{
+		MAC:       c.QueryParam("mac"),
+		Target:    c.QueryParam("target"),
+		NutServer: c.QueryParam("nut_server"),
+		Rule:      c.QueryParam("rule"),
+		Limit:     DefaultDecisionLimit,
+	}
+
+	if allowed := c.QueryParam("allowed"); allowed != "" {
+		value, err := strconv.ParseBool(allowed)
+		if err != nil {
+			return filter, fmt.Errorf("%w allowed: %w", ErrorInvalidQuery, err)
+		}
+		filter.Allowed = &value
+	}
+	if since := c.QueryParam("since"); since != "" {
+		value, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, fmt.Errorf("%w since: %w", ErrorInvalidQuery, err)
+		}
+		filter.Since = value
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return filter, fmt.Errorf("%w limit: must be a positive integer", ErrorInvalidQuery)
+		}
+		filter.Limit = value
+	}
+	return filter, nil
+}
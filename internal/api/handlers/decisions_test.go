@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDecisionLogHandler_Register(t *testing.T) {
+	e := echo.New()
+	h := NewDecisionLogHandler(nil)
+	h.Register(e.Group("/decisions"))
+
+	expectedRoutes := echo.Routes{
+		{
+			Name:   "GET:/decisions",
+			Path:   "/decisions",
+			Method: "GET",
+		},
+	}
+
+	assert.Equal(t, expectedRoutes, e.Router().Routes())
+}
+
+func TestDecisionLogHandler_ListDecisions(t *testing.T) {
+	allowed := true
+	entry := &entity.DecisionLogEntry{
+		Time:         time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC),
+		EvaluationID: "5f2b9c0e7d1a4c38",
+		MAC:          "00:11:22:33:44:55",
+		Target:       "MyNAS",
+		NutServer:    "raspberrypi",
+		Rule:         "80percentOn.rego",
+		RuleMode:     "any",
+		InputHash:    "abc",
+		Latency:      125 * time.Microsecond,
+		Allowed:      true,
+		PacketSent:   true,
+	}
+
+	tests := []struct {
+		wantFilter *entity.DecisionLogFilter
+		name       string
+		query      string
+		wantBody   string
+		wantStatus int
+	}{
+		{
+			name:       "defaults",
+			wantFilter: &entity.DecisionLogFilter{Limit: DefaultDecisionLimit},
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"time":"2026-01-05T17:30:00Z","evaluation_id":"5f2b9c0e7d1a4c38","mac":"00:11:22:33:44:55","target":"MyNAS","nut_server":"raspberrypi","rule":"80percentOn.rego","rule_mode":"any","input_hash":"abc","latency_ns":125000,"allowed":true,"packet_sent":true}]`,
+		},
+		{
+			name:  "every filter",
+			query: "?mac=00:11:22:33:44:55&target=MyNAS&nut_server=raspberrypi&rule=80percentOn.rego&allowed=true&since=2026-01-05T17:00:00Z&limit=5",
+			wantFilter: &entity.DecisionLogFilter{
+				Since:     time.Date(2026, time.January, 5, 17, 0, 0, 0, time.UTC),
+				Allowed:   &allowed,
+				MAC:       "00:11:22:33:44:55",
+				Target:    "MyNAS",
+				NutServer: "raspberrypi",
+				Rule:      "80percentOn.rego",
+				Limit:     5,
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid allowed",
+			query:      "?allowed=maybe",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"invalid query parameter allowed: strconv.ParseBool: parsing \"maybe\": invalid syntax"}`,
+		},
+		{
+			name:       "invalid since",
+			query:      "?since=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid limit",
+			query:      "?limit=0",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"invalid query parameter limit: must be a positive integer"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			mock := gomock.NewController(t)
+			decisions := mocks.NewMockDecisionLogRepository(mock)
+			if tt.wantFilter != nil {
+				decisions.EXPECT().Query(*tt.wantFilter).Return([]*entity.DecisionLogEntry{entry})
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decisions"+tt.query, http.NoBody)
+			rec := httptest.NewRecorder()
+
+			h := NewDecisionLogHandler(decisions)
+			if assert.NoError(t, h.ListDecisions(e.NewContext(req, rec))) {
+				assert.Equal(t, tt.wantStatus, rec.Code)
+				if tt.wantBody != "" {
+					assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				}
+			}
+		})
+	}
+}
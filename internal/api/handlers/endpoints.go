@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/labstack/echo/v5"
+)
+
+type EndpointHandler struct {
+	cfg    *entity.Config
+	health repository.EndpointHealthRepository
+}
+
+// NewEndpointHandler creates an EndpointHandler serving the health tracked
+// in health of the endpoints of the NUT servers in cfg.
+func NewEndpointHandler(cfg *entity.Config, health repository.EndpointHealthRepository) *EndpointHandler {
+	return &EndpointHandler{
+		cfg:    cfg,
+		health: health,
+	}
+}
+
+func (h *EndpointHandler) Register(g *echo.Group) {
+	g.GET("", h.ListEndpoints)
+}
+
+// ListEndpoints godoc
+//
+//	@Summary		List NUT server endpoints
+//	@Description	List the health of each NUT server's host and its endpoints, in the order they are configured.
+//	@Description	active marks the endpoint the NUT server's UPSes were last read from.
+//	@Tags			endpoints
+//	@Produce		json
+//	@Param			nut_server	query		string					false	"only the endpoints of this NUT server"
+//	@Success		200			{object}	[]entity.EndpointHealth	"Endpoints"
+//	@Failure		404			{object}	Response				"NUT server not found in the config"
+//	@Router			/api/endpoints [get]
+func (h *EndpointHandler) ListEndpoints(c *echo.Context) error {
+	name := c.QueryParam("nut_server")
+	endpoints := []*entity.EndpointHealth{}
+	found := false
+	for _, nutServer := range h.cfg.NutServers {
+		if name != "" && nutServer.Name != name {
+			continue
+		}
+		found = true
+		endpoints = append(endpoints, h.health.Health(nutServer)...)
+	}
+	if name != "" && !found {
+		return c.JSON(http.StatusNotFound, Response{Message: fmt.Sprintf("NUT server %s not found in the config", name)})
+	}
+	return c.JSON(http.StatusOK, endpoints)
+}
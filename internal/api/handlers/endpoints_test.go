@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestEndpointHandler_Register(t *testing.T) {
+	e := echo.New()
+	h := NewEndpointHandler(&entity.Config{}, nil)
+	h.Register(e.Group("/endpoints"))
+
+	expectedRoutes := echo.Routes{
+		{
+			Name:   "GET:/endpoints",
+			Path:   "/endpoints",
+			Method: "GET",
+		},
+	}
+
+	assert.Equal(t, expectedRoutes, e.Router().Routes())
+}
+
+func TestEndpointHandler_ListEndpoints(t *testing.T) {
+	at := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	primary := &entity.NutServer{Name: "primary", Host: "192.168.1.133", Port: 3493, Endpoints: []entity.NutEndpoint{{Host: "192.168.1.134"}}}
+	office := &entity.NutServer{Name: "office", Host: "192.168.1.135", Port: 3493}
+	cfg := &entity.Config{NutServers: []*entity.NutServer{primary, office}}
+
+	primaryHealth := []*entity.EndpointHealth{
+		{NutServer: "primary", Address: "192.168.1.133:3493", LastFailure: at, LastError: "connection refused", ConsecutiveFailures: 2},
+		{NutServer: "primary", Address: "192.168.1.134:3493", LastSuccess: at, Healthy: true, Active: true},
+	}
+	officeHealth := []*entity.EndpointHealth{
+		{NutServer: "office", Address: "192.168.1.135:3493", Healthy: true},
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantBody    string
+		wantServers []*entity.NutServer
+		wantStatus  int
+	}{
+		{
+			name:        "every NUT server",
+			wantServers: []*entity.NutServer{primary, office},
+			wantStatus:  http.StatusOK,
+			wantBody: `[` +
+				`{"last_failure":"2026-01-05T17:30:00Z","nut_server":"primary","address":"192.168.1.133:3493","last_error":"connection refused","consecutive_failures":2,"healthy":false,"active":false},` +
+				`{"last_success":"2026-01-05T17:30:00Z","nut_server":"primary","address":"192.168.1.134:3493","consecutive_failures":0,"healthy":true,"active":true},` +
+				`{"nut_server":"office","address":"192.168.1.135:3493","consecutive_failures":0,"healthy":true,"active":false}` +
+				`]`,
+		},
+		{
+			name:        "one NUT server",
+			query:       "?nut_server=office",
+			wantServers: []*entity.NutServer{office},
+			wantStatus:  http.StatusOK,
+			wantBody:    `[{"nut_server":"office","address":"192.168.1.135:3493","consecutive_failures":0,"healthy":true,"active":false}]`,
+		},
+		{
+			name:       "unknown NUT server",
+			query:      "?nut_server=garage",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"message":"NUT server garage not found in the config"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			mock := gomock.NewController(t)
+			health := mocks.NewMockEndpointHealthRepository(mock)
+			for _, server := range tt.wantServers {
+				switch server {
+				case primary:
+					health.EXPECT().Health(server).Return(primaryHealth)
+				case office:
+					health.EXPECT().Health(server).Return(officeHealth)
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/endpoints"+tt.query, http.NoBody)
+			rec := httptest.NewRecorder()
+
+			h := NewEndpointHandler(cfg, health)
+			if assert.NoError(t, h.ListEndpoints(e.NewContext(req, rec))) {
+				assert.Equal(t, tt.wantStatus, rec.Code)
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
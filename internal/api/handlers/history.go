@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/labstack/echo/v5"
+)
+
+const mimeTextCSV = "text/csv"
+
+type HistoryHandler struct {
+	cfg     *entity.Config
+	history repository.HistoryRepository
+}
+
+// NewHistoryHandler creates a HistoryHandler serving the UPS history
+// recorded in history for the NUT servers in cfg.
+func NewHistoryHandler(cfg *entity.Config, history repository.HistoryRepository) *HistoryHandler {
+	return &HistoryHandler{
+		cfg:     cfg,
+		history: history,
+	}
+}
+
+func (h *HistoryHandler) Register(g *echo.Group) {
+	g.GET("/:server/:ups/history", h.GetHistory)
+}
+
+// GetHistory godoc
+//
+//	@Summary		Get UPS history
+//	@Description	Get the recorded values of a UPS's variables, oldest first, as JSON or CSV.
+//	@Description	Only the variables chosen by history.variables are recorded, and only for history.retention.
+//	@Tags			history
+//	@Produce		json,text/csv
+//	@Param			server	path		string					true	"the name of the NUT server in the config"
+//	@Param			ups		path		string					true	"the name of the UPS on the NUT server"
+//	@Param			var		query		[]string				false	"only these variables, repeated or comma separated"	collectionFormat(multi)
+//	@Param			since	query		string					false	"only samples at or after this RFC 3339 time"
+//	@Param			until	query		string					false	"only samples at or before this RFC 3339 time"
+//	@Param			format	query		string					false	"json, or csv, which is also chosen by an Accept: text/csv header"	Enums(json, csv)
+//	@Success		200		{object}	[]entity.HistorySample	"Samples"
+//	@Failure		400		{object}	Response				"Invalid query parameter"
+//	@Failure		404		{object}	Response				"NUT server not found in the config"
+//	@Router			/api/ups/{server}/{ups}/history [get]
+func (h *HistoryHandler) GetHistory(c *echo.Context) error {
+	server := c.Param("server")
+	if !h.hasNutServer(server) {
+		return c.JSON(http.StatusNotFound, Response{Message: fmt.Sprintf("NUT server %s not found in the config", server)})
+	}
+
+	filter, err := historyFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+	}
+	filter.NutServer = server
+	filter.UPS = c.Param("ups")
+
+	format := c.QueryParam("format")
+	if format == "" && strings.Contains(c.Request().Header.Get(echo.HeaderAccept), mimeTextCSV) {
+		format = "csv"
+	}
+	switch format {
+	case "", "json":
+		return c.JSON(http.StatusOK, h.history.Query(filter))
+	case "csv":
+		return historyCSV(c, h.history.Query(filter))
+	default:
+		return c.JSON(http.StatusBadRequest, Response{Message: fmt.Sprintf("%s format: must be json or csv", ErrorInvalidQuery)})
+	}
+}
+
+func (h *HistoryHandler) hasNutServer(name string) bool {
+	for _, nutServer := range h.cfg.NutServers {
+		if nutServer.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func historyFilter(c *echo.Context) (entity.HistoryFilter, error) {
+	filter := entity.HistoryFilter{}
+
+	for _, variables := range c.QueryParams()["var"] {
+		for variable := range strings.SplitSeq(variables, ",") {
+			if variable = strings.TrimSpace(variable); variable != "" {
+				filter.Variables = append(filter.Variables, variable)
+			}
+		}
+	}
+	if since := c.QueryParam("since"); since != "" {
+		value, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, fmt.Errorf("%w since: %w", ErrorInvalidQuery, err)
+		}
+		filter.Since = value
+	}
+	if until := c.QueryParam("until"); until != "" {
+		value, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, fmt.Errorf("%w until: %w", ErrorInvalidQuery, err)
+		}
+		filter.Until = value
+	}
+	return filter, nil
+}
+
+// historyCSV writes samples as CSV with a header row, one sample per row.
+func historyCSV(c *echo.Context, samples []*entity.HistorySample) error {
+	var body bytes.Buffer
+	w := csv.NewWriter(&body)
+	_ = w.Write([]string{"time", "nut_server", "ups", "variable", "value"})
+	for _, sample := range samples {
+		_ = w.Write([]string{
+			sample.Time.Format(time.RFC3339Nano),
+			sample.NutServer,
+			sample.UPS,
+			sample.Variable,
+			fmt.Sprint(sample.Value),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, mimeTextCSV+"; charset=UTF-8", body.Bytes())
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestHistoryHandler_Register(t *testing.T) {
+	e := echo.New()
+	h := NewHistoryHandler(&entity.Config{}, nil)
+	h.Register(e.Group("/ups"))
+
+	expectedRoutes := echo.Routes{
+		{
+			Name:       "GET:/ups/:server/:ups/history",
+			Path:       "/ups/:server/:ups/history",
+			Method:     "GET",
+			Parameters: []string{"server", "ups"},
+		},
+	}
+
+	assert.Equal(t, expectedRoutes, e.Router().Routes())
+}
+
+func TestHistoryHandler_GetHistory(t *testing.T) {
+	at := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	samples := []*entity.HistorySample{
+		{Time: at, NutServer: "raspberrypi", UPS: "cyberpower900", Variable: "battery.charge", Value: json.Number("85")},
+		{Time: at, NutServer: "raspberrypi", UPS: "cyberpower900", Variable: "ups.status", Value: "OB DISCHRG"},
+	}
+	cfg := &entity.Config{NutServers: []*entity.NutServer{{Name: "raspberrypi"}}}
+
+	tests := []struct {
+		wantFilter      *entity.HistoryFilter
+		name            string
+		server          string
+		query           string
+		accept          string
+		wantBody        string
+		wantContentType string
+		wantStatus      int
+	}{
+		{
+			name:            "defaults",
+			server:          "raspberrypi",
+			wantFilter:      &entity.HistoryFilter{NutServer: "raspberrypi", UPS: "cyberpower900"},
+			wantStatus:      http.StatusOK,
+			wantContentType: echo.MIMEApplicationJSON,
+			wantBody:        `[{"time":"2026-01-05T17:30:00Z","value":85,"nut_server":"raspberrypi","ups":"cyberpower900","variable":"battery.charge"},{"time":"2026-01-05T17:30:00Z","value":"OB DISCHRG","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}]`,
+		},
+		{
+			name:   "every filter",
+			server: "raspberrypi",
+			query:  "?var=battery.charge,battery.runtime&var=ups.status&since=2026-01-05T17:00:00Z&until=2026-01-05T18:00:00Z",
+			wantFilter: &entity.HistoryFilter{
+				Since:     at.Add(-30 * time.Minute),
+				Until:     at.Add(30 * time.Minute),
+				NutServer: "raspberrypi",
+				UPS:       "cyberpower900",
+				Variables: []string{"battery.charge", "battery.runtime", "ups.status"},
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: echo.MIMEApplicationJSON,
+		},
+		{
+			name:            "CSV format",
+			server:          "raspberrypi",
+			query:           "?format=csv",
+			wantFilter:      &entity.HistoryFilter{NutServer: "raspberrypi", UPS: "cyberpower900"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=UTF-8",
+			wantBody: "time,nut_server,ups,variable,value\n" +
+				"2026-01-05T17:30:00Z,raspberrypi,cyberpower900,battery.charge,85\n" +
+				"2026-01-05T17:30:00Z,raspberrypi,cyberpower900,ups.status,OB DISCHRG\n",
+		},
+		{
+			name:            "CSV accepted",
+			server:          "raspberrypi",
+			accept:          "text/csv",
+			wantFilter:      &entity.HistoryFilter{NutServer: "raspberrypi", UPS: "cyberpower900"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=UTF-8",
+		},
+		{
+			name:            "JSON format overrides Accept",
+			server:          "raspberrypi",
+			query:           "?format=json",
+			accept:          "text/csv",
+			wantFilter:      &entity.HistoryFilter{NutServer: "raspberrypi", UPS: "cyberpower900"},
+			wantStatus:      http.StatusOK,
+			wantContentType: echo.MIMEApplicationJSON,
+		},
+		{
+			name:       "unknown NUT server",
+			server:     "other",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"message":"NUT server other not found in the config"}`,
+		},
+		{
+			name:       "invalid since",
+			server:     "raspberrypi",
+			query:      "?since=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid until",
+			server:     "raspberrypi",
+			query:      "?until=tomorrow",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid format",
+			server:     "raspberrypi",
+			query:      "?format=xml",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"invalid query parameter format: must be json or csv"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			mock := gomock.NewController(t)
+			history := mocks.NewMockHistoryRepository(mock)
+			if tt.wantFilter != nil {
+				history.EXPECT().Query(*tt.wantFilter).Return(samples)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/ups/"+tt.server+"/cyberpower900/history"+tt.query, http.NoBody)
+			if tt.accept != "" {
+				req.Header.Set(echo.HeaderAccept, tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPathValues(echo.PathValues{{Name: "server", Value: tt.server}, {Name: "ups", Value: "cyberpower900"}})
+
+			h := NewHistoryHandler(cfg, history)
+			if assert.NoError(t, h.GetHistory(c)) {
+				assert.Equal(t, tt.wantStatus, rec.Code)
+				if tt.wantContentType != "" {
+					assert.Equal(t, tt.wantContentType, rec.Header().Get(echo.HeaderContentType))
+				}
+				switch {
+				case tt.wantBody == "":
+				case tt.wantContentType == "text/csv; charset=UTF-8":
+					assert.Equal(t, tt.wantBody, rec.Body.String())
+				default:
+					assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -16,26 +17,35 @@ import (
 )
 
 type RootHandler struct {
-	cfg     *entity.Config
-	rulesFS afero.Fs
-	upsRepo repository.UPSRepository
+	cfg      *entity.Config
+	rulesFS  afero.Fs
+	upsRepo  repository.UPSRepository
+	ruleRepo repository.RuleRepository
 }
 
 type Response struct {
 	Message string `json:"message"`
 }
 
-// NewRootHandler constructs a RootHandler with the provided configuration, rules filesystem and UPS repository.
-// The returned handler holds the dependencies used by the package's HTTP handlers, including the repository for querying UPS/NUT servers.
+type HealthResponse struct {
+	Message string `json:"message"`
+	// RulesRevision is the revision of the bundle the rules were loaded from
+	RulesRevision string `json:"rules_revision,omitempty" example:"v1.2.0"`
+}
+
+// NewRootHandler constructs a RootHandler with the provided configuration, rules filesystem, UPS repository and rule repository.
+// The returned handler holds the dependencies used by the package's HTTP handlers, including the repository for querying UPS/NUT servers
+// and the rule repository whose load errors are reported by the health check.
 //
 //	@Title			UPSWake
 //	@Version		1.0
 //	@Description	UPSWake reads data from a UPS Nut Server and uses it to dynamically send Wake on Lan packets to servers
-func NewRootHandler(cfg *entity.Config, rulesFS afero.Fs, upsRepo repository.UPSRepository) *RootHandler {
+func NewRootHandler(cfg *entity.Config, rulesFS afero.Fs, upsRepo repository.UPSRepository, ruleRepo repository.RuleRepository) *RootHandler {
 	return &RootHandler{
-		cfg:     cfg,
-		rulesFS: rulesFS,
-		upsRepo: upsRepo,
+		cfg:      cfg,
+		rulesFS:  rulesFS,
+		upsRepo:  upsRepo,
+		ruleRepo: ruleRepo,
 	}
 }
 
@@ -74,24 +84,36 @@ func (*RootHandler) Root(c *echo.Context) error {
 //	@Accept			json
 //	@Produce		json
 //
-//	@Success		200	{object}	Response	"OK"
-//	@Failure		500	{object}	Response
+//	@Success		200	{object}	HealthResponse	"OK"
+//	@Failure		500	{object}	HealthResponse
 //	@Router			/health [get]
 func (h *RootHandler) Health(c *echo.Context) error {
+	revision := h.ruleRepo.Revision()
+
 	if err := h.cfg.Validate(); err != nil {
-		return c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return c.JSON(http.StatusInternalServerError, HealthResponse{Message: err.Error(), RulesRevision: revision})
 	}
 
 	if _, err := network.GetAllBroadcastAddresses(); err != nil {
 		c.Logger().Error("Error getting broadcast addresses", slog.Any("error", err))
-		return c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return c.JSON(http.StatusInternalServerError, HealthResponse{Message: err.Error(), RulesRevision: revision})
+	}
+
+	if err := h.ruleRepo.Health(); err != nil {
+		c.Logger().Error("Error loading rego rules", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, HealthResponse{Message: err.Error(), RulesRevision: revision})
 	}
 
 	g := errgroup.Group{}
 
 	for _, server := range h.cfg.NutServers {
 		g.Go(func() error {
-			if _, err := h.upsRepo.GetJSON(server); err != nil {
+			_, err := h.upsRepo.GetJSON(server)
+			var stale *entity.StaleError
+			switch {
+			case errors.As(err, &stale):
+				c.Logger().Warn("NUT server is served from the cache", slog.Any("error", err))
+			case err != nil:
 				c.Logger().Error("Error getting NUT server status", slog.Any("error", err))
 				return err
 			}
@@ -101,9 +123,9 @@ func (h *RootHandler) Health(c *echo.Context) error {
 
 	if err := g.Wait(); err != nil {
 		c.Logger().Error("Health check failed", slog.Any("error", err))
-		return c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return c.JSON(http.StatusInternalServerError, HealthResponse{Message: err.Error(), RulesRevision: revision})
 	}
 
 	c.Logger().Debug("Health check OK")
-	return c.JSON(http.StatusOK, Response{Message: "OK"})
+	return c.JSON(http.StatusOK, HealthResponse{Message: "OK", RulesRevision: revision})
 }
@@ -28,6 +28,20 @@ func (r *countingUPSRepo) GetJSON(_ *entity.NutServer) (string, error) {
 	return r.json, r.err
 }
 
+type healthRuleRepo struct {
+	repository.RuleRepository
+	err      error
+	revision string
+}
+
+func (r *healthRuleRepo) Health() error {
+	return r.err
+}
+
+func (r *healthRuleRepo) Revision() string {
+	return r.revision
+}
+
 func testConfig(_ *testing.T) *entity.Config {
 	return &entity.Config{
 		NutServers: []*entity.NutServer{
@@ -71,7 +85,7 @@ func TestRootHandlerRoot(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	rulesFS := newMemFS(t, map[string][]byte{})
-	h := NewRootHandler(testConfig(t), rulesFS, &countingUPSRepo{})
+	h := NewRootHandler(testConfig(t), rulesFS, &countingUPSRepo{}, &healthRuleRepo{})
 
 	if assert.NoError(t, h.Root(c)) {
 		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
@@ -84,9 +98,10 @@ func TestRootHandler_Health(t *testing.T) {
 	require.NoError(t, err)
 
 	type fields struct {
-		cfg     *entity.Config
-		rulesFS afero.Fs
-		upsRepo repository.UPSRepository
+		cfg      *entity.Config
+		rulesFS  afero.Fs
+		upsRepo  repository.UPSRepository
+		ruleRepo repository.RuleRepository
 	}
 	type wantedResponse struct {
 		body       string
@@ -183,6 +198,40 @@ func TestRootHandler_Health(t *testing.T) {
 				statusCode: http.StatusOK,
 			},
 		},
+		{
+			name: "rule-failed-to-reload",
+			fields: fields{
+				cfg:     &entity.Config{},
+				rulesFS: newMemFS(t, map[string][]byte{}),
+				upsRepo: &countingUPSRepo{
+					json: `[{"Name":"ups1"}]`,
+				},
+				ruleRepo: &healthRuleRepo{
+					err: errors.New("invalid rule broken.rego: rego rule must be in package 'upswake'"),
+				},
+			},
+			wantedResponse: wantedResponse{
+				body:       `{"message": "invalid rule broken.rego: rego rule must be in package 'upswake'"}`,
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "bundle-revision",
+			fields: fields{
+				cfg:     &entity.Config{},
+				rulesFS: newMemFS(t, map[string][]byte{}),
+				upsRepo: &countingUPSRepo{
+					json: `[{"Name":"ups1"}]`,
+				},
+				ruleRepo: &healthRuleRepo{
+					revision: "v1.2.0",
+				},
+			},
+			wantedResponse: wantedResponse{
+				body:       `{"message": "OK", "rules_revision": "v1.2.0"}`,
+				statusCode: http.StatusOK,
+			},
+		},
 		//	TODO: Add tests that check for where the config isn't empty
 		// 		    and test when the GetAllBroadcastAddresses fails
 	}
@@ -193,7 +242,11 @@ func TestRootHandler_Health(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
-			h := NewRootHandler(tt.fields.cfg, tt.fields.rulesFS, tt.fields.upsRepo)
+			ruleRepo := tt.fields.ruleRepo
+			if ruleRepo == nil {
+				ruleRepo = &healthRuleRepo{}
+			}
+			h := NewRootHandler(tt.fields.cfg, tt.fields.rulesFS, tt.fields.upsRepo, ruleRepo)
 
 			if assert.NoError(t, h.Health(c)) {
 				assert.Equal(t, tt.wantedResponse.statusCode, rec.Code)
@@ -207,7 +260,7 @@ func TestRootHandler_Register(t *testing.T) {
 	e := echo.New()
 	e.Validator = api.NewCustomValidator(t.Context())
 	rulesFS := newMemFS(t, map[string][]byte{})
-	h := NewRootHandler(testConfig(t), rulesFS, &countingUPSRepo{})
+	h := NewRootHandler(testConfig(t), rulesFS, &countingUPSRepo{}, &healthRuleRepo{})
 
 	g := e.Group("")
 	h.Register(g)
@@ -321,7 +374,7 @@ default wake := true`),
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equalf(t, tt.want, NewRootHandler(tt.args.cfg, tt.args.rulesFS, tt.args.upsRepo), "NewRootHandler(%v, %v)", tt.args.cfg, tt.args.rulesFS)
+			assert.Equalf(t, tt.want, NewRootHandler(tt.args.cfg, tt.args.rulesFS, tt.args.upsRepo, nil), "NewRootHandler(%v, %v)", tt.args.cfg, tt.args.rulesFS)
 		})
 	}
 }
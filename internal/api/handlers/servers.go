@@ -19,6 +19,7 @@ var (
 	ErrorCreatingTargetServer = errors.New("failed to create target server")
 	ErrorBroadcastAddress     = errors.New("no broadcast addresses available or invalid broadcast address encountered")
 	ErrorSendingWoLPacket     = errors.New("failed to send wake on LAN packet")
+	ErrorMACNotFound          = errors.New("MAC address not found in the config")
 )
 
 const (
@@ -34,12 +35,18 @@ type ServerHandler struct {
 type WakeServerRequest struct {
 	Broadcast string `json:"broadcast" validate:"required,ip" example:"192.168.1.13"`
 	Mac       string `json:"mac" validate:"required,mac" example:"00:11:22:33:44:55"`
-	Port      int    `json:"port" validate:"gte=1,lte=65535" example:"9"`
+	// SecureOn is the password sent after the magic packet, 4 bytes written
+	// like an IPv4 address or 6 bytes written like a MAC address
+	SecureOn entity.SecureOn `json:"secure_on,omitempty" swaggertype:"string" example:"0a:0b:0c:0d:0e:0f"`
+	Port     int             `json:"port" validate:"gte=1,lte=65535" example:"9"`
 }
 
 type BroadcastWakeRequest struct {
-	Mac  string `json:"mac" validate:"required,mac" example:"00:11:22:33:44:55"`
-	Port int    `json:"port" validate:"gte=1,lte=65535" example:"9"`
+	Mac string `json:"mac" validate:"required,mac" example:"00:11:22:33:44:55"`
+	// SecureOn is the password sent after the magic packet, 4 bytes written
+	// like an IPv4 address or 6 bytes written like a MAC address
+	SecureOn entity.SecureOn `json:"secure_on,omitempty" swaggertype:"string" example:"0a:0b:0c:0d:0e:0f"`
+	Port     int             `json:"port" validate:"gte=1,lte=65535" example:"9"`
 }
 
 func NewWakeServerRequest() *WakeServerRequest {
@@ -90,6 +97,11 @@ func (s *ServerHandler) WakeServer(c *echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Response{Message: ErrorValidatingRequest.Error()})
 	}
 
+	if err := wsRequest.SecureOn.Validate(); err != nil {
+		c.Logger().Error("failed to validate wake server request", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, Response{Message: ErrorValidatingRequest.Error()})
+	}
+
 	ts, err := s.newTargetServer(
 		"API Request",
 		wsRequest.Mac,
@@ -102,6 +114,7 @@ func (s *ServerHandler) WakeServer(c *echo.Context) error {
 		c.Logger().Error("failed to create target server", slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, Response{Message: ErrorCreatingTargetServer.Error()})
 	}
+	ts.SecureOn = wsRequest.SecureOn
 
 	wolClient := wol.NewWoLClient(ts)
 
@@ -137,6 +150,11 @@ func (s *ServerHandler) BroadcastWakeServer(c *echo.Context) error {
 		c.Logger().Error("failed to validate wake server request", slog.Any("error", err))
 		return c.JSON(http.StatusBadRequest, Response{Message: ErrorValidatingRequest.Error()})
 	}
+
+	if err := wsRequest.SecureOn.Validate(); err != nil {
+		c.Logger().Error("failed to validate wake server request", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, Response{Message: ErrorValidatingRequest.Error()})
+	}
 	broadcasts, err := s.broadcastAddresses()
 	if err != nil {
 		c.Logger().Error("failed to get broadcast addresses", slog.Any("error", err))
@@ -166,6 +184,7 @@ func (s *ServerHandler) BroadcastWakeServer(c *echo.Context) error {
 			c.Logger().Error("failed to create new target server", slog.Any("error", err))
 			return c.JSON(http.StatusInternalServerError, Response{Message: ErrorCreatingTargetServer.Error()})
 		}
+		ts.SecureOn = wsRequest.SecureOn
 
 		wolClient := wol.NewWoLClient(ts)
 		if err = wolClient.Wake(); err != nil {
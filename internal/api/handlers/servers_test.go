@@ -83,6 +83,30 @@ func TestServerHandler_BroadcastWakeServer(t *testing.T) {
 				statusCode: http.StatusCreated,
 			},
 		},
+		{
+			name: "valid_secure_on",
+			fields: fields{
+				body:                   `{"mac": "00:11:22:33:44:55", "secure_on": "0a:0b:0c:0d:0e:0f"}`,
+				mockBroadcastAddresses: mockValidBroadcastAddressesFunc,
+				mockNewTargetServer:    entity.NewTargetServer,
+			},
+			wantedResponse: wantedResponse{
+				body:       `{"message":"` + BroadcastWoLSentMessage + `"}`,
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name: "invalid_secure_on",
+			fields: fields{
+				body:                   `{"mac": "00:11:22:33:44:55", "secure_on": "password"}`,
+				mockBroadcastAddresses: mockValidBroadcastAddressesFunc,
+				mockNewTargetServer:    entity.NewTargetServer,
+			},
+			wantedResponse: wantedResponse{
+				body:       `{"message":"` + ErrorValidatingRequest.Error() + `"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
 		{
 			name: "missing_mac",
 			fields: fields{
@@ -270,6 +294,28 @@ func TestServerHandler_WakeServer(t *testing.T) {
 				statusCode: http.StatusCreated,
 			},
 		},
+		{
+			name: "valid_secure_on",
+			fields: fields{
+				body:                `{"mac": "00:11:22:33:44:55", "broadcast": "127.0.0.255", "secure_on": "10.11.12.13"}`,
+				mockNewTargetServer: entity.NewTargetServer,
+			},
+			wantedResponse: wantedResponse{
+				body:       `{"message":"` + WoLSentMessage + `"}`,
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name: "invalid_secure_on",
+			fields: fields{
+				body:                `{"mac": "00:11:22:33:44:55", "broadcast": "127.0.0.255", "secure_on": "01:02:03"}`,
+				mockNewTargetServer: entity.NewTargetServer,
+			},
+			wantedResponse: wantedResponse{
+				body:       `{"message":"` + ErrorValidatingRequest.Error() + `"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
 		{
 			name: "missing_mac",
 			fields: fields{
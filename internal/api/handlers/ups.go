@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/labstack/echo/v5"
+	nut "github.com/robbiet480/go.nut"
+)
+
+type UPSHandler struct {
+	cfg     *entity.Config
+	upsRepo repository.UPSRepository
+}
+
+// UPSSource is a NUT server in the config and the UPSes read from it.
+type UPSSource struct {
+	Name string `json:"name" example:"raspberrypi"`
+	Type string `json:"type" example:"nut"`
+	// Address is the host and port the UPSes are read from, the program
+	// run for command sources or the URL, without credentials, of http
+	// sources
+	Address string `json:"address" example:"192.168.1.133:3493"`
+	// Error is why the UPSes couldn't be read. If UPSes last read from
+	// the NUT server are served instead, they are listed too
+	Error string        `json:"error,omitempty"`
+	UPSes []*UPSSummary `json:"upses"`
+}
+
+// UPSSummary is a UPS without its variables.
+type UPSSummary struct {
+	Name        string `json:"name" example:"cyberpower900"`
+	Description string `json:"description" example:"CyberPower CP900EPFCLCD"`
+	Status      string `json:"status,omitempty" example:"OL CHRG"`
+}
+
+// UPSDetails is a UPS and all of its variables.
+type UPSDetails struct {
+	Source      string         `json:"source" example:"raspberrypi"`
+	Name        string         `json:"name" example:"cyberpower900"`
+	Description string         `json:"description" example:"CyberPower CP900EPFCLCD"`
+	Variables   []*UPSVariable `json:"variables"`
+}
+
+// UPSVariable is a variable of a UPS, as described by the NUT server.
+type UPSVariable struct {
+	// Value is a number for numeric variables and a string otherwise
+	Value         any    `json:"value" swaggertype:"primitive,string" example:"100"`
+	Name          string `json:"name" example:"battery.charge"`
+	Type          string `json:"type" example:"INTEGER"`
+	Description   string `json:"description" example:"Battery charge (percent of full)"`
+	MaximumLength int    `json:"maximum_length" example:"0"`
+	Writeable     bool   `json:"writeable" example:"false"`
+}
+
+// NewUPSHandler creates a UPSHandler serving the UPSes of the NUT servers in
+// cfg, read with upsRepo.
+func NewUPSHandler(cfg *entity.Config, upsRepo repository.UPSRepository) *UPSHandler {
+	return &UPSHandler{
+		cfg:     cfg,
+		upsRepo: upsRepo,
+	}
+}
+
+func (h *UPSHandler) Register(g *echo.Group) {
+	g.GET("", h.ListUPSes)
+	g.GET("/:source/:ups", h.GetUPS)
+	g.GET("/:source/:ups/vars/:name", h.GetUPSVariable)
+}
+
+// ListUPSes godoc
+//
+//	@Summary		List UPSes
+//	@Description	List every NUT server in the config, in the order they are configured, with the UPSes read from it.
+//	@Description	A NUT server that can't be read is listed with the error instead of its UPSes.
+//	@Tags			ups
+//	@Produce		json
+//	@Success		200	{object}	[]UPSSource	"NUT servers"
+//	@Router			/api/ups [get]
+func (h *UPSHandler) ListUPSes(c *echo.Context) error {
+	sources := make([]*UPSSource, len(h.cfg.NutServers))
+	var wg sync.WaitGroup
+	for i, nutServer := range h.cfg.NutServers {
+		wg.Go(func() {
+			source := &UPSSource{
+				Name:    nutServer.Name,
+				Type:    string(nutServer.Type.Kind()),
+				Address: nutServer.RedactedAddress(),
+				UPSes:   []*UPSSummary{},
+			}
+			upses, err := h.readUPSes(nutServer)
+			if err != nil {
+				c.Logger().Error("Error reading UPSes",
+					slog.String("nut_server", nutServer.Name),
+					slog.Any("error", err))
+				source.Error = nutServer.Redact(err.Error())
+			}
+			for _, ups := range upses {
+				source.UPSes = append(source.UPSes, &UPSSummary{
+					Name:        ups.Name,
+					Description: ups.Description,
+					Status:      upsStatus(ups),
+				})
+			}
+			sources[i] = source
+		})
+	}
+	wg.Wait()
+	return c.JSON(http.StatusOK, sources)
+}
+
+// GetUPS godoc
+//
+//	@Summary		Get a UPS
+//	@Description	Get a UPS and all of its variables, with their descriptions and types.
+//	@Tags			ups
+//	@Produce		json
+//	@Param			source	path		string		true	"the name of the NUT server in the config"
+//	@Param			ups		path		string		true	"the name of the UPS on the NUT server"
+//	@Success		200		{object}	UPSDetails	"UPS"
+//	@Failure		404		{object}	Response	"NUT server or UPS not found"
+//	@Failure		502		{object}	Response	"NUT server couldn't be read"
+//	@Router			/api/ups/{source}/{ups} [get]
+func (h *UPSHandler) GetUPS(c *echo.Context) error {
+	nutServer, ups, err := h.findUPS(c)
+	if ups == nil {
+		return err
+	}
+
+	details := &UPSDetails{
+		Source:      nutServer.Name,
+		Name:        ups.Name,
+		Description: ups.Description,
+		Variables:   make([]*UPSVariable, 0, len(ups.Variables)),
+	}
+	for _, variable := range ups.Variables {
+		details.Variables = append(details.Variables, upsVariable(variable))
+	}
+	return c.JSON(http.StatusOK, details)
+}
+
+// GetUPSVariable godoc
+//
+//	@Summary		Get a UPS variable
+//	@Description	Get one variable of a UPS, with its description and type.
+//	@Tags			ups
+//	@Produce		json
+//	@Param			source	path		string		true	"the name of the NUT server in the config"
+//	@Param			ups		path		string		true	"the name of the UPS on the NUT server"
+//	@Param			name	path		string		true	"the name of the variable, e.g. battery.charge"
+//	@Success		200		{object}	UPSVariable	"Variable"
+//	@Failure		404		{object}	Response	"NUT server, UPS or variable not found"
+//	@Failure		502		{object}	Response	"NUT server couldn't be read"
+//	@Router			/api/ups/{source}/{ups}/vars/{name} [get]
+func (h *UPSHandler) GetUPSVariable(c *echo.Context) error {
+	nutServer, ups, err := h.findUPS(c)
+	if ups == nil {
+		return err
+	}
+
+	name := c.Param("name")
+	for _, variable := range ups.Variables {
+		if variable.Name == name {
+			return c.JSON(http.StatusOK, upsVariable(variable))
+		}
+	}
+	return c.JSON(http.StatusNotFound, Response{Message: fmt.Sprintf("variable %s not found on UPS %s of NUT server %s", name, ups.Name, nutServer.Name)})
+}
+
+// findUPS reads the UPS named in the request's path. If the NUT server or
+// UPS can't be found or read, the UPS is nil and the error response has
+// already been written.
+func (h *UPSHandler) findUPS(c *echo.Context) (*entity.NutServer, *nut.UPS, error) {
+	name := c.Param("source")
+	var nutServer *entity.NutServer
+	for _, candidate := range h.cfg.NutServers {
+		if candidate.Name == name {
+			nutServer = candidate
+			break
+		}
+	}
+	if nutServer == nil {
+		return nil, nil, c.JSON(http.StatusNotFound, Response{Message: fmt.Sprintf("NUT server %s not found in the config", name)})
+	}
+
+	upses, err := h.readUPSes(nutServer)
+	var stale *entity.StaleError
+	switch {
+	case errors.As(err, &stale):
+		c.Logger().Warn("Serving stale UPSes",
+			slog.String("nut_server", nutServer.Name),
+			slog.Any("error", err))
+	case err != nil:
+		c.Logger().Error("Error reading UPSes",
+			slog.String("nut_server", nutServer.Name),
+			slog.Any("error", err))
+		return nil, nil, c.JSON(http.StatusBadGateway, Response{Message: nutServer.Redact(err.Error())})
+	}
+
+	upsName := c.Param("ups")
+	for i := range upses {
+		if upses[i].Name == upsName {
+			return nutServer, &upses[i], nil
+		}
+	}
+	return nil, nil, c.JSON(http.StatusNotFound, Response{Message: fmt.Sprintf("UPS %s not found on NUT server %s", upsName, nutServer.Name)})
+}
+
+// readUPSes reads the UPSes of nutServer, keeping numbers as they were
+// read from the UPS. Stale UPSes are returned along with the
+// *entity.StaleError.
+func (h *UPSHandler) readUPSes(nutServer *entity.NutServer) ([]nut.UPS, error) {
+	upsJSON, err := h.upsRepo.GetJSON(nutServer)
+	var stale *entity.StaleError
+	if err != nil && !errors.As(err, &stale) {
+		return nil, err
+	}
+	var upses []nut.UPS
+	d := json.NewDecoder(strings.NewReader(upsJSON))
+	d.UseNumber()
+	if err := d.Decode(&upses); err != nil {
+		return nil, fmt.Errorf("UPSes read from %s are not a list of UPSes: %w", nutServer.Name, err)
+	}
+	return upses, err
+}
+
+func upsVariable(variable nut.Variable) *UPSVariable {
+	return &UPSVariable{
+		Name:          variable.Name,
+		Value:         variable.Value,
+		Type:          variable.Type,
+		Description:   variable.Description,
+		Writeable:     variable.Writeable,
+		MaximumLength: variable.MaximumLength,
+	}
+}
+
+func upsStatus(ups nut.UPS) string {
+	for _, variable := range ups.Variables {
+		if variable.Name == "ups.status" {
+			return fmt.Sprint(variable.Value)
+		}
+	}
+	return ""
+}
@@ -15,9 +15,11 @@ import (
 )
 
 type UPSWakeHandler struct {
-	cfg      *entity.Config
-	upsRepo  repository.UPSRepository
-	ruleRepo repository.RuleRepository
+	cfg       *entity.Config
+	upsRepo   repository.UPSRepository
+	ruleRepo  repository.RuleRepository
+	decisions repository.DecisionLogRepository
+	inputs    *evaluator.InputBuilder
 }
 
 type WakeEvaluationRequest struct {
@@ -26,22 +28,41 @@ type WakeEvaluationRequest struct {
 
 type UpsWakeResponse struct {
 	Message string `json:"message" example:"Wake on LAN sent"`
-	Woken   bool   `json:"woken" example:"true"`
+	Reason  string `json:"reason,omitempty" example:"battery.charge 100 >= 80 on cyberpower900"`
+	// RuleMode is how the decisions of the rules were combined
+	RuleMode string                 `json:"rule_mode,omitempty" example:"any"`
+	Rules    []*entity.RuleDecision `json:"rules,omitempty"`
+	// Unavailable are the NUT servers that couldn't be read
+	Unavailable []*evaluator.UnavailableSource `json:"unavailable,omitempty"`
+	Woken       bool                           `json:"woken" example:"true"`
+}
+
+type UpsWakeExplainResponse struct {
+	Message string                         `json:"message" example:"Rules evaluated, no Wake on LAN sent"`
+	Servers []*evaluator.ServerExplanation `json:"servers,omitempty"`
+	// Unavailable are the NUT servers that couldn't be read
+	Unavailable []*evaluator.UnavailableSource `json:"unavailable,omitempty"`
+	WouldWake   bool                           `json:"would_wake" example:"false"`
 }
 
 // NewUPSWakeHandler creates a UPSWakeHandler configured with the supplied server configuration and repositories.
-// The returned handler holds cfg, upsRepo and ruleRepo for use by its HTTP endpoints.
-func NewUPSWakeHandler(cfg *entity.Config, upsRepo repository.UPSRepository, ruleRepo repository.RuleRepository) *UPSWakeHandler {
+// The returned handler holds cfg, upsRepo and ruleRepo for use by its HTTP endpoints, and records
+// the wakes it sends in inputs so rules using versioned input can see when a target was last woken.
+// Every wake evaluation is recorded in decisions, unless it is nil.
+func NewUPSWakeHandler(cfg *entity.Config, upsRepo repository.UPSRepository, ruleRepo repository.RuleRepository, inputs *evaluator.InputBuilder, decisions repository.DecisionLogRepository) *UPSWakeHandler {
 	return &UPSWakeHandler{
-		cfg:      cfg,
-		upsRepo:  upsRepo,
-		ruleRepo: ruleRepo,
+		cfg:       cfg,
+		upsRepo:   upsRepo,
+		ruleRepo:  ruleRepo,
+		decisions: decisions,
+		inputs:    inputs,
 	}
 }
 
 func (h *UPSWakeHandler) Register(g *echo.Group) {
 	g.GET("", h.ListNutServerMappings)
 	g.POST("", h.RunWakeEvaluation)
+	g.POST("/explain", h.ExplainWakeEvaluation)
 }
 
 // ListNutServerMappings godoc
@@ -61,12 +82,32 @@ func (h *UPSWakeHandler) ListNutServerMappings(c *echo.Context) error {
 	for i, nutServer := range h.cfg.NutServers {
 		nutServers[i] = viper.ToFileNutServer(nutServer)
 		// Don't leak passwords
-		nutServers[i].Password = "********"
+		nutServers[i].Password = hiddenSecret
+		nutServers[i].SNMPCommunity = hideSecret(nutServers[i].SNMPCommunity)
+		nutServers[i].SNMPPrivPassword = hideSecret(nutServers[i].SNMPPrivPassword)
+		nutServers[i].URL = nutServer.RedactedURL()
+		nutServers[i].Command = nutServer.RedactedCommand()
+		for _, target := range nutServers[i].Targets {
+			target.SecureOn = hideSecret(target.SecureOn)
+		}
 	}
 
 	return c.JSON(http.StatusOK, nutServers)
 }
 
+// hiddenSecret replaces the secrets in the config ListNutServerMappings
+// returns.
+const hiddenSecret = "********"
+
+// hideSecret returns hiddenSecret if secret is set, so that optional
+// secrets that aren't set are still left out.
+func hideSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return hiddenSecret
+}
+
 // RunWakeEvaluation godoc
 //
 //	@Summary		Run wake evaluation
@@ -78,7 +119,7 @@ func (h *UPSWakeHandler) ListNutServerMappings(c *echo.Context) error {
 //	@Success		200				{object}	UpsWakeResponse			"Wake on LAN sent"
 //	@Success		304				{object}	UpsWakeResponse			"No rule evaluated to true"
 //	@Failure		400				{object}	UpsWakeResponse			"Bad request"
-//	@Failure		404				{object}	UpsWakeResponse			"MAC address not found in the config"
+//	@Failure		409				{object}	Response				"MAC address not found in the config"
 //	@Failure		500				{object}	UpsWakeResponse			"Internal server error"
 //	@Router			/api/upswake	[post]
 func (h *UPSWakeHandler) RunWakeEvaluation(c *echo.Context) error {
@@ -99,7 +140,11 @@ func (h *UPSWakeHandler) RunWakeEvaluation(c *echo.Context) error {
 		})
 	}
 
-	eval := evaluator.NewRegoEvaluator(h.cfg, mac, h.upsRepo, h.ruleRepo)
+	opts := []evaluator.Option{evaluator.WithInputBuilder(h.inputs)}
+	if h.decisions != nil {
+		opts = append(opts, evaluator.WithDecisionLog(h.decisions))
+	}
+	eval := evaluator.NewRegoEvaluator(h.cfg, mac, h.upsRepo, h.ruleRepo, opts...)
 	result, err := eval.EvaluateExpressions()
 	if err != nil {
 		c.Logger().Error("Failed to evaluate expressions", slog.Any("error", err))
@@ -109,19 +154,32 @@ func (h *UPSWakeHandler) RunWakeEvaluation(c *echo.Context) error {
 		})
 	}
 
+	for _, source := range result.Unavailable {
+		c.Logger().Warn("NUT server unavailable during wake evaluation",
+			slog.String("nut_server", source.NutServer),
+			slog.Any("skipped_targets", source.SkippedTargets),
+			slog.String("error", source.Error))
+	}
+
 	if !result.Found {
 		c.Logger().Error("mac address not found in the config", slog.String("mac", mac.MAC))
-		return c.JSON(http.StatusConflict, UpsWakeResponse{
-			Message: "MAC address not found in the config",
-			Woken:   false,
+		return c.JSON(http.StatusConflict, Response{
+			Message: ErrorMACNotFound.Error(),
 		})
 	}
 
 	if !result.Allowed {
-		c.Logger().Debug("no rule evaluated to true", slog.String("mac", mac.MAC))
+		c.Logger().Debug("no rule evaluated to true",
+			slog.String("mac", mac.MAC),
+			slog.String("rule_mode", result.Mode),
+			slog.String("reason", result.Reason()))
 		return c.JSON(http.StatusOK, UpsWakeResponse{
-			Message: "No rule evaluated to true",
-			Woken:   false,
+			Message:     "No rule evaluated to true",
+			Reason:      result.Reason(),
+			RuleMode:    result.Mode,
+			Rules:       result.Decisions,
+			Unavailable: result.Unavailable,
+			Woken:       false,
 		})
 	}
 
@@ -136,24 +194,110 @@ func (h *UPSWakeHandler) RunWakeEvaluation(c *echo.Context) error {
 	if err != nil {
 		c.Logger().Error("Failed to create target server", slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, UpsWakeResponse{
-			Message: fmt.Sprintf("Failed to create target server: %s", err),
-			Woken:   false,
+			Message:     fmt.Sprintf("Failed to create target server: %s", err),
+			Reason:      result.Reason(),
+			RuleMode:    result.Mode,
+			Rules:       result.Decisions,
+			Unavailable: result.Unavailable,
+			Woken:       false,
 		})
 	}
+	ts.SecureOn = result.Target.SecureOn
 
 	wolClient := wol.NewWoLClient(ts)
 
 	if err = wolClient.Wake(); err != nil {
 		c.Logger().Error("Failed to send wake on lan", slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, UpsWakeResponse{
-			Message: fmt.Sprintf("Failed to send wake on LAN: %s", err),
-			Woken:   false,
+			Message:     fmt.Sprintf("Failed to send wake on LAN: %s", err),
+			Reason:      result.Reason(),
+			RuleMode:    result.Mode,
+			Rules:       result.Decisions,
+			Unavailable: result.Unavailable,
+			Woken:       false,
 		})
 	}
 
-	c.Logger().Debug("Wake on LAN sent", slog.String("mac", mac.MAC))
+	if h.inputs != nil {
+		h.inputs.RecordWake(result.Target.MAC)
+	}
+	h.recordPacketSent(c, result)
+
+	c.Logger().Debug("Wake on LAN sent",
+		slog.String("mac", mac.MAC),
+		slog.String("rule_mode", result.Mode),
+		slog.String("reason", result.Reason()))
 	return c.JSON(http.StatusOK, UpsWakeResponse{
-		Message: "Wake on LAN sent",
-		Woken:   true,
+		Message:     "Wake on LAN sent",
+		Reason:      result.Reason(),
+		RuleMode:    result.Mode,
+		Rules:       result.Decisions,
+		Unavailable: result.Unavailable,
+		Woken:       true,
+	})
+}
+
+// recordPacketSent records in the decision log that the evaluation of
+// result ended with a Wake on LAN packet being sent.
+func (h *UPSWakeHandler) recordPacketSent(c *echo.Context, result *evaluator.EvaluationResult) {
+	if h.decisions == nil {
+		return
+	}
+	if err := h.decisions.RecordPacketSent(result.EvaluationID); err != nil {
+		c.Logger().Error("Failed to write decision log", slog.Any("error", err))
+	}
+}
+
+// ExplainWakeEvaluation godoc
+//
+//	@Summary		Explain wake evaluation
+//	@Description	Evaluate every rule for the target with tracing enabled, without sending a Wake on LAN packet.
+//	@Description	Returns the input document, decision and evaluation trace of each rule, per NUT server.
+//	@Tags			UPSWake
+//	@Accept			json
+//	@Produce		json
+//	@Param			request					body		WakeEvaluationRequest	true	"the mac address of the target to explain"
+//	@Success		200						{object}	UpsWakeExplainResponse	"Rules evaluated"
+//	@Failure		400						{object}	UpsWakeExplainResponse	"Bad request"
+//	@Failure		409						{object}	Response				"MAC address not found in the config"
+//	@Failure		500						{object}	UpsWakeExplainResponse	"Internal server error"
+//	@Router			/api/upswake/explain	[post]
+func (h *UPSWakeHandler) ExplainWakeEvaluation(c *echo.Context) error {
+	request := &WakeEvaluationRequest{}
+	if err := c.Bind(request); err != nil {
+		c.Logger().Error("failed to bind mac address", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, UpsWakeExplainResponse{
+			Message: ErrorBindingRequest.Error(),
+		})
+	}
+	mac, err := entity.NewMacAddress(request.Mac)
+	if err != nil {
+		c.Logger().Error("failed to validate mac address", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, UpsWakeExplainResponse{
+			Message: err.Error(),
+		})
+	}
+
+	eval := evaluator.NewRegoEvaluator(h.cfg, mac, h.upsRepo, h.ruleRepo, evaluator.WithInputBuilder(h.inputs))
+	explanation, err := eval.ExplainExpressions()
+	if err != nil {
+		c.Logger().Error("Failed to explain expressions", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, UpsWakeExplainResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if !explanation.Found {
+		c.Logger().Error("mac address not found in the config", slog.String("mac", mac.MAC))
+		return c.JSON(http.StatusConflict, Response{
+			Message: ErrorMACNotFound.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, UpsWakeExplainResponse{
+		Message:     "Rules evaluated, no Wake on LAN sent",
+		Servers:     explanation.Servers,
+		Unavailable: explanation.Unavailable,
+		WouldWake:   explanation.Allowed,
 	})
 }
@@ -0,0 +1,112 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultCacheTTL is how long a NUT server's UPSes are cached for if the
+// config doesn't say otherwise.
+const DefaultCacheTTL = 5 * time.Minute
+
+var ErrInvalidCache = errors.New("cache durations must not be negative")
+
+// NutCache configures how long a NUT server's UPSes are cached, and what is
+// served when reading them fails.
+type NutCache struct {
+	// TTL is how long a read is served from the cache, defaulting to
+	// DefaultCacheTTL
+	TTL time.Duration `json:"ttl,omitempty" swaggertype:"string" example:"5m"`
+	// MaxStale is how long past its TTL a read is served instead of the
+	// error when reading the NUT server fails. Stale reads aren't served if
+	// it is zero
+	MaxStale time.Duration `json:"max_stale,omitempty" swaggertype:"string" example:"30m"`
+	// ErrorBackoff is how long a failed read is cached for, doubling with
+	// each consecutive failure up to the TTL. Failed reads aren't cached if
+	// it is zero
+	ErrorBackoff time.Duration `json:"error_backoff,omitempty" swaggertype:"string" example:"10s"`
+}
+
+func (c NutCache) Validate() error {
+	if c.TTL < 0 || c.MaxStale < 0 || c.ErrorBackoff < 0 {
+		return ErrInvalidCache
+	}
+	return nil
+}
+
+// FreshFor returns how long a read is served from the cache, or
+// defaultTTL if the NUT server doesn't set one.
+func (c NutCache) FreshFor(defaultTTL time.Duration) time.Duration {
+	if c.TTL == 0 {
+		return defaultTTL
+	}
+	return c.TTL
+}
+
+// Backoff returns how long a read is cached for after failing failures
+// times in a row, given the NUT server's ttl, or 0 if failed reads aren't
+// cached.
+func (c NutCache) Backoff(failures int, ttl time.Duration) time.Duration {
+	backoff := c.ErrorBackoff
+	for i := 1; i < failures && backoff < ttl; i++ {
+		backoff *= 2
+	}
+	return min(backoff, ttl)
+}
+
+// StaleError is returned along with UPSes read at ReadAt when reading the
+// NUT server again failed with Err, but the UPSes are served anyway because
+// they are within the NUT server's MaxStale. Callers that can use stale
+// UPSes check for it with errors.As.
+type StaleError struct {
+	ReadAt time.Time
+	Err    error
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("serving UPSes read at %s: %s", e.ReadAt.Format(time.RFC3339), e.Err)
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+// CacheEntry is what is cached for a NUT server.
+type CacheEntry struct {
+	// CachedAt is when the cached UPSes were read
+	CachedAt time.Time `json:"cached_at,omitzero" example:"2026-01-05T17:30:00Z"`
+	// ExpiresAt is when the cached UPSes are read again
+	ExpiresAt time.Time `json:"expires_at,omitzero" example:"2026-01-05T17:35:00Z"`
+	// FailedAt is when reading the NUT server last failed
+	FailedAt time.Time `json:"failed_at,omitzero"`
+	// RetryAt is when a failed NUT server is read again, until which the
+	// failure is served from the cache
+	RetryAt   time.Time `json:"retry_at,omitzero"`
+	NutServer string    `json:"nut_server" example:"raspberrypi"`
+	// Address and LastError leave out the NUT server's credentials
+	Address   string `json:"address" example:"192.168.1.133:3493"`
+	LastError string `json:"last_error,omitempty"`
+	// AgeSeconds is how old the cached UPSes are
+	AgeSeconds          int64 `json:"age_seconds" example:"42"`
+	ConsecutiveFailures int   `json:"consecutive_failures" example:"0"`
+	// Fresh is true if the cached UPSes are served without reading the NUT
+	// server
+	Fresh bool `json:"fresh" example:"true"`
+}
+
+// CacheStats counts how reads of the NUT servers were served, since the
+// server started.
+type CacheStats struct {
+	Entries []*CacheEntry `json:"entries"`
+	// Hits were served fresh from the cache
+	Hits uint64 `json:"hits" example:"120"`
+	// Misses read the NUT server
+	Misses uint64 `json:"misses" example:"12"`
+	// Coalesced waited for another read of the same NUT server
+	Coalesced uint64 `json:"coalesced" example:"3"`
+	// StaleHits were served stale UPSes because reading the NUT server failed
+	StaleHits uint64 `json:"stale_hits" example:"1"`
+	// NegativeHits were served a cached failure
+	NegativeHits uint64 `json:"negative_hits" example:"2"`
+}
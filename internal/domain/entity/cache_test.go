@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNutCache_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		cache   NutCache
+	}{
+		{name: "empty", cache: NutCache{}},
+		{name: "every duration", cache: NutCache{TTL: time.Minute, MaxStale: time.Hour, ErrorBackoff: time.Second}},
+		{name: "negative ttl", cache: NutCache{TTL: -time.Minute}, wantErr: ErrInvalidCache},
+		{name: "negative max stale", cache: NutCache{MaxStale: -time.Minute}, wantErr: ErrInvalidCache},
+		{name: "negative error backoff", cache: NutCache{ErrorBackoff: -time.Second}, wantErr: ErrInvalidCache},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.cache.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestNutCache_FreshFor(t *testing.T) {
+	assert.Equal(t, DefaultCacheTTL, NutCache{}.FreshFor(DefaultCacheTTL))
+	assert.Equal(t, 30*time.Second, NutCache{TTL: 30 * time.Second}.FreshFor(DefaultCacheTTL))
+}
+
+func TestNutCache_Backoff(t *testing.T) {
+	cache := NutCache{ErrorBackoff: 10 * time.Second}
+	assert.Equal(t, 10*time.Second, cache.Backoff(1, time.Minute))
+	assert.Equal(t, 20*time.Second, cache.Backoff(2, time.Minute))
+	assert.Equal(t, 40*time.Second, cache.Backoff(3, time.Minute))
+	assert.Equal(t, time.Minute, cache.Backoff(4, time.Minute), "the backoff is capped at the TTL")
+	assert.Equal(t, time.Minute, cache.Backoff(100, time.Minute))
+	assert.Zero(t, NutCache{}.Backoff(3, time.Minute), "failed reads aren't cached without a backoff")
+}
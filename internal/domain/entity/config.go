@@ -1,10 +1,18 @@
 package entity
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -23,12 +31,68 @@ var (
 	ErrInvalidBroadcast  = errors.New("broadcast is invalid, must be an IP address")
 	ErrIntervalRequired  = errors.New("interval is required")
 	ErrInvalidInterval   = errors.New("interval is invalid, must be a duration")
+	ErrInlineRuleMissing = errors.New("inline rule has no source")
+	ErrDuplicateInline   = errors.New("inline rules with the same name must have the same source")
+	ErrInvalidInputVer   = errors.New("input version is invalid, must be 1, 2 or 3")
+	ErrInvalidTimezone   = errors.New("timezone is invalid, must be an IANA time zone name")
+	ErrBundlePathMissing = errors.New("bundle path is required")
+	ErrBundleKeyMissing  = errors.New("bundle public key is required, unsigned bundles are not supported")
+	ErrInvalidRuleMode   = errors.New("rule mode is invalid, must be any, all, first-match or quorum:N")
+	ErrInvalidQuorum     = errors.New("quorum must be between 1 and the number of rules")
+	ErrInvalidLogSize    = errors.New("decision log sizes must not be negative")
+	ErrInvalidRetention  = errors.New("history retention must not be negative")
+	ErrInvalidWatch      = errors.New("watch interval must not be negative")
 	validate             *validator.Validate
 )
 
 const (
 	DefaultWoLPort       = 9
 	DefaultNUTServerPort = 3493
+
+	// DefaultDecisionBufferSize is how many decision log entries are kept in
+	// memory for the API if the config doesn't say otherwise.
+	DefaultDecisionBufferSize = 1000
+	// DefaultDecisionLogMaxSizeMB is the size a decision log file is rotated at.
+	DefaultDecisionLogMaxSizeMB = 10
+	// DefaultDecisionLogMaxBackups is how many rotated decision log files are kept.
+	DefaultDecisionLogMaxBackups = 3
+
+	// DefaultHistoryRetention is how long UPS history is kept if the config
+	// doesn't say otherwise.
+	DefaultHistoryRetention = 24 * time.Hour
+
+	// DefaultWatchInterval is how often NUT servers are polled for status
+	// transitions if the config doesn't say otherwise.
+	DefaultWatchInterval = 10 * time.Second
+
+	// redacted replaces secrets in messages, matching url.URL.Redacted.
+	redacted = "xxxxx"
+
+	// InlineRulePrefix namespaces rules written inline in the config, so they
+	// can never collide with the file name of a rule in the rules directory.
+	InlineRulePrefix = "inline:"
+
+	// InputVersionLegacy gives rules the list of UPSes from the NUT server
+	// as their input, as rules written before versioned input expect.
+	InputVersionLegacy = 1
+	// InputVersionEnvelope gives rules a versioned document wrapping the list
+	// of UPSes with the evaluation time, UPS status history and the target.
+	InputVersionEnvelope = 2
+	// InputVersionNormalized gives rules the InputVersionEnvelope document
+	// with each UPS's variables also keyed by name under ups, with numeric
+	// values as numbers and ups.status split into flags.
+	InputVersionNormalized = 3
+
+	// RuleModeAny wakes the target if any rule allows it, and is the default
+	RuleModeAny RuleMode = "any"
+	// RuleModeAll wakes the target only if every rule allows it
+	RuleModeAll RuleMode = "all"
+	// RuleModeQuorum wakes the target if at least N rules allow it and none
+	// deny it, and is written as quorum:N
+	RuleModeQuorum RuleMode = "quorum"
+	// RuleModeFirstMatch evaluates rules in order, and the first rule that
+	// either allows or denies the wake decides it
+	RuleModeFirstMatch RuleMode = "first-match"
 )
 
 func init() {
@@ -56,19 +120,313 @@ func duration(fl validator.FieldLevel) bool {
 }
 
 type Config struct {
-	Profiler   *Profiler
-	NutServers []*NutServer
+	Profiler    *Profiler
+	Input       *Input
+	Bundle      *Bundle
+	DecisionLog *DecisionLog
+	History     *History
+	Watch       *Watch
+	NutServers  []*NutServer
 }
 
 func (c *Config) Validate() error {
+	if c.Input != nil {
+		if err := c.Input.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Bundle != nil {
+		if err := c.Bundle.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.DecisionLog != nil {
+		if err := c.DecisionLog.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.History != nil {
+		if err := c.History.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Watch != nil {
+		if err := c.Watch.Validate(); err != nil {
+			return err
+		}
+	}
 	for _, target := range c.NutServers {
 		if err := target.Validate(); err != nil {
 			return err
 		}
 	}
+
+	inlineRules := make(map[string]string)
+	for _, nutServer := range c.NutServers {
+		for _, target := range nutServer.Targets {
+			for name, source := range target.InlineRules {
+				if existing, ok := inlineRules[name]; ok && existing != source {
+					return fmt.Errorf("%w: %s", ErrDuplicateInline, name)
+				}
+				inlineRules[name] = source
+			}
+		}
+	}
 	return nil
 }
 
+// InlineRules returns the source of every inline rule in the config, keyed
+// by rule name.
+func (c *Config) InlineRules() map[string]string {
+	inlineRules := make(map[string]string)
+	for _, nutServer := range c.NutServers {
+		for _, target := range nutServer.Targets {
+			maps.Copy(inlineRules, target.InlineRules)
+		}
+	}
+	return inlineRules
+}
+
+// InlineRuleName returns the name an inline rule is evaluated under. If the
+// rule isn't given a name, one is derived from a hash of its source.
+func InlineRuleName(name, source string) string {
+	if name == "" {
+		hash := sha256.Sum256([]byte(source))
+		name = hex.EncodeToString(hash[:6])
+	}
+	return InlineRulePrefix + name
+}
+
+// InputVersion returns the version of the input document the target's rules
+// are evaluated against. A target's own version takes precedence over the
+// global one, and rules get the legacy input if neither is set.
+func (c *Config) InputVersion(target *TargetServer) int {
+	if target != nil && target.InputVersion != 0 {
+		return target.InputVersion
+	}
+	if c.Input != nil && c.Input.Version != 0 {
+		return c.Input.Version
+	}
+	return InputVersionLegacy
+}
+
+// Location returns the time zone rules are evaluated in, defaulting to the
+// local time zone of the host.
+func (c *Config) Location() *time.Location {
+	if c.Input == nil {
+		return time.Local
+	}
+	return c.Input.Location()
+}
+
+// Input configures the document rules are evaluated against.
+type Input struct {
+	// Version of the input document, see InputVersionLegacy,
+	// InputVersionEnvelope and InputVersionNormalized
+	Version int `json:"version" example:"2"`
+	// Timezone is the IANA name of the time zone rules see the time in
+	Timezone string `json:"timezone,omitempty" example:"Europe/London"`
+}
+
+func (i *Input) Validate() error {
+	if err := validateInputVersion(i.Version); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(i.Timezone); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTimezone, err)
+	}
+	return nil
+}
+
+// Location returns the configured time zone, or the local time zone if it
+// isn't set or can't be loaded.
+func (i *Input) Location() *time.Location {
+	if i.Timezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(i.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+func validateInputVersion(version int) error {
+	switch version {
+	case 0, InputVersionLegacy, InputVersionEnvelope, InputVersionNormalized:
+		return nil
+	default:
+		return ErrInvalidInputVer
+	}
+}
+
+// Bundle configures loading rules from a signed OPA bundle, instead of from
+// the rules directory.
+type Bundle struct {
+	// Path of the bundle .tar.gz file
+	Path string `json:"path" example:"./bundle.tar.gz"`
+	// PublicKey is the path of the PEM encoded key bundles must be signed with
+	PublicKey string `json:"public_key" example:"./bundle.pub.pem"`
+	// Algorithm the bundle is signed with, RS256 if not set
+	Algorithm string `json:"algorithm,omitempty" example:"RS256"`
+	// Scope, if set, must match the scope in the bundle's signature
+	Scope string `json:"scope,omitempty"`
+}
+
+func (b *Bundle) Validate() error {
+	if b.Path == "" {
+		return ErrBundlePathMissing
+	}
+	if b.PublicKey == "" {
+		return ErrBundleKeyMissing
+	}
+	return nil
+}
+
+// DecisionLog configures the audit trail of rule decisions. Decisions are
+// always kept in memory for the API; they are also written to File, as JSON
+// lines, if it is set.
+type DecisionLog struct {
+	// File is the path decisions are appended to, rotated when it reaches
+	// MaxSizeMB. Decisions are only kept in memory if it is empty
+	File string `json:"file,omitempty" example:"./decisions.jsonl"`
+	// BufferSize is how many decisions are kept in memory, defaulting to
+	// DefaultDecisionBufferSize
+	BufferSize int `json:"buffer_size,omitempty" example:"1000"`
+	// MaxSizeMB is the size File is rotated at, defaulting to
+	// DefaultDecisionLogMaxSizeMB
+	MaxSizeMB int `json:"max_size_mb,omitempty" example:"10"`
+	// MaxBackups is how many rotated files are kept, defaulting to
+	// DefaultDecisionLogMaxBackups if it is nil. With 0, the file is
+	// truncated when it is rotated
+	MaxBackups *int `json:"max_backups,omitempty" example:"3"`
+	// IncludeInput logs the full input document of each decision, not just
+	// its hash
+	IncludeInput bool `json:"include_input,omitempty" example:"false"`
+}
+
+func (d *DecisionLog) Validate() error {
+	if d.BufferSize < 0 || d.MaxSizeMB < 0 || (d.MaxBackups != nil && *d.MaxBackups < 0) {
+		return ErrInvalidLogSize
+	}
+	return nil
+}
+
+// Buffer returns how many decisions to keep in memory.
+func (d *DecisionLog) Buffer() int {
+	if d == nil || d.BufferSize == 0 {
+		return DefaultDecisionBufferSize
+	}
+	return d.BufferSize
+}
+
+// MaxSize returns the size in bytes the decision log file is rotated at.
+func (d *DecisionLog) MaxSize() int64 {
+	if d.MaxSizeMB == 0 {
+		return DefaultDecisionLogMaxSizeMB << 20
+	}
+	return int64(d.MaxSizeMB) << 20
+}
+
+// Backups returns how many rotated decision log files to keep.
+func (d *DecisionLog) Backups() int {
+	if d.MaxBackups == nil {
+		return DefaultDecisionLogMaxBackups
+	}
+	return *d.MaxBackups
+}
+
+// DefaultHistoryVariables are the UPS variables recorded in the history if
+// the config doesn't choose them.
+var DefaultHistoryVariables = []string{"battery.charge", "battery.runtime", "ups.load", "ups.status"}
+
+// History configures the record of UPS variables kept each time a NUT
+// server is read. It is always kept in memory for the API; it is also
+// written to File, as JSON lines, so it survives restarts, if it is set.
+type History struct {
+	// File is the path samples are appended to. It is rewritten from time
+	// to time to drop samples older than Retention
+	File string `json:"file,omitempty" example:"./history.jsonl"`
+	// Variables are the UPS variables recorded, defaulting to
+	// DefaultHistoryVariables
+	Variables []string `json:"variables,omitempty" example:"battery.charge,ups.status"`
+	// Retention is how long samples are kept, defaulting to
+	// DefaultHistoryRetention
+	Retention time.Duration `json:"retention,omitempty" swaggertype:"string" example:"24h"`
+}
+
+func (h *History) Validate() error {
+	if h.Retention < 0 {
+		return ErrInvalidRetention
+	}
+	return nil
+}
+
+// RetentionPeriod returns how long to keep samples for.
+func (h *History) RetentionPeriod() time.Duration {
+	if h == nil || h.Retention == 0 {
+		return DefaultHistoryRetention
+	}
+	return h.Retention
+}
+
+// RecordedVariables returns the UPS variables to record.
+func (h *History) RecordedVariables() []string {
+	if h == nil || len(h.Variables) == 0 {
+		return DefaultHistoryVariables
+	}
+	return h.Variables
+}
+
+// HistoryFile returns the file to keep the history in, or an empty string
+// if it is only kept in memory.
+func (h *History) HistoryFile() string {
+	if h == nil {
+		return ""
+	}
+	return h.File
+}
+
+// DefaultChargeThresholds are the battery.charge percentages that trigger
+// an evaluation when crossed, if the config doesn't choose them.
+var DefaultChargeThresholds = []float64{20, 50, 80, 100}
+
+// Watch configures the watcher, which polls NUT servers more often than
+// targets are evaluated and evaluates a server's targets straight away
+// when one of its UPSes changes status or its charge crosses a threshold.
+// The watcher only runs if Watch is set.
+type Watch struct {
+	// ChargeThresholds are the battery.charge percentages that trigger an
+	// evaluation when crossed, defaulting to DefaultChargeThresholds
+	ChargeThresholds []float64 `json:"charge_thresholds,omitempty" example:"20,50,80,100"`
+	// Interval is how often NUT servers are polled, defaulting to
+	// DefaultWatchInterval
+	Interval time.Duration `json:"interval,omitempty" swaggertype:"string" example:"10s"`
+}
+
+func (w *Watch) Validate() error {
+	if w.Interval < 0 {
+		return ErrInvalidWatch
+	}
+	return nil
+}
+
+// PollInterval returns how often to poll NUT servers.
+func (w *Watch) PollInterval() time.Duration {
+	if w == nil || w.Interval == 0 {
+		return DefaultWatchInterval
+	}
+	return w.Interval
+}
+
+// Thresholds returns the battery.charge percentages to watch.
+func (w *Watch) Thresholds() []float64 {
+	if w == nil || len(w.ChargeThresholds) == 0 {
+		return DefaultChargeThresholds
+	}
+	return w.ChargeThresholds
+}
+
 type Profiler struct {
 	Enabled bool `json:"enabled" default:"false"`
 }
@@ -79,27 +437,68 @@ type NutServer struct {
 	Username string          `json:"username"`
 	Password string          `json:"password"`
 	Targets  []*TargetServer `json:"targets"`
-	Port     int             `json:"port"`
+	// Type is the protocol the UPSes are read with, SourceTypeNUT if empty
+	Type SourceType `json:"type,omitempty" example:"nut"`
+	TLS  NutTLS     `json:"tls,omitzero"`
+	// SNMP configures how SourceTypeSNMP sources are read
+	SNMP NutSNMP `json:"snmp,omitzero"`
+	// Plugin configures how SourceTypeCommand and SourceTypeHTTP sources
+	// are read
+	Plugin NutPlugin `json:"plugin,omitzero"`
+	// Endpoints are further hosts serving the same UPSes, tried after Host
+	// and Port
+	Endpoints []NutEndpoint `json:"endpoints,omitempty"`
+	// EndpointStrategy is how the endpoints are chosen between,
+	// EndpointStrategyFailover if empty
+	EndpointStrategy EndpointStrategy `json:"endpoint_strategy,omitempty" example:"failover"`
+	// Cache configures how long the UPSes are cached for
+	Cache NutCache `json:"cache,omitzero"`
+	Port  int      `json:"port"`
 }
 
 func (ns *NutServer) Validate() error {
 	if ns.Name == "" {
 		return ErrNameRequired
 	}
-	if ns.Host == "" {
-		return ErrHostRequired
+	if err := ns.Type.Validate(); err != nil {
+		return err
 	}
-	if validate.Var(ns.Host, "ip|hostname") != nil {
-		return ErrInvalidHost
+	if ns.Type.NeedsAddress() {
+		if ns.Host == "" {
+			return ErrHostRequired
+		}
+		if validate.Var(ns.Host, "ip|hostname") != nil {
+			return ErrInvalidHost
+		}
+		if ns.Port < 1 || ns.Port > 65535 {
+			return ErrInvalidPort
+		}
 	}
-	if ns.Port < 1 || ns.Port > 65535 {
-		return ErrInvalidPort
+	if ns.Type.NeedsCredentials() {
+		if ns.Username == "" {
+			return ErrUsernameRequired
+		}
+		if ns.Password == "" {
+			return ErrPasswordRequired
+		}
 	}
-	if ns.Username == "" {
-		return ErrUsernameRequired
+	if err := ns.TLS.Validate(); err != nil {
+		return err
+	}
+	if ns.TLS.Enabled() && ns.Type.Kind() != SourceTypeNUT {
+		return ErrTLSUnsupported
+	}
+	if err := ns.validateSNMP(); err != nil {
+		return err
+	}
+	if err := ns.Plugin.Validate(ns.Type); err != nil {
+		return err
 	}
-	if ns.Password == "" {
-		return ErrPasswordRequired
+	if err := ns.validateEndpoints(); err != nil {
+		return err
+	}
+	if err := ns.Cache.Validate(); err != nil {
+		return err
 	}
 	for _, target := range ns.Targets {
 		if err := target.Validate(); err != nil {
@@ -109,6 +508,187 @@ func (ns *NutServer) Validate() error {
 	return nil
 }
 
+// Address returns where the NUT server's UPSes are read from: its host and
+// port, or the command line or URL of command and http sources.
+func (ns *NutServer) Address() string {
+	switch ns.Type.Kind() {
+	case SourceTypeCommand:
+		return strings.Join(ns.Plugin.Command, " ")
+	case SourceTypeHTTP:
+		return ns.Plugin.URL
+	default:
+		return net.JoinHostPort(ns.Host, strconv.Itoa(ns.Port))
+	}
+}
+
+// RedactedAddress returns Address without anything that may hold a
+// secret: a command's arguments and the credentials in a URL.
+func (ns *NutServer) RedactedAddress() string {
+	switch ns.Type.Kind() {
+	case SourceTypeCommand:
+		if len(ns.Plugin.Command) == 0 {
+			return ""
+		}
+		return ns.Plugin.Command[0]
+	case SourceTypeHTTP:
+		return ns.RedactedURL()
+	default:
+		return ns.Address()
+	}
+}
+
+// RedactedURL returns the URL of an http source with its password
+// replaced, or an empty string if it can't be parsed.
+func (ns *NutServer) RedactedURL() string {
+	if ns.Plugin.URL == "" {
+		return ""
+	}
+	u, err := url.Parse(ns.Plugin.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+// RedactedCommand returns the command of a command source with every
+// argument after the program replaced, as arguments often carry tokens.
+func (ns *NutServer) RedactedCommand() []string {
+	if len(ns.Plugin.Command) == 0 {
+		return nil
+	}
+	command := []string{ns.Plugin.Command[0]}
+	for range ns.Plugin.Command[1:] {
+		command = append(command, redacted)
+	}
+	return command
+}
+
+// Redact replaces the NUT server's secrets found in message, so errors
+// from reading it can be shown without leaking them.
+func (ns *NutServer) Redact(message string) string {
+	secrets := []string{ns.Password, ns.SNMP.Community, ns.SNMP.PrivPassword}
+	if u, err := url.Parse(ns.Plugin.URL); err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		secrets = append(secrets, password)
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			message = strings.ReplaceAll(message, secret, redacted)
+		}
+	}
+	return message
+}
+
+// AllEndpoints returns the NUT server's Host and Port followed by its
+// Endpoints, with their ports defaulting to Port.
+func (ns *NutServer) AllEndpoints() []NutEndpoint {
+	endpoints := make([]NutEndpoint, 0, len(ns.Endpoints)+1)
+	endpoints = append(endpoints, NutEndpoint{Host: ns.Host, Port: ns.Port})
+	for _, endpoint := range ns.Endpoints {
+		if endpoint.Port == 0 {
+			endpoint.Port = ns.Port
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+func (ns *NutServer) validateEndpoints() error {
+	if err := ns.EndpointStrategy.Validate(); err != nil {
+		return err
+	}
+	if len(ns.Endpoints) == 0 {
+		return nil
+	}
+	if !ns.Type.NeedsAddress() {
+		return ErrEndpointsUnsupported
+	}
+	for _, endpoint := range ns.Endpoints {
+		if err := endpoint.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ns *NutServer) validateSNMP() error {
+	if ns.Type.Kind() != SourceTypeSNMP {
+		if !ns.SNMP.IsZero() {
+			return ErrSNMPOnlySourceOption
+		}
+		return nil
+	}
+	if err := ns.SNMP.Validate(); err != nil {
+		return err
+	}
+	if ns.SNMP.ProtocolVersion() != SNMPVersion3 {
+		return nil
+	}
+	if ns.Username == "" {
+		return ErrUsernameRequired
+	}
+	if ns.SNMP.PrivPassword != "" && ns.Password == "" {
+		return ErrSNMPPrivWithoutAuth
+	}
+	return nil
+}
+
+// RuleMode is how the decisions of a target's rules are combined into
+// whether the target is woken.
+type RuleMode string
+
+// Kind returns the mode without its quorum, treating an empty mode as
+// RuleModeAny.
+func (m RuleMode) Kind() RuleMode {
+	kind, _, _ := strings.Cut(string(m), ":")
+	kind = strings.TrimSpace(kind)
+	if kind == "" {
+		return RuleModeAny
+	}
+	return RuleMode(kind)
+}
+
+// Quorum returns the number of rules that must allow a wake for a quorum
+// mode, or 0 for any other mode.
+func (m RuleMode) Quorum() int {
+	_, quorum, found := strings.Cut(string(m), ":")
+	if !found || m.Kind() != RuleModeQuorum {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(quorum))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// String returns the mode in its canonical form, e.g. any or quorum:2.
+func (m RuleMode) String() string {
+	if m.Kind() == RuleModeQuorum {
+		return string(RuleModeQuorum) + ":" + strconv.Itoa(m.Quorum())
+	}
+	return string(m.Kind())
+}
+
+// Validate checks the mode is known and, for a quorum, that it can be met
+// by ruleCount rules.
+func (m RuleMode) Validate(ruleCount int) error {
+	switch m.Kind() {
+	case RuleModeAny, RuleModeAll, RuleModeFirstMatch:
+		if strings.Contains(string(m), ":") {
+			return ErrInvalidRuleMode
+		}
+		return nil
+	case RuleModeQuorum:
+		if quorum := m.Quorum(); quorum < 1 || quorum > ruleCount {
+			return ErrInvalidQuorum
+		}
+		return nil
+	default:
+		return ErrInvalidRuleMode
+	}
+}
+
 func NewMacAddress(mac string) (*MacAddress, error) {
 	macAddress := &MacAddress{mac}
 
@@ -135,11 +715,21 @@ func (m *MacAddress) Validate() error {
 
 type TargetServer struct {
 	*MacAddress
-	Name      string        `json:"name"`
-	Broadcast string        `json:"broadcast"`
-	Rules     []string      `json:"rules"`
-	Interval  time.Duration `json:"interval" default:"900000000000"`
-	Port      int           `json:"port" default:"9"`
+	Name      string   `json:"name"`
+	Broadcast string   `json:"broadcast"`
+	Rules     []string `json:"rules"`
+	// InlineRules holds the source of the rules written inline in the config,
+	// keyed by their name in Rules
+	InlineRules map[string]string `json:"inline_rules,omitempty"`
+	Interval    time.Duration     `json:"interval" default:"900000000000"`
+	Port        int               `json:"port" default:"9"`
+	// InputVersion overrides the global input version for this target's rules
+	InputVersion int `json:"input_version,omitempty"`
+	// RuleMode is how the decisions of the rules are combined, RuleModeAny if empty
+	RuleMode RuleMode `json:"rule_mode,omitempty"`
+	// SecureOn is the password sent after the magic packet, for NICs that
+	// require one
+	SecureOn SecureOn `json:"secure_on,omitempty" example:"00:11:22:33:44:55"`
 }
 
 func (ts *TargetServer) Validate() error {
@@ -167,6 +757,20 @@ func (ts *TargetServer) Validate() error {
 	if validate.Var(ts.Interval, "duration") != nil {
 		return ErrInvalidInterval
 	}
+	if err := validateInputVersion(ts.InputVersion); err != nil {
+		return err
+	}
+	if err := ts.RuleMode.Validate(len(ts.Rules)); err != nil {
+		return err
+	}
+	if err := ts.SecureOn.Validate(); err != nil {
+		return err
+	}
+	for _, rule := range ts.Rules {
+		if strings.HasPrefix(rule, InlineRulePrefix) && ts.InlineRules[rule] == "" {
+			return ErrInlineRuleMissing
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,25 @@
+package entity
+
+// RuleDecision is the outcome of evaluating a single Rego rule.
+// Rules decide whether to wake a target with data.upswake.wake, and can
+// optionally explain that decision with data.upswake.reason and
+// data.upswake.details. A rule can veto a wake with data.upswake.deny,
+// in which case it never allows the wake.
+type RuleDecision struct {
+	Details any    `json:"details,omitempty"`
+	Rule    string `json:"rule" example:"80percentOn.rego"`
+	Reason  string `json:"reason,omitempty" example:"battery.charge 62 < 80 on cyberpower900"`
+	Allowed bool   `json:"allowed" example:"false"`
+	Denied  bool   `json:"denied,omitempty" example:"false"`
+}
+
+// RuleExplanation is a RuleDecision along with a trace of how the rule
+// was evaluated, used to debug rules that don't behave as expected.
+type RuleExplanation struct {
+	Decision *RuleDecision `json:"decision"`
+	// Trace is the OPA evaluation trace, one line per event
+	Trace []string `json:"trace"`
+	// FailedExpressions lists the location and text of each expression that
+	// evaluated to false or undefined
+	FailedExpressions []string `json:"failed_expressions,omitempty" example:"80percentOn.rego:8: input[i].Variables[j].Value >= 80"`
+}
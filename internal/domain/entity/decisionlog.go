@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DecisionLogEntry records one rule's decision during a wake evaluation.
+// Every rule evaluated by a single evaluation shares its EvaluationID, and
+// PacketSent records whether that evaluation ended with a Wake on LAN
+// packet being sent. Entries are written as soon as the rules have been
+// evaluated, so PacketSent is only set once the packet has been sent; see
+// PacketSentEntry.
+type DecisionLogEntry struct {
+	Time         time.Time `json:"time" example:"2026-01-05T17:30:00Z"`
+	Details      any       `json:"details,omitempty"`
+	EvaluationID string    `json:"evaluation_id" example:"5f2b9c0e7d1a4c38"`
+	MAC          string    `json:"mac" example:"00:11:22:33:44:55"`
+	Target       string    `json:"target" example:"MyNAS"`
+	NutServer    string    `json:"nut_server" example:"raspberrypi"`
+	Rule         string    `json:"rule" example:"80percentOn.rego"`
+	RuleMode     string    `json:"rule_mode" example:"any"`
+	// InputHash is the hex encoded SHA-256 of the input the rule was given
+	InputHash string `json:"input_hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	// Input is the input document, only logged if the decision log is
+	// configured to include it
+	Input  json.RawMessage `json:"input,omitempty" swaggertype:"object"`
+	Reason string          `json:"reason,omitempty" example:"battery.charge 62 < 80 on cyberpower900"`
+	Error  string          `json:"error,omitempty"`
+	// Latency is how long the rule took to evaluate, in nanoseconds
+	Latency    time.Duration `json:"latency_ns" swaggertype:"integer" example:"125000"`
+	Allowed    bool          `json:"allowed" example:"false"`
+	Denied     bool          `json:"denied,omitempty" example:"false"`
+	PacketSent bool          `json:"packet_sent" example:"false"`
+}
+
+// PacketSentEntry is appended to the decision log file when an evaluation
+// ends with a Wake on LAN packet being sent, as the entries of its rules'
+// decisions have already been written by then.
+type PacketSentEntry struct {
+	Time         time.Time `json:"time" example:"2026-01-05T17:30:00Z"`
+	EvaluationID string    `json:"evaluation_id" example:"5f2b9c0e7d1a4c38"`
+	PacketSent   bool      `json:"packet_sent" example:"true"`
+}
+
+// DecisionLogFilter selects decision log entries. Zero valued fields match
+// every entry.
+type DecisionLogFilter struct {
+	Since     time.Time
+	Allowed   *bool
+	MAC       string
+	Target    string
+	NutServer string
+	Rule      string
+	// Limit is the most entries to return, or 0 for no limit
+	Limit int
+}
+
+// Matches returns true if entry is selected by the filter. Limit is not
+// considered.
+func (f *DecisionLogFilter) Matches(entry *DecisionLogEntry) bool {
+	switch {
+	case !f.Since.IsZero() && entry.Time.Before(f.Since),
+		f.Allowed != nil && entry.Allowed != *f.Allowed,
+		f.MAC != "" && entry.MAC != f.MAC,
+		f.Target != "" && entry.Target != f.Target,
+		f.NutServer != "" && entry.NutServer != f.NutServer,
+		f.Rule != "" && entry.Rule != f.Rule:
+		return false
+	default:
+		return true
+	}
+}
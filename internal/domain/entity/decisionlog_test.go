@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionLogFilter_Matches(t *testing.T) {
+	at := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	entry := &DecisionLogEntry{
+		Time:      at,
+		MAC:       "00:11:22:33:44:55",
+		Target:    "MyNAS",
+		NutServer: "raspberrypi",
+		Rule:      "80percentOn.rego",
+		Allowed:   true,
+	}
+	allowed, denied := true, false
+
+	tests := []struct {
+		name   string
+		filter DecisionLogFilter
+		want   bool
+	}{
+		{name: "empty filter", want: true},
+		{
+			name: "every field matches",
+			filter: DecisionLogFilter{
+				Since:     at,
+				Allowed:   &allowed,
+				MAC:       "00:11:22:33:44:55",
+				Target:    "MyNAS",
+				NutServer: "raspberrypi",
+				Rule:      "80percentOn.rego",
+				Limit:     1,
+			},
+			want: true,
+		},
+		{name: "too old", filter: DecisionLogFilter{Since: at.Add(time.Second)}},
+		{name: "different result", filter: DecisionLogFilter{Allowed: &denied}},
+		{name: "different MAC", filter: DecisionLogFilter{MAC: "66:77:88:99:AA:BB"}},
+		{name: "different target", filter: DecisionLogFilter{Target: "Gaming PC"}},
+		{name: "different NUT server", filter: DecisionLogFilter{NutServer: "other"}},
+		{name: "different rule", filter: DecisionLogFilter{Rule: "alwaysTrue.rego"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(entry))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// EndpointStrategy is how the endpoints of a NUT server watching the same
+// UPS are chosen between.
+type EndpointStrategy string
+
+const (
+	// EndpointStrategyFailover tries the endpoints in order on every read,
+	// so the first endpoint is used again as soon as it recovers, and is
+	// the default
+	EndpointStrategyFailover EndpointStrategy = "failover"
+	// EndpointStrategyFirstHealthy tries the endpoints that haven't failed
+	// first, so a dead endpoint isn't waited on for every read. Failed
+	// endpoints are tried again after EndpointRecheckInterval.
+	EndpointStrategyFirstHealthy EndpointStrategy = "first-healthy"
+
+	// EndpointRecheckInterval is how long EndpointStrategyFirstHealthy
+	// passes over a failed endpoint before trying it again.
+	EndpointRecheckInterval = time.Minute
+)
+
+var (
+	ErrInvalidEndpointStrategy = errors.New("endpoint strategy is invalid, must be failover or first-healthy")
+	ErrEndpointsUnsupported    = errors.New("endpoints are only supported by nut, apcupsd and snmp sources")
+	ErrInvalidEndpoint         = errors.New("endpoint is invalid")
+)
+
+// Kind returns the strategy, treating an empty strategy as
+// EndpointStrategyFailover.
+func (s EndpointStrategy) Kind() EndpointStrategy {
+	if s == "" {
+		return EndpointStrategyFailover
+	}
+	return s
+}
+
+func (s EndpointStrategy) Validate() error {
+	switch s.Kind() {
+	case EndpointStrategyFailover, EndpointStrategyFirstHealthy:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidEndpointStrategy, s)
+	}
+}
+
+// NutEndpoint is another host serving the same UPSes as a NUT server, such
+// as a second upsd watching the same UPS.
+type NutEndpoint struct {
+	Host string `json:"host" example:"192.168.1.134"`
+	// Port is the NUT server's port if zero
+	Port int `json:"port,omitempty" example:"3493"`
+}
+
+// Address returns the endpoint's host:port.
+func (e NutEndpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+func (e NutEndpoint) Validate() error {
+	if e.Host == "" {
+		return fmt.Errorf("%w: %w", ErrInvalidEndpoint, ErrHostRequired)
+	}
+	if validate.Var(e.Host, "ip|hostname") != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEndpoint, ErrInvalidHost)
+	}
+	if e.Port < 0 || e.Port > 65535 {
+		return fmt.Errorf("%w: %w", ErrInvalidEndpoint, ErrInvalidPort)
+	}
+	return nil
+}
+
+// EndpointHealth is how reads from one endpoint of a NUT server have gone.
+type EndpointHealth struct {
+	// LastSuccess is when the endpoint was last read, zero if never
+	LastSuccess time.Time `json:"last_success,omitzero" example:"2026-01-05T17:30:00Z"`
+	// LastFailure is when reading the endpoint last failed, zero if never
+	LastFailure time.Time `json:"last_failure,omitzero"`
+	NutServer   string    `json:"nut_server" example:"raspberrypi"`
+	Address     string    `json:"address" example:"192.168.1.133:3493"`
+	// LastError is why reading the endpoint last failed
+	LastError string `json:"last_error,omitempty"`
+	// ConsecutiveFailures is how many reads have failed since the endpoint
+	// was last read
+	ConsecutiveFailures int `json:"consecutive_failures" example:"0"`
+	// Healthy is whether the last read succeeded, true if it hasn't been
+	// read yet
+	Healthy bool `json:"healthy" example:"true"`
+	// Active is whether the NUT server's UPSes were last read from this
+	// endpoint
+	Active bool `json:"active" example:"true"`
+}
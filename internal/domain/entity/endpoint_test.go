@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointStrategy_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		strategy EndpointStrategy
+	}{
+		{name: "empty", strategy: ""},
+		{name: "failover", strategy: EndpointStrategyFailover},
+		{name: "first-healthy", strategy: EndpointStrategyFirstHealthy},
+		{name: "unknown", strategy: "round-robin", wantErr: ErrInvalidEndpointStrategy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.strategy.Validate(), tt.wantErr)
+		})
+	}
+	assert.Equal(t, EndpointStrategyFailover, EndpointStrategy("").Kind())
+}
+
+func TestNutEndpoint_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		endpoint NutEndpoint
+	}{
+		{name: "host and port", endpoint: NutEndpoint{Host: "192.168.1.134", Port: 3493}},
+		{name: "host only", endpoint: NutEndpoint{Host: "ups-2.example.com"}},
+		{name: "no host", endpoint: NutEndpoint{Port: 3493}, wantErr: ErrHostRequired},
+		{name: "invalid host", endpoint: NutEndpoint{Host: "not a host"}, wantErr: ErrInvalidHost},
+		{name: "invalid port", endpoint: NutEndpoint{Host: "192.168.1.134", Port: 65536}, wantErr: ErrInvalidPort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.endpoint.Validate()
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, ErrInvalidEndpoint)
+			}
+		})
+	}
+}
+
+func TestNutServer_AllEndpoints(t *testing.T) {
+	server := &NutServer{
+		Host:      "192.168.1.133",
+		Port:      3493,
+		Endpoints: []NutEndpoint{{Host: "192.168.1.134"}, {Host: "192.168.1.135", Port: 3494}},
+	}
+	assert.Equal(t, []NutEndpoint{
+		{Host: "192.168.1.133", Port: 3493},
+		{Host: "192.168.1.134", Port: 3493},
+		{Host: "192.168.1.135", Port: 3494},
+	}, server.AllEndpoints())
+	assert.Equal(t, []NutEndpoint{{Host: "192.168.1.133", Port: 3493}}, (&NutServer{Host: "192.168.1.133", Port: 3493}).AllEndpoints())
+}
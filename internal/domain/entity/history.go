@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"slices"
+	"time"
+)
+
+// HistorySample is the value of one UPS variable at a point in time, as
+// read from a NUT server.
+type HistorySample struct {
+	Time time.Time `json:"time" example:"2026-01-05T17:30:00Z"`
+	// Value is a number for numeric variables and a string otherwise
+	Value     any    `json:"value" swaggertype:"primitive,string" example:"85"`
+	NutServer string `json:"nut_server" example:"raspberrypi"`
+	UPS       string `json:"ups" example:"cyberpower900"`
+	Variable  string `json:"variable" example:"battery.charge"`
+}
+
+// HistoryFilter selects history samples. Zero valued fields match every
+// sample.
+type HistoryFilter struct {
+	Since     time.Time
+	Until     time.Time
+	NutServer string
+	UPS       string
+	Variables []string
+}
+
+// Matches returns true if sample is selected by the filter.
+func (f *HistoryFilter) Matches(sample *HistorySample) bool {
+	switch {
+	case !f.Since.IsZero() && sample.Time.Before(f.Since),
+		!f.Until.IsZero() && sample.Time.After(f.Until),
+		f.NutServer != "" && sample.NutServer != f.NutServer,
+		f.UPS != "" && sample.UPS != f.UPS,
+		len(f.Variables) > 0 && !slices.Contains(f.Variables, sample.Variable):
+		return false
+	default:
+		return true
+	}
+}
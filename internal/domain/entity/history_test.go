@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryFilter_Matches(t *testing.T) {
+	at := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	sample := &HistorySample{
+		Time:      at,
+		NutServer: "raspberrypi",
+		UPS:       "cyberpower900",
+		Variable:  "battery.charge",
+		Value:     85,
+	}
+
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   bool
+	}{
+		{name: "empty filter", want: true},
+		{
+			name: "every field matches",
+			filter: HistoryFilter{
+				Since:     at,
+				Until:     at,
+				NutServer: "raspberrypi",
+				UPS:       "cyberpower900",
+				Variables: []string{"ups.status", "battery.charge"},
+			},
+			want: true,
+		},
+		{name: "too old", filter: HistoryFilter{Since: at.Add(time.Second)}},
+		{name: "too new", filter: HistoryFilter{Until: at.Add(-time.Second)}},
+		{name: "different NUT server", filter: HistoryFilter{NutServer: "other"}},
+		{name: "different UPS", filter: HistoryFilter{UPS: "apc"}},
+		{name: "different variable", filter: HistoryFilter{Variables: []string{"ups.status"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(sample))
+		})
+	}
+}
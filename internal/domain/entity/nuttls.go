@@ -0,0 +1,105 @@
+package entity
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/afero"
+)
+
+// NutTLSMode is whether the connection to a NUT server is upgraded to TLS
+// with STARTTLS before authenticating.
+type NutTLSMode string
+
+const (
+	// NutTLSOff never uses TLS, and is the default
+	NutTLSOff NutTLSMode = "off"
+	// NutTLSOptional uses TLS if the NUT server supports it, and otherwise
+	// continues without it
+	NutTLSOptional NutTLSMode = "optional"
+	// NutTLSRequired refuses to authenticate unless the connection is
+	// upgraded to TLS
+	NutTLSRequired NutTLSMode = "required"
+)
+
+var (
+	ErrInvalidTLSMode    = errors.New("tls is invalid, must be required, optional or off")
+	ErrTLSKeyPair        = errors.New("tls client certificate and key must be set together")
+	ErrFailedReadKeyPair = errors.New("failed to load client certificate and key")
+	ErrNoCACertificates  = errors.New("no certificates found in CA file")
+)
+
+// NutTLS configures TLS for the connection to a NUT server.
+type NutTLS struct {
+	Mode NutTLSMode `json:"mode,omitempty"`
+	// CAFile holds the PEM certificates the NUT server's certificate is
+	// verified against. The system's certificates are used if it isn't set.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are the client certificate presented to NUT
+	// servers that require one
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// ServerName overrides the host name the NUT server's certificate is
+	// verified against, which is the NUT server's host by default
+	ServerName string `json:"server_name,omitempty"`
+}
+
+func (t *NutTLS) Validate() error {
+	switch t.Mode {
+	case "", NutTLSOff, NutTLSOptional, NutTLSRequired:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidTLSMode, t.Mode)
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return ErrTLSKeyPair
+	}
+	return nil
+}
+
+// Enabled returns whether STARTTLS should be attempted.
+func (t *NutTLS) Enabled() bool {
+	return t.Mode == NutTLSOptional || t.Mode == NutTLSRequired
+}
+
+// ClientConfig returns the TLS config for connecting to the NUT server at
+// host, reading the CA and client certificate files from fileSystem.
+func (t *NutTLS) ClientConfig(fileSystem afero.Fs, host string) (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: host,
+	}
+	if t.ServerName != "" {
+		conf.ServerName = t.ServerName
+	}
+
+	if t.CAFile != "" {
+		caFile, err := afero.ReadFile(fileSystem, t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedReadCertFile, err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(caFile) {
+			return nil, fmt.Errorf("%w: %s", ErrNoCACertificates, t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" {
+		certFile, err := afero.ReadFile(fileSystem, t.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedReadKeyPair, err)
+		}
+		keyFile, err := afero.ReadFile(fileSystem, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedReadKeyPair, err)
+		}
+		cert, err := tls.X509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedReadKeyPair, err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
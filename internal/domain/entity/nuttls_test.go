@@ -0,0 +1,108 @@
+package entity
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNutTLS_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		tls     NutTLS
+	}{
+		{name: "unset", tls: NutTLS{}},
+		{name: "off", tls: NutTLS{Mode: NutTLSOff}},
+		{name: "optional", tls: NutTLS{Mode: NutTLSOptional}},
+		{name: "required", tls: NutTLS{Mode: NutTLSRequired, CAFile: "ca.pem"}},
+		{name: "client certificate", tls: NutTLS{Mode: NutTLSRequired, CertFile: "client.pem", KeyFile: "client.key"}},
+		{name: "invalid mode", tls: NutTLS{Mode: "always"}, wantErr: ErrInvalidTLSMode},
+		{name: "certificate without key", tls: NutTLS{Mode: NutTLSRequired, CertFile: "client.pem"}, wantErr: ErrTLSKeyPair},
+		{name: "key without certificate", tls: NutTLS{Mode: NutTLSRequired, KeyFile: "client.key"}, wantErr: ErrTLSKeyPair},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.tls.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestNutTLS_Enabled(t *testing.T) {
+	assert.False(t, (&NutTLS{}).Enabled())
+	assert.False(t, (&NutTLS{Mode: NutTLSOff}).Enabled())
+	assert.True(t, (&NutTLS{Mode: NutTLSOptional}).Enabled())
+	assert.True(t, (&NutTLS{Mode: NutTLSRequired}).Enabled())
+}
+
+func TestNutTLS_ClientConfig(t *testing.T) {
+	fileSystem := afero.NewMemMapFs()
+	keyPEM, certPEM := genEcdsaCertAndKey(t)
+	require.NoError(t, afero.WriteFile(fileSystem, "ca.pem", certPEM, 0o644))
+	require.NoError(t, afero.WriteFile(fileSystem, "client.pem", certPEM, 0o644))
+	require.NoError(t, afero.WriteFile(fileSystem, "client.key", keyPEM, 0o600))
+	require.NoError(t, afero.WriteFile(fileSystem, "invalid.pem", []byte("invalid"), 0o644))
+
+	tests := []struct {
+		wantErr        error
+		name           string
+		tls            NutTLS
+		wantServerName string
+		wantRootCAs    bool
+		wantClientCert bool
+	}{
+		{
+			name:           "system CAs",
+			tls:            NutTLS{Mode: NutTLSRequired},
+			wantServerName: "192.168.1.133",
+		},
+		{
+			name:           "CA file and server name",
+			tls:            NutTLS{Mode: NutTLSRequired, CAFile: "ca.pem", ServerName: "ups.example.com"},
+			wantServerName: "ups.example.com",
+			wantRootCAs:    true,
+		},
+		{
+			name:           "client certificate",
+			tls:            NutTLS{Mode: NutTLSRequired, CertFile: "client.pem", KeyFile: "client.key"},
+			wantServerName: "192.168.1.133",
+			wantClientCert: true,
+		},
+		{
+			name:    "missing CA file",
+			tls:     NutTLS{Mode: NutTLSRequired, CAFile: "missing.pem"},
+			wantErr: ErrFailedReadCertFile,
+		},
+		{
+			name:    "invalid CA file",
+			tls:     NutTLS{Mode: NutTLSRequired, CAFile: "invalid.pem"},
+			wantErr: ErrNoCACertificates,
+		},
+		{
+			name:    "missing client key",
+			tls:     NutTLS{Mode: NutTLSRequired, CertFile: "client.pem", KeyFile: "missing.key"},
+			wantErr: ErrFailedReadKeyPair,
+		},
+		{
+			name:    "invalid client key",
+			tls:     NutTLS{Mode: NutTLSRequired, CertFile: "client.pem", KeyFile: "invalid.pem"},
+			wantErr: ErrFailedReadKeyPair,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.tls.ClientConfig(fileSystem, "192.168.1.133")
+			require.ErrorIs(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.wantServerName, got.ServerName)
+			assert.Equal(t, uint16(tls.VersionTLS12), got.MinVersion)
+			assert.Equal(t, tt.wantRootCAs, got.RootCAs != nil)
+			assert.Equal(t, tt.wantClientCert, len(got.Certificates) == 1)
+		})
+	}
+}
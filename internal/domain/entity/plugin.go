@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultPluginTimeout is how long a command or HTTP source may take to
+// return its UPSes if the config doesn't set a timeout.
+const DefaultPluginTimeout = 10 * time.Second
+
+var (
+	ErrCommandRequired        = errors.New("command is required for command sources")
+	ErrURLRequired            = errors.New("url is required for http sources")
+	ErrInvalidURL             = errors.New("url is invalid, must be an absolute http or https URL")
+	ErrInvalidPluginTimeout   = errors.New("timeout must not be negative")
+	ErrPluginOnlySourceOption = errors.New("command, url and timeout are only supported by command and http sources")
+)
+
+// NutPlugin configures a command or HTTP source, which returns its UPSes
+// as JSON in the same shape as 'upswake json' prints them.
+type NutPlugin struct {
+	// Command is run for SourceTypeCommand, the first element being the
+	// program and the rest its arguments. It isn't run through a shell.
+	Command []string `json:"command,omitempty" example:"/usr/local/bin/ups-status,--json"`
+	// URL is fetched with GET for SourceTypeHTTP
+	URL string `json:"url,omitempty" example:"http://127.0.0.1:8080/ups.json"`
+	// Timeout is how long the command or request may take,
+	// DefaultPluginTimeout if zero
+	Timeout time.Duration `json:"timeout,omitempty" swaggertype:"string" example:"10s"`
+}
+
+// Validate checks the settings needed by sources of type t are set.
+func (p *NutPlugin) Validate(t SourceType) error {
+	if p.Timeout < 0 {
+		return ErrInvalidPluginTimeout
+	}
+	switch t.Kind() {
+	case SourceTypeCommand:
+		if len(p.Command) == 0 || strings.TrimSpace(p.Command[0]) == "" {
+			return ErrCommandRequired
+		}
+		if p.URL != "" {
+			return ErrPluginOnlySourceOption
+		}
+	case SourceTypeHTTP:
+		if p.URL == "" {
+			return ErrURLRequired
+		}
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidURL, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidURL, p.URL)
+		}
+		if len(p.Command) != 0 {
+			return ErrPluginOnlySourceOption
+		}
+	default:
+		if !p.IsZero() {
+			return ErrPluginOnlySourceOption
+		}
+	}
+	return nil
+}
+
+// IsZero returns whether no command or HTTP settings are set.
+func (p *NutPlugin) IsZero() bool {
+	return len(p.Command) == 0 && p.URL == "" && p.Timeout == 0
+}
+
+// RunTimeout returns the timeout, DefaultPluginTimeout if it isn't
+// set.
+func (p *NutPlugin) RunTimeout() time.Duration {
+	if p.Timeout == 0 {
+		return DefaultPluginTimeout
+	}
+	return p.Timeout
+}
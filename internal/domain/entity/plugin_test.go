@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNutPlugin_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		t       SourceType
+		plugin  NutPlugin
+	}{
+		{name: "command", t: SourceTypeCommand, plugin: NutPlugin{Command: []string{"/usr/local/bin/ups-status", "--json"}, Timeout: time.Second}},
+		{name: "command missing", t: SourceTypeCommand, wantErr: ErrCommandRequired},
+		{name: "command blank", t: SourceTypeCommand, plugin: NutPlugin{Command: []string{" "}}, wantErr: ErrCommandRequired},
+		{name: "command with url", t: SourceTypeCommand, plugin: NutPlugin{Command: []string{"ups"}, URL: "http://localhost"}, wantErr: ErrPluginOnlySourceOption},
+		{name: "http", t: SourceTypeHTTP, plugin: NutPlugin{URL: "http://127.0.0.1:8080/ups.json"}},
+		{name: "https", t: SourceTypeHTTP, plugin: NutPlugin{URL: "https://ups.example.com/ups.json"}},
+		{name: "url missing", t: SourceTypeHTTP, wantErr: ErrURLRequired},
+		{name: "url not http", t: SourceTypeHTTP, plugin: NutPlugin{URL: "file:///tmp/ups.json"}, wantErr: ErrInvalidURL},
+		{name: "url relative", t: SourceTypeHTTP, plugin: NutPlugin{URL: "/ups.json"}, wantErr: ErrInvalidURL},
+		{name: "url unparseable", t: SourceTypeHTTP, plugin: NutPlugin{URL: "http://[::1"}, wantErr: ErrInvalidURL},
+		{name: "url with command", t: SourceTypeHTTP, plugin: NutPlugin{URL: "http://localhost", Command: []string{"ups"}}, wantErr: ErrPluginOnlySourceOption},
+		{name: "negative timeout", t: SourceTypeHTTP, plugin: NutPlugin{URL: "http://localhost", Timeout: -time.Second}, wantErr: ErrInvalidPluginTimeout},
+		{name: "nut without settings", t: SourceTypeNUT},
+		{name: "nut with command", t: SourceTypeNUT, plugin: NutPlugin{Command: []string{"ups"}}, wantErr: ErrPluginOnlySourceOption},
+		{name: "snmp with timeout", t: SourceTypeSNMP, plugin: NutPlugin{Timeout: time.Second}, wantErr: ErrPluginOnlySourceOption},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.plugin.Validate(tt.t), tt.wantErr)
+		})
+	}
+}
+
+func TestNutPlugin_RunTimeout(t *testing.T) {
+	assert.Equal(t, DefaultPluginTimeout, (&NutPlugin{}).RunTimeout())
+	assert.Equal(t, 3*time.Second, (&NutPlugin{Timeout: 3 * time.Second}).RunTimeout())
+}
+
+func TestNutServer_Address(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		server NutServer
+	}{
+		{name: "nut", server: NutServer{Host: "192.168.1.133", Port: 3493}, want: "192.168.1.133:3493"},
+		{name: "ipv6", server: NutServer{Host: "::1", Port: 161, Type: SourceTypeSNMP}, want: "[::1]:161"},
+		{name: "command", server: NutServer{Type: SourceTypeCommand, Plugin: NutPlugin{Command: []string{"ups-status", "--json"}}}, want: "ups-status --json"},
+		{name: "http", server: NutServer{Type: SourceTypeHTTP, Plugin: NutPlugin{URL: "http://127.0.0.1/ups.json"}}, want: "http://127.0.0.1/ups.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.server.Address())
+		})
+	}
+}
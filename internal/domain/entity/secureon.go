@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+var ErrInvalidSecureOn = errors.New("SecureOn password is invalid, must be 4 bytes written like an IPv4 address or 6 bytes written like a MAC address")
+
+// SecureOn is the password some NICs require after the magic packet before
+// they wake. It is either 4 bytes written like an IPv4 address, e.g.
+// 192.168.1.1, or 6 bytes written like a MAC address, e.g.
+// 00:11:22:33:44:55. Magic packets are sent without a password if it is
+// empty.
+type SecureOn string
+
+func (s SecureOn) Validate() error {
+	_, err := s.Bytes()
+	return err
+}
+
+// Bytes returns the password as it is appended to the magic packet, or nil
+// if there is no password.
+func (s SecureOn) Bytes() ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if addr, err := netip.ParseAddr(string(s)); err == nil && addr.Is4() {
+		password := addr.As4()
+		return password[:], nil
+	}
+	if hw, err := net.ParseMAC(string(s)); err == nil && len(hw) == 6 {
+		return []byte(hw), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidSecureOn, s)
+}
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecureOn_Bytes(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		secureOn SecureOn
+		want     []byte
+	}{
+		{name: "empty", secureOn: ""},
+		{name: "4 bytes", secureOn: "192.168.1.1", want: []byte{192, 168, 1, 1}},
+		{name: "6 bytes", secureOn: "01:23:45:67:89:ab", want: []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}},
+		{name: "6 bytes with dashes", secureOn: "01-23-45-67-89-AB", want: []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}},
+		{name: "IPv6 address", secureOn: "::ffff:192.168.1.1", wantErr: ErrInvalidSecureOn},
+		{name: "8 bytes", secureOn: "01:23:45:67:89:ab:cd:ef", wantErr: ErrInvalidSecureOn},
+		{name: "too short", secureOn: "01:23:45", wantErr: ErrInvalidSecureOn},
+		{name: "plain text", secureOn: "password", wantErr: ErrInvalidSecureOn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.secureOn.Bytes()
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, tt.secureOn.Validate(), tt.wantErr)
+		})
+	}
+}
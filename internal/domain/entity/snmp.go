@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// SNMPVersion is the version of SNMP a UPS's management card is read with.
+type SNMPVersion string
+
+const (
+	// SNMPVersion2c authenticates with a community string, and is the default
+	SNMPVersion2c SNMPVersion = "2c"
+	// SNMPVersion3 authenticates as the NUT server's username, with its
+	// password as the authentication passphrase
+	SNMPVersion3 SNMPVersion = "3"
+
+	// DefaultSNMPPort is the port SNMP agents listen on.
+	DefaultSNMPPort = 161
+	// DefaultSNMPCommunity is the community used with SNMPVersion2c if the
+	// config doesn't set one.
+	DefaultSNMPCommunity = "public"
+)
+
+var (
+	ErrInvalidSNMPVersion   = errors.New("snmp version is invalid, must be 2c or 3")
+	ErrInvalidSNMPAuth      = errors.New("snmp auth protocol is invalid, must be MD5, SHA, SHA224, SHA256, SHA384 or SHA512")
+	ErrInvalidSNMPPriv      = errors.New("snmp priv protocol is invalid, must be DES, AES, AES192, AES256, AES192C or AES256C")
+	ErrSNMPPrivWithoutAuth  = errors.New("snmp priv password requires a password to authenticate with")
+	ErrSNMPOnlySourceOption = errors.New("snmp settings are only supported by snmp sources")
+)
+
+// SNMPAuthProtocols are the SNMPv3 authentication protocols, the first of
+// which is the default.
+var SNMPAuthProtocols = []string{"SHA", "MD5", "SHA224", "SHA256", "SHA384", "SHA512"}
+
+// SNMPPrivProtocols are the SNMPv3 privacy protocols, the first of which
+// is the default.
+var SNMPPrivProtocols = []string{"AES", "DES", "AES192", "AES256", "AES192C", "AES256C"}
+
+// NutSNMP configures how a UPS with an SNMP management card is read. With
+// SNMPVersion3 the security level follows from which passwords are set:
+// no password is noAuthNoPriv, a password is authNoPriv and a password and
+// PrivPassword is authPriv.
+type NutSNMP struct {
+	Version SNMPVersion `json:"version,omitempty" example:"2c"`
+	// Community is the SNMPVersion2c community, DefaultSNMPCommunity if empty
+	Community string `json:"community,omitempty"`
+	// AuthProtocol is the SNMPVersion3 authentication protocol, SHA if empty
+	AuthProtocol string `json:"auth_protocol,omitempty" example:"SHA"`
+	// PrivProtocol is the SNMPVersion3 privacy protocol, AES if empty
+	PrivProtocol string `json:"priv_protocol,omitempty" example:"AES"`
+	// PrivPassword is the SNMPVersion3 privacy passphrase
+	PrivPassword string `json:"priv_password,omitempty"`
+}
+
+func (s *NutSNMP) Validate() error {
+	switch s.Version {
+	case "", SNMPVersion2c, SNMPVersion3:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidSNMPVersion, s.Version)
+	}
+	if s.AuthProtocol != "" && !slices.Contains(SNMPAuthProtocols, s.AuthProtocol) {
+		return fmt.Errorf("%w: %q", ErrInvalidSNMPAuth, s.AuthProtocol)
+	}
+	if s.PrivProtocol != "" && !slices.Contains(SNMPPrivProtocols, s.PrivProtocol) {
+		return fmt.Errorf("%w: %q", ErrInvalidSNMPPriv, s.PrivProtocol)
+	}
+	return nil
+}
+
+// IsZero returns whether no SNMP settings are set.
+func (s *NutSNMP) IsZero() bool {
+	return *s == NutSNMP{}
+}
+
+// ProtocolVersion returns the SNMP version, SNMPVersion2c if it isn't set.
+func (s *NutSNMP) ProtocolVersion() SNMPVersion {
+	if s.Version == "" {
+		return SNMPVersion2c
+	}
+	return s.Version
+}
+
+// CommunityString returns the community, DefaultSNMPCommunity if it isn't
+// set.
+func (s *NutSNMP) CommunityString() string {
+	if s.Community == "" {
+		return DefaultSNMPCommunity
+	}
+	return s.Community
+}
+
+// Auth returns the SNMPv3 authentication protocol.
+func (s *NutSNMP) Auth() string {
+	if s.AuthProtocol == "" {
+		return SNMPAuthProtocols[0]
+	}
+	return s.AuthProtocol
+}
+
+// Priv returns the SNMPv3 privacy protocol.
+func (s *NutSNMP) Priv() string {
+	if s.PrivProtocol == "" {
+		return SNMPPrivProtocols[0]
+	}
+	return s.PrivProtocol
+}
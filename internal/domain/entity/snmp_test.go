@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNutSNMP_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		snmp    NutSNMP
+	}{
+		{name: "empty", snmp: NutSNMP{}},
+		{name: "v2c", snmp: NutSNMP{Version: SNMPVersion2c, Community: "private"}},
+		{name: "v3", snmp: NutSNMP{Version: SNMPVersion3, AuthProtocol: "SHA256", PrivProtocol: "AES256", PrivPassword: "secret"}},
+		{name: "invalid version", snmp: NutSNMP{Version: "1"}, wantErr: ErrInvalidSNMPVersion},
+		{name: "invalid auth", snmp: NutSNMP{Version: SNMPVersion3, AuthProtocol: "sha"}, wantErr: ErrInvalidSNMPAuth},
+		{name: "invalid priv", snmp: NutSNMP{Version: SNMPVersion3, PrivProtocol: "3DES"}, wantErr: ErrInvalidSNMPPriv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.snmp.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestNutSNMP_defaults(t *testing.T) {
+	s := &NutSNMP{}
+	assert.True(t, s.IsZero())
+	assert.Equal(t, SNMPVersion2c, s.ProtocolVersion())
+	assert.Equal(t, DefaultSNMPCommunity, s.CommunityString())
+	assert.Equal(t, "SHA", s.Auth())
+	assert.Equal(t, "AES", s.Priv())
+
+	s = &NutSNMP{Version: SNMPVersion3, Community: "private", AuthProtocol: "MD5", PrivProtocol: "DES"}
+	assert.False(t, s.IsZero())
+	assert.Equal(t, SNMPVersion3, s.ProtocolVersion())
+	assert.Equal(t, "private", s.CommunityString())
+	assert.Equal(t, "MD5", s.Auth())
+	assert.Equal(t, "DES", s.Priv())
+}
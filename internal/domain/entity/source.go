@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SourceType is the protocol a UPS source in nut_servers is read with.
+type SourceType string
+
+const (
+	// SourceTypeNUT reads UPSes from a NUT server, and is the default
+	SourceTypeNUT SourceType = "nut"
+	// SourceTypeApcupsd reads the UPS from apcupsd's Network Information
+	// Server, mapping its fields to NUT variable names
+	SourceTypeApcupsd SourceType = "apcupsd"
+	// SourceTypeSNMP reads the UPS from its SNMP management card with the
+	// UPS-MIB of RFC 1628, mapping its objects to NUT variable names
+	SourceTypeSNMP SourceType = "snmp"
+	// SourceTypeCommand runs a command that prints the UPSes as JSON
+	SourceTypeCommand SourceType = "command"
+	// SourceTypeHTTP GETs the UPSes as JSON from a URL
+	SourceTypeHTTP SourceType = "http"
+
+	// DefaultApcupsdPort is the port apcupsd's Network Information Server
+	// listens on.
+	DefaultApcupsdPort = 3551
+)
+
+var (
+	ErrInvalidSourceType = errors.New("type is invalid, must be nut, apcupsd, snmp, command or http")
+	ErrTLSUnsupported    = errors.New("tls is only supported by nut sources")
+)
+
+// Kind returns the source type, treating an empty type as SourceTypeNUT.
+func (t SourceType) Kind() SourceType {
+	if t == "" {
+		return SourceTypeNUT
+	}
+	return t
+}
+
+func (t SourceType) Validate() error {
+	switch t.Kind() {
+	case SourceTypeNUT, SourceTypeApcupsd, SourceTypeSNMP, SourceTypeCommand, SourceTypeHTTP:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidSourceType, t)
+	}
+}
+
+// NeedsCredentials returns whether sources of this type are logged in to
+// with a username and password.
+func (t SourceType) NeedsCredentials() bool {
+	return t.Kind() == SourceTypeNUT
+}
+
+// NeedsAddress returns whether sources of this type are read from a host
+// and port, rather than a command or URL.
+func (t SourceType) NeedsAddress() bool {
+	switch t.Kind() {
+	case SourceTypeCommand, SourceTypeHTTP:
+		return false
+	default:
+		return true
+	}
+}
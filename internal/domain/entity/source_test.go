@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceType_Kind(t *testing.T) {
+	assert.Equal(t, SourceTypeNUT, SourceType("").Kind())
+	assert.Equal(t, SourceTypeNUT, SourceTypeNUT.Kind())
+	assert.Equal(t, SourceTypeApcupsd, SourceTypeApcupsd.Kind())
+}
+
+func TestSourceType_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		t       SourceType
+	}{
+		{name: "empty", t: ""},
+		{name: "nut", t: SourceTypeNUT},
+		{name: "apcupsd", t: SourceTypeApcupsd},
+		{name: "snmp", t: SourceTypeSNMP},
+		{name: "command", t: SourceTypeCommand},
+		{name: "http", t: SourceTypeHTTP},
+		{name: "unknown", t: "upsd", wantErr: ErrInvalidSourceType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.t.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestSourceType_NeedsCredentials(t *testing.T) {
+	assert.True(t, SourceType("").NeedsCredentials())
+	assert.True(t, SourceTypeNUT.NeedsCredentials())
+	assert.False(t, SourceTypeApcupsd.NeedsCredentials())
+	assert.False(t, SourceTypeSNMP.NeedsCredentials())
+}
+
+func TestSourceType_NeedsAddress(t *testing.T) {
+	assert.True(t, SourceType("").NeedsAddress())
+	assert.True(t, SourceTypeApcupsd.NeedsAddress())
+	assert.True(t, SourceTypeSNMP.NeedsAddress())
+	assert.False(t, SourceTypeCommand.NeedsAddress())
+	assert.False(t, SourceTypeHTTP.NeedsAddress())
+}
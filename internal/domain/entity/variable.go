@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// NumericString returns s without surrounding whitespace if it holds a JSON
+// number, such as "230.0", as NUT servers give some numeric variables as
+// strings. Strings that only look numeric, such as serial numbers with
+// leading zeros, aren't numbers.
+func NumericString(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	// valid JSON starting with a digit or minus sign can only be a number
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) || !json.Valid([]byte(s)) {
+		return "", false
+	}
+	return s, true
+}
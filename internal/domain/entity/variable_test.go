@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumericString(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		want   string
+		wantOK bool
+	}{
+		{name: "integer", s: "100", want: "100", wantOK: true},
+		{name: "decimal with whitespace", s: " 230.5 ", want: "230.5", wantOK: true},
+		{name: "negative", s: "-1.5e3", want: "-1.5e3", wantOK: true},
+		{name: "leading zeros", s: "000123"},
+		{name: "status", s: "OL CHRG"},
+		{name: "version", s: "1.0.3"},
+		{name: "JSON that isn't a number", s: "[1]"},
+		{name: "empty", s: " "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NumericString(tt.s)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
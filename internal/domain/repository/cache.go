@@ -0,0 +1,15 @@
+package repository
+
+import "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+
+//go:generate mockgen -package mocks -source cache.go -destination mocks/cache_mock.go CacheRepository
+
+// CacheRepository caches the UPSes read from each NUT server.
+type CacheRepository interface {
+	// Stats returns what is cached for each NUT server, and how reads have
+	// been served.
+	Stats() *entity.CacheStats
+	// Reset clears the cache so the next read of every NUT server fetches
+	// fresh data.
+	Reset()
+}
@@ -0,0 +1,19 @@
+package repository
+
+import "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+
+//go:generate mockgen -package mocks -source decisionlog.go -destination mocks/decisionlog_mock.go DecisionLogRepository
+
+// DecisionLogRepository is the audit trail of rule decisions made while
+// deciding whether to wake targets.
+type DecisionLogRepository interface {
+	// Write appends entries to the log.
+	Write(entries ...*entity.DecisionLogEntry) error
+
+	// RecordPacketSent records that the evaluation with evaluationID ended
+	// with a Wake on LAN packet being sent.
+	RecordPacketSent(evaluationID string) error
+
+	// Query returns the most recent entries matching filter, newest first.
+	Query(filter entity.DecisionLogFilter) []*entity.DecisionLogEntry
+}
@@ -0,0 +1,13 @@
+package repository
+
+import "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+
+//go:generate mockgen -package mocks -source endpoint.go -destination mocks/endpoint_mock.go EndpointHealthRepository
+
+// EndpointHealthRepository tracks how reads from each endpoint of the NUT
+// servers have gone.
+type EndpointHealthRepository interface {
+	// Health returns the health of each of server's endpoints, in the order
+	// they are configured.
+	Health(server *entity.NutServer) []*entity.EndpointHealth
+}
@@ -0,0 +1,15 @@
+package repository
+
+import "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+
+//go:generate mockgen -package mocks -source history.go -destination mocks/history_mock.go HistoryRepository
+
+// HistoryRepository is the record of UPS variables read from NUT servers
+// over time.
+type HistoryRepository interface {
+	// Record adds samples to the history.
+	Record(samples ...*entity.HistorySample) error
+
+	// Query returns the samples matching filter, oldest first.
+	Query(filter entity.HistoryFilter) []*entity.HistorySample
+}
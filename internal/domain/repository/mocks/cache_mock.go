@@ -0,0 +1,67 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: cache.go
+//
+// Generated by this command:
+//
+//	mockgen -package mocks -source cache.go -destination mocks/cache_mock.go CacheRepository
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	entity "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCacheRepository is a mock of CacheRepository interface.
+type MockCacheRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockCacheRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockCacheRepositoryMockRecorder is the mock recorder for MockCacheRepository.
+type MockCacheRepositoryMockRecorder struct {
+	mock *MockCacheRepository
+}
+
+// NewMockCacheRepository creates a new mock instance.
+func NewMockCacheRepository(ctrl *gomock.Controller) *MockCacheRepository {
+	mock := &MockCacheRepository{ctrl: ctrl}
+	mock.recorder = &MockCacheRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCacheRepository) EXPECT() *MockCacheRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Reset mocks base method.
+func (m *MockCacheRepository) Reset() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Reset")
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockCacheRepositoryMockRecorder) Reset() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockCacheRepository)(nil).Reset))
+}
+
+// Stats mocks base method.
+func (m *MockCacheRepository) Stats() *entity.CacheStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(*entity.CacheStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockCacheRepositoryMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockCacheRepository)(nil).Stats))
+}
@@ -0,0 +1,87 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: decisionlog.go
+//
+// Generated by this command:
+//
+//	mockgen -package mocks -source decisionlog.go -destination mocks/decisionlog_mock.go DecisionLogRepository
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	entity "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDecisionLogRepository is a mock of DecisionLogRepository interface.
+type MockDecisionLogRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockDecisionLogRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockDecisionLogRepositoryMockRecorder is the mock recorder for MockDecisionLogRepository.
+type MockDecisionLogRepositoryMockRecorder struct {
+	mock *MockDecisionLogRepository
+}
+
+// NewMockDecisionLogRepository creates a new mock instance.
+func NewMockDecisionLogRepository(ctrl *gomock.Controller) *MockDecisionLogRepository {
+	mock := &MockDecisionLogRepository{ctrl: ctrl}
+	mock.recorder = &MockDecisionLogRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDecisionLogRepository) EXPECT() *MockDecisionLogRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Query mocks base method.
+func (m *MockDecisionLogRepository) Query(filter entity.DecisionLogFilter) []*entity.DecisionLogEntry {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", filter)
+	ret0, _ := ret[0].([]*entity.DecisionLogEntry)
+	return ret0
+}
+
+// Query indicates an expected call of Query.
+func (mr *MockDecisionLogRepositoryMockRecorder) Query(filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockDecisionLogRepository)(nil).Query), filter)
+}
+
+// RecordPacketSent mocks base method.
+func (m *MockDecisionLogRepository) RecordPacketSent(evaluationID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordPacketSent", evaluationID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordPacketSent indicates an expected call of RecordPacketSent.
+func (mr *MockDecisionLogRepositoryMockRecorder) RecordPacketSent(evaluationID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordPacketSent", reflect.TypeOf((*MockDecisionLogRepository)(nil).RecordPacketSent), evaluationID)
+}
+
+// Write mocks base method.
+func (m *MockDecisionLogRepository) Write(entries ...*entity.DecisionLogEntry) error {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range entries {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Write", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Write indicates an expected call of Write.
+func (mr *MockDecisionLogRepositoryMockRecorder) Write(entries ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockDecisionLogRepository)(nil).Write), entries...)
+}
@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: endpoint.go
+//
+// Generated by this command:
+//
+//	mockgen -package mocks -source endpoint.go -destination mocks/endpoint_mock.go EndpointHealthRepository
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	entity "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockEndpointHealthRepository is a mock of EndpointHealthRepository interface.
+type MockEndpointHealthRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockEndpointHealthRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockEndpointHealthRepositoryMockRecorder is the mock recorder for MockEndpointHealthRepository.
+type MockEndpointHealthRepositoryMockRecorder struct {
+	mock *MockEndpointHealthRepository
+}
+
+// NewMockEndpointHealthRepository creates a new mock instance.
+func NewMockEndpointHealthRepository(ctrl *gomock.Controller) *MockEndpointHealthRepository {
+	mock := &MockEndpointHealthRepository{ctrl: ctrl}
+	mock.recorder = &MockEndpointHealthRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEndpointHealthRepository) EXPECT() *MockEndpointHealthRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Health mocks base method.
+func (m *MockEndpointHealthRepository) Health(server *entity.NutServer) []*entity.EndpointHealth {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Health", server)
+	ret0, _ := ret[0].([]*entity.EndpointHealth)
+	return ret0
+}
+
+// Health indicates an expected call of Health.
+func (mr *MockEndpointHealthRepositoryMockRecorder) Health(server any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockEndpointHealthRepository)(nil).Health), server)
+}
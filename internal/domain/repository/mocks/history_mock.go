@@ -0,0 +1,73 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: history.go
+//
+// Generated by this command:
+//
+//	mockgen -package mocks -source history.go -destination mocks/history_mock.go HistoryRepository
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	entity "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockHistoryRepository is a mock of HistoryRepository interface.
+type MockHistoryRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockHistoryRepositoryMockRecorder is the mock recorder for MockHistoryRepository.
+type MockHistoryRepositoryMockRecorder struct {
+	mock *MockHistoryRepository
+}
+
+// NewMockHistoryRepository creates a new mock instance.
+func NewMockHistoryRepository(ctrl *gomock.Controller) *MockHistoryRepository {
+	mock := &MockHistoryRepository{ctrl: ctrl}
+	mock.recorder = &MockHistoryRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistoryRepository) EXPECT() *MockHistoryRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Query mocks base method.
+func (m *MockHistoryRepository) Query(filter entity.HistoryFilter) []*entity.HistorySample {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", filter)
+	ret0, _ := ret[0].([]*entity.HistorySample)
+	return ret0
+}
+
+// Query indicates an expected call of Query.
+func (mr *MockHistoryRepositoryMockRecorder) Query(filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockHistoryRepository)(nil).Query), filter)
+}
+
+// Record mocks base method.
+func (m *MockHistoryRepository) Record(samples ...*entity.HistorySample) error {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range samples {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Record", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockHistoryRepositoryMockRecorder) Record(samples ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockHistoryRepository)(nil).Record), samples...)
+}
@@ -12,6 +12,7 @@ package mocks
 import (
 	reflect "reflect"
 
+	entity "github.com/TheDarthMole/UPSWake/internal/domain/entity"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -39,11 +40,25 @@ func (m *MockRuleRepository) EXPECT() *MockRuleRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CanDeny mocks base method.
+func (m *MockRuleRepository) CanDeny(ruleName string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanDeny", ruleName)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanDeny indicates an expected call of CanDeny.
+func (mr *MockRuleRepositoryMockRecorder) CanDeny(ruleName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanDeny", reflect.TypeOf((*MockRuleRepository)(nil).CanDeny), ruleName)
+}
+
 // Evaluate mocks base method.
-func (m *MockRuleRepository) Evaluate(ruleName, inputJSON string) (bool, error) {
+func (m *MockRuleRepository) Evaluate(ruleName, inputJSON string) (*entity.RuleDecision, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Evaluate", ruleName, inputJSON)
-	ret0, _ := ret[0].(bool)
+	ret0, _ := ret[0].(*entity.RuleDecision)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -54,6 +69,49 @@ func (mr *MockRuleRepositoryMockRecorder) Evaluate(ruleName, inputJSON any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evaluate", reflect.TypeOf((*MockRuleRepository)(nil).Evaluate), ruleName, inputJSON)
 }
 
+// Explain mocks base method.
+func (m *MockRuleRepository) Explain(ruleName, inputJSON string) (*entity.RuleExplanation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Explain", ruleName, inputJSON)
+	ret0, _ := ret[0].(*entity.RuleExplanation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Explain indicates an expected call of Explain.
+func (mr *MockRuleRepositoryMockRecorder) Explain(ruleName, inputJSON any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Explain", reflect.TypeOf((*MockRuleRepository)(nil).Explain), ruleName, inputJSON)
+}
+
+// Health mocks base method.
+func (m *MockRuleRepository) Health() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Health")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Health indicates an expected call of Health.
+func (mr *MockRuleRepositoryMockRecorder) Health() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockRuleRepository)(nil).Health))
+}
+
+// Revision mocks base method.
+func (m *MockRuleRepository) Revision() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revision")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Revision indicates an expected call of Revision.
+func (mr *MockRuleRepositoryMockRecorder) Revision() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revision", reflect.TypeOf((*MockRuleRepository)(nil).Revision))
+}
+
 // RuleNames mocks base method.
 func (m *MockRuleRepository) RuleNames() []string {
 	m.ctrl.T.Helper()
@@ -54,3 +54,42 @@ func (mr *MockUPSRepositoryMockRecorder) GetJSON(server any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJSON", reflect.TypeOf((*MockUPSRepository)(nil).GetJSON), server)
 }
+
+// MockUPSVariableRepository is a mock of UPSVariableRepository interface.
+type MockUPSVariableRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockUPSVariableRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockUPSVariableRepositoryMockRecorder is the mock recorder for MockUPSVariableRepository.
+type MockUPSVariableRepositoryMockRecorder struct {
+	mock *MockUPSVariableRepository
+}
+
+// NewMockUPSVariableRepository creates a new mock instance.
+func NewMockUPSVariableRepository(ctrl *gomock.Controller) *MockUPSVariableRepository {
+	mock := &MockUPSVariableRepository{ctrl: ctrl}
+	mock.recorder = &MockUPSVariableRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUPSVariableRepository) EXPECT() *MockUPSVariableRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetVariables mocks base method.
+func (m *MockUPSVariableRepository) GetVariables(server *entity.NutServer, names []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVariables", server, names)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVariables indicates an expected call of GetVariables.
+func (mr *MockUPSVariableRepositoryMockRecorder) GetVariables(server, names any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVariables", reflect.TypeOf((*MockUPSVariableRepository)(nil).GetVariables), server, names)
+}
@@ -1,5 +1,7 @@
 package repository
 
+import "github.com/TheDarthMole/UPSWake/internal/domain/entity"
+
 //go:generate mockgen -package mocks -source rules.go -destination mocks/rules_mock.go RuleRepository
 
 // RuleRepository provides access to pre-loaded and pre-compiled Rego rules.
@@ -8,8 +10,24 @@ package repository
 type RuleRepository interface {
 	// Evaluate evaluates a named rule against the provided JSON input.
 	// The rule should already be compiled; this only runs the evaluation.
-	Evaluate(ruleName, inputJSON string) (bool, error)
+	Evaluate(ruleName, inputJSON string) (*entity.RuleDecision, error)
+
+	// Explain evaluates a named rule the same way as Evaluate, additionally
+	// returning a trace of the evaluation for debugging.
+	Explain(ruleName, inputJSON string) (*entity.RuleExplanation, error)
+
+	// CanDeny returns true if the named rule defines data.upswake.deny, and
+	// so may veto a wake. Rules that can't be found are assumed to deny.
+	CanDeny(ruleName string) bool
 
 	// RuleNames returns all available rule names.
 	RuleNames() []string
+
+	// Revision returns the revision of the bundle the rules were loaded
+	// from, or an empty string if they weren't loaded from a bundle.
+	Revision() string
+
+	// Health returns any errors encountered while loading rules, or nil if
+	// every rule is currently loaded and compiled.
+	Health() error
 }
@@ -8,6 +8,17 @@ import "github.com/TheDarthMole/UPSWake/internal/domain/entity"
 // Implementations can cache results per evaluation cycle to avoid
 // redundant connections when multiple targets share a NUT server.
 type UPSRepository interface {
-	// GetJSON returns the JSON status for a NUT server.
+	// GetJSON returns the JSON status for a NUT server. Alongside an
+	// *entity.StaleError, it returns the UPSes last read from the NUT
+	// server.
 	GetJSON(server *entity.NutServer) (string, error)
 }
+
+// UPSVariableRepository reads only some of the variables of a NUT server's
+// UPSes, for callers that poll too often to read everything.
+type UPSVariableRepository interface {
+	// GetVariables returns the UPSes of a NUT server as JSON, in the same
+	// shape as GetJSON, with only the named variables they have. Sources
+	// that can't read single variables return every variable.
+	GetVariables(server *entity.NutServer, names []string) (string, error)
+}
@@ -1,91 +1,521 @@
 package evaluator
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
 	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
 )
 
-var ErrFailedEvaluateExpression = errors.New("could not evaluate expression")
+var (
+	ErrFailedEvaluateExpression = errors.New("could not evaluate expression")
+	ErrNutServerUnavailable     = errors.New("could not read NUT server")
+)
 
 type RegoEvaluator struct {
-	config   *entity.Config
-	ruleRepo repository.RuleRepository
-	upsRepo  repository.UPSRepository
-	mac      *entity.MacAddress
+	config      *entity.Config
+	ruleRepo    repository.RuleRepository
+	upsRepo     repository.UPSRepository
+	decisionLog repository.DecisionLogRepository
+	mac         *entity.MacAddress
+	inputs      *InputBuilder
+}
+
+// Option configures optional behaviour of a RegoEvaluator.
+type Option func(*RegoEvaluator)
+
+// WithInputBuilder builds versioned rule input with inputs, sharing its
+// UPS status and wake history with other evaluations.
+func WithInputBuilder(inputs *InputBuilder) Option {
+	return func(r *RegoEvaluator) {
+		r.inputs = inputs
+	}
+}
+
+// WithDecisionLog writes the decision of every rule evaluated by
+// EvaluateExpressions to decisionLog.
+func WithDecisionLog(decisionLog repository.DecisionLogRepository) Option {
+	return func(r *RegoEvaluator) {
+		r.decisionLog = decisionLog
+	}
 }
 
 type EvaluationResult struct {
+	// Target is the first matching target that allowed the wake, or the
+	// last one evaluated if none did
+	Target *entity.TargetServer
+	// EvaluationID identifies the evaluation's entries in the decision log
+	EvaluationID string
+	// Decisions holds the decision of every rule that was evaluated, in order
+	Decisions []*entity.RuleDecision
+	// Mode is how Target's decisions were combined, e.g. any or quorum:2
+	Mode string
+	// Unavailable are the NUT servers that couldn't be read
+	Unavailable []*UnavailableSource
+	Allowed     bool
+	Found       bool
+}
+
+// Explanation is the outcome of explaining a wake evaluation. Unlike an
+// EvaluationResult, every rule is evaluated even after one allows the wake.
+type Explanation struct {
 	Target  *entity.TargetServer
-	Allowed bool
-	Found   bool
+	Servers []*ServerExplanation
+	// Unavailable are the NUT servers that couldn't be read
+	Unavailable []*UnavailableSource
+	Allowed     bool
+	Found       bool
+}
+
+// UnavailableSource is a NUT server that couldn't be read during an
+// evaluation that went ahead without it.
+type UnavailableSource struct {
+	NutServer string `json:"nut_server" example:"raspberrypi"`
+	// Error is why the NUT server couldn't be read, without its credentials
+	Error string `json:"error" example:"dial tcp 192.168.1.133:3493: connect: connection refused"`
+	// SkippedTargets are the targets using legacy input, whose rules
+	// weren't evaluated as they have nothing to evaluate without the NUT
+	// server's UPSes
+	SkippedTargets []string `json:"skipped_targets,omitempty" example:"MyNAS"`
+}
+
+// ServerExplanation explains how a target's rules evaluated against the
+// input from a single NUT server.
+type ServerExplanation struct {
+	NutServer string `json:"nut_server" example:"raspberrypi"`
+	Target    string `json:"target" example:"MyNAS"`
+	RuleMode  string `json:"rule_mode" example:"any"`
+	// Input is the JSON document the rules were evaluated against
+	Input   json.RawMessage           `json:"input" swaggertype:"array,object"`
+	Rules   []*entity.RuleExplanation `json:"rules"`
+	Allowed bool                      `json:"allowed" example:"false"`
+}
+
+// Reason summarises why the target was or wasn't woken. When the target is
+// allowed to wake, only the reasons from rules that allowed it are included.
+func (e *EvaluationResult) Reason() string {
+	var reasons []string
+	for _, decision := range e.Decisions {
+		if decision.Reason == "" || (e.Allowed && !decision.Allowed) {
+			continue
+		}
+		reasons = append(reasons, decision.Reason)
+	}
+	return strings.Join(reasons, "; ")
 }
 
 // NewRegoEvaluator creates a RegoEvaluator configured with the provided configuration, MAC address,
 // UPS repository and rule repository.
 // The returned evaluator uses the MAC to select matching targets, upsRepo to fetch per-server JSON
 // input and ruleRepo to evaluate rules against that input.
-func NewRegoEvaluator(config *entity.Config, mac *entity.MacAddress, upsRepo repository.UPSRepository, ruleRepo repository.RuleRepository) *RegoEvaluator {
-	return &RegoEvaluator{
+// Without WithInputBuilder, targets using versioned input have no status or wake history.
+func NewRegoEvaluator(config *entity.Config, mac *entity.MacAddress, upsRepo repository.UPSRepository, ruleRepo repository.RuleRepository, opts ...Option) *RegoEvaluator {
+	r := &RegoEvaluator{
 		config:   config,
 		mac:      mac,
 		upsRepo:  upsRepo,
 		ruleRepo: ruleRepo,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
+// EvaluateExpressions evaluates the rules of every target matching the
+// evaluator's MAC address. With WithDecisionLog, the decision of each rule
+// that was evaluated is written to the decision log, even if the evaluation
+// fails. Whether a Wake on LAN packet is sent as a result is recorded
+// separately, with the result's EvaluationID.
 func (r *RegoEvaluator) EvaluateExpressions() (*EvaluationResult, error) {
 	// For each NUT server
 	evaluationResult := &EvaluationResult{
-		Allowed: false,
-		Found:   false,
-		Target:  nil,
+		Allowed:      false,
+		Found:        false,
+		Target:       nil,
+		EvaluationID: newEvaluationID(),
 	}
+	var entries []*entity.DecisionLogEntry
 
-	for _, nutServer := range r.config.NutServers {
-		inputJSON, err := r.upsRepo.GetJSON(nutServer)
+	unavailable, err := r.forEachTarget(func(nutServer *entity.NutServer, target *entity.TargetServer, inputJSON string) error {
+		allowed, timed, err := r.evaluateRules(target, inputJSON)
+		entries = append(entries, r.decisionLogEntries(evaluationResult.EvaluationID, nutServer, target, inputJSON, timed)...)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		decisions := ruleDecisions(timed)
 
-		// For each target
-		for _, target := range nutServer.Targets {
-			if target.MacAddress == nil || r.mac == nil {
-				return nil, fmt.Errorf("error comparing mac addresses :%w", entity.ErrMACRequired)
+		evaluationResult.Decisions = append(evaluationResult.Decisions, decisions...)
+		evaluationResult.Found = true
+		// once a target has allowed the wake, later targets can't change
+		// which target decided it
+		if !evaluationResult.Allowed {
+			evaluationResult.Mode = target.RuleMode.String()
+			evaluationResult.Target = target
+		}
+		evaluationResult.Allowed = evaluationResult.Allowed || allowed
+		return nil
+	})
+	if r.decisionLog != nil && len(entries) > 0 {
+		if logErr := r.decisionLog.Write(entries...); logErr != nil {
+			slog.Error("Failed to write decision log",
+				slog.String("evaluation_id", evaluationResult.EvaluationID),
+				slog.Any("error", logErr))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	evaluationResult.Unavailable = unavailable
+	return evaluationResult, nil
+}
+
+// decisionLogEntries returns a decision log entry for each of decisions,
+// made by target's rules against inputJSON from nutServer, or nil if the
+// evaluator has no decision log.
+func (r *RegoEvaluator) decisionLogEntries(evaluationID string, nutServer *entity.NutServer, target *entity.TargetServer, inputJSON string, decisions []*timedDecision) []*entity.DecisionLogEntry {
+	if r.decisionLog == nil {
+		return nil
+	}
+
+	hash := sha256.Sum256([]byte(inputJSON))
+	var input json.RawMessage
+	if r.config.DecisionLog != nil && r.config.DecisionLog.IncludeInput {
+		input = json.RawMessage(inputJSON)
+	}
+
+	entries := make([]*entity.DecisionLogEntry, 0, len(decisions))
+	for _, decision := range decisions {
+		entry := &entity.DecisionLogEntry{
+			Time:         decision.at,
+			EvaluationID: evaluationID,
+			MAC:          target.MAC,
+			Target:       target.Name,
+			NutServer:    nutServer.Name,
+			Rule:         decision.rule,
+			RuleMode:     target.RuleMode.String(),
+			InputHash:    hex.EncodeToString(hash[:]),
+			Input:        input,
+			Latency:      decision.latency,
+		}
+		if decision.err != nil {
+			entry.Error = decision.err.Error()
+		} else {
+			entry.Details = decision.Details
+			entry.Reason = decision.Reason
+			entry.Allowed = decision.Allowed
+			entry.Denied = decision.Denied
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// newEvaluationID returns a random ID grouping the decision log entries of
+// a single evaluation.
+func newEvaluationID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ExplainExpressions follows the same path as EvaluateExpressions, but
+// evaluates every rule with tracing enabled and returns the input document
+// each rule was evaluated against.
+func (r *RegoEvaluator) ExplainExpressions() (*Explanation, error) {
+	explanation := &Explanation{}
+
+	unavailable, err := r.forEachTarget(func(nutServer *entity.NutServer, target *entity.TargetServer, inputJSON string) error {
+		server := &ServerExplanation{
+			NutServer: nutServer.Name,
+			Target:    target.Name,
+			RuleMode:  target.RuleMode.String(),
+			Input:     json.RawMessage(inputJSON),
+		}
+		decisions := make([]*entity.RuleDecision, 0, len(target.Rules))
+		for _, ruleName := range target.Rules {
+			ruleExplanation, err := r.ruleRepo.Explain(ruleName, inputJSON)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrFailedEvaluateExpression, err)
+			}
+			server.Rules = append(server.Rules, ruleExplanation)
+			decisions = append(decisions, ruleExplanation.Decision)
+		}
+		server.Allowed = combine(target.RuleMode, decisions)
+
+		explanation.Servers = append(explanation.Servers, server)
+		explanation.Found = true
+		explanation.Allowed = explanation.Allowed || server.Allowed
+		explanation.Target = target
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	explanation.Unavailable = unavailable
+	return explanation, nil
+}
+
+// forEachTarget reads each NUT server with a target matching the
+// evaluator's MAC address, and calls fn for every one of those targets with
+// the input in the version the target's rules expect. A NUT server that
+// can't be read doesn't stop the others being evaluated: targets using
+// versioned input are evaluated with the NUT server marked unavailable in
+// their input, and targets using legacy input are skipped. The NUT servers
+// that couldn't be read are returned, along with the targets skipped
+// because of them, unless no target could be evaluated at all, in which
+// case their errors are returned instead.
+func (r *RegoEvaluator) forEachTarget(fn func(nutServer *entity.NutServer, target *entity.TargetServer, inputJSON string) error) ([]*UnavailableSource, error) {
+	sources, err := r.readSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	var unavailable []*UnavailableSource
+	evaluated := false
+	for _, source := range sources {
+		var skipped *UnavailableSource
+		if source.err != nil {
+			errs = append(errs, fmt.Errorf("%w %s: %w", ErrNutServerUnavailable, source.nutServer.Name, source.err))
+			skipped = &UnavailableSource{
+				NutServer: source.nutServer.Name,
+				Error:     source.nutServer.Redact(source.err.Error()),
 			}
-			if target.MAC != r.mac.MAC {
+			unavailable = append(unavailable, skipped)
+		}
+		for _, target := range source.targets {
+			if source.err != nil && r.config.InputVersion(target) == entity.InputVersionLegacy {
+				skipped.SkippedTargets = append(skipped.SkippedTargets, target.Name)
 				continue
 			}
-			allowed, err := r.evaluateExpression(target, inputJSON)
+			ruleInput, err := r.ruleInput(source, target)
 			if err != nil {
 				return nil, err
 			}
+			evaluated = true
+			if err = fn(source.nutServer, target, ruleInput); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !evaluated {
+		return nil, errors.Join(errs...)
+	}
+	return unavailable, nil
+}
 
-			evaluationResult.Found = true
-			evaluationResult.Allowed = evaluationResult.Allowed || allowed
-			evaluationResult.Target = target
+// source is a NUT server with targets matching the evaluator's MAC address,
+// and the result of reading it. Stale is set if reading it failed but the
+// UPSes last read were served instead.
+type source struct {
+	err       error
+	stale     *entity.StaleError
+	nutServer *entity.NutServer
+	inputJSON string
+	targets   []*entity.TargetServer
+}
+
+// readSources reads every NUT server with a target matching the evaluator's
+// MAC address. NUT servers without one aren't read, so they can't affect
+// the evaluation. Every read is recorded before any input is built, so the
+// input for each target knows which of the other NUT servers were read.
+func (r *RegoEvaluator) readSources() ([]*source, error) {
+	var sources []*source
+	for _, nutServer := range r.config.NutServers {
+		var targets []*entity.TargetServer
+		for _, target := range nutServer.Targets {
+			if target.MacAddress == nil || r.mac == nil {
+				return nil, fmt.Errorf("error comparing mac addresses :%w", entity.ErrMACRequired)
+			}
+			if target.MAC == r.mac.MAC {
+				targets = append(targets, target)
+			}
 		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		inputJSON, err := r.upsRepo.GetJSON(nutServer)
+		var stale *entity.StaleError
+		if errors.As(err, &stale) {
+			// the UPSes last read are still evaluated, and marked stale
+			err = nil
+		}
+		sources = append(sources, &source{
+			nutServer: nutServer,
+			targets:   targets,
+			inputJSON: inputJSON,
+			stale:     stale,
+			err:       err,
+		})
 	}
 
-	return evaluationResult, nil
+	for _, source := range sources {
+		if !r.versionedInput(source.targets) {
+			continue
+		}
+		switch {
+		case source.err != nil:
+			r.inputBuilder().RecordFailure(source.nutServer.Name, source.err)
+		case source.stale != nil:
+			r.inputBuilder().RecordStale(source.nutServer.Name, source.inputJSON, source.stale)
+		default:
+			r.inputBuilder().RecordRead(source.nutServer.Name, source.inputJSON)
+		}
+	}
+	return sources, nil
+}
+
+// versionedInput returns true if any of targets has rules using versioned
+// input.
+func (r *RegoEvaluator) versionedInput(targets []*entity.TargetServer) bool {
+	for _, target := range targets {
+		if r.config.InputVersion(target) != entity.InputVersionLegacy {
+			return true
+		}
+	}
+	return false
 }
 
-func (r *RegoEvaluator) evaluateExpression(target *entity.TargetServer, inputJSON string) (bool, error) {
+// ruleInput returns the input document for target's rules, which is the
+// UPS JSON itself for targets using the legacy input.
+func (r *RegoEvaluator) ruleInput(source *source, target *entity.TargetServer) (string, error) {
+	version := r.config.InputVersion(target)
+	if version == entity.InputVersionLegacy {
+		return source.inputJSON, nil
+	}
+	if source.err != nil {
+		return r.inputBuilder().BuildUnavailable(version, r.config.Location(), source.nutServer, target)
+	}
+	return r.inputBuilder().Build(version, r.config.Location(), source.nutServer, target, source.inputJSON)
+}
+
+func (r *RegoEvaluator) inputBuilder() *InputBuilder {
+	if r.inputs == nil {
+		r.inputs = NewInputBuilder()
+	}
+	return r.inputs
+}
+
+// timedDecision is a rule's decision along with when and how long it took
+// to make, for the decision log. If the rule failed to evaluate, err is set
+// and RuleDecision is nil.
+type timedDecision struct {
+	*entity.RuleDecision
+	at      time.Time
+	err     error
+	rule    string
+	latency time.Duration
+}
+
+// evaluateRules evaluates the target's rules in order and combines their
+// decisions according to the target's rule mode. Evaluation stops as soon as
+// a rule settles the outcome: for first-match, the first rule that allows or
+// denies the wake, and for any, the first rule that denies it or that allows
+// it with no later rule able to deny it.
+// It returns the decision of every rule that was evaluated, along with the
+// time it was evaluated at and its latency. If a rule fails to evaluate, the
+// rules evaluated so far and the failed rule are returned along with the
+// error.
+func (r *RegoEvaluator) evaluateRules(target *entity.TargetServer, inputJSON string) (bool, []*timedDecision, error) {
 	if target == nil {
-		return false, nil
+		return false, nil, nil
 	}
 
-	for _, ruleName := range target.Rules {
-		allowed, err := r.ruleRepo.Evaluate(ruleName, inputJSON)
+	var timed []*timedDecision
+	for i, ruleName := range target.Rules {
+		start := time.Now()
+		decision, err := r.ruleRepo.Evaluate(ruleName, inputJSON)
+		result := &timedDecision{
+			RuleDecision: decision,
+			at:           start,
+			rule:         ruleName,
+			latency:      time.Since(start),
+		}
 		if err != nil {
-			return false, fmt.Errorf("%w: %w", ErrFailedEvaluateExpression, err)
+			result.err = err
+			result.RuleDecision = nil
+			return false, append(timed, result), fmt.Errorf("%w: %w", ErrFailedEvaluateExpression, err)
 		}
 
-		if allowed {
-			return true, nil
+		timed = append(timed, result)
+		if r.settles(target.RuleMode, decision, target.Rules[i+1:]) {
+			break
+		}
+	}
+	return combine(target.RuleMode, ruleDecisions(timed)), timed, nil
+}
+
+func ruleDecisions(timed []*timedDecision) []*entity.RuleDecision {
+	decisions := make([]*entity.RuleDecision, 0, len(timed))
+	for _, decision := range timed {
+		if decision.RuleDecision != nil {
+			decisions = append(decisions, decision.RuleDecision)
 		}
 	}
-	return false, nil
+	return decisions
+}
+
+// settles returns true if none of the remaining rules can change the
+// outcome of mode once decision has been made. A rule that denies settles
+// every mode but all and quorum, and in the any mode a rule that allows only
+// settles it once no remaining rule can deny the wake.
+func (r *RegoEvaluator) settles(mode entity.RuleMode, decision *entity.RuleDecision, remaining []string) bool {
+	switch mode.Kind() {
+	case entity.RuleModeAny:
+		return decision.Denied || (decision.Allowed && !slices.ContainsFunc(remaining, r.ruleRepo.CanDeny))
+	case entity.RuleModeFirstMatch:
+		return decision.Allowed || decision.Denied
+	default:
+		// every rule is evaluated for all and quorum, so that the outcome
+		// of each one is reported
+		return false
+	}
+}
+
+// combine returns whether decisions, made in rule order, wake the target
+// under mode. A rule that denies blocks the wake in every mode; for
+// first-match, only rules up to the first that allows or denies count.
+func combine(mode entity.RuleMode, decisions []*entity.RuleDecision) bool {
+	if mode.Kind() == entity.RuleModeFirstMatch {
+		if i := slices.IndexFunc(decisions, func(decision *entity.RuleDecision) bool {
+			return decision.Allowed || decision.Denied
+		}); i >= 0 {
+			decisions = decisions[:i+1]
+		}
+	}
+
+	allowed, denied := 0, false
+	for _, decision := range decisions {
+		if decision.Allowed {
+			allowed++
+		}
+		denied = denied || decision.Denied
+	}
+	if denied {
+		return false
+	}
+
+	switch mode.Kind() {
+	case entity.RuleModeAny, entity.RuleModeFirstMatch:
+		return allowed > 0
+	case entity.RuleModeAll:
+		return len(decisions) > 0 && allowed == len(decisions)
+	case entity.RuleModeQuorum:
+		return allowed >= mode.Quorum() && mode.Quorum() > 0
+	default:
+		return false
+	}
 }
@@ -9,7 +9,9 @@ import (
 	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
 	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
 	"github.com/TheDarthMole/UPSWake/internal/infrastructure/rules"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
@@ -28,6 +30,14 @@ type ruleRepository struct {
 	allowed bool
 }
 
+// decision returns the decision the mocked rule repository should return.
+func (r ruleRepository) decision() *entity.RuleDecision {
+	if r.err != nil {
+		return nil
+	}
+	return &entity.RuleDecision{Rule: "test.rego", Allowed: r.allowed}
+}
+
 func TestNewRegoEvaluator(t *testing.T) {
 	type args struct {
 		config   *entity.Config
@@ -88,7 +98,7 @@ func TestNewRegoEvaluator(t *testing.T) {
 	}
 }
 
-func TestRegoEvaluator_evaluateExpression(t *testing.T) {
+func TestRegoEvaluator_evaluateRules(t *testing.T) {
 	type fields struct {
 		ruleRepo ruleRepository
 	}
@@ -97,11 +107,12 @@ func TestRegoEvaluator_evaluateExpression(t *testing.T) {
 		inputJSON string
 	}
 	tests := []struct {
-		wantErr error
-		args    args
-		name    string
-		fields  fields
-		want    bool
+		wantErr       error
+		args          args
+		name          string
+		fields        fields
+		wantDecisions int
+		want          bool
 	}{
 		{
 			name: "nothing to evaluate",
@@ -131,8 +142,9 @@ func TestRegoEvaluator_evaluateExpression(t *testing.T) {
 					allowed: true,
 				},
 			},
-			want:    true,
-			wantErr: nil,
+			want:          true,
+			wantDecisions: 1,
+			wantErr:       nil,
 		},
 		{
 			name: "evaluate with always false rule",
@@ -150,8 +162,30 @@ func TestRegoEvaluator_evaluateExpression(t *testing.T) {
 					allowed: false,
 				},
 			},
-			want:    false,
-			wantErr: nil,
+			want:          false,
+			wantDecisions: 1,
+			wantErr:       nil,
+		},
+		{
+			name: "stops at the first true rule if no later rule can deny",
+			args: args{
+				target: &entity.TargetServer{
+					Rules: []string{
+						"alwaysTrue.rego",
+						"neverEvaluated.rego",
+					},
+				},
+				inputJSON: validNUTOutput,
+			},
+			fields: fields{
+				ruleRepo: ruleRepository{
+					times:   1,
+					allowed: true,
+				},
+			},
+			want:          true,
+			wantDecisions: 1,
+			wantErr:       nil,
 		},
 		{
 			name: "file not found",
@@ -178,15 +212,181 @@ func TestRegoEvaluator_evaluateExpression(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mock := gomock.NewController(t)
 			ruleRepo := mocks.NewMockRuleRepository(mock)
-			ruleRepo.EXPECT().Evaluate(gomock.Any(), gomock.Any()).Return(tt.fields.ruleRepo.allowed, tt.fields.ruleRepo.err).Times(tt.fields.ruleRepo.times)
+			ruleRepo.EXPECT().Evaluate(gomock.Any(), gomock.Any()).Return(tt.fields.ruleRepo.decision(), tt.fields.ruleRepo.err).Times(tt.fields.ruleRepo.times)
+			ruleRepo.EXPECT().CanDeny(gomock.Any()).Return(false).AnyTimes()
 
 			r := &RegoEvaluator{
 				ruleRepo: ruleRepo,
 			}
-			got, err := r.evaluateExpression(tt.args.target, tt.args.inputJSON)
+			got, timed, err := r.evaluateRules(tt.args.target, tt.args.inputJSON)
 
 			assert.ErrorIs(t, err, tt.wantErr)
 			assert.Equal(t, tt.want, got)
+			assert.Len(t, ruleDecisions(timed), tt.wantDecisions)
+		})
+	}
+}
+
+func TestRegoEvaluator_denyBlocksEveryMode(t *testing.T) {
+	decisions := map[string]*entity.RuleDecision{
+		"allow.rego": {Rule: "allow.rego", Allowed: true},
+		"deny.rego":  {Rule: "deny.rego", Denied: true},
+	}
+
+	tests := []struct {
+		name          string
+		mode          entity.RuleMode
+		wantEvaluated []string
+	}{
+		{name: "any", mode: entity.RuleModeAny, wantEvaluated: []string{"allow.rego", "deny.rego"}},
+		{name: "all", mode: entity.RuleModeAll, wantEvaluated: []string{"allow.rego", "deny.rego"}},
+		{name: "quorum", mode: "quorum:1", wantEvaluated: []string{"allow.rego", "deny.rego"}},
+		{name: "first-match", mode: entity.RuleModeFirstMatch, wantEvaluated: []string{"deny.rego"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := gomock.NewController(t)
+			ruleRepo := mocks.NewMockRuleRepository(mock)
+			ruleRepo.EXPECT().Evaluate(gomock.Any(), gomock.Any()).DoAndReturn(func(ruleName, _ string) (*entity.RuleDecision, error) {
+				return decisions[ruleName], nil
+			}).AnyTimes()
+			ruleRepo.EXPECT().CanDeny(gomock.Any()).DoAndReturn(func(ruleName string) bool {
+				return decisions[ruleName].Denied
+			}).AnyTimes()
+
+			rules := []string{"allow.rego", "deny.rego"}
+			if tt.mode == entity.RuleModeFirstMatch {
+				// the first rule to allow or deny decides first-match
+				rules = []string{"deny.rego", "allow.rego"}
+			}
+
+			r := &RegoEvaluator{ruleRepo: ruleRepo}
+			got, evaluated, err := r.evaluateRules(&entity.TargetServer{Rules: rules, RuleMode: tt.mode}, validNUTOutput)
+			require.NoError(t, err)
+			assert.False(t, got, "a rule that denies must block the wake")
+
+			evaluatedRules := make([]string, len(evaluated))
+			for i, decision := range evaluated {
+				evaluatedRules[i] = decision.rule
+			}
+			assert.Equal(t, tt.wantEvaluated, evaluatedRules)
+		})
+	}
+}
+
+func TestRegoEvaluator_ruleModes(t *testing.T) {
+	decisions := map[string]*entity.RuleDecision{
+		"allow.rego":   {Rule: "allow.rego", Allowed: true},
+		"allow2.rego":  {Rule: "allow2.rego", Allowed: true},
+		"abstain.rego": {Rule: "abstain.rego"},
+		"deny.rego":    {Rule: "deny.rego", Denied: true},
+	}
+
+	tests := []struct {
+		name          string
+		mode          entity.RuleMode
+		rules         []string
+		wantEvaluated []string
+		want          bool
+	}{
+		{
+			name:          "default mode is any",
+			rules:         []string{"abstain.rego", "allow.rego", "allow2.rego"},
+			wantEvaluated: []string{"abstain.rego", "allow.rego"},
+			want:          true,
+		},
+		{
+			name:          "any evaluates later rules that can deny",
+			mode:          entity.RuleModeAny,
+			rules:         []string{"allow.rego", "abstain.rego", "deny.rego"},
+			wantEvaluated: []string{"allow.rego", "abstain.rego", "deny.rego"},
+			want:          false,
+		},
+		{
+			name:          "any with no rule allowing",
+			mode:          entity.RuleModeAny,
+			rules:         []string{"abstain.rego", "deny.rego"},
+			wantEvaluated: []string{"abstain.rego", "deny.rego"},
+			want:          false,
+		},
+		{
+			name:          "all allowing",
+			mode:          entity.RuleModeAll,
+			rules:         []string{"allow.rego", "allow2.rego"},
+			wantEvaluated: []string{"allow.rego", "allow2.rego"},
+			want:          true,
+		},
+		{
+			name:          "all evaluates every rule",
+			mode:          entity.RuleModeAll,
+			rules:         []string{"abstain.rego", "allow.rego"},
+			wantEvaluated: []string{"abstain.rego", "allow.rego"},
+			want:          false,
+		},
+		{
+			name:          "quorum met",
+			mode:          "quorum:2",
+			rules:         []string{"allow.rego", "abstain.rego", "allow2.rego"},
+			wantEvaluated: []string{"allow.rego", "abstain.rego", "allow2.rego"},
+			want:          true,
+		},
+		{
+			name:          "quorum not met",
+			mode:          "quorum:2",
+			rules:         []string{"allow.rego", "abstain.rego"},
+			wantEvaluated: []string{"allow.rego", "abstain.rego"},
+			want:          false,
+		},
+		{
+			name:          "quorum vetoed by deny",
+			mode:          "quorum:2",
+			rules:         []string{"allow.rego", "allow2.rego", "deny.rego"},
+			wantEvaluated: []string{"allow.rego", "allow2.rego", "deny.rego"},
+			want:          false,
+		},
+		{
+			name:          "first-match deny before allow",
+			mode:          entity.RuleModeFirstMatch,
+			rules:         []string{"abstain.rego", "deny.rego", "allow.rego"},
+			wantEvaluated: []string{"abstain.rego", "deny.rego"},
+			want:          false,
+		},
+		{
+			name:          "first-match allow before deny",
+			mode:          entity.RuleModeFirstMatch,
+			rules:         []string{"abstain.rego", "allow.rego", "deny.rego"},
+			wantEvaluated: []string{"abstain.rego", "allow.rego"},
+			want:          true,
+		},
+		{
+			name:          "first-match without a match",
+			mode:          entity.RuleModeFirstMatch,
+			rules:         []string{"abstain.rego"},
+			wantEvaluated: []string{"abstain.rego"},
+			want:          false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := gomock.NewController(t)
+			ruleRepo := mocks.NewMockRuleRepository(mock)
+			ruleRepo.EXPECT().Evaluate(gomock.Any(), gomock.Any()).DoAndReturn(func(ruleName, _ string) (*entity.RuleDecision, error) {
+				return decisions[ruleName], nil
+			}).AnyTimes()
+			ruleRepo.EXPECT().CanDeny(gomock.Any()).DoAndReturn(func(ruleName string) bool {
+				return decisions[ruleName].Denied
+			}).AnyTimes()
+
+			r := &RegoEvaluator{ruleRepo: ruleRepo}
+			got, evaluated, err := r.evaluateRules(&entity.TargetServer{Rules: tt.rules, RuleMode: tt.mode}, validNUTOutput)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, got)
+			evaluatedRules := make([]string, len(evaluated))
+			for i, decision := range evaluated {
+				evaluatedRules[i] = decision.rule
+			}
+			assert.Equal(t, tt.wantEvaluated, evaluatedRules)
 		})
 	}
 }
@@ -244,8 +444,10 @@ func TestRegoEvaluator_evaluateExpressions(t *testing.T) {
 				mac: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
 			},
 			want: &EvaluationResult{
-				Allowed: true,
-				Found:   true,
+				Allowed:   true,
+				Found:     true,
+				Mode:      "any",
+				Decisions: []*entity.RuleDecision{{Rule: "test.rego", Allowed: true}},
 				Target: &entity.TargetServer{
 					Name:       "test server",
 					MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
@@ -286,7 +488,7 @@ func TestRegoEvaluator_evaluateExpressions(t *testing.T) {
 					},
 				},
 				upsRepo: upsRepository{
-					times: 1,
+					times: 0,
 					json:  validNUTOutput,
 				},
 				rulesRepo: ruleRepository{times: 0},
@@ -326,7 +528,7 @@ func TestRegoEvaluator_evaluateExpressions(t *testing.T) {
 					},
 				},
 				upsRepo: upsRepository{
-					times: 1,
+					times: 0,
 					json:  validNUTOutput,
 				},
 				rulesRepo: ruleRepository{times: 0},
@@ -380,7 +582,7 @@ func TestRegoEvaluator_evaluateExpressions(t *testing.T) {
 			upsRepo.EXPECT().GetJSON(gomock.Any()).Return(tt.fields.upsRepo.json, tt.fields.upsRepo.err).Times(tt.fields.upsRepo.times)
 
 			ruleRepo := mocks.NewMockRuleRepository(mock)
-			ruleRepo.EXPECT().Evaluate(gomock.Any(), gomock.Any()).Return(tt.fields.rulesRepo.allowed, tt.fields.rulesRepo.err).Times(tt.fields.rulesRepo.times)
+			ruleRepo.EXPECT().Evaluate(gomock.Any(), gomock.Any()).Return(tt.fields.rulesRepo.decision(), tt.fields.rulesRepo.err).Times(tt.fields.rulesRepo.times)
 
 			r := &RegoEvaluator{
 				config:   tt.fields.config,
@@ -390,7 +592,518 @@ func TestRegoEvaluator_evaluateExpressions(t *testing.T) {
 			}
 			got, err := r.EvaluateExpressions()
 			assert.ErrorIs(t, err, tt.wantErr)
+			if got != nil {
+				assert.NotEmpty(t, got.EvaluationID)
+				got.EvaluationID = ""
+			}
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestRegoEvaluator_evaluateExpressions_decidingTarget(t *testing.T) {
+	mac := &entity.MacAddress{MAC: "00:11:22:33:44:55"}
+	decisions := map[string]*entity.RuleDecision{
+		"allow.rego": {Rule: "allow.rego", Allowed: true},
+		"deny.rego":  {Rule: "deny.rego", Denied: true},
+	}
+	newTarget := func(name, rule string, mode entity.RuleMode) *entity.TargetServer {
+		return &entity.TargetServer{
+			Name:       name,
+			MacAddress: mac,
+			Broadcast:  "192.168.1.255",
+			Rules:      []string{rule},
+			RuleMode:   mode,
+		}
+	}
+
+	tests := []struct {
+		targets    []*entity.TargetServer
+		name       string
+		wantTarget string
+		wantMode   string
+		want       bool
+	}{
+		{
+			name: "first target allows",
+			targets: []*entity.TargetServer{
+				newTarget("allows", "allow.rego", entity.RuleModeAll),
+				newTarget("denies", "deny.rego", entity.RuleModeFirstMatch),
+			},
+			wantTarget: "allows",
+			wantMode:   "all",
+			want:       true,
+		},
+		{
+			name: "last target allows",
+			targets: []*entity.TargetServer{
+				newTarget("denies", "deny.rego", entity.RuleModeFirstMatch),
+				newTarget("allows", "allow.rego", entity.RuleModeAll),
+			},
+			wantTarget: "allows",
+			wantMode:   "all",
+			want:       true,
+		},
+		{
+			name: "no target allows",
+			targets: []*entity.TargetServer{
+				newTarget("denies", "deny.rego", entity.RuleModeAll),
+				newTarget("also denies", "deny.rego", entity.RuleModeFirstMatch),
+			},
+			wantTarget: "also denies",
+			wantMode:   "first-match",
+			want:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := gomock.NewController(t)
+			upsRepo := mocks.NewMockUPSRepository(mock)
+			upsRepo.EXPECT().GetJSON(gomock.Any()).Return(validNUTOutput, nil)
+			ruleRepo := mocks.NewMockRuleRepository(mock)
+			ruleRepo.EXPECT().Evaluate(gomock.Any(), gomock.Any()).DoAndReturn(func(ruleName, _ string) (*entity.RuleDecision, error) {
+				return decisions[ruleName], nil
+			}).Times(len(tt.targets))
+
+			config := &entity.Config{
+				NutServers: []*entity.NutServer{{Name: "raspberrypi", Targets: tt.targets}},
+			}
+			got, err := NewRegoEvaluator(config, mac, upsRepo, ruleRepo).EvaluateExpressions()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, got.Allowed)
+			assert.Equal(t, tt.wantTarget, got.Target.Name)
+			assert.Equal(t, tt.wantMode, got.Mode)
+			assert.Len(t, got.Decisions, len(tt.targets))
+		})
+	}
+}
+
+func TestEvaluationResult_Reason(t *testing.T) {
+	tests := []struct {
+		result *EvaluationResult
+		name   string
+		want   string
+	}{
+		{
+			name:   "no decisions",
+			result: &EvaluationResult{},
+			want:   "",
+		},
+		{
+			name: "not allowed joins every reason",
+			result: &EvaluationResult{
+				Decisions: []*entity.RuleDecision{
+					{Rule: "a.rego", Reason: "battery.charge 62 < 80 on cyberpower900"},
+					{Rule: "b.rego"},
+					{Rule: "c.rego", Reason: "ups.status is OB"},
+				},
+			},
+			want: "battery.charge 62 < 80 on cyberpower900; ups.status is OB",
+		},
+		{
+			name: "allowed only includes reasons from allowing rules",
+			result: &EvaluationResult{
+				Allowed: true,
+				Decisions: []*entity.RuleDecision{
+					{Rule: "a.rego", Reason: "battery.charge 62 < 80 on cyberpower900"},
+					{Rule: "b.rego", Allowed: true, Reason: "ups.status is OL"},
+				},
+			},
+			want: "ups.status is OL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.result.Reason())
+		})
+	}
+}
+
+func TestRegoEvaluator_ExplainExpressions(t *testing.T) {
+	regoFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(regoFs, "alwaysTrue.rego", []byte("package upswake\ndefault wake := true"), 0o644))
+	require.NoError(t, afero.WriteFile(regoFs, "charge.rego", []byte(`package upswake
+default wake := false
+wake if input[0].Variables[0].Value < 50`), 0o644))
+
+	ruleRepo, err := rules.NewPreparedRepository(regoFs)
+	require.NoError(t, err)
+
+	target := &entity.TargetServer{
+		Name:       "test server",
+		MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+		Broadcast:  "192.168.1.255",
+		Port:       entity.DefaultWoLPort,
+		Interval:   15 * time.Minute,
+		Rules:      []string{"alwaysTrue.rego", "charge.rego"},
+	}
+	config := &entity.Config{
+		NutServers: []*entity.NutServer{
+			{
+				Name:    "test",
+				Port:    entity.DefaultNUTServerPort,
+				Targets: []*entity.TargetServer{target},
+			},
+		},
+	}
+
+	t.Run("every rule is explained", func(t *testing.T) {
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(gomock.Any()).Return(validNUTOutput, nil).Times(1)
+
+		got, err := NewRegoEvaluator(config, target.MacAddress, upsRepo, ruleRepo).ExplainExpressions()
+		require.NoError(t, err)
+
+		assert.True(t, got.Found)
+		assert.True(t, got.Allowed)
+		assert.Equal(t, target, got.Target)
+		require.Len(t, got.Servers, 1)
+
+		server := got.Servers[0]
+		assert.Equal(t, "test", server.NutServer)
+		assert.Equal(t, "test server", server.Target)
+		assert.JSONEq(t, validNUTOutput, string(server.Input))
+		assert.True(t, server.Allowed)
+		require.Len(t, server.Rules, 2)
+
+		assert.Equal(t, &entity.RuleDecision{Rule: "alwaysTrue.rego", Allowed: true}, server.Rules[0].Decision)
+		assert.NotEmpty(t, server.Rules[0].Trace)
+		assert.Empty(t, server.Rules[0].FailedExpressions)
+
+		assert.Equal(t, &entity.RuleDecision{Rule: "charge.rego", Allowed: false}, server.Rules[1].Decision)
+		assert.NotEmpty(t, server.Rules[1].Trace)
+		assert.Contains(t, server.Rules[1].FailedExpressions, "charge.rego:3: input[0].Variables[0].Value < 50")
+	})
+
+	t.Run("mac not in config", func(t *testing.T) {
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(gomock.Any()).Times(0)
+
+		got, err := NewRegoEvaluator(config, &entity.MacAddress{MAC: "00:00:00:00:00:00"}, upsRepo, ruleRepo).ExplainExpressions()
+		require.NoError(t, err)
+		assert.False(t, got.Found)
+		assert.Empty(t, got.Servers)
+	})
+
+	t.Run("missing rule", func(t *testing.T) {
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(gomock.Any()).Return(validNUTOutput, nil).Times(1)
+
+		missingRule := *target
+		missingRule.Rules = []string{"missing.rego"}
+		missingConfig := &entity.Config{NutServers: []*entity.NutServer{{Name: "test", Targets: []*entity.TargetServer{&missingRule}}}}
+
+		_, err := NewRegoEvaluator(missingConfig, target.MacAddress, upsRepo, ruleRepo).ExplainExpressions()
+		assert.ErrorIs(t, err, ErrFailedEvaluateExpression)
+		assert.ErrorIs(t, err, rules.ErrRuleNotFound)
+	})
+}
+
+func TestRegoEvaluator_versionedInput(t *testing.T) {
+	regoFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(regoFs, "envelope.rego", []byte(`package upswake
+default wake := false
+wake if {
+	input.version == 2
+	input.target.name == "test server"
+	input.ups_status.cyberpower900.status == "OL"
+	input.ups_list[0].Name == "cyberpower900"
+}`), 0o644))
+	require.NoError(t, afero.WriteFile(regoFs, "legacy.rego", []byte(`package upswake
+default wake := false
+wake if input[0].Name == "cyberpower900"`), 0o644))
+	require.NoError(t, afero.WriteFile(regoFs, "normalized.rego", []byte(`package upswake
+default wake := false
+wake if {
+	input.version == 3
+	input.ups.cyberpower900.vars["battery.charge"] >= 100
+	"OL" in input.ups.cyberpower900.flags
+}`), 0o644))
+
+	ruleRepo, err := rules.NewPreparedRepository(regoFs)
+	require.NoError(t, err)
+
+	newConfig := func(input *entity.Input, targetVersion int, rule string) *entity.Config {
+		return &entity.Config{
+			Input: input,
+			NutServers: []*entity.NutServer{
+				{
+					Name: "test",
+					Targets: []*entity.TargetServer{
+						{
+							Name:         "test server",
+							MacAddress:   &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+							Broadcast:    "192.168.1.255",
+							Rules:        []string{rule},
+							InputVersion: targetVersion,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		config *entity.Config
+		name   string
+	}{
+		{
+			name:   "legacy input by default",
+			config: newConfig(nil, 0, "legacy.rego"),
+		},
+		{
+			name:   "global envelope",
+			config: newConfig(&entity.Input{Version: entity.InputVersionEnvelope}, 0, "envelope.rego"),
+		},
+		{
+			name:   "target envelope",
+			config: newConfig(nil, entity.InputVersionEnvelope, "envelope.rego"),
+		},
+		{
+			name:   "target keeps legacy input",
+			config: newConfig(&entity.Input{Version: entity.InputVersionEnvelope}, entity.InputVersionLegacy, "legacy.rego"),
+		},
+		{
+			name:   "target normalized",
+			config: newConfig(&entity.Input{Version: entity.InputVersionEnvelope}, entity.InputVersionNormalized, "normalized.rego"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := gomock.NewController(t)
+			upsRepo := mocks.NewMockUPSRepository(mock)
+			upsRepo.EXPECT().GetJSON(gomock.Any()).Return(validNUTOutput, nil).Times(1)
+
+			mac := &entity.MacAddress{MAC: "00:11:22:33:44:55"}
+			got, err := NewRegoEvaluator(tt.config, mac, upsRepo, ruleRepo, WithInputBuilder(NewInputBuilder())).EvaluateExpressions()
+			require.NoError(t, err)
+			assert.True(t, got.Allowed)
+		})
+	}
+}
+
+func TestRegoEvaluator_unavailableNutServer(t *testing.T) {
+	refused := errors.New("connection refused")
+	regoFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(regoFs, "legacy.rego", []byte(`package upswake
+default wake := false
+wake if input[0].Name == "cyberpower900"`), 0o644))
+	require.NoError(t, afero.WriteFile(regoFs, "stale.rego", []byte(`package upswake
+default wake := false
+wake if {
+	not input.sources.office.available
+	input.sources.office.last_error == "connection refused"
+	input.sources.office.last_known.age_seconds < 300
+	"OL" in input.sources.office.last_known.ups.cyberpower900.flags
+}`), 0o644))
+	ruleRepo, err := rules.NewPreparedRepository(regoFs)
+	require.NoError(t, err)
+
+	mac := &entity.MacAddress{MAC: "00:11:22:33:44:55"}
+	newTarget := func(rule string, version int) *entity.TargetServer {
+		return &entity.TargetServer{Name: "MyNAS", MacAddress: mac, Rules: []string{rule}, InputVersion: version}
+	}
+	other := &entity.NutServer{
+		Name:    "garage",
+		Targets: []*entity.TargetServer{{Name: "Other", MacAddress: &entity.MacAddress{MAC: "66:77:88:99:AA:BB"}}},
+	}
+
+	t.Run("NUT servers without the target aren't read", func(t *testing.T) {
+		office := &entity.NutServer{Name: "office", Targets: []*entity.TargetServer{newTarget("legacy.rego", 0)}}
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(office).Return(validNUTOutput, nil)
+
+		got, err := NewRegoEvaluator(&entity.Config{NutServers: []*entity.NutServer{other, office}}, mac, upsRepo, ruleRepo).EvaluateExpressions()
+		require.NoError(t, err)
+		assert.True(t, got.Allowed)
+	})
+
+	t.Run("legacy targets of an unavailable NUT server are skipped", func(t *testing.T) {
+		office := &entity.NutServer{Name: "office", Targets: []*entity.TargetServer{newTarget("legacy.rego", 0)}}
+		backup := &entity.NutServer{Name: "backup", Targets: []*entity.TargetServer{newTarget("legacy.rego", 0)}}
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(office).Return("", refused)
+		upsRepo.EXPECT().GetJSON(backup).Return(validNUTOutput, nil)
+
+		got, err := NewRegoEvaluator(&entity.Config{NutServers: []*entity.NutServer{office, backup}}, mac, upsRepo, ruleRepo).EvaluateExpressions()
+		require.NoError(t, err)
+		assert.True(t, got.Found)
+		assert.True(t, got.Allowed)
+		assert.Equal(t, []*UnavailableSource{{NutServer: "office", Error: "connection refused", SkippedTargets: []string{"MyNAS"}}}, got.Unavailable)
+	})
+
+	t.Run("unavailable NUT servers are explained without their credentials", func(t *testing.T) {
+		office := &entity.NutServer{Name: "office", Password: "bigsecret", Targets: []*entity.TargetServer{newTarget("legacy.rego", 0)}}
+		backup := &entity.NutServer{Name: "backup", Targets: []*entity.TargetServer{newTarget("legacy.rego", 0)}}
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(office).Return("", errors.New("login failed with password bigsecret"))
+		upsRepo.EXPECT().GetJSON(backup).Return(validNUTOutput, nil)
+
+		got, err := NewRegoEvaluator(&entity.Config{NutServers: []*entity.NutServer{office, backup}}, mac, upsRepo, ruleRepo).ExplainExpressions()
+		require.NoError(t, err)
+		assert.True(t, got.Allowed)
+		assert.Equal(t, []*UnavailableSource{{NutServer: "office", Error: "login failed with password xxxxx", SkippedTargets: []string{"MyNAS"}}}, got.Unavailable)
+	})
+
+	t.Run("error when no target could be evaluated", func(t *testing.T) {
+		office := &entity.NutServer{Name: "office", Targets: []*entity.TargetServer{newTarget("legacy.rego", 0)}}
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(office).Return("", refused)
+
+		_, err := NewRegoEvaluator(&entity.Config{NutServers: []*entity.NutServer{office}}, mac, upsRepo, ruleRepo).EvaluateExpressions()
+		assert.ErrorIs(t, err, ErrNutServerUnavailable)
+		assert.ErrorIs(t, err, refused)
+		assert.ErrorContains(t, err, "office")
+	})
+
+	t.Run("versioned input describes the unavailable NUT server", func(t *testing.T) {
+		office := &entity.NutServer{Name: "office", Targets: []*entity.TargetServer{newTarget("stale.rego", entity.InputVersionNormalized)}}
+		config := &entity.Config{NutServers: []*entity.NutServer{office}}
+		inputs := NewInputBuilder()
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		gomock.InOrder(
+			upsRepo.EXPECT().GetJSON(office).Return(validNUTOutput, nil),
+			upsRepo.EXPECT().GetJSON(office).Return("", refused),
+		)
+
+		got, err := NewRegoEvaluator(config, mac, upsRepo, ruleRepo, WithInputBuilder(inputs)).EvaluateExpressions()
+		require.NoError(t, err)
+		assert.False(t, got.Allowed, "the stale rule only wakes the target once office is unavailable")
+
+		got, err = NewRegoEvaluator(config, mac, upsRepo, ruleRepo, WithInputBuilder(inputs)).EvaluateExpressions()
+		require.NoError(t, err)
+		assert.True(t, got.Found)
+		assert.True(t, got.Allowed)
+		assert.Equal(t, []*UnavailableSource{{NutServer: "office", Error: "connection refused"}}, got.Unavailable, "no target is skipped")
+	})
+
+	t.Run("stale UPSes are evaluated with the NUT server unavailable", func(t *testing.T) {
+		require.NoError(t, afero.WriteFile(regoFs, "served.rego", []byte(`package upswake
+default wake := false
+wake if {
+	input.sources.office.stale
+	not input.sources.office.available
+	input.ups.cyberpower900.vars["ups.status"] == "OL"
+}`), 0o644))
+		ruleRepo, err := rules.NewPreparedRepository(regoFs)
+		require.NoError(t, err)
+
+		office := &entity.NutServer{Name: "office", Targets: []*entity.TargetServer{newTarget("served.rego", entity.InputVersionNormalized)}}
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(office).Return(validNUTOutput, &entity.StaleError{ReadAt: time.Now().Add(-time.Minute), Err: refused})
+
+		got, err := NewRegoEvaluator(&entity.Config{NutServers: []*entity.NutServer{office}}, mac, upsRepo, ruleRepo, WithInputBuilder(NewInputBuilder())).EvaluateExpressions()
+		require.NoError(t, err)
+		assert.True(t, got.Allowed)
+	})
+}
+
+func TestRegoEvaluator_decisionLog(t *testing.T) {
+	ruleErr := errors.New("rule failed")
+	newConfig := func(decisionLog *entity.DecisionLog) *entity.Config {
+		return &entity.Config{
+			DecisionLog: decisionLog,
+			NutServers: []*entity.NutServer{
+				{
+					Name: "raspberrypi",
+					Targets: []*entity.TargetServer{
+						{
+							Name:       "MyNAS",
+							MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+							Broadcast:  "192.168.1.255",
+							Rules:      []string{"deny.rego", "allow.rego"},
+							RuleMode:   entity.RuleModeAll,
+						},
+					},
+				},
+			},
+		}
+	}
+	t.Run("evaluator logs every decision", func(t *testing.T) {
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(gomock.Any()).Return(validNUTOutput, nil)
+		ruleRepo := mocks.NewMockRuleRepository(mock)
+		ruleRepo.EXPECT().Evaluate("deny.rego", validNUTOutput).Return(&entity.RuleDecision{Rule: "deny.rego", Denied: true, Reason: "maintenance"}, nil)
+		ruleRepo.EXPECT().Evaluate("allow.rego", validNUTOutput).Return(&entity.RuleDecision{Rule: "allow.rego", Allowed: true}, nil)
+
+		var written []*entity.DecisionLogEntry
+		decisionLog := mocks.NewMockDecisionLogRepository(mock)
+		decisionLog.EXPECT().Write(gomock.Any()).DoAndReturn(func(entries ...*entity.DecisionLogEntry) error {
+			written = entries
+			return nil
+		})
+
+		mac := &entity.MacAddress{MAC: "00:11:22:33:44:55"}
+		result, err := NewRegoEvaluator(newConfig(nil), mac, upsRepo, ruleRepo, WithDecisionLog(decisionLog)).EvaluateExpressions()
+		require.NoError(t, err)
+		require.False(t, result.Allowed)
+		assert.Len(t, result.EvaluationID, 16)
+
+		require.Len(t, written, 2)
+		for i, rule := range []string{"deny.rego", "allow.rego"} {
+			entry := written[i]
+			assert.Equal(t, rule, entry.Rule)
+			assert.Equal(t, "00:11:22:33:44:55", entry.MAC)
+			assert.Equal(t, "MyNAS", entry.Target)
+			assert.Equal(t, "raspberrypi", entry.NutServer)
+			assert.Equal(t, "all", entry.RuleMode)
+			assert.Len(t, entry.InputHash, 64)
+			assert.Nil(t, entry.Input)
+			assert.False(t, entry.PacketSent, "whether a packet was sent is recorded separately")
+			assert.False(t, entry.Time.IsZero())
+			assert.Equal(t, result.EvaluationID, entry.EvaluationID)
+		}
+		assert.True(t, written[0].Denied)
+		assert.Equal(t, "maintenance", written[0].Reason)
+		assert.True(t, written[1].Allowed)
+	})
+
+	t.Run("failed evaluation is logged by the evaluator", func(t *testing.T) {
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(gomock.Any()).Return(validNUTOutput, nil)
+		ruleRepo := mocks.NewMockRuleRepository(mock)
+		ruleRepo.EXPECT().Evaluate("deny.rego", validNUTOutput).Return(nil, ruleErr)
+
+		var written []*entity.DecisionLogEntry
+		decisionLog := mocks.NewMockDecisionLogRepository(mock)
+		decisionLog.EXPECT().Write(gomock.Any()).DoAndReturn(func(entries ...*entity.DecisionLogEntry) error {
+			written = entries
+			return nil
+		})
+
+		mac := &entity.MacAddress{MAC: "00:11:22:33:44:55"}
+		_, err := NewRegoEvaluator(newConfig(&entity.DecisionLog{IncludeInput: true}), mac, upsRepo, ruleRepo, WithDecisionLog(decisionLog)).EvaluateExpressions()
+		require.ErrorIs(t, err, ruleErr)
+
+		require.Len(t, written, 1)
+		assert.Equal(t, "deny.rego", written[0].Rule)
+		assert.Equal(t, ruleErr.Error(), written[0].Error)
+		assert.JSONEq(t, validNUTOutput, string(written[0].Input))
+		assert.False(t, written[0].PacketSent)
+	})
+
+	t.Run("no decision log", func(t *testing.T) {
+		mock := gomock.NewController(t)
+		upsRepo := mocks.NewMockUPSRepository(mock)
+		upsRepo.EXPECT().GetJSON(gomock.Any()).Return(validNUTOutput, nil)
+		ruleRepo := mocks.NewMockRuleRepository(mock)
+		ruleRepo.EXPECT().Evaluate(gomock.Any(), validNUTOutput).Return(&entity.RuleDecision{Allowed: true}, nil).Times(2)
+
+		mac := &entity.MacAddress{MAC: "00:11:22:33:44:55"}
+		result, err := NewRegoEvaluator(newConfig(nil), mac, upsRepo, ruleRepo).EvaluateExpressions()
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+}
@@ -0,0 +1,409 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+)
+
+var ErrInvalidUPSInput = errors.New("UPS input is not a list of UPSes")
+
+const upsStatusVariable = "ups.status"
+
+// InputBuilder builds the versioned input document rules are evaluated
+// against. It remembers when each UPS's status last changed and when each
+// target was last woken, so a single InputBuilder should be shared by every
+// evaluation for that history to be useful.
+type InputBuilder struct {
+	now      func() time.Time
+	statuses map[upsKey]*upsStatusChange
+	wakes    map[string]time.Time
+	sources  map[string]*sourceState
+	mu       sync.Mutex
+}
+
+type upsKey struct {
+	nutServer string
+	ups       string
+}
+
+type upsStatusChange struct {
+	changedAt time.Time
+	status    string
+}
+
+// sourceState is what the InputBuilder knows about reading a NUT server:
+// whether the last read succeeded, the UPSes it last read and why the last
+// read failed.
+type sourceState struct {
+	readAt    time.Time
+	failedAt  time.Time
+	inputJSON string
+	lastError string
+	upses     []nutUPS
+	available bool
+	// stale is true if the last read failed but its UPSes were served
+	// from the cache
+	stale bool
+}
+
+// ruleInput is the input document rules get with entity.InputVersionEnvelope
+// and entity.InputVersionNormalized.
+type ruleInput struct {
+	Time      inputTime              `json:"time"`
+	UPSStatus map[string]upsStatus   `json:"ups_status"`
+	UPS       map[string]inputUPS    `json:"ups,omitempty"`
+	Sources   map[string]inputSource `json:"sources"`
+	NutServer string                 `json:"nut_server"`
+	Target    inputTarget            `json:"target"`
+	UPSList   json.RawMessage        `json:"ups_list"`
+	Version   int                    `json:"version"`
+}
+
+type inputTime struct {
+	Now      string `json:"now"`
+	Timezone string `json:"timezone"`
+	Weekday  string `json:"weekday"`
+	Unix     int64  `json:"unix"`
+	Hour     int    `json:"hour"`
+	Minute   int    `json:"minute"`
+}
+
+type inputTarget struct {
+	SecondsSinceWake *int64 `json:"seconds_since_wake,omitempty"`
+	Name             string `json:"name"`
+	MAC              string `json:"mac"`
+	Broadcast        string `json:"broadcast"`
+	LastWake         string `json:"last_wake,omitempty"`
+}
+
+type upsStatus struct {
+	Status             string `json:"status"`
+	ChangedAt          string `json:"changed_at"`
+	SecondsSinceChange int64  `json:"seconds_since_change"`
+}
+
+// inputSource tells rules whether a NUT server could be read. If it
+// couldn't, LastKnown holds the UPSes last read from it, if any, and Stale
+// is true if rules were given those UPSes anyway.
+type inputSource struct {
+	LastKnown   *lastKnown `json:"last_known,omitempty"`
+	LastRead    string     `json:"last_read,omitempty"`
+	LastFailure string     `json:"last_failure,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Available   bool       `json:"available"`
+	Stale       bool       `json:"stale,omitempty"`
+}
+
+// lastKnown is the list of UPSes last read from a NUT server that can no
+// longer be read, and how old it is.
+type lastKnown struct {
+	UPS        map[string]inputUPS `json:"ups,omitempty"`
+	ReadAt     string              `json:"read_at"`
+	UPSList    json.RawMessage     `json:"ups_list"`
+	AgeSeconds int64               `json:"age_seconds"`
+}
+
+// inputUPS is a UPS with its variables keyed by name, as rules get it with
+// entity.InputVersionNormalized.
+type inputUPS struct {
+	Vars  map[string]any `json:"vars"`
+	Flags []string       `json:"flags"`
+}
+
+// nutUPS holds the parts of a UPS from the NUT server the InputBuilder reads.
+type nutUPS struct {
+	Name      string
+	Variables []struct {
+		Value any
+		Name  string
+	}
+}
+
+// NewInputBuilder creates an InputBuilder with no status or wake history.
+func NewInputBuilder() *InputBuilder {
+	return &InputBuilder{
+		now:      time.Now,
+		statuses: make(map[upsKey]*upsStatusChange),
+		wakes:    make(map[string]time.Time),
+		sources:  make(map[string]*sourceState),
+	}
+}
+
+// Observe records the status of every UPS in inputJSON, the list of UPSes
+// from nutServer. As the history starts when a status is first observed,
+// the time since a status changed is at most the time UPSWake has been
+// watching the UPS.
+func (b *InputBuilder) Observe(nutServer, inputJSON string) error {
+	upses, err := parseUPSes(inputJSON)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.observe(nutServer, upses)
+	return nil
+}
+
+func (b *InputBuilder) observe(nutServer string, upses []nutUPS) {
+	now := b.now()
+	for _, ups := range upses {
+		status, ok := upsStatusOf(ups)
+		if !ok {
+			continue
+		}
+		key := upsKey{nutServer: nutServer, ups: ups.Name}
+		if previous, seen := b.statuses[key]; seen && previous.status == status {
+			continue
+		}
+		b.statuses[key] = &upsStatusChange{status: status, changedAt: now}
+	}
+}
+
+// RecordWake records that a Wake on LAN packet was sent to mac.
+func (b *InputBuilder) RecordWake(mac string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.wakes[mac] = b.now()
+}
+
+// RecordRead records that nutServer was read, returning inputJSON. If
+// inputJSON is a list of UPSes, rules are given it as the NUT server's last
+// known UPSes should a later read fail.
+func (b *InputBuilder) RecordRead(nutServer, inputJSON string) {
+	upses, err := parseUPSes(inputJSON)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	source := b.source(nutServer)
+	if err != nil {
+		// keep the last UPSes that could be given to rules
+		inputJSON, upses = source.inputJSON, source.upses
+	}
+	source.available = true
+	source.stale = false
+	source.readAt = b.now()
+	source.inputJSON = inputJSON
+	source.upses = upses
+}
+
+// RecordFailure records that reading nutServer failed with err.
+func (b *InputBuilder) RecordFailure(nutServer string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fail(nutServer, err)
+}
+
+// RecordStale records that reading nutServer failed, but that inputJSON,
+// the UPSes read from it at stale.ReadAt, was served instead. The NUT
+// server is unavailable to rules, with inputJSON as its last known UPSes.
+func (b *InputBuilder) RecordStale(nutServer, inputJSON string, stale *entity.StaleError) {
+	upses, err := parseUPSes(inputJSON)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	source := b.fail(nutServer, stale.Err)
+	if err != nil {
+		return
+	}
+	source.stale = true
+	source.readAt = stale.ReadAt
+	source.inputJSON = inputJSON
+	source.upses = upses
+}
+
+func (b *InputBuilder) fail(nutServer string, err error) *sourceState {
+	source := b.source(nutServer)
+	source.available = false
+	source.stale = false
+	source.failedAt = b.now()
+	source.lastError = err.Error()
+	return source
+}
+
+func (b *InputBuilder) source(nutServer string) *sourceState {
+	source, ok := b.sources[nutServer]
+	if !ok {
+		source = &sourceState{}
+		b.sources[nutServer] = source
+	}
+	return source
+}
+
+// Build wraps inputJSON, the list of UPSes from nutServer, in the input
+// document of the given version for target's rules, with times given in
+// location. Version must be entity.InputVersionEnvelope or
+// entity.InputVersionNormalized. Rules find how nutServer was read under
+// sources once it has been recorded with RecordRead or RecordStale.
+func (b *InputBuilder) Build(version int, location *time.Location, nutServer *entity.NutServer, target *entity.TargetServer, inputJSON string) (string, error) {
+	upses, err := parseUPSes(inputJSON)
+	if err != nil {
+		return "", err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.observe(nutServer.Name, upses)
+
+	return b.build(version, location, nutServer, target, inputJSON, upses)
+}
+
+// BuildUnavailable builds the input document for target's rules like Build,
+// for when nutServer couldn't be read. The document has no UPSes, and rules
+// find what is known about nutServer under sources, after it has been
+// recorded with RecordFailure.
+func (b *InputBuilder) BuildUnavailable(version int, location *time.Location, nutServer *entity.NutServer, target *entity.TargetServer) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.build(version, location, nutServer, target, "[]", nil)
+}
+
+func (b *InputBuilder) build(version int, location *time.Location, nutServer *entity.NutServer, target *entity.TargetServer, inputJSON string, upses []nutUPS) (string, error) {
+	now := b.now().In(location)
+	input := ruleInput{
+		Version: version,
+		Time: inputTime{
+			Now:      now.Format(time.RFC3339),
+			Timezone: location.String(),
+			Weekday:  now.Weekday().String(),
+			Unix:     now.Unix(),
+			Hour:     now.Hour(),
+			Minute:   now.Minute(),
+		},
+		NutServer: nutServer.Name,
+		Target: inputTarget{
+			Name:      target.Name,
+			Broadcast: target.Broadcast,
+		},
+		UPSList:   json.RawMessage(inputJSON),
+		UPSStatus: make(map[string]upsStatus),
+		Sources:   make(map[string]inputSource, len(b.sources)),
+	}
+
+	if target.MacAddress != nil {
+		input.Target.MAC = target.MAC
+		if lastWake, ok := b.wakes[target.MAC]; ok {
+			seconds := int64(now.Sub(lastWake).Seconds())
+			input.Target.LastWake = lastWake.In(location).Format(time.RFC3339)
+			input.Target.SecondsSinceWake = &seconds
+		}
+	}
+
+	for _, ups := range upses {
+		change, ok := b.statuses[upsKey{nutServer: nutServer.Name, ups: ups.Name}]
+		if !ok {
+			continue
+		}
+		input.UPSStatus[ups.Name] = upsStatus{
+			Status:             change.status,
+			ChangedAt:          change.changedAt.In(location).Format(time.RFC3339),
+			SecondsSinceChange: int64(now.Sub(change.changedAt).Seconds()),
+		}
+	}
+
+	if version == entity.InputVersionNormalized {
+		input.UPS = normalize(upses)
+	}
+
+	for name, source := range b.sources {
+		input.Sources[name] = source.input(version, location, now)
+	}
+
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// input returns what rules are told about the source at now, with times
+// given in location.
+func (s *sourceState) input(version int, location *time.Location, now time.Time) inputSource {
+	input := inputSource{
+		Available: s.available,
+		Stale:     s.stale,
+		LastError: s.lastError,
+	}
+	if !s.readAt.IsZero() {
+		input.LastRead = s.readAt.In(location).Format(time.RFC3339)
+	}
+	if !s.failedAt.IsZero() {
+		input.LastFailure = s.failedAt.In(location).Format(time.RFC3339)
+	}
+	if s.available || s.inputJSON == "" {
+		return input
+	}
+
+	input.LastKnown = &lastKnown{
+		ReadAt:     input.LastRead,
+		UPSList:    json.RawMessage(s.inputJSON),
+		AgeSeconds: int64(now.Sub(s.readAt).Seconds()),
+	}
+	if version == entity.InputVersionNormalized {
+		input.LastKnown.UPS = normalize(s.upses)
+	}
+	return input
+}
+
+// parseUPSes decodes inputJSON with numbers kept as json.Number, so that
+// large integers and decimals reach rules as they were read from the UPS.
+func parseUPSes(inputJSON string) ([]nutUPS, error) {
+	var upses []nutUPS
+	d := json.NewDecoder(strings.NewReader(inputJSON))
+	d.UseNumber()
+	if err := d.Decode(&upses); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidUPSInput, err)
+	}
+	return upses, nil
+}
+
+// normalize keys each UPS by name, and its variables by name. Values that
+// are numbers, or strings holding a number, become numbers, and ups.status
+// is split into its flags, e.g. "OB LB" into ["LB", "OB"].
+func normalize(upses []nutUPS) map[string]inputUPS {
+	normalized := make(map[string]inputUPS, len(upses))
+	for _, ups := range upses {
+		vars := make(map[string]any, len(ups.Variables))
+		for _, variable := range ups.Variables {
+			vars[variable.Name] = toNumber(variable.Value)
+		}
+
+		flags := []string{}
+		if status, ok := upsStatusOf(ups); ok {
+			flags = strings.Fields(status)
+			sort.Strings(flags)
+		}
+
+		normalized[ups.Name] = inputUPS{Vars: vars, Flags: flags}
+	}
+	return normalized
+}
+
+// toNumber returns value as a json.Number if it is a string holding a JSON
+// number, such as "230.0", and value unchanged otherwise.
+func toNumber(value any) any {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if number, ok := entity.NumericString(s); ok {
+		return json.Number(number)
+	}
+	return value
+}
+
+func upsStatusOf(ups nutUPS) (string, bool) {
+	for _, variable := range ups.Variables {
+		if variable.Name == upsStatusVariable {
+			return fmt.Sprint(variable.Value), true
+		}
+	}
+	return "", false
+}
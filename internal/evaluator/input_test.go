@@ -0,0 +1,281 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const normalizedNUTOutput = `[{"Name":"cyberpower900","Variables":[{"Name":"battery.charge","Value":60},{"Name":"battery.runtime","Value":1234567890123},{"Name":"input.voltage","Value":" 230.5"},{"Name":"ups.serial","Value":"000123"},{"Name":"ups.firmware","Value":"1.0.3"},{"Name":"ups.status","Value":"OB DISCHRG LB"}]},{"Name":"eaton","Variables":[]}]`
+
+const onBatteryNUTOutput = `[{"Name":"cyberpower900","Variables":[{"Name":"battery.charge","Value":60},{"Name":"ups.status","Value":"OB DISCHRG"}]}]`
+
+// testClock is a clock for the InputBuilder that only moves when told to.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestInputBuilder(t *testing.T) (*InputBuilder, *testClock) {
+	t.Helper()
+	clock := &testClock{now: time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)}
+	b := NewInputBuilder()
+	b.now = clock.Now
+	return b, clock
+}
+
+func buildInput(t *testing.T, b *InputBuilder, location *time.Location, target *entity.TargetServer, inputJSON string) ruleInput {
+	t.Helper()
+	return buildVersionedInput(t, b, entity.InputVersionEnvelope, location, target, inputJSON)
+}
+
+func buildVersionedInput(t *testing.T, b *InputBuilder, version int, location *time.Location, target *entity.TargetServer, inputJSON string) ruleInput {
+	t.Helper()
+	raw, err := b.Build(version, location, &entity.NutServer{Name: "raspberrypi"}, target, inputJSON)
+	require.NoError(t, err)
+
+	var input ruleInput
+	require.NoError(t, json.Unmarshal([]byte(raw), &input))
+	return input
+}
+
+func TestInputBuilder_Build(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	target := &entity.TargetServer{
+		Name:       "MyNAS",
+		MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+		Broadcast:  "192.168.1.255",
+	}
+
+	t.Run("envelope", func(t *testing.T) {
+		b, _ := newTestInputBuilder(t)
+		input := buildInput(t, b, london, target, validNUTOutput)
+
+		assert.Equal(t, entity.InputVersionEnvelope, input.Version)
+		assert.Equal(t, "raspberrypi", input.NutServer)
+		assert.JSONEq(t, validNUTOutput, string(input.UPSList))
+		assert.Equal(t, inputTime{
+			Now:      "2026-01-05T17:30:00Z",
+			Timezone: "Europe/London",
+			Weekday:  "Monday",
+			Unix:     1767634200,
+			Hour:     17,
+			Minute:   30,
+		}, input.Time)
+		assert.Equal(t, inputTarget{
+			Name:      "MyNAS",
+			MAC:       "00:11:22:33:44:55",
+			Broadcast: "192.168.1.255",
+		}, input.Target)
+		assert.Equal(t, map[string]upsStatus{
+			"cyberpower900": {Status: "OL", ChangedAt: "2026-01-05T17:30:00Z", SecondsSinceChange: 0},
+		}, input.UPSStatus)
+		assert.Nil(t, input.UPS)
+	})
+
+	t.Run("normalized", func(t *testing.T) {
+		b, _ := newTestInputBuilder(t)
+		input := buildVersionedInput(t, b, entity.InputVersionNormalized, london, target, normalizedNUTOutput)
+
+		assert.Equal(t, entity.InputVersionNormalized, input.Version)
+		assert.JSONEq(t, normalizedNUTOutput, string(input.UPSList))
+		assert.Equal(t, map[string]inputUPS{
+			"cyberpower900": {
+				Vars: map[string]any{
+					"battery.charge":  float64(60),
+					"battery.runtime": 1234567890123.0,
+					"input.voltage":   230.5,
+					"ups.serial":      "000123",
+					"ups.firmware":    "1.0.3",
+					"ups.status":      "OB DISCHRG LB",
+				},
+				Flags: []string{"DISCHRG", "LB", "OB"},
+			},
+			"eaton": {
+				Vars:  map[string]any{},
+				Flags: []string{},
+			},
+		}, input.UPS)
+	})
+
+	t.Run("time is in the configured time zone", func(t *testing.T) {
+		newYork, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+
+		b, _ := newTestInputBuilder(t)
+		input := buildInput(t, b, newYork, target, validNUTOutput)
+
+		assert.Equal(t, "2026-01-05T12:30:00-05:00", input.Time.Now)
+		assert.Equal(t, "America/New_York", input.Time.Timezone)
+		assert.Equal(t, 12, input.Time.Hour)
+	})
+
+	t.Run("seconds since status change", func(t *testing.T) {
+		b, clock := newTestInputBuilder(t)
+		buildInput(t, b, london, target, validNUTOutput)
+
+		clock.Advance(10 * time.Minute)
+		input := buildInput(t, b, london, target, validNUTOutput)
+		assert.Equal(t, int64(600), input.UPSStatus["cyberpower900"].SecondsSinceChange)
+		assert.Equal(t, "OL", input.UPSStatus["cyberpower900"].Status)
+
+		clock.Advance(time.Minute)
+		input = buildInput(t, b, london, target, onBatteryNUTOutput)
+		assert.Equal(t, upsStatus{
+			Status:             "OB DISCHRG",
+			ChangedAt:          "2026-01-05T17:41:00Z",
+			SecondsSinceChange: 0,
+		}, input.UPSStatus["cyberpower900"])
+	})
+
+	t.Run("status history is per NUT server", func(t *testing.T) {
+		b, clock := newTestInputBuilder(t)
+		require.NoError(t, b.Observe("other", validNUTOutput))
+
+		clock.Advance(time.Minute)
+		input := buildInput(t, b, london, target, validNUTOutput)
+		assert.Equal(t, int64(0), input.UPSStatus["cyberpower900"].SecondsSinceChange)
+	})
+
+	t.Run("last wake", func(t *testing.T) {
+		b, clock := newTestInputBuilder(t)
+		b.RecordWake("00:11:22:33:44:55")
+		b.RecordWake("66:77:88:99:AA:BB")
+
+		clock.Advance(90 * time.Second)
+		input := buildInput(t, b, london, target, validNUTOutput)
+
+		require.NotNil(t, input.Target.SecondsSinceWake)
+		assert.Equal(t, int64(90), *input.Target.SecondsSinceWake)
+		assert.Equal(t, "2026-01-05T17:30:00Z", input.Target.LastWake)
+	})
+
+	t.Run("invalid UPS input", func(t *testing.T) {
+		b, _ := newTestInputBuilder(t)
+		_, err := b.Build(entity.InputVersionEnvelope, london, &entity.NutServer{Name: "raspberrypi"}, target, `{"not": "a list"}`)
+		assert.ErrorIs(t, err, ErrInvalidUPSInput)
+	})
+}
+
+func TestInputBuilder_Observe(t *testing.T) {
+	b, clock := newTestInputBuilder(t)
+
+	require.NoError(t, b.Observe("raspberrypi", validNUTOutput))
+	clock.Advance(time.Minute)
+	require.NoError(t, b.Observe("raspberrypi", validNUTOutput))
+	assert.Equal(t, clock.now.Add(-time.Minute), b.statuses[upsKey{nutServer: "raspberrypi", ups: "cyberpower900"}].changedAt)
+
+	require.NoError(t, b.Observe("raspberrypi", onBatteryNUTOutput))
+	assert.Equal(t, &upsStatusChange{status: "OB DISCHRG", changedAt: clock.now}, b.statuses[upsKey{nutServer: "raspberrypi", ups: "cyberpower900"}])
+
+	assert.ErrorIs(t, b.Observe("raspberrypi", "not json"), ErrInvalidUPSInput)
+}
+
+func TestInputBuilder_sources(t *testing.T) {
+	target := &entity.TargetServer{Name: "MyNAS", MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"}}
+	office := &entity.NutServer{Name: "office"}
+
+	t.Run("read NUT servers are available", func(t *testing.T) {
+		b, _ := newTestInputBuilder(t)
+		b.RecordRead("garage", validNUTOutput)
+		b.RecordRead("raspberrypi", validNUTOutput)
+		input := buildInput(t, b, time.UTC, target, validNUTOutput)
+
+		assert.Equal(t, map[string]inputSource{
+			"garage":      {Available: true, LastRead: "2026-01-05T17:30:00Z"},
+			"raspberrypi": {Available: true, LastRead: "2026-01-05T17:30:00Z"},
+		}, input.Sources)
+	})
+
+	t.Run("unavailable NUT server with last known UPSes", func(t *testing.T) {
+		b, clock := newTestInputBuilder(t)
+		b.RecordRead("office", normalizedNUTOutput)
+		clock.Advance(2 * time.Minute)
+		b.RecordFailure("office", errors.New("connection refused"))
+
+		raw, err := b.BuildUnavailable(entity.InputVersionNormalized, time.UTC, office, target)
+		require.NoError(t, err)
+		var input ruleInput
+		require.NoError(t, json.Unmarshal([]byte(raw), &input))
+
+		assert.Equal(t, "office", input.NutServer)
+		assert.JSONEq(t, `[]`, string(input.UPSList))
+		assert.Empty(t, input.UPS)
+		assert.Empty(t, input.UPSStatus)
+
+		source := input.Sources["office"]
+		assert.False(t, source.Available)
+		assert.Equal(t, "connection refused", source.LastError)
+		assert.Equal(t, "2026-01-05T17:30:00Z", source.LastRead)
+		assert.Equal(t, "2026-01-05T17:32:00Z", source.LastFailure)
+		require.NotNil(t, source.LastKnown)
+		assert.Equal(t, int64(120), source.LastKnown.AgeSeconds)
+		assert.Equal(t, "2026-01-05T17:30:00Z", source.LastKnown.ReadAt)
+		assert.JSONEq(t, normalizedNUTOutput, string(source.LastKnown.UPSList))
+		assert.Equal(t, []string{"DISCHRG", "LB", "OB"}, source.LastKnown.UPS["cyberpower900"].Flags)
+	})
+
+	t.Run("stale UPSes served for an unavailable NUT server", func(t *testing.T) {
+		b, clock := newTestInputBuilder(t)
+		readAt := clock.Now()
+		clock.Advance(10 * time.Minute)
+		b.RecordStale("office", normalizedNUTOutput, &entity.StaleError{ReadAt: readAt, Err: errors.New("connection refused")})
+
+		raw, err := b.Build(entity.InputVersionEnvelope, time.UTC, office, target, normalizedNUTOutput)
+		require.NoError(t, err)
+		var input ruleInput
+		require.NoError(t, json.Unmarshal([]byte(raw), &input))
+
+		assert.JSONEq(t, normalizedNUTOutput, string(input.UPSList))
+		source := input.Sources["office"]
+		assert.False(t, source.Available)
+		assert.True(t, source.Stale)
+		assert.Equal(t, "connection refused", source.LastError)
+		assert.Equal(t, "2026-01-05T17:30:00Z", source.LastRead)
+		assert.Equal(t, "2026-01-05T17:40:00Z", source.LastFailure)
+		require.NotNil(t, source.LastKnown)
+		assert.Equal(t, int64(600), source.LastKnown.AgeSeconds)
+
+		b.RecordRead("office", normalizedNUTOutput)
+		input = buildInput(t, b, time.UTC, target, normalizedNUTOutput)
+		assert.Equal(t, inputSource{Available: true, LastRead: "2026-01-05T17:40:00Z", LastFailure: "2026-01-05T17:40:00Z", LastError: "connection refused"}, input.Sources["office"])
+	})
+
+	t.Run("unavailable NUT server never read", func(t *testing.T) {
+		b, _ := newTestInputBuilder(t)
+		b.RecordFailure("office", errors.New("connection refused"))
+
+		raw, err := b.BuildUnavailable(entity.InputVersionEnvelope, time.UTC, office, target)
+		require.NoError(t, err)
+		assert.Contains(t, raw, `"sources":{"office":{"last_failure":"2026-01-05T17:30:00Z","last_error":"connection refused","available":false}}`)
+	})
+
+	t.Run("invalid UPS input keeps the last known UPSes", func(t *testing.T) {
+		b, _ := newTestInputBuilder(t)
+		b.RecordRead("office", validNUTOutput)
+		b.RecordRead("office", "not json")
+		b.RecordFailure("office", errors.New("connection refused"))
+
+		raw, err := b.BuildUnavailable(entity.InputVersionEnvelope, time.UTC, office, target)
+		require.NoError(t, err)
+		var input ruleInput
+		require.NoError(t, json.Unmarshal([]byte(raw), &input))
+		require.NotNil(t, input.Sources["office"].LastKnown)
+		assert.JSONEq(t, validNUTOutput, string(input.Sources["office"].LastKnown.UPSList))
+		assert.Nil(t, input.Sources["office"].LastKnown.UPS, "only version 3 has UPSes keyed by name")
+	})
+}
@@ -3,12 +3,19 @@ package viper
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
 )
 
-var ErrFailedParsingInterval = errors.New("failed to parse interval, must be a valid duration string")
+var (
+	ErrFailedParsingInterval  = errors.New("failed to parse interval, must be a valid duration string")
+	ErrFailedParsingRetention = errors.New("failed to parse history retention, must be a valid duration string")
+	ErrFailedParsingWatch     = errors.New("failed to parse watch interval, must be a valid duration string")
+	ErrFailedParsingTimeout   = errors.New("failed to parse timeout, must be a valid duration string")
+	ErrFailedParsingCache     = errors.New("failed to parse cache, durations must be valid duration strings")
+)
 
 func FromFileConfig(config *Config) (*entity.Config, error) {
 	nutServers := make([]*entity.NutServer, len(config.NutServers))
@@ -20,9 +27,24 @@ func FromFileConfig(config *Config) (*entity.Config, error) {
 		nutServers[i] = entityNutServer
 	}
 
+	history, err := FromFileHistory(config.History)
+	if err != nil {
+		return nil, err
+	}
+
+	watch, err := FromFileWatch(config.Watch)
+	if err != nil {
+		return nil, err
+	}
+
 	return &entity.Config{
-		NutServers: nutServers,
-		Profiler:   FromFileProfiler(config.Profiler),
+		NutServers:  nutServers,
+		Profiler:    FromFileProfiler(config.Profiler),
+		Input:       FromFileInput(config.Input),
+		Bundle:      FromFileBundle(config.Bundle),
+		DecisionLog: FromFileDecisionLog(config.DecisionLog),
+		History:     history,
+		Watch:       watch,
 	}, nil
 }
 
@@ -33,8 +55,13 @@ func ToFileConfig(entityConfig *entity.Config) *Config {
 	}
 
 	return &Config{
-		NutServers: nutServers,
-		Profiler:   ToFileProfiler(entityConfig.Profiler),
+		NutServers:  nutServers,
+		Profiler:    ToFileProfiler(entityConfig.Profiler),
+		Input:       ToFileInput(entityConfig.Input),
+		Bundle:      ToFileBundle(entityConfig.Bundle),
+		DecisionLog: ToFileDecisionLog(entityConfig.DecisionLog),
+		History:     ToFileHistory(entityConfig.History),
+		Watch:       ToFileWatch(entityConfig.Watch),
 	}
 }
 
@@ -48,6 +75,20 @@ func FromFileNutServer(nutServer *NutServer) (*entity.NutServer, error) {
 		targets[i] = entityTarget
 	}
 
+	var timeout time.Duration
+	if nutServer.Timeout != "" {
+		parsed, err := time.ParseDuration(nutServer.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedParsingTimeout, err)
+		}
+		timeout = parsed
+	}
+
+	cache, err := FromFileCache(nutServer.Cache)
+	if err != nil {
+		return nil, err
+	}
+
 	return &entity.NutServer{
 		Name:     nutServer.Name,
 		Host:     nutServer.Host,
@@ -55,6 +96,29 @@ func FromFileNutServer(nutServer *NutServer) (*entity.NutServer, error) {
 		Username: nutServer.Username,
 		Password: nutServer.Password,
 		Targets:  targets,
+		Type:     entity.SourceType(nutServer.Type),
+		TLS: entity.NutTLS{
+			Mode:       entity.NutTLSMode(nutServer.TLS),
+			CAFile:     nutServer.TLSCAFile,
+			CertFile:   nutServer.TLSCertFile,
+			KeyFile:    nutServer.TLSKeyFile,
+			ServerName: nutServer.TLSServerName,
+		},
+		SNMP: entity.NutSNMP{
+			Version:      entity.SNMPVersion(nutServer.SNMPVersion),
+			Community:    nutServer.SNMPCommunity,
+			AuthProtocol: nutServer.SNMPAuthProtocol,
+			PrivProtocol: nutServer.SNMPPrivProtocol,
+			PrivPassword: nutServer.SNMPPrivPassword,
+		},
+		Plugin: entity.NutPlugin{
+			Command: nutServer.Command,
+			URL:     nutServer.URL,
+			Timeout: timeout,
+		},
+		Endpoints:        FromFileEndpoints(nutServer.Endpoints),
+		EndpointStrategy: entity.EndpointStrategy(nutServer.EndpointStrategy),
+		Cache:            cache,
 	}, nil
 }
 
@@ -63,14 +127,106 @@ func ToFileNutServer(nutServer *entity.NutServer) *NutServer {
 	for i, target := range nutServer.Targets {
 		targets[i] = ToFileTargetServer(target)
 	}
+	timeout := ""
+	if nutServer.Plugin.Timeout != 0 {
+		timeout = nutServer.Plugin.Timeout.String()
+	}
 	return &NutServer{
-		Name:     nutServer.Name,
-		Host:     nutServer.Host,
-		Port:     nutServer.Port,
-		Username: nutServer.Username,
-		Password: nutServer.Password,
-		Targets:  targets,
+		Name:             nutServer.Name,
+		Host:             nutServer.Host,
+		Port:             nutServer.Port,
+		Username:         nutServer.Username,
+		Password:         nutServer.Password,
+		Targets:          targets,
+		Type:             string(nutServer.Type),
+		TLS:              string(nutServer.TLS.Mode),
+		TLSCAFile:        nutServer.TLS.CAFile,
+		TLSCertFile:      nutServer.TLS.CertFile,
+		TLSKeyFile:       nutServer.TLS.KeyFile,
+		TLSServerName:    nutServer.TLS.ServerName,
+		SNMPVersion:      string(nutServer.SNMP.Version),
+		SNMPCommunity:    nutServer.SNMP.Community,
+		SNMPAuthProtocol: nutServer.SNMP.AuthProtocol,
+		SNMPPrivProtocol: nutServer.SNMP.PrivProtocol,
+		SNMPPrivPassword: nutServer.SNMP.PrivPassword,
+		Command:          nutServer.Plugin.Command,
+		URL:              nutServer.Plugin.URL,
+		Timeout:          timeout,
+		Endpoints:        ToFileEndpoints(nutServer.Endpoints),
+		EndpointStrategy: string(nutServer.EndpointStrategy),
+		Cache:            ToFileCache(nutServer.Cache),
+	}
+}
+
+func FromFileEndpoints(endpoints []*NutEndpoint) []entity.NutEndpoint {
+	if len(endpoints) == 0 {
+		return nil
 	}
+	entityEndpoints := make([]entity.NutEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint == nil {
+			continue
+		}
+		entityEndpoints = append(entityEndpoints, entity.NutEndpoint{Host: endpoint.Host, Port: endpoint.Port})
+	}
+	return entityEndpoints
+}
+
+func ToFileEndpoints(endpoints []entity.NutEndpoint) []*NutEndpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	fileEndpoints := make([]*NutEndpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		fileEndpoints[i] = &NutEndpoint{Host: endpoint.Host, Port: endpoint.Port}
+	}
+	return fileEndpoints
+}
+
+// FromFileCache maps the cache section of a NUT server, leaving durations
+// that aren't set as zero.
+func FromFileCache(cache *NutCache) (entity.NutCache, error) {
+	if cache == nil {
+		return entity.NutCache{}, nil
+	}
+	var entityCache entity.NutCache
+	for _, field := range []struct {
+		into  *time.Duration
+		value string
+	}{
+		{into: &entityCache.TTL, value: cache.TTL},
+		{into: &entityCache.MaxStale, value: cache.MaxStale},
+		{into: &entityCache.ErrorBackoff, value: cache.ErrorBackoff},
+	} {
+		if field.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(field.value)
+		if err != nil {
+			return entity.NutCache{}, fmt.Errorf("%w: %w", ErrFailedParsingCache, err)
+		}
+		*field.into = parsed
+	}
+	return entityCache, nil
+}
+
+// ToFileCache maps the cache section of a NUT server. It is left nil if
+// none of the durations are set.
+func ToFileCache(entityCache entity.NutCache) *NutCache {
+	if entityCache == (entity.NutCache{}) {
+		return nil
+	}
+	cache := &NutCache{}
+	if entityCache.TTL != 0 {
+		cache.TTL = entityCache.TTL.String()
+	}
+	if entityCache.MaxStale != 0 {
+		cache.MaxStale = entityCache.MaxStale.String()
+	}
+	if entityCache.ErrorBackoff != 0 {
+		cache.ErrorBackoff = entityCache.ErrorBackoff.String()
+	}
+	return cache
 }
 
 func FromFileTargetServer(targetServer *TargetServer) (*entity.TargetServer, error) {
@@ -84,27 +240,82 @@ func FromFileTargetServer(targetServer *TargetServer) (*entity.TargetServer, err
 		return nil, err
 	}
 
+	rules, inlineRules, err := FromFileRules(targetServer.Rules)
+	if err != nil {
+		return nil, err
+	}
+
 	return &entity.TargetServer{
-		Name:       targetServer.Name,
-		MacAddress: mac,
-		Broadcast:  targetServer.Broadcast,
-		Port:       targetServer.Port,
-		Interval:   interval,
-		Rules:      targetServer.Rules,
+		Name:         targetServer.Name,
+		MacAddress:   mac,
+		Broadcast:    targetServer.Broadcast,
+		Port:         targetServer.Port,
+		Interval:     interval,
+		Rules:        rules,
+		InlineRules:  inlineRules,
+		InputVersion: targetServer.InputVersion,
+		RuleMode:     entity.RuleMode(targetServer.RuleMode),
+		SecureOn:     entity.SecureOn(targetServer.SecureOn),
 	}, nil
 }
 
+// FromFileRules returns the names of the rules, in order, and the source of
+// any inline rules keyed by name.
+func FromFileRules(fileRules []Rule) ([]string, map[string]string, error) {
+	rules := make([]string, len(fileRules))
+	var inlineRules map[string]string
+
+	for i, rule := range fileRules {
+		switch {
+		case rule.File != "" && rule.Inline == "" && rule.Name == "":
+			rules[i] = rule.File
+		case rule.Inline != "" && rule.File == "":
+			name := entity.InlineRuleName(rule.Name, rule.Inline)
+			if inlineRules == nil {
+				inlineRules = make(map[string]string)
+			}
+			if existing, ok := inlineRules[name]; ok && existing != rule.Inline {
+				return nil, nil, fmt.Errorf("%w: %s", entity.ErrDuplicateInline, name)
+			}
+			inlineRules[name] = rule.Inline
+			rules[i] = name
+		default:
+			return nil, nil, ErrInvalidRule
+		}
+	}
+	return rules, inlineRules, nil
+}
+
 func ToFileTargetServer(targetServer *entity.TargetServer) *TargetServer {
 	return &TargetServer{
-		Name:      targetServer.Name,
-		MAC:       targetServer.MAC,
-		Broadcast: targetServer.Broadcast,
-		Port:      targetServer.Port,
-		Interval:  targetServer.Interval.String(),
-		Rules:     targetServer.Rules,
+		Name:         targetServer.Name,
+		MAC:          targetServer.MAC,
+		Broadcast:    targetServer.Broadcast,
+		Port:         targetServer.Port,
+		Interval:     targetServer.Interval.String(),
+		Rules:        ToFileRules(targetServer.Rules, targetServer.InlineRules),
+		InputVersion: targetServer.InputVersion,
+		RuleMode:     string(targetServer.RuleMode),
+		SecureOn:     string(targetServer.SecureOn),
 	}
 }
 
+func ToFileRules(rules []string, inlineRules map[string]string) []Rule {
+	fileRules := make([]Rule, len(rules))
+	for i, rule := range rules {
+		source, ok := inlineRules[rule]
+		if !ok {
+			fileRules[i] = Rule{File: rule}
+			continue
+		}
+		fileRules[i] = Rule{
+			Name:   strings.TrimPrefix(rule, entity.InlineRulePrefix),
+			Inline: source,
+		}
+	}
+	return fileRules
+}
+
 func FromFileProfiler(profiler *Profiler) *entity.Profiler {
 	if profiler == nil {
 		return &entity.Profiler{Enabled: false}
@@ -118,3 +329,145 @@ func ToFileProfiler(entityProfiler *entity.Profiler) *Profiler {
 	}
 	return &Profiler{Enabled: entityProfiler.Enabled}
 }
+
+// FromFileInput maps the input section of the config. It is left nil when
+// the section is missing so rules keep getting the legacy input.
+func FromFileInput(input *Input) *entity.Input {
+	if input == nil {
+		return nil
+	}
+	return &entity.Input{
+		Version:  input.Version,
+		Timezone: input.Timezone,
+	}
+}
+
+func ToFileInput(entityInput *entity.Input) *Input {
+	if entityInput == nil {
+		return nil
+	}
+	return &Input{
+		Version:  entityInput.Version,
+		Timezone: entityInput.Timezone,
+	}
+}
+
+// FromFileBundle maps the bundle section of the config. It is left nil when
+// the section is missing, so rules are loaded from the rules directory.
+func FromFileBundle(bundle *Bundle) *entity.Bundle {
+	if bundle == nil {
+		return nil
+	}
+	return &entity.Bundle{
+		Path:      bundle.Path,
+		PublicKey: bundle.PublicKey,
+		Algorithm: bundle.Algorithm,
+		Scope:     bundle.Scope,
+	}
+}
+
+func ToFileBundle(entityBundle *entity.Bundle) *Bundle {
+	if entityBundle == nil {
+		return nil
+	}
+	return &Bundle{
+		Path:      entityBundle.Path,
+		PublicKey: entityBundle.PublicKey,
+		Algorithm: entityBundle.Algorithm,
+		Scope:     entityBundle.Scope,
+	}
+}
+
+// FromFileDecisionLog maps the decision_log section of the config. It is
+// left nil when the section is missing so the defaults apply.
+func FromFileDecisionLog(decisionLog *DecisionLog) *entity.DecisionLog {
+	if decisionLog == nil {
+		return nil
+	}
+	return &entity.DecisionLog{
+		File:         decisionLog.File,
+		BufferSize:   decisionLog.BufferSize,
+		MaxSizeMB:    decisionLog.MaxSizeMB,
+		MaxBackups:   decisionLog.MaxBackups,
+		IncludeInput: decisionLog.IncludeInput,
+	}
+}
+
+func ToFileDecisionLog(entityDecisionLog *entity.DecisionLog) *DecisionLog {
+	if entityDecisionLog == nil {
+		return nil
+	}
+	return &DecisionLog{
+		File:         entityDecisionLog.File,
+		BufferSize:   entityDecisionLog.BufferSize,
+		MaxSizeMB:    entityDecisionLog.MaxSizeMB,
+		MaxBackups:   entityDecisionLog.MaxBackups,
+		IncludeInput: entityDecisionLog.IncludeInput,
+	}
+}
+
+// FromFileHistory maps the history section of the config. It is left nil
+// when the section is missing so the defaults apply.
+func FromFileHistory(history *History) (*entity.History, error) {
+	if history == nil {
+		return nil, nil
+	}
+	entityHistory := &entity.History{
+		File:      history.File,
+		Variables: history.Variables,
+	}
+	if history.Retention != "" {
+		retention, err := time.ParseDuration(history.Retention)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedParsingRetention, err)
+		}
+		entityHistory.Retention = retention
+	}
+	return entityHistory, nil
+}
+
+func ToFileHistory(entityHistory *entity.History) *History {
+	if entityHistory == nil {
+		return nil
+	}
+	history := &History{
+		File:      entityHistory.File,
+		Variables: entityHistory.Variables,
+	}
+	if entityHistory.Retention != 0 {
+		history.Retention = entityHistory.Retention.String()
+	}
+	return history
+}
+
+// FromFileWatch maps the watch section of the config. It is left nil when
+// the section is missing so the watcher doesn't run.
+func FromFileWatch(watch *Watch) (*entity.Watch, error) {
+	if watch == nil {
+		return nil, nil
+	}
+	entityWatch := &entity.Watch{
+		ChargeThresholds: watch.ChargeThresholds,
+	}
+	if watch.Interval != "" {
+		interval, err := time.ParseDuration(watch.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedParsingWatch, err)
+		}
+		entityWatch.Interval = interval
+	}
+	return entityWatch, nil
+}
+
+func ToFileWatch(entityWatch *entity.Watch) *Watch {
+	if entityWatch == nil {
+		return nil
+	}
+	watch := &Watch{
+		ChargeThresholds: entityWatch.ChargeThresholds,
+	}
+	if entityWatch.Interval != 0 {
+		watch.Interval = entityWatch.Interval.String()
+	}
+	return watch
+}
@@ -1,6 +1,7 @@
 package viper
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -40,9 +41,9 @@ func TestFromFileConfig(t *testing.T) {
 								{
 									Name: "TestTarget",
 									MAC:  "00:11:22:33:44:55",
-									Rules: []string{
-										"rule1",
-										"rule2",
+									Rules: []Rule{
+										{File: "rule1"},
+										{File: "rule2"},
 									},
 									Interval:  "15m",
 									Port:      9,
@@ -136,7 +137,7 @@ func TestFromFileConfig(t *testing.T) {
 								{
 									Name:     "TestTarget",
 									MAC:      "00:11:22:33:44:55",
-									Rules:    []string{"rule1"},
+									Rules:    []Rule{{File: "rule1"}},
 									Interval: "invalid",
 									Port:     9,
 								},
@@ -159,6 +160,7 @@ func TestFromFileConfig(t *testing.T) {
 }
 
 func TestToFileConfig(t *testing.T) {
+	maxBackups := 2
 	type args struct {
 		entityConfig *entity.Config
 	}
@@ -206,7 +208,7 @@ func TestToFileConfig(t *testing.T) {
 							{
 								Name:      "TestTarget",
 								MAC:       "00:11:22:33:44:55",
-								Rules:     []string{"rule1", "rule2"},
+								Rules:     []Rule{{File: "rule1"}, {File: "rule2"}},
 								Interval:  "15m0s", // Trailing zero values are included in the string representation of durations. Annoying I know, but this is how time.Duration.String() works in Go.
 								Port:      9,
 								Broadcast: "127.0.0.255",
@@ -216,6 +218,200 @@ func TestToFileConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "apcupsd source",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler: &entity.Profiler{},
+					NutServers: []*entity.NutServer{
+						{Name: "apcupsd", Type: entity.SourceTypeApcupsd, Host: "localhost", Port: 3551, Targets: []*entity.TargetServer{}},
+					},
+				},
+			},
+			want: &Config{
+				Profiler: &Profiler{},
+				NutServers: []*NutServer{
+					{Name: "apcupsd", Type: "apcupsd", Host: "localhost", Port: 3551, Targets: []*TargetServer{}},
+				},
+			},
+		},
+		{
+			name: "snmp source",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler: &entity.Profiler{},
+					NutServers: []*entity.NutServer{
+						{
+							Name:     "snmp",
+							Type:     entity.SourceTypeSNMP,
+							Host:     "localhost",
+							Port:     161,
+							Username: "upsmon",
+							Password: "authpassword",
+							SNMP:     entity.NutSNMP{Version: entity.SNMPVersion3, AuthProtocol: "SHA256", PrivProtocol: "AES", PrivPassword: "privpassword"},
+							Targets:  []*entity.TargetServer{},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Profiler: &Profiler{},
+				NutServers: []*NutServer{
+					{
+						Name:             "snmp",
+						Type:             "snmp",
+						Host:             "localhost",
+						Port:             161,
+						Username:         "upsmon",
+						Password:         "authpassword",
+						SNMPVersion:      "3",
+						SNMPAuthProtocol: "SHA256",
+						SNMPPrivProtocol: "AES",
+						SNMPPrivPassword: "privpassword",
+						Targets:          []*TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "command source",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler: &entity.Profiler{},
+					NutServers: []*entity.NutServer{
+						{
+							Name:    "script",
+							Type:    entity.SourceTypeCommand,
+							Plugin:  entity.NutPlugin{Command: []string{"ups-status", "--json"}, Timeout: 5 * time.Second},
+							Targets: []*entity.TargetServer{},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Profiler: &Profiler{},
+				NutServers: []*NutServer{
+					{
+						Name:    "script",
+						Type:    "command",
+						Command: []string{"ups-status", "--json"},
+						Timeout: "5s",
+						Targets: []*TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "redundant endpoints",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler: &entity.Profiler{},
+					NutServers: []*entity.NutServer{
+						{
+							Name:             "redundant",
+							Host:             "192.168.1.133",
+							Port:             3493,
+							Endpoints:        []entity.NutEndpoint{{Host: "192.168.1.134", Port: 3494}},
+							EndpointStrategy: entity.EndpointStrategyFailover,
+							Targets:          []*entity.TargetServer{},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Profiler: &Profiler{},
+				NutServers: []*NutServer{
+					{
+						Name:             "redundant",
+						Host:             "192.168.1.133",
+						Port:             3493,
+						Endpoints:        []*NutEndpoint{{Host: "192.168.1.134", Port: 3494}},
+						EndpointStrategy: "failover",
+						Targets:          []*TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "cache",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler: &entity.Profiler{},
+					NutServers: []*entity.NutServer{
+						{
+							Name:    "cached",
+							Host:    "192.168.1.133",
+							Port:    3493,
+							Cache:   entity.NutCache{TTL: 30 * time.Second, ErrorBackoff: 5 * time.Second},
+							Targets: []*entity.TargetServer{},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Profiler: &Profiler{},
+				NutServers: []*NutServer{
+					{
+						Name:    "cached",
+						Host:    "192.168.1.133",
+						Port:    3493,
+						Cache:   &NutCache{TTL: "30s", ErrorBackoff: "5s"},
+						Targets: []*TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "secure on",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler: &entity.Profiler{},
+					NutServers: []*entity.NutServer{
+						{
+							Name:     "TestServer",
+							Host:     "localhost",
+							Port:     1234,
+							Username: "user",
+							Password: "pass",
+							Targets: []*entity.TargetServer{
+								{
+									Name:       "TestTarget",
+									MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+									Rules:      []string{"rule1"},
+									Interval:   15 * time.Minute,
+									Port:       9,
+									Broadcast:  "127.0.0.255",
+									SecureOn:   "0a:0b:0c:0d:0e:0f",
+								},
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Profiler: &Profiler{},
+				NutServers: []*NutServer{
+					{
+						Name:     "TestServer",
+						Host:     "localhost",
+						Port:     1234,
+						Username: "user",
+						Password: "pass",
+						Targets: []*TargetServer{
+							{
+								Name:      "TestTarget",
+								MAC:       "00:11:22:33:44:55",
+								Rules:     []Rule{{File: "rule1"}},
+								Interval:  "15m0s",
+								Port:      9,
+								Broadcast: "127.0.0.255",
+								SecureOn:  "0a:0b:0c:0d:0e:0f",
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "nil profiler",
 			args: args{
@@ -242,6 +438,81 @@ func TestToFileConfig(t *testing.T) {
 				NutServers: []*NutServer{},
 			},
 		},
+		{
+			name: "input config",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler:   &entity.Profiler{},
+					Input:      &entity.Input{Version: entity.InputVersionEnvelope, Timezone: "Europe/London"},
+					NutServers: []*entity.NutServer{},
+				},
+			},
+			want: &Config{
+				Profiler:   &Profiler{},
+				Input:      &Input{Version: entity.InputVersionEnvelope, Timezone: "Europe/London"},
+				NutServers: []*NutServer{},
+			},
+		},
+		{
+			name: "bundle config",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler:   &entity.Profiler{},
+					Bundle:     &entity.Bundle{Path: "bundle.tar.gz", PublicKey: "bundle.pub.pem", Algorithm: "ES256"},
+					NutServers: []*entity.NutServer{},
+				},
+			},
+			want: &Config{
+				Profiler:   &Profiler{},
+				Bundle:     &Bundle{Path: "bundle.tar.gz", PublicKey: "bundle.pub.pem", Algorithm: "ES256"},
+				NutServers: []*NutServer{},
+			},
+		},
+		{
+			name: "decision log config",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler:    &entity.Profiler{},
+					DecisionLog: &entity.DecisionLog{File: "decisions.jsonl", BufferSize: 50, MaxSizeMB: 1, MaxBackups: &maxBackups, IncludeInput: true},
+					NutServers:  []*entity.NutServer{},
+				},
+			},
+			want: &Config{
+				Profiler:    &Profiler{},
+				DecisionLog: &DecisionLog{File: "decisions.jsonl", BufferSize: 50, MaxSizeMB: 1, MaxBackups: &maxBackups, IncludeInput: true},
+				NutServers:  []*NutServer{},
+			},
+		},
+		{
+			name: "history config",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler:   &entity.Profiler{},
+					History:    &entity.History{File: "history.jsonl", Variables: []string{"ups.status"}, Retention: 90 * time.Minute},
+					NutServers: []*entity.NutServer{},
+				},
+			},
+			want: &Config{
+				Profiler:   &Profiler{},
+				History:    &History{File: "history.jsonl", Variables: []string{"ups.status"}, Retention: "1h30m0s"},
+				NutServers: []*NutServer{},
+			},
+		},
+		{
+			name: "watch config",
+			args: args{
+				entityConfig: &entity.Config{
+					Profiler:   &entity.Profiler{},
+					Watch:      &entity.Watch{Interval: 30 * time.Second, ChargeThresholds: []float64{90}},
+					NutServers: []*entity.NutServer{},
+				},
+			},
+			want: &Config{
+				Profiler:   &Profiler{},
+				Watch:      &Watch{Interval: "30s", ChargeThresholds: []float64{90}},
+				NutServers: []*NutServer{},
+			},
+		},
 		{
 			name: "profiler enabled",
 			args: args{
@@ -267,3 +538,107 @@ func TestToFileConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestFromFileRules(t *testing.T) {
+	const source = "package upswake\ndefault wake := true"
+	hashedName := entity.InlineRuleName("", source)
+
+	tests := []struct {
+		err             error
+		wantInlineRules map[string]string
+		name            string
+		rules           []Rule
+		wantRules       []string
+	}{
+		{
+			name:      "file rules",
+			rules:     []Rule{{File: "rule1.rego"}, {File: "rule2.rego"}},
+			wantRules: []string{"rule1.rego", "rule2.rego"},
+		},
+		{
+			name:            "named inline rule",
+			rules:           []Rule{{File: "rule1.rego"}, {Name: "always", Inline: source}},
+			wantRules:       []string{"rule1.rego", "inline:always"},
+			wantInlineRules: map[string]string{"inline:always": source},
+		},
+		{
+			name:            "unnamed inline rule is named after its source",
+			rules:           []Rule{{Inline: source}},
+			wantRules:       []string{hashedName},
+			wantInlineRules: map[string]string{hashedName: source},
+		},
+		{
+			name:  "file and inline",
+			rules: []Rule{{File: "rule1.rego", Inline: source}},
+			err:   ErrInvalidRule,
+		},
+		{
+			name:  "named file rule",
+			rules: []Rule{{File: "rule1.rego", Name: "rule1"}},
+			err:   ErrInvalidRule,
+		},
+		{
+			name:  "empty rule",
+			rules: []Rule{{}},
+			err:   ErrInvalidRule,
+		},
+		{
+			name:  "same name with different sources",
+			rules: []Rule{{Name: "always", Inline: source}, {Name: "always", Inline: "package upswake"}},
+			err:   entity.ErrDuplicateInline,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, inlineRules, err := FromFileRules(tt.rules)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.wantRules, rules)
+			assert.Equal(t, tt.wantInlineRules, inlineRules)
+		})
+	}
+}
+
+func TestToFileRules(t *testing.T) {
+	const source = "package upswake\ndefault wake := true"
+
+	got := ToFileRules(
+		[]string{"rule1.rego", "inline:always"},
+		map[string]string{"inline:always": source},
+	)
+	assert.Equal(t, []Rule{{File: "rule1.rego"}, {Name: "always", Inline: source}}, got)
+
+	roundTrip, inlineRules, err := FromFileRules(got)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rule1.rego", "inline:always"}, roundTrip)
+	assert.Equal(t, map[string]string{"inline:always": source}, inlineRules)
+}
+
+func TestRule_UnmarshalMapstructure(t *testing.T) {
+	tests := []struct {
+		input any
+		err   error
+		want  Rule
+		name  string
+	}{
+		{name: "file name", input: "rule1.rego", want: Rule{File: "rule1.rego"}},
+		{name: "file key", input: map[string]any{"file": "rule1.rego"}, want: Rule{File: "rule1.rego"}},
+		{name: "inline", input: map[string]any{"name": "always", "inline": "package upswake"}, want: Rule{Name: "always", Inline: "package upswake"}},
+		{name: "unknown key", input: map[string]any{"rego": "package upswake"}, err: ErrInvalidRule},
+		{name: "non string value", input: map[string]any{"inline": 1}, err: ErrInvalidRule},
+		{name: "wrong type", input: 1, err: ErrInvalidRule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Rule
+			err := got.UnmarshalMapstructure(tt.input)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRule_MarshalJSON(t *testing.T) {
+	got, err := json.Marshal([]Rule{{File: "rule1.rego"}, {Name: "always", Inline: "package upswake"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["rule1.rego",{"name":"always","inline":"package upswake"}]`, string(got))
+}
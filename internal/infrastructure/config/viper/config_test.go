@@ -60,6 +60,460 @@ func Test_Load(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "inline rules",
+			args: args{
+				fs:       testFS,
+				filePath: "inline_rules_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets: []*entity.TargetServer{
+							{
+								Name:       "nas_1",
+								MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+								Broadcast:  "192.168.1.255",
+								Port:       9,
+								Interval:   5 * time.Minute,
+								Rules: []string{
+									"80percentOn.rego",
+									"inline:always",
+								},
+								InlineRules: map[string]string{
+									"inline:always": "package upswake\ndefault wake := true\n",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "versioned input",
+			args: args{
+				fs:       testFS,
+				filePath: "input_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				Input: &entity.Input{
+					Version:  entity.InputVersionEnvelope,
+					Timezone: "Europe/London",
+				},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets: []*entity.TargetServer{
+							{
+								Name:         "nas_1",
+								MacAddress:   &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+								Broadcast:    "192.168.1.255",
+								Port:         9,
+								Interval:     5 * time.Minute,
+								Rules:        []string{"80percentOn.rego"},
+								InputVersion: entity.InputVersionLegacy,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "rule mode",
+			args: args{
+				fs:       testFS,
+				filePath: "rule_mode_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets: []*entity.TargetServer{
+							{
+								Name:       "nas_1",
+								MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+								Broadcast:  "192.168.1.255",
+								Port:       9,
+								Interval:   5 * time.Minute,
+								Rules:      []string{"80percentOn.rego", "onBattery.rego"},
+								RuleMode:   "quorum:2",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid rule mode",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_rule_mode.yaml",
+			},
+			wantErr: entity.ErrInvalidRuleMode,
+			want:    nil,
+		},
+		{
+			name: "secure on",
+			args: args{
+				fs:       testFS,
+				filePath: "secure_on_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets: []*entity.TargetServer{
+							{
+								Name:       "nas_1",
+								MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"},
+								Broadcast:  "192.168.1.255",
+								Port:       9,
+								Interval:   5 * time.Minute,
+								Rules:      []string{"80percentOn.rego", "onBattery.rego"},
+								SecureOn:   "0a:0b:0c:0d:0e:0f",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid secure on",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_secure_on.yaml",
+			},
+			wantErr: entity.ErrInvalidSecureOn,
+			want:    nil,
+		},
+		{
+			name: "tls",
+			args: args{
+				fs:       testFS,
+				filePath: "tls_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets:  []*entity.TargetServer{},
+						TLS: entity.NutTLS{
+							Mode:       entity.NutTLSRequired,
+							CAFile:     "/etc/upswake/nut-ca.pem",
+							CertFile:   "/etc/upswake/client.pem",
+							KeyFile:    "/etc/upswake/client.key",
+							ServerName: "ups.example.com",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid tls",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_tls.yaml",
+			},
+			wantErr: entity.ErrInvalidTLSMode,
+			want:    nil,
+		},
+		{
+			name: "apcupsd source",
+			args: args{
+				fs:       testFS,
+				filePath: "apcupsd_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets:  []*entity.TargetServer{},
+					},
+					{
+						Name:    "apcupsd_1",
+						Type:    entity.SourceTypeApcupsd,
+						Host:    "192.168.1.134",
+						Port:    3551,
+						Targets: []*entity.TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid source type",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_source_type.yaml",
+			},
+			wantErr: entity.ErrInvalidSourceType,
+			want:    nil,
+		},
+		{
+			name: "snmp source",
+			args: args{
+				fs:       testFS,
+				filePath: "snmp_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:    "rack_ups",
+						Type:    entity.SourceTypeSNMP,
+						Host:    "192.168.1.135",
+						Port:    161,
+						SNMP:    entity.NutSNMP{Community: "private"},
+						Targets: []*entity.TargetServer{},
+					},
+					{
+						Name:     "office_ups",
+						Type:     entity.SourceTypeSNMP,
+						Host:     "192.168.1.136",
+						Port:     161,
+						Username: "upsmon",
+						Password: "authpassword",
+						SNMP: entity.NutSNMP{
+							Version:      entity.SNMPVersion3,
+							AuthProtocol: "SHA256",
+							PrivProtocol: "AES",
+							PrivPassword: "privpassword",
+						},
+						Targets: []*entity.TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid snmp version",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_snmp_version.yaml",
+			},
+			wantErr: entity.ErrInvalidSNMPVersion,
+			want:    nil,
+		},
+		{
+			name: "command and http sources",
+			args: args{
+				fs:       testFS,
+				filePath: "plugin_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:    "script",
+						Type:    entity.SourceTypeCommand,
+						Plugin:  entity.NutPlugin{Command: []string{"/usr/local/bin/ups-status", "--json"}, Timeout: 5 * time.Second},
+						Targets: []*entity.TargetServer{},
+					},
+					{
+						Name:    "bridge",
+						Type:    entity.SourceTypeHTTP,
+						Plugin:  entity.NutPlugin{URL: "http://127.0.0.1:8080/ups.json"},
+						Targets: []*entity.TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid plugin timeout",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_plugin_timeout.yaml",
+			},
+			wantErr: ErrFailedParsingTimeout,
+			want:    nil,
+		},
+		{
+			name: "redundant endpoints",
+			args: args{
+				fs:       testFS,
+				filePath: "endpoints_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:             "nut_server_1",
+						Host:             "192.168.1.133",
+						Port:             3493,
+						Username:         "upsmon",
+						Password:         "password",
+						Endpoints:        []entity.NutEndpoint{{Host: "192.168.1.134"}, {Host: "192.168.1.135", Port: 3494}},
+						EndpointStrategy: entity.EndpointStrategyFirstHealthy,
+						Targets:          []*entity.TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid endpoint strategy",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_endpoint_strategy.yaml",
+			},
+			wantErr: entity.ErrInvalidEndpointStrategy,
+			want:    nil,
+		},
+		{
+			name: "cache",
+			args: args{
+				fs:       testFS,
+				filePath: "cache_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Cache:    entity.NutCache{TTL: 30 * time.Second, MaxStale: 10 * time.Minute, ErrorBackoff: 5 * time.Second},
+						Targets:  []*entity.TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid cache",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_cache.yaml",
+			},
+			wantErr: ErrFailedParsingCache,
+			want:    nil,
+		},
+		{
+			name: "history",
+			args: args{
+				fs:       testFS,
+				filePath: "history_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				History: &entity.History{
+					File:      "/var/lib/upswake/history.jsonl",
+					Variables: []string{"battery.charge", "ups.status"},
+					Retention: 72 * time.Hour,
+				},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets:  []*entity.TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid history retention",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_retention.yaml",
+			},
+			wantErr: ErrFailedParsingRetention,
+			want:    nil,
+		},
+		{
+			name: "watch",
+			args: args{
+				fs:       testFS,
+				filePath: "watch_config.yaml",
+			},
+			wantErr: nil,
+			want: &entity.Config{
+				Profiler: &entity.Profiler{},
+				Watch: &entity.Watch{
+					Interval:         5 * time.Second,
+					ChargeThresholds: []float64{50, 80},
+				},
+				NutServers: []*entity.NutServer{
+					{
+						Name:     "nut_server_1",
+						Host:     "192.168.1.133",
+						Port:     3493,
+						Username: "upsmon",
+						Password: "password",
+						Targets:  []*entity.TargetServer{},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid watch interval",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_watch.yaml",
+			},
+			wantErr: ErrFailedParsingWatch,
+			want:    nil,
+		},
+		{
+			name: "invalid timezone",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_timezone.yaml",
+			},
+			wantErr: entity.ErrInvalidTimezone,
+			want:    nil,
+		},
+		{
+			name: "rule with both file and inline",
+			args: args{
+				fs:       testFS,
+				filePath: "invalid_rule.yaml",
+			},
+			wantErr: ErrInvalidRule,
+			want:    nil,
+		},
 		{
 			name: "no target servers",
 			args: args{
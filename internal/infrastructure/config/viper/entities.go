@@ -1,28 +1,173 @@
 package viper
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidRule = errors.New("invalid rule, must be a file name or have exactly one of 'file' or 'inline'")
+
 type Config struct {
-	Profiler   *Profiler    `mapstructure:"profiler"`
-	NutServers []*NutServer `mapstructure:"nut_servers"`
+	Profiler    *Profiler    `mapstructure:"profiler"`
+	Input       *Input       `mapstructure:"input"`
+	Bundle      *Bundle      `mapstructure:"bundle"`
+	DecisionLog *DecisionLog `mapstructure:"decision_log"`
+	History     *History     `mapstructure:"history"`
+	Watch       *Watch       `mapstructure:"watch"`
+	NutServers  []*NutServer `mapstructure:"nut_servers"`
 }
 
 type Profiler struct {
 	Enabled bool `mapstructure:"enabled" json:"enabled"`
 }
 
+type Input struct {
+	Version  int    `mapstructure:"version" json:"version" example:"2"`
+	Timezone string `mapstructure:"timezone" json:"timezone,omitempty" example:"Europe/London"`
+}
+
+type Bundle struct {
+	Path      string `mapstructure:"path" json:"path"`
+	PublicKey string `mapstructure:"public_key" json:"public_key"`
+	Algorithm string `mapstructure:"algorithm" json:"algorithm,omitempty"`
+	Scope     string `mapstructure:"scope" json:"scope,omitempty"`
+}
+
+type DecisionLog struct {
+	File         string `mapstructure:"file" json:"file,omitempty"`
+	BufferSize   int    `mapstructure:"buffer_size" json:"buffer_size,omitempty"`
+	MaxSizeMB    int    `mapstructure:"max_size_mb" json:"max_size_mb,omitempty"`
+	MaxBackups   *int   `mapstructure:"max_backups" json:"max_backups,omitempty"`
+	IncludeInput bool   `mapstructure:"include_input" json:"include_input,omitempty"`
+}
+
+type History struct {
+	File      string   `mapstructure:"file" json:"file,omitempty"`
+	Variables []string `mapstructure:"variables" json:"variables,omitempty"`
+	Retention string   `mapstructure:"retention" json:"retention,omitempty" example:"24h"`
+}
+
+type Watch struct {
+	Interval         string    `mapstructure:"interval" json:"interval,omitempty" example:"10s"`
+	ChargeThresholds []float64 `mapstructure:"charge_thresholds" json:"charge_thresholds,omitempty"`
+}
+
 type NutServer struct {
 	Name     string          `mapstructure:"name" json:"name"`
 	Host     string          `mapstructure:"host" json:"host"`
 	Username string          `mapstructure:"username" json:"username"`
 	Password string          `mapstructure:"password" json:"password"`
 	Targets  []*TargetServer `mapstructure:"targets" json:"targets"`
-	Port     int             `mapstructure:"port" json:"port"`
+	// Type is one of nut (the default), apcupsd, snmp, command or http
+	Type string `mapstructure:"type" json:"type,omitempty" example:"nut"`
+	// TLS is one of off (the default), optional or required
+	TLS           string `mapstructure:"tls" json:"tls,omitempty" example:"required"`
+	TLSCAFile     string `mapstructure:"tls_ca_file" json:"tls_ca_file,omitempty"`
+	TLSCertFile   string `mapstructure:"tls_cert_file" json:"tls_cert_file,omitempty"`
+	TLSKeyFile    string `mapstructure:"tls_key_file" json:"tls_key_file,omitempty"`
+	TLSServerName string `mapstructure:"tls_server_name" json:"tls_server_name,omitempty"`
+	// SNMPVersion is one of 2c (the default) or 3
+	SNMPVersion      string `mapstructure:"snmp_version" json:"snmp_version,omitempty" example:"2c"`
+	SNMPCommunity    string `mapstructure:"snmp_community" json:"snmp_community,omitempty"`
+	SNMPAuthProtocol string `mapstructure:"snmp_auth_protocol" json:"snmp_auth_protocol,omitempty" example:"SHA"`
+	SNMPPrivProtocol string `mapstructure:"snmp_priv_protocol" json:"snmp_priv_protocol,omitempty" example:"AES"`
+	SNMPPrivPassword string `mapstructure:"snmp_priv_password" json:"snmp_priv_password,omitempty"`
+	// Command is run for command sources, without a shell
+	Command []string `mapstructure:"command" json:"command,omitempty" example:"/usr/local/bin/ups-status,--json"`
+	// URL is fetched for http sources
+	URL string `mapstructure:"url" json:"url,omitempty" example:"http://127.0.0.1:8080/ups.json"`
+	// Timeout bounds the command or request of command and http sources
+	Timeout string `mapstructure:"timeout" json:"timeout,omitempty" example:"10s"`
+	// Endpoints are further hosts serving the same UPSes, tried after host
+	// and port
+	Endpoints []*NutEndpoint `mapstructure:"endpoints" json:"endpoints,omitempty"`
+	// EndpointStrategy is one of failover (the default) or first-healthy
+	EndpointStrategy string `mapstructure:"endpoint_strategy" json:"endpoint_strategy,omitempty" example:"failover"`
+	// Cache configures how long the UPSes are cached for
+	Cache *NutCache `mapstructure:"cache" json:"cache,omitempty"`
+	Port  int       `mapstructure:"port" json:"port"`
+}
+
+type NutCache struct {
+	TTL          string `mapstructure:"ttl" json:"ttl,omitempty" example:"5m"`
+	MaxStale     string `mapstructure:"max_stale" json:"max_stale,omitempty" example:"30m"`
+	ErrorBackoff string `mapstructure:"error_backoff" json:"error_backoff,omitempty" example:"10s"`
+}
+
+type NutEndpoint struct {
+	Host string `mapstructure:"host" json:"host"`
+	Port int    `mapstructure:"port" json:"port,omitempty"`
 }
 
 type TargetServer struct {
-	Name      string   `mapstructure:"name" json:"name"`
-	MAC       string   `mapstructure:"mac" json:"mac"`
-	Broadcast string   `mapstructure:"broadcast" json:"broadcast"`
-	Interval  string   `mapstructure:"interval" json:"interval" default:"15m"`
-	Rules     []string `mapstructure:"rules" json:"rules"`
-	Port      int      `mapstructure:"port" json:"port" default:"9"`
+	Name      string `mapstructure:"name" json:"name"`
+	MAC       string `mapstructure:"mac" json:"mac"`
+	Broadcast string `mapstructure:"broadcast" json:"broadcast"`
+	Interval  string `mapstructure:"interval" json:"interval" default:"15m"`
+	Rules     []Rule `mapstructure:"rules" json:"rules" swaggertype:"array,string" example:"80percentOn.rego"`
+	Port      int    `mapstructure:"port" json:"port" default:"9"`
+	// InputVersion overrides the global input version for this target's rules
+	InputVersion int `mapstructure:"input_version" json:"input_version,omitempty" example:"2"`
+	// RuleMode is one of any (the default), all, first-match or quorum:N
+	RuleMode string `mapstructure:"rule_mode" json:"rule_mode,omitempty" example:"quorum:2"`
+	// SecureOn is the password sent after the magic packet, 4 bytes written
+	// like an IPv4 address or 6 bytes written like a MAC address
+	SecureOn string `mapstructure:"secure_on" json:"secure_on,omitempty" example:"0a:0b:0c:0d:0e:0f"`
+}
+
+// Rule is either the file name of a rule in the rules directory, written
+// as a plain string, or a rule written inline in the config:
+//
+//	rules:
+//	  - 80percentOn.rego
+//	  - name: business-hours
+//	    inline: |
+//	      package upswake
+//	      ...
+type Rule struct {
+	File   string `mapstructure:"file" json:"file,omitempty"`
+	Name   string `mapstructure:"name" json:"name,omitempty"`
+	Inline string `mapstructure:"inline" json:"inline,omitempty"`
+}
+
+// UnmarshalMapstructure decodes a rule from either a file name or a map.
+func (r *Rule) UnmarshalMapstructure(input any) error {
+	switch v := input.(type) {
+	case string:
+		*r = Rule{File: v}
+		return nil
+	case map[string]any:
+		rule := Rule{}
+		for key, value := range v {
+			str, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: %s must be a string", ErrInvalidRule, key)
+			}
+			switch key {
+			case "file":
+				rule.File = str
+			case "name":
+				rule.Name = str
+			case "inline":
+				rule.Inline = str
+			default:
+				return fmt.Errorf("%w: unknown key %s", ErrInvalidRule, key)
+			}
+		}
+		*r = rule
+		return nil
+	default:
+		return fmt.Errorf("%w: expected a file name or a map, got %T", ErrInvalidRule, input)
+	}
+}
+
+// MarshalJSON writes rule files as plain strings, matching how they are
+// written in the config.
+func (r Rule) MarshalJSON() ([]byte, error) {
+	if r.Inline == "" && r.Name == "" {
+		return json.Marshal(r.File)
+	}
+	type rule Rule
+	return json.Marshal(rule(r))
 }
@@ -0,0 +1,132 @@
+package decisionlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+)
+
+var ErrWriteDecisionLog = errors.New("failed to write decision log")
+
+// Repository keeps the most recent decisions in a fixed size ring buffer
+// for querying, and optionally writes every decision to an io.Writer as a
+// line of JSON.
+type Repository struct {
+	out     io.Writer
+	entries []*entity.DecisionLogEntry
+	// next is the index the next entry is written to
+	next int
+	// full is true once the buffer has wrapped around
+	full bool
+	mu   sync.RWMutex
+}
+
+// NewRepository creates a Repository keeping the last size decisions in
+// memory. If out is not nil, every decision is also written to it as JSON
+// lines.
+func NewRepository(size int, out io.Writer) *Repository {
+	if size < 1 {
+		size = 1
+	}
+	return &Repository{
+		out:     out,
+		entries: make([]*entity.DecisionLogEntry, size),
+	}
+}
+
+// Write adds entries to the ring buffer, replacing the oldest entries once
+// it is full, and writes them to the output. Entries are kept in memory
+// even if they can't be written to the output.
+func (r *Repository) Write(entries ...*entity.DecisionLogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range entries {
+		r.entries[r.next] = entry
+		r.next = (r.next + 1) % len(r.entries)
+		r.full = r.full || r.next == 0
+	}
+
+	lines := make([]any, len(entries))
+	for i, entry := range entries {
+		lines[i] = entry
+	}
+	return r.writeLines(lines...)
+}
+
+// RecordPacketSent marks the entries of the evaluation with evaluationID
+// that are still in the ring buffer as having sent a packet, and appends a
+// entity.PacketSentEntry to the output.
+func (r *Repository) RecordPacketSent(evaluationID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, entry := range r.entries {
+		if entry == nil || entry.EvaluationID != evaluationID {
+			continue
+		}
+		// Entries returned by Query may still be read, so they are replaced
+		// rather than changed
+		sent := *entry
+		sent.PacketSent = true
+		r.entries[i] = &sent
+	}
+
+	return r.writeLines(&entity.PacketSentEntry{
+		Time:         time.Now(),
+		EvaluationID: evaluationID,
+		PacketSent:   true,
+	})
+}
+
+// writeLines writes each of lines to the output as a line of JSON.
+func (r *Repository) writeLines(lines ...any) error {
+	if r.out == nil {
+		return nil
+	}
+	var errs []error
+	for _, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err = r.out.Write(append(data, '\n')); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteDecisionLog, err)
+	}
+	return nil
+}
+
+// Query returns the entries in the ring buffer matching filter, newest
+// first.
+func (r *Repository) Query(filter entity.DecisionLogFilter) []*entity.DecisionLogEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+
+	matches := []*entity.DecisionLogEntry{}
+	for i := 1; i <= count; i++ {
+		entry := r.entries[(r.next-i+len(r.entries))%len(r.entries)]
+		if !filter.Matches(entry) {
+			continue
+		}
+		matches = append(matches, entry)
+		if filter.Limit > 0 && len(matches) == filter.Limit {
+			break
+		}
+	}
+	return matches
+}
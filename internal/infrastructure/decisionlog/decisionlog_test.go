@@ -0,0 +1,159 @@
+package decisionlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var _ repository.DecisionLogRepository = new(Repository)
+
+var errWrite = errors.New("disk full")
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func newEntries(count int) []*entity.DecisionLogEntry {
+	start := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	entries := make([]*entity.DecisionLogEntry, count)
+	for i := range entries {
+		entries[i] = &entity.DecisionLogEntry{
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			MAC:     "00:11:22:33:44:55",
+			Rule:    fmt.Sprintf("rule%d.rego", i),
+			Allowed: i%2 == 0,
+		}
+	}
+	return entries
+}
+
+func rules(entries []*entity.DecisionLogEntry) []string {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Rule
+	}
+	return names
+}
+
+func TestRepository_Query(t *testing.T) {
+	entries := newEntries(5)
+	allowed := true
+
+	tests := []struct {
+		filter entity.DecisionLogFilter
+		name   string
+		size   int
+		want   []string
+	}{
+		{
+			name: "newest first",
+			size: 10,
+			want: []string{"rule4.rego", "rule3.rego", "rule2.rego", "rule1.rego", "rule0.rego"},
+		},
+		{
+			name: "oldest entries are dropped",
+			size: 3,
+			want: []string{"rule4.rego", "rule3.rego", "rule2.rego"},
+		},
+		{
+			name: "buffer exactly full",
+			size: 5,
+			want: []string{"rule4.rego", "rule3.rego", "rule2.rego", "rule1.rego", "rule0.rego"},
+		},
+		{
+			name:   "limit",
+			size:   10,
+			filter: entity.DecisionLogFilter{Limit: 2},
+			want:   []string{"rule4.rego", "rule3.rego"},
+		},
+		{
+			name:   "filtered",
+			size:   10,
+			filter: entity.DecisionLogFilter{Allowed: &allowed, Since: entries[1].Time},
+			want:   []string{"rule4.rego", "rule2.rego"},
+		},
+		{
+			name:   "no matches",
+			size:   10,
+			filter: entity.DecisionLogFilter{MAC: "66:77:88:99:AA:BB"},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRepository(tt.size, nil)
+			require.NoError(t, r.Write(entries[:2]...))
+			require.NoError(t, r.Write(entries[2:]...))
+
+			assert.Equal(t, tt.want, rules(r.Query(tt.filter)))
+		})
+	}
+}
+
+func TestRepository_Write(t *testing.T) {
+	t.Run("json lines", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		r := NewRepository(10, out)
+		entries := newEntries(2)
+		require.NoError(t, r.Write(entries...))
+
+		scanner := bufio.NewScanner(out)
+		var got []*entity.DecisionLogEntry
+		for scanner.Scan() {
+			entry := &entity.DecisionLogEntry{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), entry))
+			got = append(got, entry)
+		}
+		assert.Equal(t, entries, got)
+	})
+
+	t.Run("entries are kept when the output fails", func(t *testing.T) {
+		r := NewRepository(10, failingWriter{})
+		err := r.Write(newEntries(1)...)
+		require.ErrorIs(t, err, ErrWriteDecisionLog)
+		require.ErrorIs(t, err, errWrite)
+		assert.Len(t, r.Query(entity.DecisionLogFilter{}), 1)
+	})
+}
+
+func TestRepository_RecordPacketSent(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := NewRepository(10, out)
+	entries := newEntries(3)
+	entries[0].EvaluationID = "sent"
+	entries[1].EvaluationID = "sent"
+	entries[2].EvaluationID = "other"
+	require.NoError(t, r.Write(entries...))
+	queried := r.Query(entity.DecisionLogFilter{})
+	out.Reset()
+
+	require.NoError(t, r.RecordPacketSent("sent"))
+
+	got := r.Query(entity.DecisionLogFilter{})
+	require.Len(t, got, 3)
+	assert.False(t, got[0].PacketSent, "other evaluations are unchanged")
+	assert.True(t, got[1].PacketSent)
+	assert.True(t, got[2].PacketSent)
+	for _, entry := range queried {
+		assert.False(t, entry.PacketSent, "entries already returned by Query are not changed")
+	}
+
+	line := &entity.PacketSentEntry{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), line))
+	assert.Equal(t, "sent", line.EvaluationID)
+	assert.True(t, line.PacketSent)
+	assert.False(t, line.Time.IsZero())
+}
@@ -0,0 +1,110 @@
+package decisionlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+var ErrOpenLogFile = errors.New("failed to open decision log file")
+
+// RotatingFile is an io.WriteCloser appending to a file, which is rotated
+// before a write would take it over a maximum size. Rotated files are
+// renamed with a numbered suffix, path.1 being the most recent, and only
+// the newest maxBackups are kept.
+type RotatingFile struct {
+	fs         afero.Fs
+	file       afero.File
+	path       string
+	maxSize    int64
+	size       int64
+	maxBackups int
+	mu         sync.Mutex
+}
+
+// NewRotatingFile opens path in fs for appending, creating it if needed.
+func NewRotatingFile(fs afero.Fs, path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		fs:         fs,
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := f.fs.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrOpenLogFile, f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w %s: %w", ErrOpenLogFile, f.path, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would take it over
+// the maximum size. A single write larger than the maximum size is written
+// to a new file on its own.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate closes the file, shifts each backup up by one, dropping the
+// oldest, and opens a new empty file. If the file can't be moved aside, it
+// is reopened so that later writes still succeed.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	return errors.Join(f.shiftBackups(), f.open())
+}
+
+func (f *RotatingFile) shiftBackups() error {
+	if f.maxBackups < 1 {
+		return f.fs.Remove(f.path)
+	}
+
+	_ = f.fs.Remove(f.backup(f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		if _, err := f.fs.Stat(f.backup(i)); err != nil {
+			continue
+		}
+		if err := f.fs.Rename(f.backup(i), f.backup(i+1)); err != nil {
+			return err
+		}
+	}
+	return f.fs.Rename(f.path, f.backup(1))
+}
+
+func (f *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,76 @@
+package decisionlog
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const logPath = "decisions.jsonl"
+
+func readLog(t *testing.T, fs afero.Fs, path string) string {
+	t.Helper()
+	raw, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func TestRotatingFile_Write(t *testing.T) {
+	t.Run("rotates and keeps the newest backups", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		f, err := NewRotatingFile(fs, logPath, 8, 2)
+		require.NoError(t, err)
+
+		for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+			_, err = f.Write([]byte(line))
+			require.NoError(t, err)
+		}
+		require.NoError(t, f.Close())
+
+		assert.Equal(t, "five\n", readLog(t, fs, logPath))
+		assert.Equal(t, "four\n", readLog(t, fs, logPath+".1"))
+		assert.Equal(t, "three\n", readLog(t, fs, logPath+".2"))
+		exists, err := afero.Exists(fs, logPath+".3")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("appends to an existing file", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, logPath, []byte("old\n"), 0o640))
+
+		f, err := NewRotatingFile(fs, logPath, 8, 1)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("new\n"))
+		require.NoError(t, err)
+		_, err = f.Write([]byte("next\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		assert.Equal(t, "next\n", readLog(t, fs, logPath))
+		assert.Equal(t, "old\nnew\n", readLog(t, fs, logPath+".1"))
+	})
+
+	t.Run("no backups", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		f, err := NewRotatingFile(fs, logPath, 4, 0)
+		require.NoError(t, err)
+		for _, line := range []string{"one\n", "two\n"} {
+			_, err = f.Write([]byte(line))
+			require.NoError(t, err)
+		}
+		require.NoError(t, f.Close())
+
+		assert.Equal(t, "two\n", readLog(t, fs, logPath))
+		exists, err := afero.Exists(fs, logPath+".1")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("unwritable path", func(t *testing.T) {
+		_, err := NewRotatingFile(afero.NewReadOnlyFs(afero.NewMemMapFs()), logPath, 8, 1)
+		assert.ErrorIs(t, err, ErrOpenLogFile)
+	})
+}
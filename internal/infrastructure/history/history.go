@@ -0,0 +1,277 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/spf13/afero"
+)
+
+var (
+	ErrOpenHistoryFile = errors.New("failed to open history file")
+	ErrWriteHistory    = errors.New("failed to write history")
+)
+
+// compactInterval is how often expired samples are dropped, and the
+// history file rewritten without them.
+const compactInterval = time.Hour
+
+// series identifies the samples of one variable of one UPS.
+type series struct {
+	nutServer string
+	ups       string
+	variable  string
+}
+
+// Repository keeps the samples recorded in the last retention period in
+// memory, and optionally in a file of JSON lines so they survive restarts.
+type Repository struct {
+	fs          afero.Fs
+	file        afero.File
+	now         func() time.Time
+	compactedAt time.Time
+	// samples holds each series' samples, oldest first
+	samples   map[series][]*entity.HistorySample
+	path      string
+	retention time.Duration
+	mu        sync.RWMutex
+}
+
+// NewRepository creates a Repository keeping samples for retention. If path
+// is not empty, the samples already in the file at path in fs are loaded,
+// and new samples are appended to it.
+func NewRepository(fs afero.Fs, path string, retention time.Duration) (*Repository, error) {
+	return newRepository(fs, path, retention, time.Now)
+}
+
+func newRepository(fs afero.Fs, path string, retention time.Duration, now func() time.Time) (*Repository, error) {
+	r := &Repository{
+		fs:        fs,
+		now:       now,
+		samples:   make(map[series][]*entity.HistorySample),
+		path:      path,
+		retention: retention,
+	}
+	if path == "" {
+		r.compactedAt = r.now()
+		return r, nil
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	if err := r.compact(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the samples in the history file, if it exists. Lines that
+// aren't a valid sample, such as one cut short by a crash, are skipped.
+func (r *Repository) load() error {
+	raw, err := afero.ReadFile(r.fs, r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrOpenHistoryFile, r.path, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		sample := &entity.HistorySample{}
+		d := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		d.UseNumber()
+		if err := d.Decode(sample); err != nil {
+			continue
+		}
+		r.add(sample)
+	}
+	return nil
+}
+
+// Record adds samples to the history and appends them to the history file.
+// Samples are kept in memory even if they can't be written to the file.
+func (r *Repository) Record(samples ...*entity.HistorySample) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, sample := range samples {
+		r.add(sample)
+	}
+
+	var compactErr error
+	if r.now().Sub(r.compactedAt) >= compactInterval {
+		// compacting rewrites the file with every sample, including these
+		if compactErr = r.compact(); compactErr == nil {
+			return nil
+		}
+	}
+	if r.file == nil {
+		return compactErr
+	}
+	var errs []error
+	for _, sample := range samples {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err = r.file.Write(append(line, '\n')); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return errors.Join(compactErr, fmt.Errorf("%w: %w", ErrWriteHistory, err))
+	}
+	return compactErr
+}
+
+// add inserts sample into its series, keeping the series in time order.
+func (r *Repository) add(sample *entity.HistorySample) {
+	key := series{nutServer: sample.NutServer, ups: sample.UPS, variable: sample.Variable}
+	samples := r.samples[key]
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Time.After(sample.Time) })
+	r.samples[key] = slices.Insert(samples, i, sample)
+}
+
+// compact drops expired samples and, if there is a history file, replaces
+// it with one holding only the samples left. If the file can't be replaced,
+// the existing file is appended to until the next compaction succeeds.
+func (r *Repository) compact() error {
+	cutoff := r.now().Add(-r.retention)
+	for key, samples := range r.samples {
+		i := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(cutoff) })
+		if i == len(samples) {
+			delete(r.samples, key)
+			continue
+		}
+		r.samples[key] = slices.Clone(samples[i:])
+	}
+
+	if r.path == "" {
+		r.compactedAt = r.now()
+		return nil
+	}
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+	}
+
+	err := r.replaceFile()
+	if openErr := r.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	if err != nil {
+		return err
+	}
+	r.compactedAt = r.now()
+	return nil
+}
+
+// replaceFile replaces the history file with one holding every sample.
+func (r *Repository) replaceFile() error {
+	tmpPath := r.path + ".tmp"
+	if err := r.writeAll(tmpPath); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrWriteHistory, tmpPath, err)
+	}
+	if err := r.fs.Rename(tmpPath, r.path); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrWriteHistory, r.path, err)
+	}
+	return nil
+}
+
+// open opens the history file for new samples to be appended to.
+func (r *Repository) open() error {
+	file, err := r.fs.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrOpenHistoryFile, r.path, err)
+	}
+	r.file = file
+	return nil
+}
+
+// writeAll writes every sample to a new file at path.
+func (r *Repository) writeAll(path string) error {
+	file, err := r.fs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for _, sample := range r.sorted(entity.HistoryFilter{}, time.Time{}) {
+		if err := encoder.Encode(sample); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Query returns the samples matching filter that haven't expired, oldest
+// first.
+func (r *Repository) Query(filter entity.HistoryFilter) []*entity.HistorySample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sorted(filter, r.now().Add(-r.retention))
+}
+
+// sorted returns the samples matching filter at or after cutoff, in time
+// order. Samples at the same time are ordered by NUT server, UPS and
+// variable.
+func (r *Repository) sorted(filter entity.HistoryFilter, cutoff time.Time) []*entity.HistorySample {
+	since := filter.Since
+	if since.Before(cutoff) {
+		since = cutoff
+	}
+
+	matches := []*entity.HistorySample{}
+	for _, samples := range r.samples {
+		i := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(since) })
+		for _, sample := range samples[i:] {
+			if !filter.Until.IsZero() && sample.Time.After(filter.Until) {
+				break
+			}
+			if filter.Matches(sample) {
+				matches = append(matches, sample)
+			}
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *entity.HistorySample) int {
+		return cmp.Or(
+			a.Time.Compare(b.Time),
+			cmp.Compare(a.NutServer, b.NutServer),
+			cmp.Compare(a.UPS, b.UPS),
+			cmp.Compare(a.Variable, b.Variable),
+		)
+	})
+	return matches
+}
+
+// Close closes the history file, if there is one.
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
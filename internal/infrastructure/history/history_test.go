@@ -0,0 +1,215 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var _ repository.HistoryRepository = (*Repository)(nil)
+
+var start = time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+
+func sample(at time.Duration, ups, variable string, value any) *entity.HistorySample {
+	return &entity.HistorySample{
+		Time:      start.Add(at),
+		NutServer: "raspberrypi",
+		UPS:       ups,
+		Variable:  variable,
+		Value:     value,
+	}
+}
+
+// clock is a time that tests move forward, starting at start.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Set(elapsed time.Duration) {
+	c.now = start.Add(elapsed)
+}
+
+func newTestRepository(t *testing.T, fs afero.Fs, path string, retention time.Duration, c *clock) *Repository {
+	t.Helper()
+	r, err := newRepository(fs, path, retention, c.Now)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+	return r
+}
+
+// renameFs is an afero.Fs whose renames fail with err, if set.
+type renameFs struct {
+	afero.Fs
+	err error
+}
+
+func (fs *renameFs) Rename(oldname, newname string) error {
+	if fs.err != nil {
+		return fs.err
+	}
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func readFile(t *testing.T, fs afero.Fs, path string) string {
+	t.Helper()
+	raw, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func TestRepository_Query(t *testing.T) {
+	r := newTestRepository(t, nil, "", time.Hour, &clock{now: start})
+	charge0 := sample(0, "cyberpower900", "battery.charge", 100)
+	status0 := sample(0, "cyberpower900", "ups.status", "OL")
+	other0 := sample(0, "apc", "battery.charge", 90)
+	charge1 := sample(time.Minute, "cyberpower900", "battery.charge", 99)
+	status1 := sample(time.Minute, "cyberpower900", "ups.status", "OB")
+	// recorded out of order, as concurrent reads of NUT servers can be
+	require.NoError(t, r.Record(charge1, status1))
+	require.NoError(t, r.Record(status0, charge0, other0))
+
+	tests := []struct {
+		name   string
+		filter entity.HistoryFilter
+		want   []*entity.HistorySample
+	}{
+		{
+			name: "everything, oldest first",
+			want: []*entity.HistorySample{other0, charge0, status0, charge1, status1},
+		},
+		{
+			name:   "one UPS",
+			filter: entity.HistoryFilter{NutServer: "raspberrypi", UPS: "cyberpower900"},
+			want:   []*entity.HistorySample{charge0, status0, charge1, status1},
+		},
+		{
+			name:   "one variable",
+			filter: entity.HistoryFilter{UPS: "cyberpower900", Variables: []string{"ups.status"}},
+			want:   []*entity.HistorySample{status0, status1},
+		},
+		{
+			name:   "since",
+			filter: entity.HistoryFilter{Since: start.Add(time.Second)},
+			want:   []*entity.HistorySample{charge1, status1},
+		},
+		{
+			name:   "until",
+			filter: entity.HistoryFilter{Until: start.Add(time.Second)},
+			want:   []*entity.HistorySample{other0, charge0, status0},
+		},
+		{
+			name:   "unknown NUT server",
+			filter: entity.HistoryFilter{NutServer: "other"},
+			want:   []*entity.HistorySample{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.Query(tt.filter))
+		})
+	}
+}
+
+func TestRepository_retention(t *testing.T) {
+	c := &clock{now: start}
+	r := newTestRepository(t, nil, "", time.Hour, c)
+	key := series{nutServer: "raspberrypi", ups: "cyberpower900", variable: "battery.charge"}
+	old := sample(0, "cyberpower900", "battery.charge", 100)
+	recent := sample(30*time.Minute, "cyberpower900", "battery.charge", 90)
+	require.NoError(t, r.Record(old, recent))
+
+	c.Set(time.Hour + time.Minute)
+	assert.Equal(t, []*entity.HistorySample{recent}, r.Query(entity.HistoryFilter{}), "expired samples aren't returned")
+	assert.Len(t, r.samples[key], 2, "expired samples are only dropped when compacting")
+
+	c.Set(2 * time.Hour)
+	latest := sample(2*time.Hour, "cyberpower900", "battery.charge", 80)
+	require.NoError(t, r.Record(latest))
+	assert.Equal(t, []*entity.HistorySample{latest}, r.samples[key])
+}
+
+func TestRepository_file(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	existing := strings.Join([]string{
+		`{"time":"2026-01-05T15:30:00Z","value":100,"nut_server":"raspberrypi","ups":"cyberpower900","variable":"battery.charge"}`,
+		`{"time":"2026-01-05T17:00:00Z","value":95,"nut_server":"raspberrypi","ups":"cyberpower900","variable":"battery.charge"}`,
+		// cut short by a crash
+		`{"time":"2026-01-05T17:10:00Z","val`,
+		"",
+	}, "\n")
+	require.NoError(t, afero.WriteFile(fs, "history.jsonl", []byte(existing), 0o640))
+
+	c := &clock{now: start}
+	r := newTestRepository(t, fs, "history.jsonl", time.Hour, c)
+
+	loaded := sample(-30*time.Minute, "cyberpower900", "battery.charge", json.Number("95"))
+	assert.Equal(t, []*entity.HistorySample{loaded}, r.Query(entity.HistoryFilter{}), "expired and invalid samples are dropped on load")
+	assert.Equal(t, `{"time":"2026-01-05T17:00:00Z","value":95,"nut_server":"raspberrypi","ups":"cyberpower900","variable":"battery.charge"}
+`, readFile(t, fs, "history.jsonl"), "the file is compacted on load")
+
+	charge := sample(0, "cyberpower900", "battery.charge", json.Number("85"))
+	status := sample(time.Minute, "cyberpower900", "ups.status", "OB DISCHRG")
+	require.NoError(t, r.Record(charge))
+	require.NoError(t, r.Record(status))
+	assert.Equal(t, `{"time":"2026-01-05T17:00:00Z","value":95,"nut_server":"raspberrypi","ups":"cyberpower900","variable":"battery.charge"}
+{"time":"2026-01-05T17:30:00Z","value":85,"nut_server":"raspberrypi","ups":"cyberpower900","variable":"battery.charge"}
+{"time":"2026-01-05T17:31:00Z","value":"OB DISCHRG","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}
+`, readFile(t, fs, "history.jsonl"), "samples are appended to the file")
+
+	require.NoError(t, r.Close())
+	reopened := newTestRepository(t, fs, "history.jsonl", time.Hour, c)
+	assert.Equal(t, []*entity.HistorySample{loaded, charge, status}, reopened.Query(entity.HistoryFilter{}), "samples survive a restart")
+
+	c.Set(compactInterval + time.Minute)
+	restored := sample(compactInterval+time.Minute, "cyberpower900", "ups.status", "OL")
+	require.NoError(t, reopened.Record(restored))
+	assert.Equal(t, `{"time":"2026-01-05T17:31:00Z","value":"OB DISCHRG","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}
+{"time":"2026-01-05T18:31:00Z","value":"OL","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}
+`, readFile(t, fs, "history.jsonl"), "compacting rewrites the file without expired samples")
+}
+
+func TestNewRepository_readOnly(t *testing.T) {
+	fs := afero.NewReadOnlyFs(afero.NewMemMapFs())
+	_, err := NewRepository(fs, "history.jsonl", time.Hour)
+	assert.ErrorIs(t, err, ErrWriteHistory)
+}
+
+func TestRepository_compactFails(t *testing.T) {
+	fs := &renameFs{Fs: afero.NewMemMapFs()}
+	c := &clock{now: start}
+	r := newTestRepository(t, fs, "history.jsonl", time.Hour, c)
+	expired := sample(0, "cyberpower900", "ups.status", "OL")
+	require.NoError(t, r.Record(expired))
+
+	renameErr := errors.New("device busy")
+	fs.err = renameErr
+	c.Set(compactInterval + time.Minute)
+	onBattery := sample(compactInterval+time.Minute, "cyberpower900", "ups.status", "OB")
+	err := r.Record(onBattery)
+	require.ErrorIs(t, err, ErrWriteHistory)
+	require.ErrorIs(t, err, renameErr)
+	assert.Equal(t, `{"time":"2026-01-05T17:30:00Z","value":"OL","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}
+{"time":"2026-01-05T18:31:00Z","value":"OB","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}
+`, readFile(t, fs, "history.jsonl"), "samples are still appended to the file when it can't be compacted")
+
+	fs.err = nil
+	c.Set(compactInterval + 2*time.Minute)
+	restored := sample(compactInterval+2*time.Minute, "cyberpower900", "ups.status", "OL")
+	require.NoError(t, r.Record(restored))
+	assert.Equal(t, `{"time":"2026-01-05T18:31:00Z","value":"OB","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}
+{"time":"2026-01-05T18:32:00Z","value":"OL","nut_server":"raspberrypi","ups":"cyberpower900","variable":"ups.status"}
+`, readFile(t, fs, "history.jsonl"), "compacting is retried on the next sample")
+}
@@ -0,0 +1,195 @@
+// Package nutclient is a client for the network protocol of NUT's upsd,
+// which can upgrade its connection to TLS with STARTTLS.
+package nutclient
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutprotocol"
+)
+
+// DefaultTimeout bounds connecting to the NUT server and each command.
+const DefaultTimeout = 5 * time.Second
+
+var (
+	ErrServer          = errors.New("NUT server returned an error")
+	ErrUnexpectedReply = errors.New("unexpected reply from NUT server")
+	ErrTLSRequired     = errors.New("NUT server does not support STARTTLS, which is required")
+	ErrTLSHandshake    = errors.New("TLS handshake with NUT server failed")
+)
+
+// Options configures how a Client connects.
+type Options struct {
+	// TLSConfig is used to upgrade the connection if TLSMode enables TLS
+	TLSConfig *tls.Config
+	TLSMode   entity.NutTLSMode
+	// Timeout bounds connecting and each command, DefaultTimeout if unset
+	Timeout time.Duration
+}
+
+// Client is a session with a NUT server. It is not safe for concurrent use.
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	tls     bool
+}
+
+// Dial connects to the NUT server at address, then upgrades the connection
+// to TLS as opts.TLSMode says. With NutTLSRequired, Dial fails rather than
+// return a connection that isn't encrypted.
+func Dial(address string, opts Options) (*Client, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	client := newClient(conn, timeout)
+
+	switch opts.TLSMode {
+	case entity.NutTLSRequired, entity.NutTLSOptional:
+		err = client.StartTLS(opts.TLSConfig)
+		if errors.Is(err, ErrServer) && opts.TLSMode == entity.NutTLSOptional {
+			// the server refused STARTTLS, but the connection is still
+			// usable without it
+			err = nil
+		} else if errors.Is(err, ErrServer) {
+			err = fmt.Errorf("%w: %w", ErrTLSRequired, err)
+		}
+	}
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func newClient(conn net.Conn, timeout time.Duration) *Client {
+	return &Client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+}
+
+// TLS returns whether the connection has been upgraded to TLS.
+func (c *Client) TLS() bool {
+	return c.tls
+}
+
+// StartTLS upgrades the connection to TLS. If the server refuses, the
+// returned error wraps ErrServer and the connection can still be used
+// without TLS. Any other error leaves the connection unusable.
+func (c *Client) StartTLS(config *tls.Config) error {
+	if _, err := c.command("STARTTLS"); err != nil {
+		return err
+	}
+
+	conn := tls.Client(c.conn, config)
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	if err := conn.Handshake(); err != nil {
+		return fmt.Errorf("%w: %w", ErrTLSHandshake, err)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.tls = true
+	return nil
+}
+
+// Authenticate sends the username and password, returning false if the
+// server doesn't accept them.
+func (c *Client) Authenticate(username, password string) (bool, error) {
+	usernameReply, err := c.command("USERNAME " + nutprotocol.Quote(username))
+	if err != nil {
+		return false, err
+	}
+	passwordReply, err := c.command("PASSWORD " + nutprotocol.Quote(password))
+	if err != nil {
+		return false, err
+	}
+	return usernameReply == "OK" && passwordReply == "OK", nil
+}
+
+// Disconnect logs out and closes the connection.
+func (c *Client) Disconnect() error {
+	_, err := c.command("LOGOUT")
+	return errors.Join(err, c.Close())
+}
+
+// Close closes the connection without logging out.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// command sends a command that has a single line reply, and returns the
+// reply.
+func (c *Client) command(cmd string) (string, error) {
+	if err := c.send(cmd); err != nil {
+		return "", err
+	}
+	return c.readLine()
+}
+
+// list sends a LIST command and returns the lines between its BEGIN and END
+// lines.
+func (c *Client) list(args string) ([]string, error) {
+	first, err := c.command("LIST " + args)
+	if err != nil {
+		return nil, err
+	}
+	if first != "BEGIN LIST "+args {
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedReply, first)
+	}
+
+	lines := []string{}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END LIST "+args {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (c *Client) send(cmd string) error {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(c.conn, "%s\n", cmd)
+	return err
+}
+
+// readLine reads a line of a reply, returning an error wrapping ErrServer
+// if it is an ERR line.
+func (c *Client) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if code, ok := strings.CutPrefix(line, "ERR "); ok {
+		return "", fmt.Errorf("%w: %s", ErrServer, code)
+	}
+	return line, nil
+}
+
+// isServerError reports whether err is the ERR reply with the given code.
+func isServerError(err error, code string) bool {
+	return errors.Is(err, ErrServer) && strings.HasSuffix(err.Error(), ": "+code)
+}
@@ -0,0 +1,168 @@
+package nutclient_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDial_TLS(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	tests := []struct {
+		serverTLS *tls.Config
+		clientTLS *tls.Config
+		wantErr   error
+		name      string
+		mode      entity.NutTLSMode
+		wantTLS   bool
+	}{
+		{
+			name:      "required with STARTTLS",
+			serverTLS: ca.serverTLSConfig(t, "127.0.0.1"),
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			mode:      entity.NutTLSRequired,
+			wantTLS:   true,
+		},
+		{
+			name:      "required without STARTTLS fails closed",
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			mode:      entity.NutTLSRequired,
+			wantErr:   nutclient.ErrTLSRequired,
+		},
+		{
+			name:      "required with untrusted certificate",
+			serverTLS: otherCA.serverTLSConfig(t, "127.0.0.1"),
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			mode:      entity.NutTLSRequired,
+			wantErr:   nutclient.ErrTLSHandshake,
+		},
+		{
+			name:      "required with wrong server name",
+			serverTLS: ca.serverTLSConfig(t, "upsd.example.com"),
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			mode:      entity.NutTLSRequired,
+			wantErr:   nutclient.ErrTLSHandshake,
+		},
+		{
+			name:      "required with server name override",
+			serverTLS: ca.serverTLSConfig(t, "upsd.example.com"),
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "upsd.example.com"},
+			mode:      entity.NutTLSRequired,
+			wantTLS:   true,
+		},
+		{
+			name:      "optional with STARTTLS",
+			serverTLS: ca.serverTLSConfig(t, "127.0.0.1"),
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			mode:      entity.NutTLSOptional,
+			wantTLS:   true,
+		},
+		{
+			name:      "optional without STARTTLS",
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			mode:      entity.NutTLSOptional,
+		},
+		{
+			name:      "optional with untrusted certificate",
+			serverTLS: otherCA.serverTLSConfig(t, "127.0.0.1"),
+			clientTLS: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			mode:      entity.NutTLSOptional,
+			wantErr:   nutclient.ErrTLSHandshake,
+		},
+		{
+			name:      "off",
+			serverTLS: ca.serverTLSConfig(t, "127.0.0.1"),
+			mode:      entity.NutTLSOff,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []nutserver.Option{nutserver.WithUser("upsmon", "big secret")}
+			if tt.serverTLS != nil {
+				opts = append(opts, nutserver.WithTLS(tt.serverTLS))
+			}
+			server := startServer(t, opts...)
+
+			client, err := nutclient.Dial(server.address(), nutclient.Options{TLSConfig: tt.clientTLS, TLSMode: tt.mode})
+			require.ErrorIs(t, err, tt.wantErr)
+			if err != nil {
+				assert.Empty(t, server.received(t), "credentials sent")
+				return
+			}
+			defer func() { assert.NoError(t, client.Disconnect()) }()
+			assert.Equal(t, tt.wantTLS, client.TLS())
+
+			ok, err := client.Authenticate("upsmon", "big secret")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, []credential{
+				{Command: "USERNAME", TLS: tt.wantTLS},
+				{Command: "PASSWORD", TLS: tt.wantTLS},
+			}, server.received(t))
+		})
+	}
+}
+
+func TestDial_clientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	serverTLS := ca.serverTLSConfig(t, "127.0.0.1")
+	serverTLS.ClientAuth = tls.RequireAndVerifyClientCert
+	serverTLS.ClientCAs = ca.pool()
+	server := startServer(t, nutserver.WithTLS(serverTLS))
+
+	certPEM, keyPEM := ca.issue(t, x509.ExtKeyUsageClientAuth, "upswake")
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	t.Run("with certificate", func(t *testing.T) {
+		client, err := nutclient.Dial(server.address(), nutclient.Options{
+			TLSConfig: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1", Certificates: []tls.Certificate{cert}},
+			TLSMode:   entity.NutTLSRequired,
+		})
+		require.NoError(t, err)
+		assert.True(t, client.TLS())
+		assert.NoError(t, client.Disconnect())
+	})
+
+	t.Run("without certificate", func(t *testing.T) {
+		client, err := nutclient.Dial(server.address(), nutclient.Options{
+			TLSConfig: &tls.Config{RootCAs: ca.pool(), ServerName: "127.0.0.1"},
+			TLSMode:   entity.NutTLSRequired,
+		})
+		if err == nil {
+			// with TLS 1.3 the server's rejection arrives after the handshake
+			_, err = client.Authenticate("upsmon", "upsmon")
+			_ = client.Close()
+		}
+		assert.Error(t, err)
+		assert.Empty(t, server.received(t))
+	})
+}
+
+func TestDial_connectionRefused(t *testing.T) {
+	server := startServer(t)
+	address := server.address()
+	require.NoError(t, server.Close())
+
+	_, err := nutclient.Dial(address, nutclient.Options{})
+	assert.Error(t, err)
+}
+
+func TestClient_serverError(t *testing.T) {
+	server := startServer(t, nutserver.WithUser("upsmon", "upsmon"))
+	client, err := nutclient.Dial(server.address(), nutclient.Options{})
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	_, err = client.GetUPSList()
+	assert.ErrorIs(t, err, nutclient.ErrServer)
+	assert.ErrorContains(t, err, "ACCESS-DENIED")
+}
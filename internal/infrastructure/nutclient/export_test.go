@@ -0,0 +1,4 @@
+package nutclient
+
+// ParseType exports parseType for the tests in nutclient_test.
+var ParseType = parseType
@@ -0,0 +1,182 @@
+package nutclient_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testUPSes = nutserver.StaticSource{{
+	Name:        "cyberpower900",
+	Description: "Simulated UPS for testing",
+	Variables: map[string]string{
+		"battery.charge":    "85",
+		"battery.voltage":   "13.5",
+		"driver.version":    "2.8.3",
+		"ups.beeper.status": "enabled",
+		"ups.mfr":           `Dummy "Quoted" Manufacturer`,
+		"ups.status":        "OL",
+	},
+}}
+
+// credential is a USERNAME or PASSWORD the server received.
+type credential struct {
+	Command string `json:"command"`
+	TLS     bool   `json:"tls"`
+}
+
+// testServer is a nutserver.Server that remembers the commands it received.
+type testServer struct {
+	*nutserver.Server
+	log *lockedBuffer
+}
+
+// startServer starts a server on a free port, stopping it when the test
+// ends.
+func startServer(t *testing.T, opts ...nutserver.Option) *testServer {
+	t.Helper()
+	log := &lockedBuffer{}
+	logger := slog.New(slog.NewJSONHandler(log, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	server := nutserver.NewServer(testUPSes, append(opts, nutserver.WithLogger(logger))...)
+	require.NoError(t, server.Listen("127.0.0.1:0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return &testServer{Server: server, log: log}
+}
+
+func (s *testServer) address() string {
+	return s.Addr().String()
+}
+
+// received returns the credentials received so far.
+func (s *testServer) received(t *testing.T) []credential {
+	t.Helper()
+	credentials := []credential{}
+	for line := range strings.Lines(s.log.String()) {
+		var received credential
+		require.NoError(t, json.Unmarshal([]byte(line), &received))
+		if received.Command == "USERNAME" || received.Command == "PASSWORD" {
+			credentials = append(credentials, received)
+		}
+	}
+	return credentials
+}
+
+// lockedBuffer is a buffer the server can log to while a test reads it.
+type lockedBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// testCA is a certificate authority for the test server's and clients'
+// certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "UPSWake test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issue returns a certificate and key signed by the CA for the given DNS
+// names and IP addresses.
+func (ca *testCA) issue(t *testing.T, usage x509.ExtKeyUsage, names ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// serverTLSConfig returns a TLS config for the test server with a certificate
+// for names.
+func (ca *testCA) serverTLSConfig(t *testing.T, names ...string) *tls.Config {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, x509.ExtKeyUsageServerAuth, names...)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+}
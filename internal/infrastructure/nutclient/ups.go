@@ -0,0 +1,267 @@
+package nutclient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutprotocol"
+	nut "github.com/robbiet480/go.nut"
+)
+
+// numeric matches values converted to numbers, as go.nut does.
+var numeric = regexp.MustCompile(`^-?[0-9.]+$`)
+
+// GetUPSList returns every UPS on the NUT server with its clients,
+// commands and variables. The UPSes are in the same shape, and their
+// variables converted in the same way, as go.nut's, so rules see the same
+// input whichever client fetched it.
+func (c *Client) GetUPSList() ([]nut.UPS, error) {
+	lines, err := c.list("UPS")
+	if err != nil {
+		return nil, err
+	}
+
+	upsList := []nut.UPS{}
+	for _, line := range lines {
+		fields, err := c.fields(line, "UPS", 3)
+		if err != nil {
+			return nil, err
+		}
+		ups, err := c.getUPS(fields[1], fields[2])
+		if err != nil {
+			return nil, err
+		}
+		upsList = append(upsList, ups)
+	}
+	return upsList, nil
+}
+
+// GetVariables returns every UPS on the NUT server with only the named
+// variables, read with GET VAR. Variables a UPS doesn't have are left out.
+// Their types aren't read, so values are converted as go.nut would convert
+// a variable of an unknown type.
+func (c *Client) GetVariables(names ...string) ([]nut.UPS, error) {
+	lines, err := c.list("UPS")
+	if err != nil {
+		return nil, err
+	}
+
+	upsList := []nut.UPS{}
+	for _, line := range lines {
+		fields, err := c.fields(line, "UPS", 3)
+		if err != nil {
+			return nil, err
+		}
+		ups := nut.UPS{Name: fields[1], Description: fields[2]}
+		for _, name := range names {
+			value, err := c.get("VAR", ups.Name, name)
+			if isServerError(err, "VAR-NOT-SUPPORTED") {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			variable := nut.Variable{Name: name, Value: value}
+			convertValue(&variable, "UNKNOWN")
+			ups.Variables = append(ups.Variables, variable)
+		}
+		upsList = append(upsList, ups)
+	}
+	return upsList, nil
+}
+
+func (c *Client) getUPS(name, description string) (nut.UPS, error) {
+	ups := nut.UPS{
+		Name:        name,
+		Description: description,
+	}
+
+	var err error
+	if ups.Clients, err = c.getClients(name); err != nil {
+		return ups, err
+	}
+	if ups.Commands, err = c.getCommands(name); err != nil {
+		return ups, err
+	}
+	if ups.NumberOfLogins, err = c.getNumberOfLogins(name); err != nil {
+		return ups, err
+	}
+	if ups.Variables, err = c.getVariables(name); err != nil {
+		return ups, err
+	}
+	return ups, nil
+}
+
+func (c *Client) getClients(ups string) ([]string, error) {
+	lines, err := c.list("CLIENT " + ups)
+	if err != nil {
+		return nil, err
+	}
+	clients := []string{}
+	for _, line := range lines {
+		fields, err := c.fields(line, "CLIENT", 3)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, fields[2])
+	}
+	return clients, nil
+}
+
+func (c *Client) getCommands(ups string) ([]nut.Command, error) {
+	lines, err := c.list("CMD " + ups)
+	if err != nil {
+		return nil, err
+	}
+	commands := []nut.Command{}
+	for _, line := range lines {
+		fields, err := c.fields(line, "CMD", 3)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, nut.Command{Name: fields[2]})
+	}
+	for i := range commands {
+		if commands[i].Description, err = c.get("CMDDESC", ups, commands[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return commands, nil
+}
+
+func (c *Client) getNumberOfLogins(ups string) (int, error) {
+	logins, err := c.get("NUMLOGINS", ups)
+	if err != nil {
+		return 0, err
+	}
+	number, err := strconv.Atoi(logins)
+	if err != nil {
+		return 0, fmt.Errorf("%w: NUMLOGINS %q", ErrUnexpectedReply, logins)
+	}
+	return number, nil
+}
+
+func (c *Client) getVariables(ups string) ([]nut.Variable, error) {
+	lines, err := c.list("VAR " + ups)
+	if err != nil {
+		return nil, err
+	}
+	variables := []nut.Variable{}
+	for _, line := range lines {
+		fields, err := c.fields(line, "VAR", 4)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, nut.Variable{Name: fields[2], Value: fields[3]})
+	}
+
+	for i := range variables {
+		variable := &variables[i]
+		if variable.Description, err = c.get("DESC", ups, variable.Name); err != nil {
+			return nil, err
+		}
+		typeFields, err := c.getFields("TYPE", ups, variable.Name)
+		if err != nil {
+			return nil, err
+		}
+		varType, writeable, maximumLength := parseType(typeFields)
+		variable.Writeable = writeable
+		variable.MaximumLength = maximumLength
+		convertValue(variable, varType)
+	}
+	return variables, nil
+}
+
+// parseType parses the flags of a TYPE reply, e.g. RW STRING:32, into the
+// variable's type, whether it is writeable and its maximum length.
+func parseType(flags []string) (string, bool, int) {
+	if len(flags) == 0 {
+		return "UNKNOWN", false, 0
+	}
+	if flags[0] != "RW" || len(flags) < 2 {
+		return flags[0], false, 0
+	}
+	varType, length, ok := strings.Cut(flags[1], ":")
+	if !ok {
+		return varType, true, 0
+	}
+	maximumLength, err := strconv.Atoi(length)
+	if err != nil {
+		return varType, true, -1
+	}
+	return varType, true, maximumLength
+}
+
+// convertValue converts the string value of a variable to a boolean or
+// number, setting its Type and OriginalType as go.nut does.
+func convertValue(variable *nut.Variable, varType string) {
+	value, _ := variable.Value.(string)
+	variable.Type = varType
+
+	switch value {
+	case "enabled":
+		variable.Value = true
+		variable.Type = "BOOLEAN"
+	case "disabled":
+		variable.Value = false
+		variable.Type = "BOOLEAN"
+	}
+
+	if !numeric.MatchString(value) {
+		variable.Type = "STRING"
+		variable.OriginalType = varType
+		return
+	}
+	if strings.Count(value, ".") == 1 {
+		if converted, err := strconv.ParseFloat(value, 64); err == nil {
+			variable.Value = converted
+			variable.Type = "FLOAT_64"
+			variable.OriginalType = varType
+		}
+		return
+	}
+	// values such as 2.8.3 are left as strings with the type upsd reported
+	if converted, err := strconv.ParseInt(value, 10, 64); err == nil {
+		variable.Value = converted
+		variable.Type = "INTEGER"
+		variable.OriginalType = varType
+	}
+}
+
+// get sends a GET command and returns the value in its reply.
+func (c *Client) get(kind string, args ...string) (string, error) {
+	fields, err := c.getFields(kind, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// getFields sends a GET command and returns the fields of its reply after
+// the echoed arguments.
+func (c *Client) getFields(kind string, args ...string) ([]string, error) {
+	reply, err := c.command("GET " + kind + " " + strings.Join(args, " "))
+	if err != nil {
+		return nil, err
+	}
+	fields, err := c.fields(reply, kind, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	return fields[len(args)+1:], nil
+}
+
+// fields splits a reply line, checking it starts with kind and has at
+// least n fields.
+func (*Client) fields(line, kind string, n int) ([]string, error) {
+	fields, err := nutprotocol.SplitFields(line)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnexpectedReply, err)
+	}
+	if len(fields) < n || fields[0] != kind {
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedReply, line)
+	}
+	return fields, nil
+}
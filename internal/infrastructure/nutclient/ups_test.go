@@ -0,0 +1,77 @@
+package nutclient_test
+
+import (
+	"testing"
+
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	nut "github.com/robbiet480/go.nut"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetUPSList(t *testing.T) {
+	server := startServer(t)
+	client, err := nutclient.Dial(server.address(), nutclient.Options{})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, client.Disconnect()) }()
+
+	got, err := client.GetUPSList()
+	require.NoError(t, err)
+
+	want := []nut.UPS{{
+		Name:        "cyberpower900",
+		Description: "Simulated UPS for testing",
+		Clients:     []string{},
+		Commands:    []nut.Command{},
+		Variables: []nut.Variable{
+			{Name: "battery.charge", Value: int64(85), Type: "INTEGER", Description: "Description unavailable", OriginalType: "NUMBER"},
+			{Name: "battery.voltage", Value: 13.5, Type: "FLOAT_64", Description: "Description unavailable", OriginalType: "NUMBER"},
+			{Name: "driver.version", Value: "2.8.3", Type: "STRING:64", Description: "Description unavailable"},
+			{Name: "ups.beeper.status", Value: true, Type: "STRING", Description: "Description unavailable", OriginalType: "STRING:64"},
+			{Name: "ups.mfr", Value: `Dummy "Quoted" Manufacturer`, Type: "STRING", Description: "Description unavailable", OriginalType: "STRING:64"},
+			{Name: "ups.status", Value: "OL", Type: "STRING", Description: "Description unavailable", OriginalType: "STRING:64"},
+		},
+	}}
+	assert.Equal(t, want, got)
+}
+
+func TestClient_GetVariables(t *testing.T) {
+	server := startServer(t)
+	client, err := nutclient.Dial(server.address(), nutclient.Options{})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, client.Disconnect()) }()
+
+	got, err := client.GetVariables("ups.status", "battery.charge", "ups.model")
+	require.NoError(t, err)
+
+	want := []nut.UPS{{
+		Name:        "cyberpower900",
+		Description: "Simulated UPS for testing",
+		Variables: []nut.Variable{
+			{Name: "ups.status", Value: "OL", Type: "STRING", OriginalType: "UNKNOWN"},
+			{Name: "battery.charge", Value: int64(85), Type: "INTEGER", OriginalType: "UNKNOWN"},
+		},
+	}}
+	assert.Equal(t, want, got, "variables the UPS doesn't have are left out")
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		flags         []string
+		wantType      string
+		wantMaxLength int
+		wantWriteable bool
+	}{
+		{flags: []string{"NUMBER"}, wantType: "NUMBER"},
+		{flags: []string{"RW", "STRING:32"}, wantType: "STRING", wantWriteable: true, wantMaxLength: 32},
+		{flags: []string{"RW", "ENUM"}, wantType: "ENUM", wantWriteable: true},
+		{flags: []string{"RW", "STRING:x"}, wantType: "STRING", wantWriteable: true, wantMaxLength: -1},
+		{flags: nil, wantType: "UNKNOWN"},
+	}
+	for _, tt := range tests {
+		varType, writeable, maxLength := nutclient.ParseType(tt.flags)
+		assert.Equal(t, tt.wantType, varType, tt.flags)
+		assert.Equal(t, tt.wantWriteable, writeable, tt.flags)
+		assert.Equal(t, tt.wantMaxLength, maxLength, tt.flags)
+	}
+}
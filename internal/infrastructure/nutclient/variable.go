@@ -0,0 +1,37 @@
+package nutclient
+
+import (
+	"math"
+
+	nut "github.com/robbiet480/go.nut"
+)
+
+// StringVariable returns a read-only STRING variable, for UPS sources
+// other than NUT that map their values to NUT variables.
+func StringVariable(name, description, value string) nut.Variable {
+	return nut.Variable{
+		Name:         name,
+		Value:        value,
+		Type:         "STRING",
+		Description:  description,
+		OriginalType: "STRING",
+	}
+}
+
+// NumberVariable returns a read-only number variable typed the way numbers
+// read from a NUT server are, as an INTEGER if it is whole and a FLOAT_64
+// otherwise.
+func NumberVariable(name, description string, value float64) nut.Variable {
+	variable := nut.Variable{
+		Name:         name,
+		Value:        value,
+		Type:         "FLOAT_64",
+		Description:  description,
+		OriginalType: "NUMBER",
+	}
+	if value == math.Trunc(value) {
+		variable.Value = int64(value)
+		variable.Type = "INTEGER"
+	}
+	return variable
+}
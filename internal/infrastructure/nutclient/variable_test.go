@@ -0,0 +1,43 @@
+package nutclient_test
+
+import (
+	"testing"
+
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	nut "github.com/robbiet480/go.nut"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringVariable(t *testing.T) {
+	assert.Equal(t, nut.Variable{
+		Name:         "ups.model",
+		Value:        "Smart-UPS 750",
+		Type:         "STRING",
+		Description:  "UPS model",
+		OriginalType: "STRING",
+	}, nutclient.StringVariable("ups.model", "UPS model", "Smart-UPS 750"))
+}
+
+func TestNumberVariable(t *testing.T) {
+	tests := []struct {
+		want  nut.Variable
+		name  string
+		value float64
+	}{
+		{
+			name:  "whole",
+			value: 62,
+			want:  nut.Variable{Name: "battery.charge", Value: int64(62), Type: "INTEGER", Description: "charge", OriginalType: "NUMBER"},
+		},
+		{
+			name:  "fractional",
+			value: 26.8,
+			want:  nut.Variable{Name: "battery.charge", Value: 26.8, Type: "FLOAT_64", Description: "charge", OriginalType: "NUMBER"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nutclient.NumberVariable("battery.charge", "charge", tt.value))
+		})
+	}
+}
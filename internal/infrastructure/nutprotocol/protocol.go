@@ -0,0 +1,59 @@
+// Package nutprotocol holds the parts of the network protocol of NUT's upsd
+// that both its client and the fake upsd need.
+package nutprotocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// SplitFields splits a line of the NUT protocol on spaces, treating text in
+// double quotes as a single field and unescaping it.
+func SplitFields(line string) ([]string, error) {
+	var (
+		fields  []string
+		field   strings.Builder
+		inField bool
+		quoted  bool
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case r == ' ' && !quoted:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quoted || escaped {
+		return nil, fmt.Errorf("%w in %q", ErrUnterminatedQuote, line)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// Quote quotes an argument if it can't be sent as it is.
+func Quote(arg string) string {
+	if !strings.ContainsAny(arg, " \"\\") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
@@ -0,0 +1,50 @@
+package nutprotocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		line    string
+		want    []string
+	}{
+		{name: "plain", line: "NUMLOGINS cyberpower900 1", want: []string{"NUMLOGINS", "cyberpower900", "1"}},
+		{name: "quoted", line: `VAR ups ups.mfr "Dummy Manufacturer"`, want: []string{"VAR", "ups", "ups.mfr", "Dummy Manufacturer"}},
+		{name: "escaped", line: `VAR ups ups.mfr "a \"b\" \\c"`, want: []string{"VAR", "ups", "ups.mfr", `a "b" \c`}},
+		{name: "empty quoted", line: `VAR ups ups.mfr ""`, want: []string{"VAR", "ups", "ups.mfr", ""}},
+		{name: "repeated spaces", line: "TYPE  ups  var  RW", want: []string{"TYPE", "ups", "var", "RW"}},
+		{name: "unterminated", line: `VAR ups ups.mfr "Dummy`, wantErr: ErrUnterminatedQuote},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitFields(tt.line)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"upsmon":       "upsmon",
+		"":             "",
+		"big secret":   `"big secret"`,
+		`pass"word`:    `"pass\"word"`,
+		`back\slash`:   `"back\\slash"`,
+		`both \ and "`: `"both \\ and \""`,
+	}
+	for arg, want := range tests {
+		assert.Equal(t, want, Quote(arg), arg)
+		if arg != "" {
+			fields, err := SplitFields("PASSWORD " + Quote(arg))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"PASSWORD", arg}, fields)
+		}
+	}
+}
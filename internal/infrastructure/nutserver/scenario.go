@@ -0,0 +1,176 @@
+package nutserver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrReadingScenario = errors.New("error reading scenario file")
+	ErrInvalidScenario = errors.New("scenario is invalid")
+)
+
+// Scenario scripts how the state of one or more UPSes changes over time.
+type Scenario struct {
+	UPS []ScenarioUPS `yaml:"ups"`
+}
+
+// ScenarioUPS is a UPS's initial state and the events that change it.
+type ScenarioUPS struct {
+	// Vars are the UPS's initial variables. Numbers are written as numbers.
+	Vars        map[string]any `yaml:"vars"`
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Events      []Event        `yaml:"events"`
+}
+
+// Event changes a UPS's variables At a time after the scenario starts.
+// Set replaces variables, and Change adds to numeric variables. If Every
+// is set, Change is applied again every interval until Until, or for the
+// rest of the scenario if Until isn't set.
+type Event struct {
+	Set    map[string]any     `yaml:"set"`
+	Change map[string]float64 `yaml:"change"`
+	At     time.Duration      `yaml:"at"`
+	Every  time.Duration      `yaml:"every"`
+	Until  time.Duration      `yaml:"until"`
+}
+
+// LoadScenario reads a Scenario from a YAML file.
+func LoadScenario(fs afero.Fs, path string) (*Scenario, error) {
+	raw, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadingScenario, err)
+	}
+
+	scenario := &Scenario{}
+	if err := yaml.Unmarshal(raw, scenario); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadingScenario, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+func (s *Scenario) Validate() error {
+	if len(s.UPS) == 0 {
+		return fmt.Errorf("%w: no UPSes", ErrInvalidScenario)
+	}
+	names := make(map[string]bool, len(s.UPS))
+	for _, ups := range s.UPS {
+		if ups.Name == "" {
+			return fmt.Errorf("%w: UPS without a name", ErrInvalidScenario)
+		}
+		if names[ups.Name] {
+			return fmt.Errorf("%w: more than one UPS named %s", ErrInvalidScenario, ups.Name)
+		}
+		names[ups.Name] = true
+
+		for i, event := range ups.Events {
+			if err := event.validate(); err != nil {
+				return fmt.Errorf("%w: UPS %s event %d: %w", ErrInvalidScenario, ups.Name, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Event) validate() error {
+	switch {
+	case e.At < 0 || e.Every < 0 || e.Until < 0:
+		return errors.New("times must not be negative")
+	case len(e.Set) == 0 && len(e.Change) == 0:
+		return errors.New("event must set or change a variable")
+	case e.Every == 0 && e.Until != 0:
+		return errors.New("until requires every")
+	case e.Until != 0 && e.Until < e.At:
+		return errors.New("until must not be before at")
+	}
+	return nil
+}
+
+// State returns the UPSes as they are elapsed after the scenario starts.
+// Events are applied in the order they are written.
+func (s *Scenario) State(elapsed time.Duration) []UPS {
+	upses := make([]UPS, 0, len(s.UPS))
+	for _, scenarioUPS := range s.UPS {
+		ups := UPS{
+			Name:        scenarioUPS.Name,
+			Description: scenarioUPS.Description,
+			Variables:   make(map[string]string, len(scenarioUPS.Vars)),
+		}
+		setVariables(ups.Variables, scenarioUPS.Vars)
+		for _, event := range scenarioUPS.Events {
+			event.apply(ups.Variables, elapsed)
+		}
+		upses = append(upses, ups)
+	}
+	return upses
+}
+
+// apply applies the event to variables as of elapsed.
+func (e *Event) apply(variables map[string]string, elapsed time.Duration) {
+	if elapsed < e.At {
+		return
+	}
+	setVariables(variables, e.Set)
+
+	times := 1.0
+	if e.Every > 0 {
+		end := elapsed
+		if e.Until != 0 {
+			end = min(end, e.Until)
+		}
+		times = float64((end-e.At)/e.Every + 1)
+	}
+	for name, delta := range e.Change {
+		value, err := strconv.ParseFloat(variables[name], 64)
+		if err != nil {
+			// only numeric variables can be changed
+			continue
+		}
+		variables[name] = strconv.FormatFloat(value+delta*times, 'f', -1, 64)
+	}
+}
+
+func setVariables(variables map[string]string, values map[string]any) {
+	for name, value := range values {
+		variables[name] = fmt.Sprint(value)
+	}
+}
+
+// Player is a Source that plays a Scenario in real time.
+type Player struct {
+	start    time.Time
+	now      func() time.Time
+	scenario *Scenario
+	mu       sync.Mutex
+}
+
+// NewPlayer creates a Player that starts playing scenario when it is
+// created.
+func NewPlayer(scenario *Scenario) *Player {
+	return &Player{
+		start:    time.Now(),
+		now:      time.Now,
+		scenario: scenario,
+	}
+}
+
+// Elapsed returns how long the scenario has been playing.
+func (p *Player) Elapsed() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.now().Sub(p.start)
+}
+
+func (p *Player) UPSes() []UPS {
+	return p.scenario.State(p.Elapsed())
+}
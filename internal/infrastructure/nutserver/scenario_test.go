@@ -0,0 +1,177 @@
+package nutserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var (
+	_ Source = (*Player)(nil)
+	_ Source = StaticSource(nil)
+)
+
+func TestLoadScenario(t *testing.T) {
+	testFS := afero.NewBasePathFs(afero.NewOsFs(), "./testing/")
+	require.NoError(t, afero.WriteFile(testFS, "/tmp-malformed.yaml", []byte("ups: ["), 0o644))
+	t.Cleanup(func() { _ = testFS.Remove("/tmp-malformed.yaml") })
+
+	tests := []struct {
+		wantErr error
+		name    string
+		path    string
+	}{
+		{name: "power cut", path: "power_cut.yaml"},
+		{name: "invalid event", path: "invalid_event.yaml", wantErr: ErrInvalidScenario},
+		{name: "missing file", path: "missing.yaml", wantErr: ErrReadingScenario},
+		{name: "malformed file", path: "tmp-malformed.yaml", wantErr: ErrReadingScenario},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadScenario(testFS, tt.path)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if err == nil {
+				require.Len(t, got.UPS, 1)
+				assert.Equal(t, "cyberpower900", got.UPS[0].Name)
+				assert.Len(t, got.UPS[0].Events, 3)
+				assert.Equal(t, 30*time.Second, got.UPS[0].Events[1].At)
+				assert.Equal(t, 10*time.Second, got.UPS[0].Events[1].Every)
+			}
+		})
+	}
+}
+
+func TestScenario_Validate(t *testing.T) {
+	vars := map[string]any{"ups.status": "OL"}
+	tests := []struct {
+		wantErr  error
+		name     string
+		scenario Scenario
+	}{
+		{
+			name:     "valid",
+			scenario: Scenario{UPS: []ScenarioUPS{{Name: "ups", Vars: vars}}},
+		},
+		{
+			name:     "no UPSes",
+			scenario: Scenario{},
+			wantErr:  ErrInvalidScenario,
+		},
+		{
+			name:     "UPS without name",
+			scenario: Scenario{UPS: []ScenarioUPS{{Vars: vars}}},
+			wantErr:  ErrInvalidScenario,
+		},
+		{
+			name:     "duplicate UPS",
+			scenario: Scenario{UPS: []ScenarioUPS{{Name: "ups"}, {Name: "ups"}}},
+			wantErr:  ErrInvalidScenario,
+		},
+		{
+			name:     "empty event",
+			scenario: Scenario{UPS: []ScenarioUPS{{Name: "ups", Events: []Event{{At: time.Second}}}}},
+			wantErr:  ErrInvalidScenario,
+		},
+		{
+			name:     "negative time",
+			scenario: Scenario{UPS: []ScenarioUPS{{Name: "ups", Events: []Event{{At: -time.Second, Set: vars}}}}},
+			wantErr:  ErrInvalidScenario,
+		},
+		{
+			name:     "until without every",
+			scenario: Scenario{UPS: []ScenarioUPS{{Name: "ups", Events: []Event{{Until: time.Minute, Set: vars}}}}},
+			wantErr:  ErrInvalidScenario,
+		},
+		{
+			name: "until before at",
+			scenario: Scenario{UPS: []ScenarioUPS{{Name: "ups", Events: []Event{
+				{At: time.Minute, Every: time.Second, Until: time.Second, Change: map[string]float64{"battery.charge": -1}},
+			}}}},
+			wantErr: ErrInvalidScenario,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.scenario.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestScenario_State(t *testing.T) {
+	scenario, err := LoadScenario(afero.NewBasePathFs(afero.NewOsFs(), "./testing/"), "power_cut.yaml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		want    map[string]string
+		elapsed time.Duration
+	}{
+		{
+			elapsed: 0,
+			want:    map[string]string{"battery.charge": "100", "battery.runtime": "3600", "input.voltage": "230.5", "ups.status": "OL"},
+		},
+		{
+			elapsed: 29 * time.Second,
+			want:    map[string]string{"battery.charge": "100", "battery.runtime": "3600", "input.voltage": "230.5", "ups.status": "OL"},
+		},
+		{
+			elapsed: 30 * time.Second,
+			want:    map[string]string{"battery.charge": "99", "battery.runtime": "3540", "input.voltage": "0", "ups.status": "OB DISCHRG"},
+		},
+		{
+			elapsed: 65 * time.Second,
+			want:    map[string]string{"battery.charge": "96", "battery.runtime": "3360", "input.voltage": "0", "ups.status": "OB DISCHRG"},
+		},
+		{
+			elapsed: 5 * time.Minute,
+			want:    map[string]string{"battery.charge": "72", "battery.runtime": "1920", "input.voltage": "230.5", "ups.status": "OL CHRG"},
+		},
+		{
+			elapsed: time.Hour,
+			want:    map[string]string{"battery.charge": "72", "battery.runtime": "1920", "input.voltage": "230.5", "ups.status": "OL CHRG"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.elapsed.String(), func(t *testing.T) {
+			got := scenario.State(tt.elapsed)
+			require.Len(t, got, 1)
+			assert.Equal(t, "cyberpower900", got[0].Name)
+			assert.Equal(t, "Simulated UPS for testing", got[0].Description)
+			assert.Equal(t, tt.want, got[0].Variables)
+		})
+	}
+}
+
+func TestScenario_State_changeWithoutEvery(t *testing.T) {
+	scenario := &Scenario{UPS: []ScenarioUPS{{
+		Name: "ups",
+		Vars: map[string]any{"battery.charge": 50, "ups.status": "OL"},
+		Events: []Event{
+			{At: time.Minute, Change: map[string]float64{"battery.charge": 10.5, "ups.status": 1}},
+		},
+	}}}
+
+	assert.Equal(t, "50", scenario.State(time.Second)[0].Variables["battery.charge"])
+	got := scenario.State(time.Hour)[0].Variables
+	assert.Equal(t, "60.5", got["battery.charge"])
+	assert.Equal(t, "OL", got["ups.status"], "non-numeric variables are not changed")
+}
+
+func TestPlayer_UPSes(t *testing.T) {
+	scenario := &Scenario{UPS: []ScenarioUPS{{
+		Name:   "ups",
+		Vars:   map[string]any{"ups.status": "OL"},
+		Events: []Event{{At: time.Minute, Set: map[string]any{"ups.status": "OB"}}},
+	}}}
+	player := NewPlayer(scenario)
+	now := player.start
+	player.now = func() time.Time { return now }
+
+	assert.Equal(t, "OL", player.UPSes()[0].Variables["ups.status"])
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Minute, player.Elapsed())
+	assert.Equal(t, "OB", player.UPSes()[0].Variables["ups.status"])
+}
@@ -0,0 +1,416 @@
+// Package nutserver is a fake NUT upsd, implementing enough of its network
+// protocol to serve scripted UPS states to UPSWake and other NUT clients in
+// tests and demos.
+package nutserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutprotocol"
+)
+
+// Version is the server version reported by VER.
+const Version = "Network UPS Tools upsd 2.8.3 - UPSWake simulator"
+
+// UPS is the state of a UPS at a point in time.
+type UPS struct {
+	// Variables holds the UPS's variables, such as battery.charge, by name
+	Variables   map[string]string
+	Name        string
+	Description string
+}
+
+// Source supplies the UPSes the server reports. It is asked for their
+// state whenever a client reads them.
+type Source interface {
+	UPSes() []UPS
+}
+
+// StaticSource is a Source whose UPSes never change.
+type StaticSource []UPS
+
+func (s StaticSource) UPSes() []UPS {
+	return s
+}
+
+// Server is a fake upsd.
+type Server struct {
+	source   Source
+	listener net.Listener
+	logger   *slog.Logger
+	// tlsConfig enables STARTTLS if it is set
+	tlsConfig *tls.Config
+	// users holds the password of each user allowed to log in. If it is
+	// empty, any username and password are accepted.
+	users  map[string]string
+	conns  map[net.Conn]struct{}
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+}
+
+// Option configures optional behaviour of a Server.
+type Option func(*Server)
+
+// WithTLS allows clients to upgrade their connection with STARTTLS, using
+// config for the server's side of the handshake.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = config
+	}
+}
+
+// WithUser only allows clients that log in as username with password to
+// read UPSes. It can be given more than once.
+func WithUser(username, password string) Option {
+	return func(s *Server) {
+		s.users[username] = password
+	}
+}
+
+// WithLogger logs the commands clients send at debug level to logger, and
+// whether they were sent over TLS.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewServer creates a Server reporting the UPSes from source.
+func NewServer(source Source, opts ...Option) *Server {
+	s := &Server{
+		source: source,
+		logger: slog.New(slog.DiscardHandler),
+		users:  make(map[string]string),
+		conns:  make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Listen starts listening on address, such as 127.0.0.1:3493. Port 0
+// picks a free port, which Addr returns.
+func (s *Server) Listen(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listener.Addr()
+}
+
+// Serve accepts connections until ctx is done or the server is closed. The
+// server must be listening.
+func (s *Server) Serve(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() { _ = s.Close() })
+	defer stop()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops listening, closes every connection and waits for them to
+// finish. The server must be listening.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.listener.Close()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// session is a client's connection.
+type session struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	username string
+	password string
+	tls      bool
+}
+
+func (s *Server) handle(conn net.Conn) {
+	sess := &session{conn: conn, reader: bufio.NewReader(conn)}
+	defer func() { _ = sess.conn.Close() }()
+
+	for {
+		line, err := sess.reader.ReadString('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Debug("Error reading from NUT client", slog.Any("error", err))
+			}
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		fields, err := nutprotocol.SplitFields(line)
+		if err != nil || len(fields) == 0 {
+			sess.reply("ERR INVALID-ARGUMENT")
+			continue
+		}
+		s.logger.Debug("NUT command",
+			slog.String("remote", conn.RemoteAddr().String()),
+			slog.String("command", fields[0]),
+			slog.Bool("tls", sess.tls))
+
+		if done := s.command(sess, fields); done {
+			return
+		}
+	}
+}
+
+// command runs a single command, returning true if the connection should
+// be closed.
+func (s *Server) command(sess *session, fields []string) bool {
+	args := fields[1:]
+	switch fields[0] {
+	case "VER":
+		sess.reply(Version)
+	case "NETVER":
+		sess.reply("1.3")
+	case "HELP":
+		sess.reply("Commands: HELP VER GET LIST SET INSTCMD LOGIN LOGOUT USERNAME PASSWORD STARTTLS")
+	case "STARTTLS":
+		return s.startTLS(sess)
+	case "USERNAME":
+		s.credential(sess, &sess.username, "USERNAME", args)
+	case "PASSWORD":
+		s.credential(sess, &sess.password, "PASSWORD", args)
+	case "LOGIN":
+		sess.reply("OK")
+	case "LOGOUT":
+		sess.reply("OK Goodbye")
+		return true
+	case "LIST":
+		s.list(sess, args)
+	case "GET":
+		s.get(sess, args)
+	default:
+		sess.reply("ERR UNKNOWN-COMMAND")
+	}
+	return false
+}
+
+func (s *Server) startTLS(sess *session) bool {
+	switch {
+	case sess.tls:
+		sess.reply("ERR ALREADY-SSL-MODE")
+		return false
+	case s.tlsConfig == nil:
+		sess.reply("ERR FEATURE-NOT-CONFIGURED")
+		return false
+	}
+
+	sess.reply("OK STARTTLS")
+	conn := tls.Server(sess.conn, s.tlsConfig)
+	if err := conn.Handshake(); err != nil {
+		s.logger.Debug("TLS handshake with NUT client failed", slog.Any("error", err))
+		return true
+	}
+	sess.conn = conn
+	sess.reader = bufio.NewReader(conn)
+	sess.tls = true
+	return false
+}
+
+func (*Server) credential(sess *session, field *string, name string, args []string) {
+	switch {
+	case len(args) != 1:
+		sess.reply("ERR INVALID-ARGUMENT")
+	case *field != "":
+		sess.reply("ERR ALREADY-SET-" + name)
+	default:
+		*field = args[0]
+		sess.reply("OK")
+	}
+}
+
+// authorised returns whether the session may read UPSes, replying with an
+// error if it may not.
+func (s *Server) authorised(sess *session) bool {
+	if len(s.users) == 0 {
+		return true
+	}
+	if password, ok := s.users[sess.username]; ok && sess.username != "" && password == sess.password {
+		return true
+	}
+	sess.reply("ERR ACCESS-DENIED")
+	return false
+}
+
+// ups returns the named UPS, replying with an error if it doesn't exist.
+func (s *Server) ups(sess *session, name string) (UPS, bool) {
+	for _, ups := range s.source.UPSes() {
+		if ups.Name == name {
+			return ups, true
+		}
+	}
+	sess.reply("ERR UNKNOWN-UPS")
+	return UPS{}, false
+}
+
+func (s *Server) list(sess *session, args []string) {
+	if len(args) == 0 {
+		sess.reply("ERR INVALID-ARGUMENT")
+		return
+	}
+	if !s.authorised(sess) {
+		return
+	}
+
+	if args[0] == "UPS" {
+		lines := []string{}
+		for _, ups := range s.source.UPSes() {
+			lines = append(lines, fmt.Sprintf("UPS %s %s", ups.Name, quote(ups.Description)))
+		}
+		sess.replyList("UPS", lines)
+		return
+	}
+
+	if len(args) != 2 {
+		sess.reply("ERR INVALID-ARGUMENT")
+		return
+	}
+	ups, ok := s.ups(sess, args[1])
+	if !ok {
+		return
+	}
+	header := args[0] + " " + ups.Name
+
+	switch args[0] {
+	case "VAR":
+		lines := []string{}
+		for _, name := range slices.Sorted(maps.Keys(ups.Variables)) {
+			lines = append(lines, fmt.Sprintf("VAR %s %s %s", ups.Name, name, quote(ups.Variables[name])))
+		}
+		sess.replyList(header, lines)
+	case "RW", "CMD", "CLIENT":
+		// the simulated UPSes have no writable variables or commands, and
+		// nothing logs in to them
+		sess.replyList(header, nil)
+	default:
+		sess.reply("ERR INVALID-ARGUMENT")
+	}
+}
+
+func (s *Server) get(sess *session, args []string) {
+	if len(args) < 2 {
+		sess.reply("ERR INVALID-ARGUMENT")
+		return
+	}
+	if !s.authorised(sess) {
+		return
+	}
+	ups, ok := s.ups(sess, args[1])
+	if !ok {
+		return
+	}
+
+	switch {
+	case args[0] == "UPSDESC" && len(args) == 2:
+		sess.reply(fmt.Sprintf("UPSDESC %s %s", ups.Name, quote(ups.Description)))
+	case args[0] == "NUMLOGINS" && len(args) == 2:
+		sess.reply(fmt.Sprintf("NUMLOGINS %s 0", ups.Name))
+	case args[0] == "CMDDESC" && len(args) == 3:
+		sess.reply("ERR CMD-NOT-SUPPORTED")
+	case (args[0] == "VAR" || args[0] == "TYPE" || args[0] == "DESC") && len(args) == 3:
+		value, ok := ups.Variables[args[2]]
+		if !ok {
+			sess.reply("ERR VAR-NOT-SUPPORTED")
+			return
+		}
+		switch args[0] {
+		case "VAR":
+			sess.reply(fmt.Sprintf("VAR %s %s %s", ups.Name, args[2], quote(value)))
+		case "TYPE":
+			sess.reply(fmt.Sprintf("TYPE %s %s %s", ups.Name, args[2], variableType(value)))
+		case "DESC":
+			sess.reply(fmt.Sprintf("DESC %s %s %s", ups.Name, args[2], quote("Description unavailable")))
+		}
+	default:
+		sess.reply("ERR INVALID-ARGUMENT")
+	}
+}
+
+// variableType returns the type upsd would report for a variable with
+// value.
+func variableType(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return "NUMBER"
+	}
+	return "STRING:64"
+}
+
+func (sess *session) reply(line string) {
+	_, _ = fmt.Fprintf(sess.conn, "%s\n", line)
+}
+
+func (sess *session) replyList(header string, lines []string) {
+	var reply strings.Builder
+	reply.WriteString("BEGIN LIST " + header + "\n")
+	for _, line := range lines {
+		reply.WriteString(line + "\n")
+	}
+	reply.WriteString("END LIST " + header + "\n")
+	_, _ = io.WriteString(sess.conn, reply.String())
+}
+
+// quote quotes a value for a reply.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
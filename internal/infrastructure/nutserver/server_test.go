@@ -0,0 +1,317 @@
+package nutserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	nut "github.com/robbiet480/go.nut"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testUPSes = StaticSource{{
+	Name:        "cyberpower900",
+	Description: "Simulated UPS for testing",
+	Variables: map[string]string{
+		"battery.charge": "85",
+		"ups.mfr":        `Dummy "Quoted" Manufacturer`,
+		"ups.status":     "OB DISCHRG",
+	},
+}}
+
+// startServer starts a server on a free port, stopping it when the test
+// ends.
+func startServer(t *testing.T, source Source, opts ...Option) *Server {
+	t.Helper()
+	server := NewServer(source, opts...)
+	require.NoError(t, server.Listen("127.0.0.1:0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return server
+}
+
+// rawConn is a plain text connection to a server, for checking replies
+// the client doesn't expose.
+type rawConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialRaw(t *testing.T, server *Server) *rawConn {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &rawConn{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// send sends cmd and returns the first n lines of the reply.
+func (c *rawConn) send(t *testing.T, cmd string, n int) []string {
+	t.Helper()
+	_, err := fmt.Fprintf(c.conn, "%s\n", cmd)
+	require.NoError(t, err)
+
+	lines := make([]string, 0, n)
+	for range n {
+		line, err := c.reader.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimRight(line, "\n"))
+	}
+	return lines
+}
+
+func TestServer_GetUPSList(t *testing.T) {
+	server := startServer(t, testUPSes)
+	client, err := nutclient.Dial(server.Addr().String(), nutclient.Options{})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, client.Disconnect()) }()
+
+	got, err := client.GetUPSList()
+	require.NoError(t, err)
+
+	want := []nut.UPS{{
+		Name:        "cyberpower900",
+		Description: "Simulated UPS for testing",
+		Clients:     []string{},
+		Commands:    []nut.Command{},
+		Variables: []nut.Variable{
+			{Name: "battery.charge", Value: int64(85), Type: "INTEGER", Description: "Description unavailable", OriginalType: "NUMBER"},
+			{Name: "ups.mfr", Value: `Dummy "Quoted" Manufacturer`, Type: "STRING", Description: "Description unavailable", OriginalType: "STRING:64"},
+			{Name: "ups.status", Value: "OB DISCHRG", Type: "STRING", Description: "Description unavailable", OriginalType: "STRING:64"},
+		},
+	}}
+	assert.Equal(t, want, got)
+}
+
+func TestServer_GetUPSList_source(t *testing.T) {
+	scenario := &Scenario{UPS: []ScenarioUPS{{
+		Name:   "ups",
+		Vars:   map[string]any{"ups.status": "OL"},
+		Events: []Event{{At: time.Minute, Set: map[string]any{"ups.status": "OB"}}},
+	}}}
+	player := NewPlayer(scenario)
+	now := player.start
+	player.now = func() time.Time { return now }
+	server := startServer(t, player)
+
+	status := func() any {
+		client, err := nutclient.Dial(server.Addr().String(), nutclient.Options{})
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, client.Disconnect()) }()
+		upsList, err := client.GetUPSList()
+		require.NoError(t, err)
+		require.Len(t, upsList, 1)
+		return upsList[0].Variables[0].Value
+	}
+
+	assert.Equal(t, "OL", status())
+	now = now.Add(time.Minute)
+	assert.Equal(t, "OB", status(), "the server reports the source's current state")
+}
+
+func TestServer_users(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		username string
+		password string
+	}{
+		{name: "valid user", username: "upsmon", password: "secret"},
+		{name: "wrong password", username: "upsmon", password: "wrong", wantErr: nutclient.ErrServer},
+		{name: "unknown user", username: "admin", password: "secret", wantErr: nutclient.ErrServer},
+		{name: "no credentials", wantErr: nutclient.ErrServer},
+	}
+	server := startServer(t, testUPSes, WithUser("upsmon", "secret"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := nutclient.Dial(server.Addr().String(), nutclient.Options{})
+			require.NoError(t, err)
+			defer func() { assert.NoError(t, client.Disconnect()) }()
+
+			if tt.username != "" {
+				ok, err := client.Authenticate(tt.username, tt.password)
+				require.NoError(t, err)
+				assert.True(t, ok, "upsd only checks credentials when they are used")
+			}
+			_, err = client.GetUPSList()
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestServer_STARTTLS(t *testing.T) {
+	certificate, pool := selfSigned(t, "localhost")
+	server := startServer(t, testUPSes, WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}))
+
+	client, err := nutclient.Dial(server.Addr().String(), nutclient.Options{
+		TLSMode:   entity.NutTLSRequired,
+		TLSConfig: &tls.Config{RootCAs: pool, ServerName: "localhost", MinVersion: tls.VersionTLS12},
+	})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, client.Disconnect()) }()
+	assert.True(t, client.TLS())
+
+	upsList, err := client.GetUPSList()
+	require.NoError(t, err)
+	assert.Len(t, upsList, 1)
+
+	// STARTTLS is refused once the connection is encrypted
+	assert.ErrorIs(t, client.StartTLS(&tls.Config{MinVersion: tls.VersionTLS12}), nutclient.ErrServer)
+}
+
+func TestServer_STARTTLS_notConfigured(t *testing.T) {
+	server := startServer(t, testUPSes)
+
+	_, err := nutclient.Dial(server.Addr().String(), nutclient.Options{
+		TLSMode:   entity.NutTLSRequired,
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	})
+	require.ErrorIs(t, err, nutclient.ErrTLSRequired)
+
+	client, err := nutclient.Dial(server.Addr().String(), nutclient.Options{
+		TLSMode:   entity.NutTLSOptional,
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, client.Disconnect()) }()
+	assert.False(t, client.TLS())
+}
+
+func TestServer_commands(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want []string
+	}{
+		{name: "VER", cmd: "VER", want: []string{Version}},
+		{name: "NETVER", cmd: "NETVER", want: []string{"1.3"}},
+		{name: "unknown command", cmd: "FSD cyberpower900", want: []string{"ERR UNKNOWN-COMMAND"}},
+		{name: "unterminated quote", cmd: `GET VAR "cyberpower900`, want: []string{"ERR INVALID-ARGUMENT"}},
+		{
+			name: "LIST UPS",
+			cmd:  "LIST UPS",
+			want: []string{"BEGIN LIST UPS", `UPS cyberpower900 "Simulated UPS for testing"`, "END LIST UPS"},
+		},
+		{name: "LIST without arguments", cmd: "LIST", want: []string{"ERR INVALID-ARGUMENT"}},
+		{name: "LIST unknown UPS", cmd: "LIST VAR missing", want: []string{"ERR UNKNOWN-UPS"}},
+		{name: "LIST unknown kind", cmd: "LIST ENUM cyberpower900", want: []string{"ERR INVALID-ARGUMENT"}},
+		{
+			name: "LIST CMD",
+			cmd:  "LIST CMD cyberpower900",
+			want: []string{"BEGIN LIST CMD cyberpower900", "END LIST CMD cyberpower900"},
+		},
+		{name: "GET VAR", cmd: "GET VAR cyberpower900 ups.status", want: []string{`VAR cyberpower900 ups.status "OB DISCHRG"`}},
+		{name: "GET VAR escaped", cmd: "GET VAR cyberpower900 ups.mfr", want: []string{`VAR cyberpower900 ups.mfr "Dummy \"Quoted\" Manufacturer"`}},
+		{name: "GET TYPE number", cmd: "GET TYPE cyberpower900 battery.charge", want: []string{"TYPE cyberpower900 battery.charge NUMBER"}},
+		{name: "GET TYPE string", cmd: "GET TYPE cyberpower900 ups.status", want: []string{"TYPE cyberpower900 ups.status STRING:64"}},
+		{name: "GET unknown variable", cmd: "GET VAR cyberpower900 ups.model", want: []string{"ERR VAR-NOT-SUPPORTED"}},
+		{name: "GET unknown UPS", cmd: "GET VAR missing ups.status", want: []string{"ERR UNKNOWN-UPS"}},
+		{name: "GET UPSDESC", cmd: "GET UPSDESC cyberpower900", want: []string{`UPSDESC cyberpower900 "Simulated UPS for testing"`}},
+		{name: "GET NUMLOGINS", cmd: "GET NUMLOGINS cyberpower900", want: []string{"NUMLOGINS cyberpower900 0"}},
+		{name: "GET CMDDESC", cmd: "GET CMDDESC cyberpower900 load.off", want: []string{"ERR CMD-NOT-SUPPORTED"}},
+		{name: "GET without variable", cmd: "GET VAR cyberpower900", want: []string{"ERR INVALID-ARGUMENT"}},
+	}
+	server := startServer(t, testUPSes)
+	conn := dialRaw(t, server)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, conn.send(t, tt.cmd, len(tt.want)))
+		})
+	}
+}
+
+func TestServer_credentials(t *testing.T) {
+	server := startServer(t, testUPSes)
+	conn := dialRaw(t, server)
+
+	assert.Equal(t, []string{"OK"}, conn.send(t, "USERNAME upsmon", 1))
+	assert.Equal(t, []string{"ERR ALREADY-SET-USERNAME"}, conn.send(t, "USERNAME admin", 1))
+	assert.Equal(t, []string{"ERR INVALID-ARGUMENT"}, conn.send(t, "PASSWORD", 1))
+	assert.Equal(t, []string{"OK"}, conn.send(t, `PASSWORD "with space"`, 1))
+	assert.Equal(t, []string{"ERR ALREADY-SET-PASSWORD"}, conn.send(t, "PASSWORD secret", 1))
+	assert.Equal(t, []string{"OK Goodbye"}, conn.send(t, "LOGOUT", 1))
+
+	_, err := conn.reader.ReadString('\n')
+	assert.Error(t, err, "the server closes the connection after LOGOUT")
+}
+
+func TestServer_Close(t *testing.T) {
+	server := NewServer(testUPSes)
+	require.NoError(t, server.Listen("127.0.0.1:0"))
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(context.Background()) }()
+
+	conn := dialRaw(t, server)
+	assert.Equal(t, []string{"1.3"}, conn.send(t, "NETVER", 1))
+
+	require.NoError(t, server.Close())
+	require.NoError(t, <-done)
+
+	_, err := conn.reader.ReadString('\n')
+	assert.Error(t, err, "open connections are closed")
+	_, err = net.Dial("tcp", server.Addr().String())
+	assert.Error(t, err, "the server stops listening")
+}
+
+func TestServer_Listen(t *testing.T) {
+	server := startServer(t, testUPSes)
+
+	err := NewServer(testUPSes).Listen(server.Addr().String())
+	var opErr *net.OpError
+	assert.True(t, errors.As(err, &opErr), "the address is already in use: %v", err)
+}
+
+// selfSigned returns a self-signed certificate for names and a pool that
+// trusts it.
+func selfSigned(t *testing.T, names ...string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: certificate}, pool
+}
@@ -0,0 +1,286 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/tester"
+	"github.com/open-policy-agent/opa/v1/types"
+)
+
+// windowLayout is the layout of the times given to upswake.in_window.
+const windowLayout = "15:04"
+
+var ErrInvalidWindowTime = errors.New("window time must be in HH:MM format")
+
+// locationKey is the key of the time zone upswake.in_window compares times
+// in, in the context rules are evaluated with.
+type locationKey struct{}
+
+// withLocation returns ctx with the time zone upswake.in_window compares
+// times in.
+func withLocation(ctx context.Context, location *time.Location) context.Context {
+	return context.WithValue(ctx, locationKey{}, location)
+}
+
+// locationOf returns the time zone set on ctx by withLocation, or the
+// server's local time zone if there isn't one.
+func locationOf(ctx context.Context) *time.Location {
+	if ctx != nil {
+		if location, ok := ctx.Value(locationKey{}).(*time.Location); ok && location != nil {
+			return location
+		}
+	}
+	return time.Local
+}
+
+// builtin is a Rego function rules can call, in addition to OPA's own.
+type builtin struct {
+	decl *rego.Function
+	// option registers the function's implementation on a query
+	option func(*rego.Rego)
+}
+
+// builtins are the upswake.* functions available to every rule and rule
+// test. See docs/RULES.md for how they are used.
+var builtins = []builtin{
+	newBuiltin2(&rego.Function{
+		Name:        "upswake.var",
+		Description: "Returns the value of the named variable of a UPS, with strings holding a number returned as numbers.",
+		Decl:        types.NewFunction(types.Args(types.A, types.S), types.A),
+	}, builtinVar),
+	newBuiltin2(&rego.Function{
+		Name:        "upswake.status_has",
+		Description: "Returns whether a UPS's ups.status includes the given flag, e.g. OL or LB.",
+		Decl:        types.NewFunction(types.Args(types.A, types.S), types.B),
+	}, builtinStatusHas),
+	newBuiltin1(&rego.Function{
+		Name:        "upswake.runtime_minutes",
+		Description: "Returns a UPS's battery.runtime in minutes.",
+		Decl:        types.NewFunction(types.Args(types.A), types.N),
+	}, builtinRuntimeMinutes),
+	newBuiltin2(&rego.Function{
+		Name:             "upswake.in_window",
+		Description:      "Returns whether the current time, in the configured time zone, is between two HH:MM times, which may span midnight.",
+		Decl:             types.NewFunction(types.Args(types.S, types.S), types.B),
+		Nondeterministic: true,
+	}, builtinInWindow),
+}
+
+func newBuiltin1(decl *rego.Function, impl rego.Builtin1) builtin {
+	return builtin{decl: decl, option: rego.Function1(decl, impl)}
+}
+
+func newBuiltin2(decl *rego.Function, impl rego.Builtin2) builtin {
+	return builtin{decl: decl, option: rego.Function2(decl, impl)}
+}
+
+// astBuiltin returns the declaration the compiler type checks calls against.
+func (b builtin) astBuiltin() *ast.Builtin {
+	return &ast.Builtin{
+		Name:             b.decl.Name,
+		Description:      b.decl.Description,
+		Decl:             b.decl.Decl,
+		Nondeterministic: b.decl.Nondeterministic,
+	}
+}
+
+// builtinOptions registers every builtin on a query.
+func builtinOptions() []func(*rego.Rego) {
+	options := make([]func(*rego.Rego), 0, len(builtins))
+	for _, b := range builtins {
+		options = append(options, b.option)
+	}
+	return options
+}
+
+// testerBuiltins returns every builtin for a test runner.
+func testerBuiltins() []*tester.Builtin {
+	testers := make([]*tester.Builtin, 0, len(builtins))
+	for _, b := range builtins {
+		testers = append(testers, &tester.Builtin{Decl: b.astBuiltin(), Func: b.option})
+	}
+	return testers
+}
+
+// ruleCapabilities returns OPA's capabilities with every builtin added, so
+// calls to them can be type checked.
+var ruleCapabilities = sync.OnceValue(func() *ast.Capabilities {
+	caps := ast.CapabilitiesForThisVersion()
+	for _, b := range builtins {
+		caps.Builtins = append(caps.Builtins, b.astBuiltin())
+	}
+	return caps
+})
+
+// builtinVar implements upswake.var(ups, name). The variable is undefined
+// if the UPS doesn't have it.
+func builtinVar(_ rego.BuiltinContext, ups, name *ast.Term) (*ast.Term, error) {
+	varName, ok := name.Value.(ast.String)
+	if !ok {
+		return nil, nil
+	}
+	value := upsVariable(ups, string(varName))
+	if value == nil {
+		return nil, nil
+	}
+	return toNumber(value), nil
+}
+
+// builtinStatusHas implements upswake.status_has(ups, flag).
+func builtinStatusHas(_ rego.BuiltinContext, ups, flag *ast.Term) (*ast.Term, error) {
+	want, ok := flag.Value.(ast.String)
+	if !ok {
+		return nil, nil
+	}
+	variable := upsVariable(ups, "ups.status")
+	if variable == nil {
+		return ast.BooleanTerm(false), nil
+	}
+	status, ok := variable.Value.(ast.String)
+	if !ok {
+		return ast.BooleanTerm(false), nil
+	}
+	for _, f := range strings.Fields(string(status)) {
+		if f == string(want) {
+			return ast.BooleanTerm(true), nil
+		}
+	}
+	return ast.BooleanTerm(false), nil
+}
+
+// builtinRuntimeMinutes implements upswake.runtime_minutes(ups). The result
+// is undefined if the UPS doesn't report battery.runtime.
+func builtinRuntimeMinutes(_ rego.BuiltinContext, ups *ast.Term) (*ast.Term, error) {
+	runtime := upsVariable(ups, "battery.runtime")
+	if runtime == nil {
+		return nil, nil
+	}
+	number, ok := toNumber(runtime).Value.(ast.Number)
+	if !ok {
+		return nil, nil
+	}
+	seconds, ok := number.Float64()
+	if !ok {
+		return nil, nil
+	}
+	return ast.FloatNumberTerm(seconds / 60), nil
+}
+
+// builtinInWindow implements upswake.in_window(start, end), using the time
+// the rule is being evaluated at in the time zone of the evaluation's
+// context.
+func builtinInWindow(bctx rego.BuiltinContext, start, end *ast.Term) (*ast.Term, error) {
+	startTime, ok := start.Value.(ast.String)
+	if !ok {
+		return nil, nil
+	}
+	endTime, ok := end.Value.(ast.String)
+	if !ok {
+		return nil, nil
+	}
+
+	now := time.Now()
+	if bctx.Time != nil {
+		if ns, ok := bctx.Time.Value.(ast.Number); ok {
+			if ns, ok := ns.Int64(); ok {
+				now = time.Unix(0, ns)
+			}
+		}
+	}
+
+	in, err := inWindow(now.In(locationOf(bctx.Context)), string(startTime), string(endTime))
+	if err != nil {
+		return nil, err
+	}
+	return ast.BooleanTerm(in), nil
+}
+
+// inWindow returns whether the time of day of now is at or after start and
+// before end. If end is before start the window spans midnight, and if they
+// are equal the window is empty.
+func inWindow(now time.Time, start, end string) (bool, error) {
+	from, err := minuteOfDay(start)
+	if err != nil {
+		return false, err
+	}
+	to, err := minuteOfDay(end)
+	if err != nil {
+		return false, err
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if from <= to {
+		return minute >= from && minute < to, nil
+	}
+	return minute >= from || minute < to, nil
+}
+
+func minuteOfDay(hhmm string) (int, error) {
+	t, err := time.Parse(windowLayout, hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidWindowTime, hhmm)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// upsVariable returns the value of the named variable of ups, which is
+// either a UPS from the list of UPSes from the NUT server, or a UPS from
+// the normalized input's ups object. It returns nil if the variable isn't
+// found.
+func upsVariable(ups *ast.Term, name string) *ast.Term {
+	obj, ok := ups.Value.(ast.Object)
+	if !ok {
+		return nil
+	}
+
+	if vars := obj.Get(ast.StringTerm("vars")); vars != nil {
+		if vars, ok := vars.Value.(ast.Object); ok {
+			return vars.Get(ast.StringTerm(name))
+		}
+		return nil
+	}
+
+	variables := obj.Get(ast.StringTerm("Variables"))
+	if variables == nil {
+		return nil
+	}
+	list, ok := variables.Value.(*ast.Array)
+	if !ok {
+		return nil
+	}
+	var value *ast.Term
+	list.Until(func(variable *ast.Term) bool {
+		v, ok := variable.Value.(ast.Object)
+		if !ok {
+			return false
+		}
+		if variableName := v.Get(ast.StringTerm("Name")); variableName != nil && variableName.Equal(ast.StringTerm(name)) {
+			value = v.Get(ast.StringTerm("Value"))
+			return true
+		}
+		return false
+	})
+	return value
+}
+
+// toNumber returns value as a number if it is a string holding a JSON
+// number, such as "230.0", and value unchanged otherwise.
+func toNumber(value *ast.Term) *ast.Term {
+	s, ok := value.Value.(ast.String)
+	if !ok {
+		return value
+	}
+	if number, ok := entity.NumericString(string(s)); ok {
+		return ast.NumberTerm(json.Number(number))
+	}
+	return value
+}
@@ -0,0 +1,256 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixturesDir holds the UPS snapshots shipped for rule tests.
+const fixturesDir = "../../../rules/fixtures"
+
+const normalizedJSON = `{"ups":{"cyberpower900":{"vars":{"battery.charge":62,"battery.runtime":"1500","input.voltage":"230.5","ups.status":"OB LB"},"flags":["LB","OB"]}}}`
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(fixturesDir, name))
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func TestBuiltins(t *testing.T) {
+	onBattery := readFixture(t, "on_battery.json")
+	online := readFixture(t, "online_full_charge.json")
+
+	tests := []struct {
+		name  string
+		rule  string
+		input string
+		want  bool
+	}{
+		{
+			name:  "var",
+			rule:  `wake if upswake.var(input[0], "battery.charge") == 100`,
+			input: online,
+			want:  true,
+		},
+		{
+			name:  "var missing",
+			rule:  `wake if upswake.var(input[0], "input.voltage")`,
+			input: online,
+			want:  false,
+		},
+		{
+			name:  "var normalized",
+			rule:  `wake if upswake.var(input.ups.cyberpower900, "battery.charge") == 62`,
+			input: normalizedJSON,
+			want:  true,
+		},
+		{
+			name:  "var numeric string",
+			rule:  `wake if upswake.var(input.ups.cyberpower900, "input.voltage") > 230`,
+			input: normalizedJSON,
+			want:  true,
+		},
+		{
+			name:  "status has flag",
+			rule:  `wake if upswake.status_has(input[0], "OB")`,
+			input: onBattery,
+			want:  true,
+		},
+		{
+			name:  "status does not have flag",
+			rule:  `wake if upswake.status_has(input[0], "OL")`,
+			input: onBattery,
+			want:  false,
+		},
+		{
+			name:  "status has partial flag",
+			rule:  `wake if upswake.status_has(input[0], "DIS")`,
+			input: onBattery,
+			want:  false,
+		},
+		{
+			name:  "status has normalized",
+			rule:  `wake if upswake.status_has(input.ups.cyberpower900, "LB")`,
+			input: normalizedJSON,
+			want:  true,
+		},
+		{
+			name:  "runtime minutes",
+			rule:  `wake if upswake.runtime_minutes(input[0]) == 25`,
+			input: onBattery,
+			want:  true,
+		},
+		{
+			name:  "runtime minutes normalized string",
+			rule:  `wake if upswake.runtime_minutes(input.ups.cyberpower900) == 25`,
+			input: normalizedJSON,
+			want:  true,
+		},
+		{
+			name:  "runtime minutes missing",
+			rule:  `wake if upswake.runtime_minutes(input[0]) >= 0`,
+			input: `[{"Name":"cyberpower900","Variables":[]}]`,
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newTestFS(t, map[string][]byte{
+				"rule.rego": []byte("package upswake\ndefault wake := false\n" + tt.rule),
+			})
+			repo, err := NewPreparedRepository(fs)
+			require.NoError(t, err)
+
+			got, err := repo.Evaluate("rule.rego", tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Allowed)
+		})
+	}
+}
+
+func TestBuiltins_InWindow(t *testing.T) {
+	query, err := rego.New(append(builtinOptions(),
+		rego.Query(`x := upswake.in_window("22:00", "06:00")`),
+	)...).PrepareForEval(t.Context())
+	require.NoError(t, err)
+
+	for hour, want := range map[int]bool{21: false, 22: true, 3: true, 6: false} {
+		now := time.Date(2026, time.January, 5, hour, 0, 0, 0, time.Local)
+		rs, err := query.Eval(t.Context(), rego.EvalTime(now))
+		require.NoError(t, err)
+		require.Len(t, rs, 1)
+		assert.Equal(t, want, rs[0].Bindings["x"], "hour %d", hour)
+	}
+
+	t.Run("in the context's time zone", func(t *testing.T) {
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		require.NoError(t, err)
+
+		// 14:00 UTC is 23:00 in Tokyo
+		now := time.Date(2026, time.January, 5, 14, 0, 0, 0, time.UTC)
+		rs, err := query.Eval(withLocation(t.Context(), tokyo), rego.EvalTime(now))
+		require.NoError(t, err)
+		require.Len(t, rs, 1)
+		assert.Equal(t, true, rs[0].Bindings["x"])
+
+		rs, err = query.Eval(withLocation(t.Context(), time.UTC), rego.EvalTime(now))
+		require.NoError(t, err)
+		require.Len(t, rs, 1)
+		assert.Equal(t, false, rs[0].Bindings["x"])
+	})
+}
+
+func TestPreparedRepository_SetLocation(t *testing.T) {
+	fs := newTestFS(t, map[string][]byte{
+		"rule.rego": []byte("package upswake\ndefault wake := false\nwake if upswake.in_window(\"00:00\", \"12:00\")"),
+	})
+	repo, err := NewPreparedRepository(fs)
+	require.NoError(t, err)
+
+	// Etc/GMT-12 is always 12 hours ahead of UTC, so the window is open in
+	// exactly one of them
+	ahead, err := time.LoadLocation("Etc/GMT-12")
+	require.NoError(t, err)
+
+	repo.SetLocation(time.UTC)
+	inUTC, err := repo.Evaluate("rule.rego", "{}")
+	require.NoError(t, err)
+	repo.SetLocation(ahead)
+	inAhead, err := repo.Evaluate("rule.rego", "{}")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, inUTC.Allowed, inAhead.Allowed)
+}
+
+func Test_inWindow(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		start   string
+		end     string
+		now     string
+		want    bool
+	}{
+		{name: "inside", start: "09:00", end: "17:00", now: "12:00", want: true},
+		{name: "at start", start: "09:00", end: "17:00", now: "09:00", want: true},
+		{name: "at end", start: "09:00", end: "17:00", now: "17:00", want: false},
+		{name: "before", start: "09:00", end: "17:00", now: "08:59", want: false},
+		{name: "overnight before midnight", start: "22:00", end: "06:00", now: "23:30", want: true},
+		{name: "overnight after midnight", start: "22:00", end: "06:00", now: "05:59", want: true},
+		{name: "overnight outside", start: "22:00", end: "06:00", now: "12:00", want: false},
+		{name: "empty window", start: "09:00", end: "09:00", now: "09:00", want: false},
+		{name: "invalid start", start: "9am", end: "17:00", now: "12:00", wantErr: ErrInvalidWindowTime},
+		{name: "invalid end", start: "09:00", end: "25:00", now: "12:00", wantErr: ErrInvalidWindowTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now, err := time.Parse(windowLayout, tt.now)
+			require.NoError(t, err)
+
+			got, err := inWindow(now, tt.start, tt.end)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsValidRego(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		rule    string
+	}{
+		{
+			name: "valid rule",
+			rule: "package upswake\ndefault wake := false",
+		},
+		{
+			name: "builtins",
+			rule: `package upswake
+default wake := false
+wake if {
+	upswake.var(input[0], "battery.charge") >= 80
+	upswake.status_has(input[0], "OL")
+	upswake.runtime_minutes(input[0]) > 10
+	not upswake.in_window("22:00", "06:00")
+}`,
+		},
+		{
+			name:    "builtin with wrong number of arguments",
+			rule:    "package upswake\nwake if upswake.var(input[0])",
+			wantErr: ErrCompileError,
+		},
+		{
+			name:    "builtin with wrong argument type",
+			rule:    "package upswake\nwake if upswake.in_window(22, 6)",
+			wantErr: ErrCompileError,
+		},
+		{
+			name:    "unknown builtin",
+			rule:    "package upswake\nwake if upswake.charge(input[0])",
+			wantErr: ErrCompileError,
+		},
+		{
+			name:    "syntax error",
+			rule:    "package upswake\nwake if {",
+			wantErr: ErrInvalidRegoRule,
+		},
+		{
+			name:    "wrong package",
+			rule:    "package other\ndefault wake := false",
+			wantErr: ErrPackageName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, IsValidRego("rule.rego", tt.rule), tt.wantErr)
+		})
+	}
+}
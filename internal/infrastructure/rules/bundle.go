@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/spf13/afero"
+)
+
+const (
+	// DefaultBundleAlgorithm is the algorithm bundles are expected to be
+	// signed with if none is configured, matching 'opa build'.
+	DefaultBundleAlgorithm = "RS256"
+
+	// bundleKeyID is the ID the configured public key is registered under.
+	// As only one key is trusted, every bundle is verified with it
+	// regardless of the key ID in its signature.
+	bundleKeyID = "upswake"
+)
+
+var (
+	ErrBundleKeyRequired = errors.New("a public key is required to verify rule bundles")
+	ErrReadBundle        = errors.New("failed to read rule bundle")
+	ErrInvalidBundle     = errors.New("invalid rule bundle")
+)
+
+// BundleVerification holds the key rule bundles must be signed with.
+type BundleVerification struct {
+	// PublicKey is the PEM encoded public key, or the shared secret for HS*
+	// algorithms
+	PublicKey string
+	// Algorithm defaults to DefaultBundleAlgorithm
+	Algorithm string
+	// Scope, if set, must match the scope in the bundle's signature
+	Scope string
+}
+
+type bundleSource struct {
+	verification *bundle.VerificationConfig
+	path         string
+}
+
+// NewBundleRepository loads and pre-compiles the rules in the OPA bundle
+// (a .tar.gz built with 'opa build --signing-key ...') at path in fs. Each
+// .rego file in the bundle is a rule, named by its path in the bundle.
+//
+// Bundles must be signed by the key in verification. Unsigned bundles,
+// bundles signed by another key, and bundles whose files don't match their
+// signature are rejected. Reload keeps the last good bundle if the bundle
+// on disk is replaced by one that is rejected.
+func NewBundleRepository(fs afero.Fs, path string, verification BundleVerification) (*PreparedRepository, error) {
+	if verification.PublicKey == "" {
+		return nil, ErrBundleKeyRequired
+	}
+	if verification.Algorithm == "" {
+		verification.Algorithm = DefaultBundleAlgorithm
+	}
+
+	keys := map[string]*bundle.KeyConfig{
+		bundleKeyID: {
+			Key:       verification.PublicKey,
+			Algorithm: verification.Algorithm,
+			Scope:     verification.Scope,
+		},
+	}
+
+	r := &PreparedRepository{
+		fs: fs,
+		bundle: &bundleSource{
+			path:         path,
+			verification: bundle.NewVerificationConfig(keys, bundleKeyID, verification.Scope, nil),
+		},
+	}
+
+	set := r.scan(&ruleSet{})
+	if set.dirErr != nil {
+		return nil, set.dirErr
+	}
+	if err := set.err(); err != nil {
+		return nil, err
+	}
+
+	r.state.Store(set)
+	return r, nil
+}
+
+// scanBundle reads and verifies the bundle, and builds a new rule set from
+// its modules. If the bundle can't be read or fails verification, the rules
+// from previous are kept. Otherwise, the bundle is treated like a rules
+// directory, with each module in it loaded as a rule file, and the revision
+// of previous is kept until every module has loaded.
+func (r *PreparedRepository) scanBundle(previous *ruleSet) *ruleSet {
+	failed := func(err error) *ruleSet {
+		next := *previous
+		next.dirErr = err
+		return &next
+	}
+
+	raw, err := afero.ReadFile(r.fs, r.bundle.path)
+	if err != nil {
+		return failed(fmt.Errorf("%w %s: %w", ErrReadBundle, r.bundle.path, err))
+	}
+
+	hash := sha256.Sum256(raw)
+	if previous.rules != nil && previous.bundleHash == hash {
+		next := *previous
+		next.dirErr = nil
+		return &next
+	}
+
+	b, err := bundle.NewReader(bytes.NewReader(raw)).
+		WithBundleVerificationConfig(r.bundle.verification).
+		Read()
+	if err != nil {
+		return failed(fmt.Errorf("%w %s: %w", ErrInvalidBundle, r.bundle.path, err))
+	}
+
+	next := &ruleSet{
+		rules:      make(map[string]*preparedRule, len(b.Modules)),
+		inline:     previous.inline,
+		errors:     make(map[string]error),
+		revision:   b.Manifest.Revision,
+		bundleHash: hash,
+	}
+
+	for _, module := range b.Modules {
+		name := strings.TrimPrefix(module.Path, "/")
+		if IsTestFile(name) {
+			continue
+		}
+
+		rule, loadErr := compileRule(name, module.Raw, previous.rules[name])
+		if loadErr != nil {
+			next.errors[name] = loadErr
+			if old, ok := previous.rules[name]; ok {
+				next.rules[name] = old
+			}
+			continue
+		}
+		next.rules[name] = rule
+	}
+	if len(next.errors) > 0 {
+		// the bundle is only at its new revision once every module loaded
+		next.revision = previous.revision
+	}
+
+	return next
+}
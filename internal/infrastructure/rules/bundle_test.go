@@ -0,0 +1,216 @@
+package rules
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bundlePath     = "bundle.tar.gz"
+	alwaysTrueRule = "package upswake\ndefault wake := true"
+)
+
+type bundleKey struct {
+	private string
+	public  string
+}
+
+func newBundleKey(t *testing.T) bundleKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	return bundleKey{
+		private: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		public:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+	}
+}
+
+// buildBundle builds a bundle with the given revision and modules, keyed
+// by path. The bundle is signed with key unless key is nil, and tamper is
+// applied to the bundle after it has been signed.
+func buildBundle(t *testing.T, key *bundleKey, revision string, modules map[string]string, tamper func(*bundle.Bundle)) []byte {
+	t.Helper()
+	b := bundle.Bundle{
+		Manifest: bundle.Manifest{Revision: revision},
+		Data:     map[string]any{},
+	}
+	for path, raw := range modules {
+		b.Modules = append(b.Modules, bundle.ModuleFile{URL: path, Path: path, Raw: []byte(raw)})
+	}
+
+	if key != nil {
+		require.NoError(t, b.GenerateSignature(bundle.NewSigningConfig(key.private, DefaultBundleAlgorithm, ""), "test", false))
+	}
+	if tamper != nil {
+		tamper(&b)
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, bundle.NewWriter(buf).Write(b))
+	return buf.Bytes()
+}
+
+func TestNewBundleRepository(t *testing.T) {
+	key := newBundleKey(t)
+	otherKey := newBundleKey(t)
+	modules := map[string]string{
+		"/alwaysTrue.rego":      alwaysTrueRule,
+		"/alwaysTrue_test.rego": "package upswake\ntest_wake if wake",
+	}
+
+	tests := []struct {
+		wantErr error
+		name    string
+		bundle  []byte
+		key     string
+	}{
+		{
+			name:   "signed bundle",
+			bundle: buildBundle(t, &key, "v1", modules, nil),
+			key:    key.public,
+		},
+		{
+			name:    "no public key",
+			bundle:  buildBundle(t, &key, "v1", modules, nil),
+			wantErr: ErrBundleKeyRequired,
+		},
+		{
+			name:    "unsigned bundle",
+			bundle:  buildBundle(t, nil, "v1", modules, nil),
+			key:     key.public,
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "signed with another key",
+			bundle:  buildBundle(t, &otherKey, "v1", modules, nil),
+			key:     key.public,
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name: "module changed after signing",
+			bundle: buildBundle(t, &key, "v1", modules, func(b *bundle.Bundle) {
+				b.Modules[0].Raw = []byte("package upswake\ndefault wake := false")
+			}),
+			key:     key.public,
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name: "module added after signing",
+			bundle: buildBundle(t, &key, "v1", modules, func(b *bundle.Bundle) {
+				b.Modules = append(b.Modules, bundle.ModuleFile{URL: "/extra.rego", Path: "/extra.rego", Raw: []byte(alwaysTrueRule)})
+			}),
+			key:     key.public,
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "signed rule in wrong package",
+			bundle:  buildBundle(t, &key, "v1", map[string]string{"/wrong.rego": "package other\ndefault wake := true"}, nil),
+			key:     key.public,
+			wantErr: ErrPackageName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, bundlePath, tt.bundle, 0o644))
+
+			repo, err := NewBundleRepository(fs, bundlePath, BundleVerification{PublicKey: tt.key})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, repo)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, "v1", repo.Revision())
+			assert.Equal(t, []string{"alwaysTrue.rego"}, repo.RuleNames())
+
+			decision, err := repo.Evaluate("alwaysTrue.rego", "[]")
+			require.NoError(t, err)
+			assert.True(t, decision.Allowed)
+		})
+	}
+
+	t.Run("missing bundle", func(t *testing.T) {
+		_, err := NewBundleRepository(afero.NewMemMapFs(), bundlePath, BundleVerification{PublicKey: key.public})
+		assert.ErrorIs(t, err, ErrReadBundle)
+	})
+}
+
+func TestBundleRepository_Reload(t *testing.T) {
+	key := newBundleKey(t)
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, bundlePath, buildBundle(t, &key, "v1", map[string]string{"/rule.rego": alwaysTrueRule}, nil), 0o644))
+
+	repo, err := NewBundleRepository(fs, bundlePath, BundleVerification{PublicKey: key.public})
+	require.NoError(t, err)
+
+	// Unchanged bundle
+	changed, err := repo.Reload()
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	// A tampered bundle is rejected and the last good bundle kept
+	tampered := buildBundle(t, &key, "v2", map[string]string{"/rule.rego": alwaysTrueRule}, func(b *bundle.Bundle) {
+		b.Modules[0].Raw = []byte("package upswake\ndefault wake := false")
+	})
+	require.NoError(t, afero.WriteFile(fs, bundlePath, tampered, 0o644))
+
+	changed, err = repo.Reload()
+	require.ErrorIs(t, err, ErrInvalidBundle)
+	assert.Empty(t, changed)
+	assert.ErrorIs(t, repo.Health(), ErrInvalidBundle)
+	assert.Equal(t, "v1", repo.Revision())
+
+	decision, err := repo.Evaluate("rule.rego", "[]")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	// A new signed revision replaces the old one
+	v2 := buildBundle(t, &key, "v2", map[string]string{"/rule.rego": "package upswake\ndefault wake := false"}, nil)
+	require.NoError(t, afero.WriteFile(fs, bundlePath, v2, 0o644))
+
+	changed, err = repo.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rule.rego"}, changed)
+	require.NoError(t, repo.Health())
+	assert.Equal(t, "v2", repo.Revision())
+
+	decision, err = repo.Evaluate("rule.rego", "[]")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	// A revision with a module that fails to compile keeps the old revision
+	broken := buildBundle(t, &key, "v3", map[string]string{"/rule.rego": "package upswake\ndefault wake :="}, nil)
+	require.NoError(t, afero.WriteFile(fs, bundlePath, broken, 0o644))
+
+	_, err = repo.Reload()
+	require.Error(t, err)
+	assert.Error(t, repo.Health())
+	assert.Equal(t, "v2", repo.Revision())
+
+	decision, err = repo.Evaluate("rule.rego", "[]")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	// Once every module loads, the bundle's revision is taken
+	v4 := buildBundle(t, &key, "v4", map[string]string{"/rule.rego": alwaysTrueRule}, nil)
+	require.NoError(t, afero.WriteFile(fs, bundlePath, v4, 0o644))
+
+	_, err = repo.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, "v4", repo.Revision())
+}
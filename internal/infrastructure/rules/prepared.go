@@ -3,17 +3,34 @@ package rules
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
 	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/topdown"
 	"github.com/spf13/afero"
 )
 
+const (
+	// DefaultReloadInterval is how often Watch rescans the rules filesystem.
+	DefaultReloadInterval = 5 * time.Second
+
+	// query evaluates the whole upswake package so that the optional
+	// reason and details rules are returned alongside wake.
+	query = "data.upswake"
+)
+
 var (
 	ErrRuleNotFound    = errors.New("rule not found")
 	ErrDecodeFailed    = errors.New("failed to decode input JSON")
@@ -21,63 +38,339 @@ var (
 	ErrCompileError    = errors.New("failed to compile rego rule")
 	ErrInvalidRegoRule = errors.New("invalid rego rule")
 	ErrPackageName     = errors.New("rego rule must be in package 'upswake'")
+	ErrReadRulesDir    = errors.New("failed to read rules directory")
+	ErrReadRule        = errors.New("failed to read rule")
+	ErrInlineRuleName  = errors.New("inline rule name must start with '" + entity.InlineRulePrefix + "'")
 )
 
 // PreparedRepository loads and pre-compiles all Rego rules from the
 // filesystem at construction time. Evaluate() only runs the prepared
 // query against new input, skipping parsing and compilation entirely.
+//
+// Reload() and Watch() rescan the filesystem and atomically swap in a new
+// rule set, so evaluations never wait on a recompile.
 type PreparedRepository struct {
-	rules map[string]rego.PreparedEvalQuery
+	fs afero.Fs
+	// bundle is set when rules are loaded from a signed bundle in fs,
+	// rather than from the .rego files in fs
+	bundle *bundleSource
+	state  atomic.Pointer[ruleSet]
+	// location is the time zone upswake.in_window compares times in
+	location atomic.Pointer[time.Location]
+	mu       sync.Mutex // serialises reloads
+}
+
+// ruleSet is an immutable snapshot of the compiled rules and of any
+// files that failed to load during the last scan.
+// Inline rules from the config are kept separately from rule files, as they
+// are not affected by rescans of the filesystem.
+type ruleSet struct {
+	rules  map[string]*preparedRule
+	inline map[string]*preparedRule
+	errors map[string]error
+	dirErr error
+	// revision and bundleHash identify the bundle the rules were loaded from
+	revision   string
+	bundleHash [sha256.Size]byte
+}
+
+// rule returns the compiled rule with the given name. Names starting with
+// entity.InlineRulePrefix are only looked up in the inline rules.
+func (s *ruleSet) rule(name string) (*preparedRule, bool) {
+	if strings.HasPrefix(name, entity.InlineRulePrefix) {
+		rule, ok := s.inline[name]
+		return rule, ok
+	}
+	rule, ok := s.rules[name]
+	return rule, ok
+}
+
+type preparedRule struct {
+	query rego.PreparedEvalQuery
+	hash  [sha256.Size]byte
+	// denies is true if the rule defines data.upswake.deny
+	denies bool
 }
 
 // NewPreparedRepository reads every .rego file from fs, validates it,
 // and compiles it into a PreparedEvalQuery. Returns an error if any
 // rule fails validation or compilation.
-func NewPreparedRepository(fs afero.Fs) (repository.RuleRepository, error) {
-	rules := make(map[string]rego.PreparedEvalQuery)
+func NewPreparedRepository(fs afero.Fs) (*PreparedRepository, error) {
+	r := &PreparedRepository{fs: fs}
+
+	set := r.scan(&ruleSet{})
+	if set.dirErr != nil {
+		return nil, set.dirErr
+	}
+	if err := set.err(); err != nil {
+		return nil, err
+	}
+
+	r.state.Store(set)
+	return r, nil
+}
+
+// scan reads the rules filesystem and builds a new rule set on top of
+// previous. Unchanged files reuse their compiled query, and files that
+// fail to load keep the query from previous (if any) alongside the error.
+func (r *PreparedRepository) scan(previous *ruleSet) *ruleSet {
+	if r.bundle != nil {
+		return r.scanBundle(previous)
+	}
 
-	entries, err := afero.ReadDir(fs, ".")
+	entries, err := afero.ReadDir(r.fs, ".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read rules directory: %w", err)
+		return &ruleSet{
+			rules:  previous.rules,
+			inline: previous.inline,
+			errors: previous.errors,
+			dirErr: fmt.Errorf("%w: %w", ErrReadRulesDir, err),
+		}
+	}
+
+	next := &ruleSet{
+		rules:  make(map[string]*preparedRule, len(entries)),
+		inline: previous.inline,
+		errors: make(map[string]error),
 	}
 
 	for _, entry := range entries {
 		name := entry.Name()
-		if entry.IsDir() || !strings.HasSuffix(name, ".rego") {
+		if entry.IsDir() || !strings.HasSuffix(name, ".rego") || IsTestFile(name) {
 			continue
 		}
 
-		raw, readErr := afero.ReadFile(fs, name)
-		if readErr != nil {
-			return nil, fmt.Errorf("failed to read rule %s: %w", name, readErr)
+		rule, loadErr := r.loadRule(name, previous.rules[name])
+		if loadErr != nil {
+			next.errors[name] = loadErr
+			if old, ok := previous.rules[name]; ok {
+				next.rules[name] = old
+			}
+			continue
 		}
+		next.rules[name] = rule
+	}
+
+	return next
+}
+
+// loadRule reads and compiles a single rule, reusing old if the file
+// contents have not changed since it was compiled.
+func (r *PreparedRepository) loadRule(name string, old *preparedRule) (*preparedRule, error) {
+	raw, err := afero.ReadFile(r.fs, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrReadRule, name, err)
+	}
+	return compileRule(name, raw, old)
+}
+
+// compileRule validates and compiles raw, reusing old if raw has not
+// changed since it was compiled.
+func compileRule(name string, raw []byte, old *preparedRule) (*preparedRule, error) {
+	hash := sha256.Sum256(raw)
+	if old != nil && old.hash == hash {
+		return old, nil
+	}
+
+	if err := IsValidRego(name, string(raw)); err != nil {
+		return nil, fmt.Errorf("invalid rule %s: %w", name, err)
+	}
 
-		if err = IsValidRego(name, string(raw)); err != nil {
-			return nil, fmt.Errorf("invalid rule %s: %w", name, err)
+	prepared, err := prepareRule(name, string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCompileError, name, err)
+	}
+
+	return &preparedRule{query: prepared, hash: hash, denies: definesDeny(name, string(raw))}, nil
+}
+
+// err joins every per-file load error, sorted by file name.
+func (s *ruleSet) err() error {
+	names := make([]string, 0, len(s.errors))
+	for name := range s.errors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	errs := make([]error, 0, len(names)+1)
+	if s.dirErr != nil {
+		errs = append(errs, s.dirErr)
+	}
+	for _, name := range names {
+		errs = append(errs, s.errors[name])
+	}
+	return errors.Join(errs...)
+}
+
+// Reload rescans the rules filesystem, recompiling rules that were added
+// or changed and dropping rules whose files were deleted. A rule that
+// fails to load keeps its last good version; the failure is returned here
+// and reported by Health until the file is fixed.
+// It returns the names of the rules whose compiled version changed.
+func (r *PreparedRepository) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.state.Load()
+	next := r.scan(previous)
+	r.state.Store(next)
+
+	var changed []string
+	for name, rule := range next.rules {
+		if previous.rules[name] != rule {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous.rules {
+		if _, ok := next.rules[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+
+	return changed, next.err()
+}
+
+// LoadInlineRules validates and compiles rules written inline in the config,
+// replacing any previously loaded inline rules. Inline rules are keyed by
+// name, which must start with entity.InlineRulePrefix so that they can never
+// collide with a rule file. If any rule fails to load, none are replaced.
+func (r *PreparedRepository) LoadInlineRules(inlineRules map[string]string) error {
+	inline := make(map[string]*preparedRule, len(inlineRules))
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(inlineRules)) {
+		if !strings.HasPrefix(name, entity.InlineRulePrefix) {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrInlineRuleName, name))
+			continue
+		}
+
+		source := inlineRules[name]
+		if err := IsValidRego(name, source); err != nil {
+			errs = append(errs, fmt.Errorf("invalid rule %s: %w", name, err))
+			continue
 		}
 
-		prepared, prepErr := prepareRule(name, string(raw))
-		if prepErr != nil {
-			return nil, fmt.Errorf("%w: %s: %w", ErrCompileError, name, prepErr)
+		prepared, err := prepareRule(name, source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrCompileError, name, err))
+			continue
 		}
-		rules[name] = prepared
+		inline[name] = &preparedRule{query: prepared, hash: sha256.Sum256([]byte(source)), denies: definesDeny(name, source)}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
 
-	return &PreparedRepository{rules: rules}, nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := *r.state.Load()
+	next.inline = inline
+	r.state.Store(&next)
+	return nil
+}
+
+// Watch calls Reload every interval until ctx is cancelled, logging any
+// rules that changed and any that failed to load.
+func (r *PreparedRepository) Watch(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.Reload()
+			if len(changed) > 0 {
+				logger.Info("Reloaded rego rules", slog.Any("rules", changed), slog.String("revision", r.Revision()))
+			}
+
+			// Only log a failure when it changes, rather than on every tick
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg == lastErr {
+				continue
+			}
+			lastErr = errMsg
+			if err != nil {
+				logger.Error("Failed to reload rego rules, keeping last good versions", slog.Any("error", err))
+				continue
+			}
+			logger.Info("All rego rules loaded successfully")
+		}
+	}
+}
+
+// definesDeny returns true if raw defines data.upswake.deny, or can't be
+// parsed to tell.
+func definesDeny(name, raw string) bool {
+	mod, err := ast.ParseModule(name, raw)
+	if err != nil {
+		return true
+	}
+	for _, rule := range mod.Rules {
+		if ref := rule.Head.Ref(); len(ref) > 0 && ref[0].Value.Compare(ast.Var("deny")) == 0 {
+			return true
+		}
+	}
+	return false
 }
 
 func prepareRule(name, raw string) (rego.PreparedEvalQuery, error) {
-	r := rego.New(
-		rego.Query("data.upswake.wake"),
+	options := append([]func(*rego.Rego){
+		rego.Query(query),
 		rego.Module(name, raw),
-	)
-	return r.PrepareForEval(context.Background())
+	}, builtinOptions()...)
+	return rego.New(options...).PrepareForEval(context.Background())
+}
+
+// SetLocation sets the time zone upswake.in_window compares times in,
+// which is the server's local time zone until it is set.
+func (r *PreparedRepository) SetLocation(location *time.Location) {
+	r.location.Store(location)
+}
+
+// Evaluate runs the named rule against inputJSON and returns its decision.
+// The target is only woken if data.upswake.wake is exactly true and the
+// rule doesn't deny it with data.upswake.deny; the optional
+// data.upswake.reason (a string or a set of strings) and
+// data.upswake.details are passed through to explain the decision.
+func (r *PreparedRepository) Evaluate(ruleName, inputJSON string) (*entity.RuleDecision, error) {
+	rs, err := r.eval(ruleName, inputJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecision(ruleName, rs), nil
+}
+
+// Explain runs the named rule the same way as Evaluate, but also records
+// the evaluation trace and every expression that failed.
+func (r *PreparedRepository) Explain(ruleName, inputJSON string) (*entity.RuleExplanation, error) {
+	tracer := topdown.NewBufferTracer()
+	rs, err := r.eval(ruleName, inputJSON, rego.EvalQueryTracer(tracer))
+	if err != nil {
+		return nil, err
+	}
+
+	trace := new(bytes.Buffer)
+	topdown.PrettyTraceWithLocation(trace, *tracer)
+
+	return &entity.RuleExplanation{
+		Decision:          newDecision(ruleName, rs),
+		Trace:             strings.Split(strings.TrimSuffix(trace.String(), "\n"), "\n"),
+		FailedExpressions: failedExpressions(*tracer),
+	}, nil
 }
 
-func (r *PreparedRepository) Evaluate(ruleName, inputJSON string) (bool, error) {
-	prepared, ok := r.rules[ruleName]
+func (r *PreparedRepository) eval(ruleName, inputJSON string, opts ...rego.EvalOption) (rego.ResultSet, error) {
+	rule, ok := r.state.Load().rule(ruleName)
 	if !ok {
-		return false, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleName)
+		return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleName)
 	}
 
 	var input any
@@ -86,32 +379,162 @@ func (r *PreparedRepository) Evaluate(ruleName, inputJSON string) (bool, error)
 	d.DisallowUnknownFields()
 
 	if err := d.Decode(&input); err != nil {
-		return false, fmt.Errorf("%w: %w", ErrDecodeFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrDecodeFailed, err)
 	}
 
-	rs, err := prepared.Eval(context.Background(), rego.EvalInput(input))
+	ctx := withLocation(context.Background(), r.location.Load())
+	rs, err := rule.query.Eval(ctx, append(opts, rego.EvalInput(input))...)
 	if err != nil {
-		return false, fmt.Errorf("%w: %w", ErrEvaluationError, err)
+		return nil, fmt.Errorf("%w: %w", ErrEvaluationError, err)
+	}
+	return rs, nil
+}
+
+// failedExpressions returns each distinct expression that failed during
+// evaluation, in the order they first failed.
+func failedExpressions(trace []*topdown.Event) []string {
+	var failed []string
+	seen := make(map[string]bool)
+	for _, event := range trace {
+		if event.Op != topdown.FailOp {
+			continue
+		}
+		expr, ok := event.Node.(*ast.Expr)
+		if !ok || expr.Location == nil || expr.Location.File == "" {
+			continue
+		}
+
+		line := fmt.Sprintf("%s:%d: %s", expr.Location.File, expr.Location.Row, expr.Location.Text)
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		failed = append(failed, line)
+	}
+	return failed
+}
+
+// newDecision converts the value of the upswake package into a RuleDecision.
+func newDecision(ruleName string, rs rego.ResultSet) *entity.RuleDecision {
+	decision := &entity.RuleDecision{Rule: ruleName}
+	if len(rs) != 1 || len(rs[0].Expressions) != 1 {
+		return decision
+	}
+
+	pkg, ok := rs[0].Expressions[0].Value.(map[string]any)
+	if !ok {
+		return decision
+	}
+
+	allowed, ok := pkg["wake"].(bool)
+	decision.Denied = isDenied(pkg["deny"])
+	decision.Allowed = ok && allowed && !decision.Denied
+	decision.Reason = reasonString(pkg["reason"])
+	decision.Details = pkg["details"]
+
+	// Messages from a set of deny rules explain the veto
+	if _, isBool := pkg["deny"].(bool); decision.Denied && !isBool {
+		reasons := []string{reasonString(pkg["deny"])}
+		if decision.Reason != "" {
+			reasons = append([]string{decision.Reason}, reasons...)
+		}
+		decision.Reason = strings.Join(reasons, "; ")
+	}
+
+	return decision
+}
+
+// isDenied returns true if a deny rule vetoes the wake. Deny can either be
+// a boolean, or a set of messages built up with 'deny contains msg if'
+// that vetoes the wake if it isn't empty.
+func isDenied(deny any) bool {
+	switch v := deny.(type) {
+	case bool:
+		return v
+	case []any:
+		return len(v) > 0
+	case string:
+		return v != ""
+	default:
+		return false
+	}
+}
+
+// reasonString flattens a reason rule into a single string. Reasons can be
+// a single string, or a set of strings built up with 'reason contains msg if'.
+func reasonString(reason any) string {
+	switch v := reason.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		reasons := make([]string, 0, len(v))
+		for _, r := range v {
+			reasons = append(reasons, reasonString(r))
+		}
+		slices.Sort(reasons)
+		return strings.Join(reasons, "; ")
+	default:
+		return fmt.Sprint(v)
 	}
+}
 
-	return rs.Allowed(), nil
+// CanDeny returns true if the named rule defines data.upswake.deny. Rules
+// that can't be found are assumed to deny, so a wake is never allowed
+// without evaluating them.
+func (r *PreparedRepository) CanDeny(ruleName string) bool {
+	rule, ok := r.state.Load().rule(ruleName)
+	return !ok || rule.denies
 }
 
 func (r *PreparedRepository) RuleNames() []string {
-	names := make([]string, 0, len(r.rules))
-	for name := range r.rules {
+	state := r.state.Load()
+	names := make([]string, 0, len(state.rules)+len(state.inline))
+	for name := range state.rules {
+		names = append(names, name)
+	}
+	for name := range state.inline {
 		names = append(names, name)
 	}
 	return names
 }
 
+// Revision returns the revision of the bundle the rules were loaded from,
+// or an empty string if they weren't loaded from a bundle.
+func (r *PreparedRepository) Revision() string {
+	return r.state.Load().revision
+}
+
+// Health returns the errors from the most recent scan of the rules
+// filesystem, or nil if every rule loaded successfully.
+func (r *PreparedRepository) Health() error {
+	return r.state.Load().err()
+}
+
+// IsValidRego checks input is a rule in the upswake package that compiles,
+// type checking calls to OPA's and UPSWake's built-in functions.
 func IsValidRego(filename, input string) error {
+	mod, err := parseRego(filename, input)
+	if err != nil {
+		return err
+	}
+
+	compiler := ast.NewCompiler().WithCapabilities(ruleCapabilities())
+	if compiler.Compile(map[string]*ast.Module{filename: mod}); compiler.Failed() {
+		return fmt.Errorf("%w: %w", ErrCompileError, compiler.Errors)
+	}
+	return nil
+}
+
+// parseRego parses a Rego module and checks it belongs to the upswake package.
+func parseRego(filename, input string) (*ast.Module, error) {
 	mod, err := ast.ParseModule(filename, input)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrInvalidRegoRule, err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRegoRule, err)
 	}
 	if mod.Package.String() != "package upswake" {
-		return ErrPackageName
+		return nil, ErrPackageName
 	}
-	return nil
+	return mod, nil
 }
@@ -1,8 +1,14 @@
 package rules
 
 import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
 	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -36,6 +42,20 @@ default wake := false`),
 	assert.Len(t, repo.RuleNames(), 2)
 }
 
+func TestNewPreparedRepository_SkipsTestFiles(t *testing.T) {
+	fs := newTestFS(t, map[string][]byte{
+		"alwaysTrue.rego": []byte(`package upswake
+default wake := true`),
+		"alwaysTrue_test.rego": []byte(`package upswake
+test_wake if wake`),
+		"fixtures/online.json": []byte(validJSON),
+	})
+
+	repo, err := NewPreparedRepository(fs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alwaysTrue.rego"}, repo.RuleNames())
+}
+
 func TestNewPreparedRepository_InvalidRule(t *testing.T) {
 	fs := newTestFS(t, map[string][]byte{
 		"bad.rego": []byte(`package wrongname
@@ -59,6 +79,9 @@ wake if {
 	input[i].Variables[j].Name == "battery.charge"
 	input[i].Variables[j].Value == 100
 }`),
+		"exactRuntime.rego": []byte(`package upswake
+default wake := false
+wake if input[0].Variables[0].Value == 9007199254740993`),
 	})
 
 	repo, err := NewPreparedRepository(fs)
@@ -89,6 +112,12 @@ wake if {
 			json:     validJSON,
 			want:     true,
 		},
+		{
+			name:     "large numbers keep their precision",
+			ruleName: "exactRuntime.rego",
+			json:     `[{"Name":"cyberpower900","Variables":[{"Name":"battery.runtime","Value":9007199254740993}]}]`,
+			want:     true,
+		},
 		{
 			name:     "rule not found",
 			ruleName: "nonexistent.rego",
@@ -109,7 +138,379 @@ wake if {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := repo.Evaluate(tt.ruleName, tt.json)
 			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, tt.ruleName, got.Rule)
+			assert.Equal(t, tt.want, got.Allowed)
+		})
+	}
+}
+
+func TestPreparedRepository_EvaluateDecision(t *testing.T) {
+	fs := newTestFS(t, map[string][]byte{
+		"reason.rego": []byte(`package upswake
+import rego.v1
+
+default wake := false
+
+charge := input[0].Variables[0].Value
+
+wake if charge >= 80
+
+reason := sprintf("battery.charge %v >= 80 on %s", [charge, input[0].Name]) if wake
+reason := sprintf("battery.charge %v < 80 on %s", [charge, input[0].Name]) if not wake
+
+details := {"charge": charge, "threshold": 80}`),
+		"reasonSet.rego": []byte(`package upswake
+import rego.v1
+
+default wake := false
+
+reason contains "second reason"
+reason contains "first reason"`),
+		"wakeNotBool.rego": []byte(`package upswake
+wake := "yes"`),
+		"noWake.rego": []byte(`package upswake
+reason := "no wake rule defined"`),
+		"deny.rego": []byte(`package upswake
+default wake := true
+deny if input[0].Variables[0].Value < 80`),
+		"denySet.rego": []byte(`package upswake
+import rego.v1
+
+default wake := true
+reason := "mains is present"
+deny contains "maintenance window" if input[0].Name == "cyberpower900"`),
+	})
+
+	repo, err := NewPreparedRepository(fs)
+	require.NoError(t, err)
+
+	tests := []struct {
+		want     *entity.RuleDecision
+		name     string
+		ruleName string
+		json     string
+	}{
+		{
+			name:     "reason and details when allowed",
+			ruleName: "reason.rego",
+			json:     validJSON,
+			want: &entity.RuleDecision{
+				Rule:    "reason.rego",
+				Allowed: true,
+				Reason:  "battery.charge 100 >= 80 on cyberpower900",
+				Details: map[string]any{"charge": json.Number("100"), "threshold": json.Number("80")},
+			},
+		},
+		{
+			name:     "reason and details when not allowed",
+			ruleName: "reason.rego",
+			json:     strings.Replace(validJSON, `"Value":100`, `"Value":62`, 1),
+			want: &entity.RuleDecision{
+				Rule:    "reason.rego",
+				Allowed: false,
+				Reason:  "battery.charge 62 < 80 on cyberpower900",
+				Details: map[string]any{"charge": json.Number("62"), "threshold": json.Number("80")},
+			},
+		},
+		{
+			name:     "set of reasons are sorted and joined",
+			ruleName: "reasonSet.rego",
+			json:     validJSON,
+			want: &entity.RuleDecision{
+				Rule:   "reasonSet.rego",
+				Reason: "first reason; second reason",
+			},
+		},
+		{
+			name:     "non boolean wake is not allowed",
+			ruleName: "wakeNotBool.rego",
+			json:     validJSON,
+			want:     &entity.RuleDecision{Rule: "wakeNotBool.rego"},
+		},
+		{
+			name:     "undefined wake is not allowed",
+			ruleName: "noWake.rego",
+			json:     validJSON,
+			want: &entity.RuleDecision{
+				Rule:   "noWake.rego",
+				Reason: "no wake rule defined",
+			},
+		},
+		{
+			name:     "deny vetoes wake",
+			ruleName: "deny.rego",
+			json:     strings.Replace(validJSON, `"Value":100`, `"Value":62`, 1),
+			want:     &entity.RuleDecision{Rule: "deny.rego", Denied: true},
+		},
+		{
+			name:     "deny undefined",
+			ruleName: "deny.rego",
+			json:     validJSON,
+			want:     &entity.RuleDecision{Rule: "deny.rego", Allowed: true},
+		},
+		{
+			name:     "deny messages are added to the reason",
+			ruleName: "denySet.rego",
+			json:     validJSON,
+			want: &entity.RuleDecision{
+				Rule:   "denySet.rego",
+				Denied: true,
+				Reason: "mains is present; maintenance window",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.Evaluate(tt.ruleName, tt.json)
+			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestPreparedRepository_Explain(t *testing.T) {
+	fs := newTestFS(t, map[string][]byte{
+		"charge.rego": []byte(`package upswake
+
+default wake := false
+
+wake if input[0].Variables[0].Value >= 80
+
+reason := "charge is below 80%" if not wake`),
+	})
+
+	repo, err := NewPreparedRepository(fs)
+	require.NoError(t, err)
+
+	t.Run("allowed", func(t *testing.T) {
+		got, err := repo.Explain("charge.rego", validJSON)
+		require.NoError(t, err)
+		assert.Equal(t, &entity.RuleDecision{Rule: "charge.rego", Allowed: true}, got.Decision)
+		assert.NotEmpty(t, got.Trace)
+		assert.Contains(t, got.Trace[0], "Enter data.upswake")
+		assert.NotContains(t, got.FailedExpressions, "charge.rego:5: input[0].Variables[0].Value >= 80")
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		got, err := repo.Explain("charge.rego", strings.Replace(validJSON, `"Value":100`, `"Value":62`, 1))
+		require.NoError(t, err)
+		assert.Equal(t, &entity.RuleDecision{Rule: "charge.rego", Reason: "charge is below 80%"}, got.Decision)
+		assert.Contains(t, got.FailedExpressions, "charge.rego:5: input[0].Variables[0].Value >= 80")
+	})
+
+	t.Run("unknown rule", func(t *testing.T) {
+		_, err := repo.Explain("missing.rego", validJSON)
+		assert.ErrorIs(t, err, ErrRuleNotFound)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := repo.Explain("charge.rego", "not json")
+		assert.ErrorIs(t, err, ErrDecodeFailed)
+	})
+}
+
+func TestPreparedRepository_CanDeny(t *testing.T) {
+	fs := newTestFS(t, map[string][]byte{
+		"alwaysTrue.rego": []byte(`package upswake
+default wake := true`),
+		"maintenance.rego": []byte(`package upswake
+deny contains "maintenance window" if time.clock(time.now_ns())[0] == 3`),
+		"veto.rego": []byte(`package upswake
+default deny := false`),
+	})
+	repo, err := NewPreparedRepository(fs)
+	require.NoError(t, err)
+	require.NoError(t, repo.LoadInlineRules(map[string]string{
+		"inline:deny": "package upswake\ndeny if input[0].Name == \"eaton\"",
+	}))
+
+	assert.False(t, repo.CanDeny("alwaysTrue.rego"))
+	assert.True(t, repo.CanDeny("maintenance.rego"))
+	assert.True(t, repo.CanDeny("veto.rego"))
+	assert.True(t, repo.CanDeny("inline:deny"))
+	assert.True(t, repo.CanDeny("missing.rego"), "missing rules are assumed to deny")
+}
+
+func TestPreparedRepository_LoadInlineRules(t *testing.T) {
+	newRepo := func(t *testing.T) (*PreparedRepository, afero.Fs) {
+		t.Helper()
+		fs := newTestFS(t, map[string][]byte{
+			"alwaysFalse.rego": []byte(`package upswake
+default wake := false`),
+		})
+		repo, err := NewPreparedRepository(fs)
+		require.NoError(t, err)
+		return repo, fs
+	}
+
+	t.Run("inline rules are evaluated alongside files", func(t *testing.T) {
+		repo, _ := newRepo(t)
+		require.NoError(t, repo.LoadInlineRules(map[string]string{
+			"inline:always": "package upswake\ndefault wake := true",
+		}))
+
+		assert.ElementsMatch(t, []string{"alwaysFalse.rego", "inline:always"}, repo.RuleNames())
+
+		got, err := repo.Evaluate("inline:always", validJSON)
+		require.NoError(t, err)
+		assert.Equal(t, &entity.RuleDecision{Rule: "inline:always", Allowed: true}, got)
+
+		got, err = repo.Evaluate("alwaysFalse.rego", validJSON)
+		require.NoError(t, err)
+		assert.False(t, got.Allowed)
+	})
+
+	t.Run("inline rules survive a reload", func(t *testing.T) {
+		repo, fs := newRepo(t)
+		require.NoError(t, repo.LoadInlineRules(map[string]string{
+			"inline:always": "package upswake\ndefault wake := true",
+		}))
+		require.NoError(t, afero.WriteFile(fs, "alwaysTrue.rego", []byte("package upswake\ndefault wake := true"), 0o644))
+
+		_, err := repo.Reload()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"alwaysFalse.rego", "alwaysTrue.rego", "inline:always"}, repo.RuleNames())
+	})
+
+	t.Run("inline rules can't shadow files", func(t *testing.T) {
+		repo, _ := newRepo(t)
+		err := repo.LoadInlineRules(map[string]string{
+			"alwaysFalse.rego": "package upswake\ndefault wake := true",
+		})
+		assert.ErrorIs(t, err, ErrInlineRuleName)
+
+		_, err = repo.Evaluate("inline:alwaysFalse.rego", validJSON)
+		assert.ErrorIs(t, err, ErrRuleNotFound)
+	})
+
+	t.Run("invalid inline rules are not loaded", func(t *testing.T) {
+		repo, _ := newRepo(t)
+		require.NoError(t, repo.LoadInlineRules(map[string]string{
+			"inline:always": "package upswake\ndefault wake := true",
+		}))
+
+		err := repo.LoadInlineRules(map[string]string{
+			"inline:valid":        "package upswake\ndefault wake := true",
+			"inline:wrongPackage": "package other\ndefault wake := true",
+			"inline:noCompile":    "package upswake\nwake if undefined_function(1)",
+		})
+		assert.ErrorIs(t, err, ErrPackageName)
+		assert.ErrorIs(t, err, ErrCompileError)
+
+		assert.ElementsMatch(t, []string{"alwaysFalse.rego", "inline:always"}, repo.RuleNames(), "previous inline rules should be kept")
+	})
+}
+
+func TestPreparedRepository_Reload(t *testing.T) {
+	fs := newTestFS(t, map[string][]byte{
+		"toggle.rego": []byte(`package upswake
+default wake := false`),
+		"deleted.rego": []byte(`package upswake
+default wake := true`),
+	})
+
+	repo, err := NewPreparedRepository(fs)
+	require.NoError(t, err)
+
+	t.Run("no changes", func(t *testing.T) {
+		changed, err := repo.Reload()
+		require.NoError(t, err)
+		assert.Empty(t, changed)
+	})
+
+	t.Run("changed, added and deleted rules", func(t *testing.T) {
+		require.NoError(t, afero.WriteFile(fs, "toggle.rego", []byte(`package upswake
+default wake := true`), 0o644))
+		require.NoError(t, afero.WriteFile(fs, "added.rego", []byte(`package upswake
+default wake := true`), 0o644))
+		require.NoError(t, fs.Remove("deleted.rego"))
+
+		changed, err := repo.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"added.rego", "deleted.rego", "toggle.rego"}, changed)
+		assert.ElementsMatch(t, []string{"added.rego", "toggle.rego"}, repo.RuleNames())
+
+		got, err := repo.Evaluate("toggle.rego", validJSON)
+		require.NoError(t, err)
+		assert.True(t, got.Allowed)
+
+		_, err = repo.Evaluate("deleted.rego", validJSON)
+		assert.ErrorIs(t, err, ErrRuleNotFound)
+		assert.NoError(t, repo.Health())
+	})
+
+	t.Run("invalid update keeps last good version", func(t *testing.T) {
+		require.NoError(t, afero.WriteFile(fs, "toggle.rego", []byte(`package wrongname
+default wake := false`), 0o644))
+		require.NoError(t, afero.WriteFile(fs, "broken.rego", []byte(`package upswake
+wake := `), 0o644))
+
+		changed, err := repo.Reload()
+		require.ErrorIs(t, err, ErrPackageName)
+		require.ErrorIs(t, err, ErrInvalidRegoRule)
+		assert.Empty(t, changed)
+		assert.ElementsMatch(t, []string{"added.rego", "toggle.rego"}, repo.RuleNames())
+
+		got, err := repo.Evaluate("toggle.rego", validJSON)
+		require.NoError(t, err)
+		assert.True(t, got.Allowed, "expected the last good version of the rule to be evaluated")
+
+		healthErr := repo.Health()
+		assert.ErrorIs(t, healthErr, ErrPackageName)
+		assert.ErrorContains(t, healthErr, "toggle.rego")
+		assert.ErrorContains(t, healthErr, "broken.rego")
+	})
+
+	t.Run("fixing the rule clears the error", func(t *testing.T) {
+		require.NoError(t, afero.WriteFile(fs, "toggle.rego", []byte(`package upswake
+default wake := false`), 0o644))
+		require.NoError(t, fs.Remove("broken.rego"))
+
+		changed, err := repo.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"toggle.rego"}, changed)
+		assert.NoError(t, repo.Health())
+
+		got, err := repo.Evaluate("toggle.rego", validJSON)
+		require.NoError(t, err)
+		assert.False(t, got.Allowed)
+	})
+}
+
+func TestPreparedRepository_Watch(t *testing.T) {
+	fs := newTestFS(t, map[string][]byte{
+		"rule.rego": []byte(`package upswake
+default wake := false`),
+	})
+
+	repo, err := NewPreparedRepository(fs)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		repo.Watch(ctx, slog.New(slog.DiscardHandler), 10*time.Millisecond)
+		close(done)
+	}()
+
+	require.NoError(t, afero.WriteFile(fs, "rule.rego", []byte(`package upswake
+default wake := true`), 0o644))
+
+	assert.Eventually(t, func() bool {
+		got, evalErr := repo.Evaluate("rule.rego", validJSON)
+		return evalErr == nil && got.Allowed
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch did not return after the context was cancelled")
+	}
+}
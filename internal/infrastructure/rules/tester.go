@@ -0,0 +1,250 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/cover"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+	"github.com/open-policy-agent/opa/v1/tester"
+	nut "github.com/robbiet480/go.nut"
+	"github.com/spf13/afero"
+)
+
+const (
+	// TestFileSuffix marks a Rego file as containing tests for the rule
+	// file of the same name, e.g. 80percentOn_test.rego tests 80percentOn.rego.
+	TestFileSuffix = "_test.rego"
+
+	// FixturesDir is the directory, relative to the rules filesystem, that
+	// UPS snapshots are loaded from. Each fixture is available to tests as
+	// data.fixtures.<file name without .json>.
+	FixturesDir = "fixtures"
+)
+
+var (
+	ErrNoRuleForTest  = errors.New("no rule file found for test file")
+	ErrInvalidFixture = errors.New("invalid fixture")
+	ErrRunTests       = errors.New("failed to run rego tests")
+)
+
+// TestResult is the outcome of a single test rule.
+type TestResult struct {
+	Error    error
+	Name     string
+	FailedAt string
+	Duration time.Duration
+	Passed   bool
+	Skipped  bool
+}
+
+// TestReport holds the results of the tests for a single rule file,
+// along with how much of the rule file those tests covered.
+type TestReport struct {
+	Rule     string
+	TestFile string
+	Results  []TestResult
+	Coverage float64
+}
+
+// Passed returns true if no test in the report failed or errored.
+func (r *TestReport) Passed() bool {
+	for _, result := range r.Results {
+		if !result.Passed && !result.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// IsTestFile returns true if name is a Rego test file rather than a rule.
+func IsTestFile(name string) bool {
+	return strings.HasSuffix(name, TestFileSuffix)
+}
+
+// RunTests discovers every *_test.rego file in fs and runs it against the
+// rule file it is named after. Fixtures in FixturesDir are loaded into
+// data.fixtures so tests can evaluate rules with
+// 'wake with input as data.fixtures.<name>'.
+//
+// A report is returned for every test file, sorted by rule name. An error
+// is only returned if the tests could not be run at all.
+func RunTests(ctx context.Context, fs afero.Fs) ([]*TestReport, error) {
+	entries, err := afero.ReadDir(fs, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadRulesDir, err)
+	}
+
+	fixtures, err := loadFixtures(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []*TestReport
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsTestFile(name) {
+			continue
+		}
+
+		report, err := runTestFile(ctx, fs, name, fixtures)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	slices.SortFunc(reports, func(a, b *TestReport) int {
+		return strings.Compare(a.Rule, b.Rule)
+	})
+	return reports, nil
+}
+
+// runTestFile compiles testFile together with its rule file and runs the
+// tests. Each rule file is tested on its own as every rule defines its own
+// data.upswake.wake.
+func runTestFile(ctx context.Context, fs afero.Fs, testFile string, fixtures map[string]any) (*TestReport, error) {
+	ruleFile := strings.TrimSuffix(testFile, TestFileSuffix) + ".rego"
+
+	ruleModule, err := readModule(fs, ruleFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s (expected %s)", ErrNoRuleForTest, testFile, ruleFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	testModule, err := readModule(fs, testFile)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := map[string]*ast.Module{
+		ruleFile: ruleModule,
+		testFile: testModule,
+	}
+
+	store := inmem.NewFromObject(map[string]any{"fixtures": fixtures})
+	txn, err := store.NewTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRunTests, err)
+	}
+	defer store.Abort(ctx, txn)
+
+	coverage := cover.New()
+	ch, err := tester.NewRunner().
+		SetStore(store).
+		SetModules(modules).
+		AddCustomBuiltins(testerBuiltins()).
+		SetCoverageQueryTracer(coverage).
+		RunTests(ctx, txn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCompileError, testFile, err)
+	}
+
+	report := &TestReport{
+		Rule:     ruleFile,
+		TestFile: testFile,
+	}
+	for result := range ch {
+		report.Results = append(report.Results, newTestResult(result))
+	}
+
+	fileReport := coverage.Report(map[string]*ast.Module{ruleFile: ruleModule}).Files[ruleFile]
+	if fileReport != nil {
+		report.Coverage = fileReport.Coverage
+	}
+
+	return report, nil
+}
+
+func newTestResult(result *tester.Result) TestResult {
+	tr := TestResult{
+		Name:     strings.TrimPrefix(result.Package, "data.") + "." + result.Name,
+		Duration: result.Duration,
+		Error:    result.Error,
+		Passed:   result.Pass(),
+		Skipped:  result.Skip,
+	}
+	if result.FailedAt != nil {
+		tr.FailedAt = result.FailedAt.String()
+	}
+	return tr
+}
+
+// readModule reads and parses a Rego file, applying the same validation
+// as rules loaded by the PreparedRepository.
+func readModule(fs afero.Fs, name string) (*ast.Module, error) {
+	raw, err := afero.ReadFile(fs, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrReadRule, name, err)
+	}
+
+	mod, err := parseRego(name, string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %s: %w", name, err)
+	}
+	return mod, nil
+}
+
+// loadFixtures reads every .json file in FixturesDir. Fixtures must be in
+// the shape produced by UPSRepository.GetJSON, a list of UPSes.
+func loadFixtures(fs afero.Fs) (map[string]any, error) {
+	fixtures := map[string]any{}
+
+	entries, err := afero.ReadDir(fs, FixturesDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return fixtures, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadRulesDir, err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".json" {
+			continue
+		}
+
+		raw, err := afero.ReadFile(fs, path.Join(FixturesDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrInvalidFixture, name, err)
+		}
+
+		fixture, err := decodeFixture(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrInvalidFixture, name, err)
+		}
+		fixtures[strings.TrimSuffix(name, ".json")] = fixture
+	}
+
+	return fixtures, nil
+}
+
+// decodeFixture checks raw is a list of UPSes, then decodes it the same way
+// PreparedRepository.Evaluate decodes its input.
+func decodeFixture(raw []byte) (any, error) {
+	var upses []nut.UPS
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&upses); err != nil {
+		return nil, err
+	}
+
+	var fixture any
+	d = json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&fixture); err != nil {
+		return nil, err
+	}
+	return fixture, nil
+}
@@ -0,0 +1,153 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chargeRule = `package upswake
+
+default wake := false
+
+wake if {
+	input[i].Variables[j].Name == "battery.charge"
+	input[i].Variables[j].Value >= 80
+}
+
+reason := "battery charge is below 80%" if not wake`
+
+const lowChargeJSON = `[{"Name":"cyberpower900","Description":"Unavailable","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[{"Name":"battery.charge","Value":62,"Type":"INTEGER","Description":"Battery charge (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"}]}]`
+
+func TestRunTests(t *testing.T) {
+	tests := []struct {
+		files        map[string][]byte
+		name         string
+		wantErr      error
+		wantPassed   []bool
+		wantCoverage float64
+		wantReports  int
+	}{
+		{
+			name: "no test files",
+			files: map[string][]byte{
+				"charge.rego": []byte(chargeRule),
+			},
+		},
+		{
+			name: "passing tests with fixtures",
+			files: map[string][]byte{
+				"charge.rego": []byte(chargeRule),
+				"charge_test.rego": []byte(`package upswake
+test_full if wake with input as data.fixtures.full
+test_low if not wake with input as data.fixtures.low
+test_low_reason if reason == "battery charge is below 80%" with input as data.fixtures.low`),
+				"fixtures/full.json": []byte(validJSON),
+				"fixtures/low.json":  []byte(lowChargeJSON),
+			},
+			wantReports:  1,
+			wantPassed:   []bool{true, true, true},
+			wantCoverage: 100,
+		},
+		{
+			name: "tests can call builtins",
+			files: map[string][]byte{
+				"charge.rego": []byte(`package upswake
+default wake := false
+wake if upswake.var(input[0], "battery.charge") >= 80`),
+				"charge_test.rego": []byte(`package upswake
+test_full if wake with input as data.fixtures.full
+test_low if not wake with input as data.fixtures.low`),
+				"fixtures/full.json": []byte(validJSON),
+				"fixtures/low.json":  []byte(lowChargeJSON),
+			},
+			wantReports:  1,
+			wantPassed:   []bool{true, true},
+			wantCoverage: 100,
+		},
+		{
+			name: "failing test reduces coverage",
+			files: map[string][]byte{
+				"charge.rego": []byte(chargeRule),
+				"charge_test.rego": []byte(`package upswake
+test_low if wake with input as data.fixtures.low`),
+				"fixtures/low.json": []byte(lowChargeJSON),
+			},
+			wantReports:  1,
+			wantPassed:   []bool{false},
+			wantCoverage: 60,
+		},
+		{
+			name: "test file without rule file",
+			files: map[string][]byte{
+				"missing_test.rego": []byte(`package upswake
+test_wake if wake`),
+			},
+			wantErr: ErrNoRuleForTest,
+		},
+		{
+			name: "test file in wrong package",
+			files: map[string][]byte{
+				"charge.rego": []byte(chargeRule),
+				"charge_test.rego": []byte(`package upswake_test
+test_wake if data.upswake.wake`),
+			},
+			wantErr: ErrPackageName,
+		},
+		{
+			name: "test file does not compile",
+			files: map[string][]byte{
+				"charge.rego": []byte(chargeRule),
+				"charge_test.rego": []byte(`package upswake
+test_wake if undefined_function(wake)`),
+			},
+			wantErr: ErrCompileError,
+		},
+		{
+			name: "fixture not in GetJSON shape",
+			files: map[string][]byte{
+				"charge.rego":         []byte(chargeRule),
+				"fixtures/wrong.json": []byte(`{"battery.charge": 100}`),
+			},
+			wantErr: ErrInvalidFixture,
+		},
+		{
+			name: "fixture with unknown field",
+			files: map[string][]byte{
+				"charge.rego":         []byte(chargeRule),
+				"fixtures/wrong.json": []byte(`[{"Name":"cyberpower900","Charge":100}]`),
+			},
+			wantErr: ErrInvalidFixture,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports, err := RunTests(t.Context(), newTestFS(t, tt.files))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, reports, tt.wantReports)
+			if tt.wantReports == 0 {
+				return
+			}
+
+			report := reports[0]
+			assert.Equal(t, "charge.rego", report.Rule)
+			assert.Equal(t, "charge_test.rego", report.TestFile)
+			assert.InDelta(t, tt.wantCoverage, report.Coverage, 0.01)
+
+			passed := make([]bool, 0, len(report.Results))
+			allPassed := true
+			for _, result := range report.Results {
+				passed = append(passed, result.Passed)
+				allPassed = allPassed && result.Passed
+			}
+			assert.Equal(t, tt.wantPassed, passed)
+			assert.Equal(t, allPassed, report.Passed())
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package apcupsdups
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	nut "github.com/robbiet480/go.nut"
+)
+
+// DefaultTimeout is how long a status request to apcupsd may take.
+const DefaultTimeout = 10 * time.Second
+
+var (
+	ErrConnectionFailed = errors.New("could not connect to apcupsd")
+	ErrReadingStatus    = errors.New("failed to read status from apcupsd")
+)
+
+// ApcupsdRepository reads the UPS from apcupsd's Network Information Server
+// (NIS) on every call, and returns it in the same shape as a NUT server's
+// UPS list, with apcupsd's fields mapped to NUT variable names.
+// Satisfies repository.UPSRepository.
+type ApcupsdRepository struct {
+	timeout time.Duration
+}
+
+// NewApcupsdRepository constructs an ApcupsdRepository whose status
+// requests time out after timeout. If timeout isn't positive,
+// DefaultTimeout is used.
+func NewApcupsdRepository(timeout time.Duration) *ApcupsdRepository {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &ApcupsdRepository{timeout: timeout}
+}
+
+func (r *ApcupsdRepository) GetJSON(server *entity.NutServer) (string, error) {
+	address := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	conn, err := net.DialTimeout("tcp", address, r.timeout)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(r.timeout))
+
+	fields, err := status(conn)
+	if err != nil {
+		return "", fmt.Errorf("%w at %s: %w", ErrReadingStatus, address, err)
+	}
+
+	jsonData, err := json.Marshal([]nut.UPS{toUPS(server.Name, fields)})
+	return string(jsonData), err
+}
+
+// status sends the status command and reads apcupsd's reply. Messages in
+// both directions are a two byte big-endian length followed by that many
+// bytes, and the reply is one message per "KEY : value" line, ended by an
+// empty message.
+func status(conn io.ReadWriter) ([]field, error) {
+	command := []byte("status")
+	message := binary.BigEndian.AppendUint16(nil, uint16(len(command)))
+	if _, err := conn.Write(append(message, command...)); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	var fields []field
+	for {
+		var length uint16
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length == 0 {
+			return fields, nil
+		}
+		line := make([]byte, length)
+		if _, err := io.ReadFull(reader, line); err != nil {
+			return nil, err
+		}
+		key, value, found := strings.Cut(string(line), ":")
+		if !found {
+			continue
+		}
+		fields = append(fields, field{
+			key:   strings.TrimSpace(key),
+			value: strings.TrimSpace(value),
+		})
+	}
+}
+
+// field is one line of apcupsd's status.
+type field struct {
+	key   string
+	value string
+}
+
+// nutVariable is the NUT variable an apcupsd field is mapped to.
+type nutVariable struct {
+	name        string
+	description string
+	// scale converts the field's number to the NUT variable's unit, which
+	// is rounded to a whole number
+	scale float64
+	// numeric fields have their unit, such as "Percent", dropped
+	numeric bool
+}
+
+// nutVariables maps apcupsd's status fields to their NUT equivalents.
+var nutVariables = map[string]nutVariable{
+	"BCHARGE":  {name: "battery.charge", description: "Battery charge (percent of full)", numeric: true},
+	"MBATTCHG": {name: "battery.charge.low", description: "Remaining battery level when UPS switches to LB (percent)", numeric: true},
+	"TIMELEFT": {name: "battery.runtime", description: "Battery runtime (seconds)", numeric: true, scale: 60},
+	"MINTIMEL": {name: "battery.runtime.low", description: "Remaining battery runtime when UPS switches to LB (seconds)", numeric: true, scale: 60},
+	"BATTV":    {name: "battery.voltage", description: "Battery voltage (V)", numeric: true},
+	"NOMBATTV": {name: "battery.voltage.nominal", description: "Nominal battery voltage (V)", numeric: true},
+	"BATTDATE": {name: "battery.date", description: "Battery installation or last change date"},
+	"LINEFREQ": {name: "input.frequency", description: "Input line frequency (Hz)", numeric: true},
+	"HITRANS":  {name: "input.transfer.high", description: "High voltage transfer point (V)", numeric: true},
+	"LOTRANS":  {name: "input.transfer.low", description: "Low voltage transfer point (V)", numeric: true},
+	"LINEV":    {name: "input.voltage", description: "Input voltage (V)", numeric: true},
+	"NOMINV":   {name: "input.voltage.nominal", description: "Nominal input voltage (V)", numeric: true},
+	"OUTPUTV":  {name: "output.voltage", description: "Output voltage (V)", numeric: true},
+	"NOMOUTV":  {name: "output.voltage.nominal", description: "Nominal output voltage (V)", numeric: true},
+	"FIRMWARE": {name: "ups.firmware", description: "UPS firmware"},
+	"LOADPCT":  {name: "ups.load", description: "Load on UPS (percent of full)", numeric: true},
+	"MODEL":    {name: "ups.model", description: "UPS model"},
+	"NOMPOWER": {name: "ups.realpower.nominal", description: "UPS real power rating (W)", numeric: true},
+	"SERIALNO": {name: "ups.serial", description: "UPS serial number"},
+	"ITEMP":    {name: "ups.temperature", description: "UPS temperature (degrees C)", numeric: true},
+}
+
+// statusFlags maps the words of apcupsd's STATUS field to NUT's ups.status
+// flags. Words without a NUT equivalent are dropped.
+var statusFlags = map[string]string{
+	"ONLINE":      "OL",
+	"ONBATT":      "OB",
+	"LOWBATT":     "LB",
+	"CAL":         "CAL",
+	"TRIM":        "TRIM",
+	"BOOST":       "BOOST",
+	"OVERLOAD":    "OVER",
+	"REPLACEBATT": "RB",
+	"SHUTTING":    "FSD",
+}
+
+// toUPS maps apcupsd's status fields to a NUT UPS. The UPS is named after
+// apcupsd's UPSNAME, or nutServer if apcupsd doesn't have one. Fields
+// without a NUT equivalent are kept as strings named apcupsd.<field>, in
+// lower case with spaces replaced by underscores, e.g. apcupsd.end_apc.
+func toUPS(nutServer string, fields []field) nut.UPS {
+	ups := nut.UPS{
+		Name:        nutServer,
+		Description: "apcupsd",
+		Clients:     []string{},
+		Variables:   []nut.Variable{},
+		Commands:    []nut.Command{},
+	}
+	for _, f := range fields {
+		switch f.key {
+		case "UPSNAME":
+			if f.value != "" {
+				ups.Name = f.value
+			}
+			continue
+		case "STATUS":
+			ups.Variables = append(ups.Variables, nutclient.StringVariable("ups.status", "UPS status", upsStatus(f.value)))
+			continue
+		}
+
+		mapped, ok := nutVariables[f.key]
+		if !ok {
+			ups.Variables = append(ups.Variables, nutclient.StringVariable(apcupsdVariable(f.key), "apcupsd "+f.key, f.value))
+			continue
+		}
+		if !mapped.numeric {
+			ups.Variables = append(ups.Variables, nutclient.StringVariable(mapped.name, mapped.description, f.value))
+			continue
+		}
+		number, err := parseNumber(f.value)
+		if err != nil {
+			ups.Variables = append(ups.Variables, nutclient.StringVariable(mapped.name, mapped.description, f.value))
+			continue
+		}
+		if mapped.scale != 0 {
+			number = math.Round(number * mapped.scale)
+		}
+		ups.Variables = append(ups.Variables, nutclient.NumberVariable(mapped.name, mapped.description, number))
+	}
+	return ups
+}
+
+func apcupsdVariable(key string) string {
+	return "apcupsd." + strings.ReplaceAll(strings.ToLower(key), " ", "_")
+}
+
+// parseNumber parses the number at the start of a field such as
+// "100.0 Percent".
+func parseNumber(value string) (float64, error) {
+	number, _, _ := strings.Cut(value, " ")
+	return strconv.ParseFloat(number, 64)
+}
+
+// upsStatus maps apcupsd's STATUS, such as "ONBATT LOWBATT", to NUT flags.
+func upsStatus(status string) string {
+	var flags []string
+	for word := range strings.FieldsSeq(status) {
+		if flag, ok := statusFlags[word]; ok {
+			flags = append(flags, flag)
+		}
+	}
+	return strings.Join(flags, " ")
+}
@@ -0,0 +1,178 @@
+package apcupsdups
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var _ repository.UPSRepository = new(ApcupsdRepository)
+
+// startNIS starts a fake apcupsd Network Information Server answering the
+// status command with lines, and returns the NUT server config for it.
+func startNIS(t *testing.T, lines []string) *entity.NutServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				reader := bufio.NewReader(conn)
+				var length uint16
+				if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+					return
+				}
+				command := make([]byte, length)
+				if _, err := io.ReadFull(reader, command); err != nil || string(command) != "status" {
+					return
+				}
+				for _, line := range lines {
+					_, _ = conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(line)+1)))
+					_, _ = conn.Write([]byte(line + "\n"))
+				}
+				_, _ = conn.Write([]byte{0, 0})
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return &entity.NutServer{Name: "raspberrypi", Host: "127.0.0.1", Port: addr.Port, Type: entity.SourceTypeApcupsd}
+}
+
+func readStatus(t *testing.T) []string {
+	t.Helper()
+	raw, err := os.ReadFile("testing/status.txt")
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(raw)), "\n")
+}
+
+func TestApcupsdRepository_GetJSON(t *testing.T) {
+	server := startNIS(t, readStatus(t))
+
+	got, err := NewApcupsdRepository(time.Second).GetJSON(server)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `[{
+		"Name": "smartups750",
+		"Description": "apcupsd",
+		"Master": false,
+		"NumberOfLogins": 0,
+		"Clients": [],
+		"Commands": [],
+		"Variables": [
+			{"Name":"apcupsd.apc","Value":"001,036,0879","Type":"STRING","Description":"apcupsd APC","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"apcupsd.date","Value":"2026-01-05 17:30:00 +0000","Type":"STRING","Description":"apcupsd DATE","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"apcupsd.hostname","Value":"raspberrypi","Type":"STRING","Description":"apcupsd HOSTNAME","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"apcupsd.version","Value":"3.14.14 (31 May 2016) debian","Type":"STRING","Description":"apcupsd VERSION","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"apcupsd.cable","Value":"USB Cable","Type":"STRING","Description":"apcupsd CABLE","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"apcupsd.driver","Value":"USB UPS Driver","Type":"STRING","Description":"apcupsd DRIVER","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"apcupsd.upsmode","Value":"Stand Alone","Type":"STRING","Description":"apcupsd UPSMODE","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"ups.model","Value":"Smart-UPS 750","Type":"STRING","Description":"UPS model","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"ups.status","Value":"OB LB","Type":"STRING","Description":"UPS status","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"input.voltage","Value":0,"Type":"INTEGER","Description":"Input voltage (V)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"ups.load","Value":15,"Type":"INTEGER","Description":"Load on UPS (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.charge","Value":62,"Type":"INTEGER","Description":"Battery charge (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.runtime","Value":2712,"Type":"INTEGER","Description":"Battery runtime (seconds)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.charge.low","Value":5,"Type":"INTEGER","Description":"Remaining battery level when UPS switches to LB (percent)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.runtime.low","Value":180,"Type":"INTEGER","Description":"Remaining battery runtime when UPS switches to LB (seconds)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"ups.temperature","Value":29.2,"Type":"FLOAT_64","Description":"UPS temperature (degrees C)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.voltage","Value":26.8,"Type":"FLOAT_64","Description":"Battery voltage (V)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"input.frequency","Value":50,"Type":"INTEGER","Description":"Input line frequency (Hz)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"apcupsd.numxfers","Value":"2","Type":"STRING","Description":"apcupsd NUMXFERS","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"ups.serial","Value":"AS1234567890","Type":"STRING","Description":"UPS serial number","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"ups.realpower.nominal","Value":500,"Type":"INTEGER","Description":"UPS real power rating (W)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"apcupsd.end_apc","Value":"2026-01-05 17:30:01 +0000","Type":"STRING","Description":"apcupsd END APC","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"}
+		]
+	}]`, got)
+}
+
+func TestApcupsdRepository_GetJSON_errors(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		port := listener.Addr().(*net.TCPAddr).Port
+		require.NoError(t, listener.Close())
+
+		_, err = NewApcupsdRepository(time.Second).GetJSON(&entity.NutServer{Host: "127.0.0.1", Port: port})
+		assert.ErrorIs(t, err, ErrConnectionFailed)
+	})
+
+	t.Run("closed before the end of the status", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = listener.Close() })
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte{0, 20, 'S', 'T'})
+			_ = conn.Close()
+		}()
+
+		_, err = NewApcupsdRepository(time.Second).GetJSON(&entity.NutServer{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port})
+		assert.ErrorIs(t, err, ErrReadingStatus)
+	})
+}
+
+func TestToUPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		fields     []field
+		wantName   string
+		wantStatus string
+	}{
+		{
+			name:       "no UPSNAME uses the NUT server name",
+			fields:     []field{{key: "STATUS", value: "ONLINE"}},
+			wantName:   "raspberrypi",
+			wantStatus: "OL",
+		},
+		{
+			name:       "unknown status words are dropped",
+			fields:     []field{{key: "UPSNAME", value: "ups"}, {key: "STATUS", value: "ONLINE COMMLOST REPLACEBATT"}},
+			wantName:   "ups",
+			wantStatus: "OL RB",
+		},
+		{
+			name:       "shutting down",
+			fields:     []field{{key: "STATUS", value: "ONBATT LOWBATT SHUTTING DOWN"}},
+			wantName:   "raspberrypi",
+			wantStatus: "OB LB FSD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ups := toUPS("raspberrypi", tt.fields)
+			assert.Equal(t, tt.wantName, ups.Name)
+			require.Len(t, ups.Variables, 1)
+			assert.Equal(t, "ups.status", ups.Variables[0].Name)
+			assert.Equal(t, tt.wantStatus, ups.Variables[0].Value)
+		})
+	}
+
+	t.Run("unparseable numbers are kept as strings", func(t *testing.T) {
+		ups := toUPS("raspberrypi", []field{{key: "BCHARGE", value: "N/A"}})
+		require.Len(t, ups.Variables, 1)
+		assert.Equal(t, "battery.charge", ups.Variables[0].Name)
+		assert.Equal(t, "N/A", ups.Variables[0].Value)
+	})
+}
@@ -1,31 +1,56 @@
 package cachedups
 
 import (
-	"fmt"
+	"cmp"
+	"encoding/json"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
 	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"golang.org/x/sync/singleflight"
 )
 
 // CachedRepository wraps another UPSRepository and caches results
-// keyed by host:port. Call Reset() between evaluation cycles to
-// force fresh data on the next tick.
+// keyed by each NUT server's source type and address. Each NUT server's
+// entity.NutCache sets how long its results are cached for, and whether
+// stale results and failures are served from the cache. Concurrent reads of
+// a NUT server that isn't cached share a single read of the inner
+// repository. Call Reset() between evaluation cycles to force fresh data on
+// the next tick.
 type CachedRepository struct {
 	inner repository.UPSRepository
 	cache map[string]*cachedResult
+	reads singleflight.Group
 	ttl   time.Duration
 	mu    sync.RWMutex
+
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	coalesced    atomic.Uint64
+	staleHits    atomic.Uint64
+	negativeHits atomic.Uint64
 }
 
 type cachedResult struct {
-	cachedAt time.Time
-	json     string
+	cachedAt  time.Time
+	failedAt  time.Time
+	retryAt   time.Time
+	err       error
+	nutServer string
+	// address and lastError leave out the NUT server's secrets, as they
+	// are shown by the API
+	address   string
+	lastError string
+	json      string
+	ttl       time.Duration
+	failures  int
 }
 
-// NewCachedRepository creates a CachedRepository that wraps the provided UPSRepository and caches GetJSON results keyed by host:port.
-// It initialises an empty cache and uses ttl as the maximum age for cached entries.
+// NewCachedRepository creates a CachedRepository that wraps the provided UPSRepository and caches GetJSON results keyed by each NUT server's address.
+// It initialises an empty cache and uses ttl as the maximum age for cached entries of NUT servers that don't set their own.
 func NewCachedRepository(inner repository.UPSRepository, ttl time.Duration) *CachedRepository {
 	return &CachedRepository{
 		inner: inner,
@@ -35,27 +60,81 @@ func NewCachedRepository(inner repository.UPSRepository, ttl time.Duration) *Cac
 }
 
 func (r *CachedRepository) GetJSON(server *entity.NutServer) (string, error) {
-	key := fmt.Sprintf("%s:%d", server.Host, server.Port)
+	key := cacheKey(server)
+	ttl := server.Cache.FreshFor(r.ttl)
 
 	r.mu.RLock()
-	if result, ok := r.cache[key]; ok && time.Since(result.cachedAt) < r.ttl {
-		r.mu.RUnlock()
-		return result.json, nil
+	if result, ok := r.cache[key]; ok {
+		now := time.Now()
+		switch {
+		case result.fresh(now, ttl):
+			r.mu.RUnlock()
+			r.hits.Add(1)
+			return result.json, nil
+		case now.Before(result.retryAt) && result.servesStale(now, ttl, server.Cache.MaxStale):
+			stale := result.stale()
+			r.mu.RUnlock()
+			r.staleHits.Add(1)
+			return result.json, stale
+		case now.Before(result.retryAt):
+			r.mu.RUnlock()
+			r.negativeHits.Add(1)
+			return "", result.err
+		}
 	}
 	r.mu.RUnlock()
 
-	json, err := r.inner.GetJSON(server)
-	if err != nil { // skip saving cache on error
-		return json, err
+	leader := false
+	json, err, _ := r.reads.Do(key, func() (any, error) {
+		leader = true
+		r.misses.Add(1)
+		json, err := r.inner.GetJSON(server)
+		return r.store(key, server, ttl, json, err)
+	})
+	if !leader {
+		r.coalesced.Add(1)
 	}
+	return json.(string), err
+}
 
+// store caches the result of reading server and returns what GetJSON
+// should: the stale result if reading failed but it can still be served.
+func (r *CachedRepository) store(key string, server *entity.NutServer, ttl time.Duration, json string, err error) (string, error) {
 	r.mu.Lock()
-	r.cache[key] = &cachedResult{
-		json:     json,
-		cachedAt: time.Now(),
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	result, ok := r.cache[key]
+	if !ok {
+		result = &cachedResult{}
+		r.cache[key] = result
 	}
-	r.mu.Unlock()
+	result.nutServer = server.Name
+	result.address = server.RedactedAddress()
+	result.ttl = ttl
 
+	if err == nil {
+		result.json = json
+		result.cachedAt = now
+		result.err = nil
+		result.lastError = ""
+		result.failures = 0
+		result.retryAt = time.Time{}
+		return json, nil
+	}
+
+	result.err = err
+	result.lastError = server.Redact(err.Error())
+	result.failures++
+	result.failedAt = now
+	result.retryAt = time.Time{}
+	if backoff := server.Cache.Backoff(result.failures, ttl); backoff > 0 {
+		result.retryAt = now.Add(backoff)
+	}
+	if result.servesStale(now, ttl, server.Cache.MaxStale) {
+		r.staleHits.Add(1)
+		return result.json, result.stale()
+	}
 	return json, err
 }
 
@@ -65,3 +144,99 @@ func (r *CachedRepository) Reset() {
 	defer r.mu.Unlock()
 	clear(r.cache)
 }
+
+// Invalidate removes server from the cache so the next GetJSON call for it
+// fetches fresh data, leaving other NUT servers cached.
+func (r *CachedRepository) Invalidate(server *entity.NutServer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, cacheKey(server))
+}
+
+// Stats returns what is cached for each NUT server, ordered by NUT server
+// name, and how reads have been served since the repository was created.
+func (r *CachedRepository) Stats() *entity.CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	stats := &entity.CacheStats{
+		Entries:      make([]*entity.CacheEntry, 0, len(r.cache)),
+		Hits:         r.hits.Load(),
+		Misses:       r.misses.Load(),
+		Coalesced:    r.coalesced.Load(),
+		StaleHits:    r.staleHits.Load(),
+		NegativeHits: r.negativeHits.Load(),
+	}
+	for _, result := range r.cache {
+		stats.Entries = append(stats.Entries, result.entry(now))
+	}
+	slices.SortFunc(stats.Entries, func(a, b *entity.CacheEntry) int {
+		return cmp.Or(cmp.Compare(a.NutServer, b.NutServer), cmp.Compare(a.Address, b.Address))
+	})
+	return stats
+}
+
+// fresh returns true if the result holds UPSes read less than ttl ago.
+func (c *cachedResult) fresh(now time.Time, ttl time.Duration) bool {
+	return !c.cachedAt.IsZero() && now.Sub(c.cachedAt) < ttl
+}
+
+// servesStale returns true if the result holds UPSes that expired less
+// than maxStale ago.
+func (c *cachedResult) servesStale(now time.Time, ttl, maxStale time.Duration) bool {
+	return maxStale > 0 && !c.cachedAt.IsZero() && now.Sub(c.cachedAt) < ttl+maxStale
+}
+
+// stale returns the error served along with the result's UPSes once they
+// have expired.
+func (c *cachedResult) stale() error {
+	return &entity.StaleError{ReadAt: c.cachedAt, Err: c.err}
+}
+
+func (c *cachedResult) entry(now time.Time) *entity.CacheEntry {
+	entry := &entity.CacheEntry{
+		NutServer:           c.nutServer,
+		Address:             c.address,
+		LastError:           c.lastError,
+		FailedAt:            c.failedAt,
+		RetryAt:             c.retryAt,
+		ConsecutiveFailures: c.failures,
+	}
+	if !c.cachedAt.IsZero() {
+		entry.CachedAt = c.cachedAt
+		entry.ExpiresAt = c.cachedAt.Add(c.ttl)
+		entry.AgeSeconds = int64(now.Sub(c.cachedAt).Seconds())
+		entry.Fresh = c.fresh(now, c.ttl)
+	}
+	return entry
+}
+
+// cacheKey identifies the source a NUT server is read from. It is JSON
+// encoded so a command argument containing a space can't collide with two
+// arguments, and HTTP sources sharing a URL but not credentials are cached
+// separately.
+func cacheKey(server *entity.NutServer) string {
+	key := struct {
+		Kind     entity.SourceType `json:"kind"`
+		Address  string            `json:"address,omitempty"`
+		Command  []string          `json:"command,omitempty"`
+		Username string            `json:"username,omitempty"`
+		Password string            `json:"password,omitempty"`
+	}{Kind: server.Type.Kind()}
+
+	switch key.Kind {
+	case entity.SourceTypeCommand:
+		key.Command = server.Plugin.Command
+	case entity.SourceTypeHTTP:
+		key.Address = server.Plugin.URL
+		key.Username = server.Username
+		key.Password = server.Password
+	default:
+		key.Address = server.Address()
+	}
+
+	// Marshalling strings can't fail
+	encoded, _ := json.Marshal(key)
+	return string(encoded)
+}
@@ -5,63 +5,82 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"strconv"
 
 	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
-	nut "github.com/robbiet480/go.nut"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	"github.com/spf13/afero"
 )
 
 // DirectRepository connects to the NUT server on every call.
 // Satisfies repository.UPSRepository.
-type DirectRepository struct{}
+type DirectRepository struct {
+	fs afero.Fs
+}
 
 // NewDirectRepository constructs a DirectRepository that implements repository.UPSRepository.
-// The returned repository opens a fresh connection to the NUT server for each method call.
-func NewDirectRepository() *DirectRepository {
-	return &DirectRepository{}
+// The returned repository opens a fresh connection to the NUT server for each method call,
+// reading any TLS certificates the NUT server's config refers to from fs.
+func NewDirectRepository(fs afero.Fs) *DirectRepository {
+	return &DirectRepository{fs: fs}
 }
 
 var (
 	ErrAuthenticationFailed  = errors.New("failed to authenticate to NUT server")
 	ErrFailureAuthenticating = errors.New("an error occurred during authentication to NUT server")
 	ErrConnectionFailed      = errors.New("could not connect to NUT server")
+	ErrTLSConfig             = errors.New("invalid TLS config for NUT server")
 )
 
-// connect establishes and authenticates a NUT client connection to the specified host and port.
-// On success it returns the authenticated *nut.Client. If the network connection fails it
+// connect establishes and authenticates a NUT client connection to the server, upgrading
+// it to TLS with STARTTLS first if the server's TLS mode asks for it.
+// On success it returns the authenticated *nutclient.Client. If the TLS config can't be
+// loaded it returns ErrTLSConfig; if the network connection or TLS negotiation fails it
 // returns ErrConnectionFailed wrapped with the underlying error; if the authentication call
 // fails it returns ErrFailureAuthenticating wrapped with the underlying error. If authentication
 // is refused it closes the session and returns ErrAuthenticationFailed including the target host
 // and port.
-func connect(host string, port int, username, password string) (*nut.Client, error) {
-	client, err := nut.Connect(host, port)
+func connect(fs afero.Fs, server *entity.NutServer) (*nutclient.Client, error) {
+	opts := nutclient.Options{TLSMode: server.TLS.Mode}
+	if server.TLS.Enabled() {
+		tlsConfig, err := server.TLS.ClientConfig(fs, server.Host)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrTLSConfig, server.Name, err)
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	address := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	client, err := nutclient.Dial(address, opts)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
 	}
 
-	authenticate, err := client.Authenticate(username, password)
+	authenticate, err := client.Authenticate(server.Username, server.Password)
 	if err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("%w: %w", ErrFailureAuthenticating, err)
 	}
 	if !authenticate {
-		disconnect(&client, host)
-		return nil, fmt.Errorf("%w: could not authenticate to NUT server at %s:%d", ErrAuthenticationFailed, host, port)
+		disconnect(client, server.Host)
+		return nil, fmt.Errorf("%w: could not authenticate to NUT server at %s", ErrAuthenticationFailed, address)
 	}
-	return &client, nil
+	return client, nil
 }
 
 // disconnect closes the client's connection to the NUT server and logs a warning if the operation fails.
 // It does not return an error to the caller.
-func disconnect(client *nut.Client, host string) {
-	_, err := client.Disconnect()
-	if err != nil {
+func disconnect(client *nutclient.Client, host string) {
+	if err := client.Disconnect(); err != nil {
 		slog.Warn("Error disconnecting from NUT server",
 			slog.String("host", host),
 			slog.Any("error", err))
 	}
 }
 
-func (*DirectRepository) GetJSON(server *entity.NutServer) (string, error) {
-	client, err := connect(server.Host, server.Port, server.Username, server.Password)
+func (r *DirectRepository) GetJSON(server *entity.NutServer) (string, error) {
+	client, err := connect(r.fs, server)
 	if err != nil {
 		return "", err
 	}
@@ -1,13 +1,19 @@
 package directups
 
 import (
+	"context"
+	"net"
 	"testing"
 
 	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
 	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutserver"
 	"github.com/google/uuid"
 	levenshtein "github.com/ka-weihe/fast-levenshtein"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -20,8 +26,9 @@ var (
 
 func TestNewDirectRepository(t *testing.T) {
 	t.Run("create new direct repository", func(t *testing.T) {
-		want := &DirectRepository{}
-		got := NewDirectRepository()
+		fs := afero.NewMemMapFs()
+		want := &DirectRepository{fs: fs}
+		got := NewDirectRepository(fs)
 		assert.Equalf(t, want, got, "NewDirectRepository()")
 	})
 }
@@ -93,7 +100,7 @@ func TestDirectRepository_GetJSON(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			directRepo := NewDirectRepository()
+			directRepo := NewDirectRepository(afero.NewMemMapFs())
 			got, err := directRepo.GetJSON(tt.args.server)
 			assert.ErrorIs(t, err, tt.wantErr)
 
@@ -105,3 +112,55 @@ func TestDirectRepository_GetJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestDirectRepository_GetJSON_simulator(t *testing.T) {
+	server := nutserver.NewServer(nutserver.StaticSource{{
+		Name:        "cyberpower900",
+		Description: "Simulated UPS for testing",
+		Variables:   map[string]string{"battery.charge": "85", "ups.status": "OL"},
+	}}, nutserver.WithUser("upsmon", "upsmon"))
+	require.NoError(t, server.Listen("127.0.0.1:0"))
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	addr := server.Addr().(*net.TCPAddr)
+
+	tests := []struct {
+		wantErr  error
+		name     string
+		username string
+		password string
+		want     string
+	}{
+		{
+			name:     "valid credentials",
+			username: "upsmon",
+			password: "upsmon",
+			want:     `[{"Name":"cyberpower900","Description":"Simulated UPS for testing","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[{"Name":"battery.charge","Value":85,"Type":"INTEGER","Description":"Description unavailable","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},{"Name":"ups.status","Value":"OL","Type":"STRING","Description":"Description unavailable","Writeable":false,"MaximumLength":0,"OriginalType":"STRING:64"}],"Commands":[]}]`,
+		},
+		{
+			name:     "wrong password",
+			username: "upsmon",
+			password: randomPassword,
+			// upsd accepts any credentials, then denies access when they are used
+			wantErr: nutclient.ErrServer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directRepo := NewDirectRepository(afero.NewMemMapFs())
+			got, err := directRepo.GetJSON(&entity.NutServer{
+				Host:     addr.IP.String(),
+				Port:     addr.Port,
+				Username: tt.username,
+				Password: tt.password,
+			})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package directups
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	nut "github.com/robbiet480/go.nut"
+	"github.com/spf13/afero"
+)
+
+const (
+	// DefaultIdleTimeout is how long an unused session is kept open.
+	DefaultIdleTimeout = time.Minute
+
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+var ErrReconnectBackoff = errors.New("waiting to reconnect to NUT server")
+
+// nutClient is the part of a NUT session the pooled repository uses.
+type nutClient interface {
+	GetUPSList() ([]nut.UPS, error)
+	GetVariables(names ...string) ([]nut.UPS, error)
+	Disconnect() error
+}
+
+// dialFunc opens an authenticated session with a NUT server.
+type dialFunc func(server *entity.NutServer) (nutClient, error)
+
+// PooledRepository keeps an authenticated session open to each NUT server,
+// keyed by host:port, and reuses it across calls. Commands on a session are
+// serialised. A session that fails is closed and reopened, and a server
+// that can't be connected to is retried with exponential backoff.
+// Satisfies repository.UPSRepository and repository.UPSVariableRepository.
+type PooledRepository struct {
+	dial        dialFunc
+	sessions    map[string]*session
+	idleTimeout time.Duration
+	mu          sync.Mutex
+}
+
+// session is the connection to a single NUT server.
+type session struct {
+	retryAt  time.Time
+	client   nutClient
+	lastErr  error
+	idle     *time.Timer
+	username string
+	password string
+	host     string
+	tls      entity.NutTLS
+	failures int
+	mu       sync.Mutex
+}
+
+// NewPooledRepository constructs a PooledRepository that implements repository.UPSRepository.
+// Sessions that haven't been used for idleTimeout are closed; if idleTimeout isn't positive,
+// DefaultIdleTimeout is used. TLS certificates the NUT servers' config refers to are read from fs.
+func NewPooledRepository(fs afero.Fs, idleTimeout time.Duration) *PooledRepository {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &PooledRepository{
+		dial: func(server *entity.NutServer) (nutClient, error) {
+			return connect(fs, server)
+		},
+		sessions:    make(map[string]*session),
+		idleTimeout: idleTimeout,
+	}
+}
+
+func (r *PooledRepository) GetJSON(server *entity.NutServer) (string, error) {
+	return r.read(server, func(client nutClient) ([]nut.UPS, error) {
+		return client.GetUPSList()
+	})
+}
+
+// GetVariables reads only the named variables of each UPS, without the
+// descriptions, types, clients and commands GetJSON reads too.
+func (r *PooledRepository) GetVariables(server *entity.NutServer, names []string) (string, error) {
+	return r.read(server, func(client nutClient) ([]nut.UPS, error) {
+		return client.GetVariables(names...)
+	})
+}
+
+// read reads the UPSes with get on the NUT server's session and returns them
+// as JSON.
+func (r *PooledRepository) read(server *entity.NutServer, get func(client nutClient) ([]nut.UPS, error)) (string, error) {
+	s := r.session(server)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer r.resetIdle(s)
+
+	reused := s.client != nil
+	if s.client != nil && (s.username != server.Username || s.password != server.Password || s.tls != server.TLS) {
+		// the credentials or TLS settings have changed since the session was opened
+		s.close()
+		reused = false
+	}
+
+	ups, err := r.getUPSList(s, server, get)
+	if err != nil && reused {
+		// the server may have closed a session that was reused, so try
+		// once more with a new one
+		slog.Debug("Reconnecting to NUT server",
+			slog.String("host", server.Host),
+			slog.Any("error", err))
+		ups, err = r.getUPSList(s, server, get)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.Marshal(ups)
+	return string(jsonData), err
+}
+
+// getUPSList reads the UPSes on the session with get, opening it first if
+// needed. If reading fails the session is closed.
+func (r *PooledRepository) getUPSList(s *session, server *entity.NutServer, get func(client nutClient) ([]nut.UPS, error)) ([]nut.UPS, error) {
+	if s.client == nil {
+		if err := r.open(s, server); err != nil {
+			return nil, err
+		}
+	}
+
+	ups, err := get(s.client)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	return ups, nil
+}
+
+// open connects the session, unless a previous attempt failed recently.
+func (r *PooledRepository) open(s *session, server *entity.NutServer) error {
+	if time.Now().Before(s.retryAt) {
+		return fmt.Errorf("%w until %s: %w", ErrReconnectBackoff, s.retryAt.Format(time.RFC3339), s.lastErr)
+	}
+
+	client, err := r.dial(server)
+	if err != nil {
+		s.failures++
+		s.lastErr = err
+		s.retryAt = time.Now().Add(reconnectBackoff(s.failures))
+		return err
+	}
+
+	s.client = client
+	s.username = server.Username
+	s.password = server.Password
+	s.tls = server.TLS
+	s.failures = 0
+	s.lastErr = nil
+	s.retryAt = time.Time{}
+	return nil
+}
+
+// session returns the session for server, creating it if needed.
+func (r *PooledRepository) session(server *entity.NutServer) *session {
+	key := fmt.Sprintf("%s:%d", server.Host, server.Port)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[key]
+	if !ok {
+		s = &session{host: server.Host}
+		r.sessions[key] = s
+	}
+	return s
+}
+
+// resetIdle closes the session once it hasn't been used for the idle timeout.
+// The session's lock must be held.
+func (r *PooledRepository) resetIdle(s *session) {
+	if s.client == nil {
+		return
+	}
+	if s.idle != nil {
+		s.idle.Reset(r.idleTimeout)
+		return
+	}
+	s.idle = time.AfterFunc(r.idleTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.close()
+	})
+}
+
+// Close closes every open session.
+func (r *PooledRepository) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sessions {
+		s.mu.Lock()
+		if s.idle != nil {
+			s.idle.Stop()
+		}
+		s.close()
+		s.mu.Unlock()
+	}
+}
+
+// close disconnects the session's client, if it has one. The session's lock
+// must be held.
+func (s *session) close() {
+	if s.client == nil {
+		return
+	}
+	if err := s.client.Disconnect(); err != nil {
+		slog.Warn("Error disconnecting from NUT server",
+			slog.String("host", s.host),
+			slog.Any("error", err))
+	}
+	s.client = nil
+}
+
+// reconnectBackoff returns how long to wait before reconnecting after the
+// given number of consecutive failures.
+func reconnectBackoff(failures int) time.Duration {
+	backoff := minReconnectBackoff
+	for range failures - 1 {
+		backoff *= 2
+		if backoff >= maxReconnectBackoff {
+			return maxReconnectBackoff
+		}
+	}
+	return backoff
+}
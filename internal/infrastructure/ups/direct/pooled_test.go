@@ -0,0 +1,265 @@
+package directups
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	nut "github.com/robbiet480/go.nut"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var (
+	_ repository.UPSRepository         = new(PooledRepository)
+	_ repository.UPSVariableRepository = new(PooledRepository)
+)
+
+var errSessionClosed = errors.New("session closed")
+
+// fakeClient is a NUT session that fails once closed.
+type fakeClient struct {
+	calls        atomic.Int32
+	inFlight     atomic.Int32
+	concurrent   atomic.Bool
+	disconnected atomic.Bool
+	broken       atomic.Bool
+}
+
+func (c *fakeClient) GetUPSList() ([]nut.UPS, error) {
+	if c.inFlight.Add(1) > 1 {
+		c.concurrent.Store(true)
+	}
+	defer c.inFlight.Add(-1)
+	c.calls.Add(1)
+	time.Sleep(time.Millisecond)
+
+	if c.broken.Load() || c.disconnected.Load() {
+		return nil, errSessionClosed
+	}
+	return []nut.UPS{{Name: "cyberpower900"}}, nil
+}
+
+func (c *fakeClient) GetVariables(names ...string) ([]nut.UPS, error) {
+	c.calls.Add(1)
+	if c.broken.Load() || c.disconnected.Load() {
+		return nil, errSessionClosed
+	}
+	variables := make([]nut.Variable, 0, len(names))
+	for _, name := range names {
+		variables = append(variables, nut.Variable{Name: name, Value: "OL"})
+	}
+	return []nut.UPS{{Name: "cyberpower900", Variables: variables}}, nil
+}
+
+func (c *fakeClient) Disconnect() error {
+	c.disconnected.Store(true)
+	return nil
+}
+
+// fakeDialer records the sessions it opens, failing while err is set.
+type fakeDialer struct {
+	err     error
+	clients []*fakeClient
+	mu      sync.Mutex
+}
+
+func (d *fakeDialer) dial(*entity.NutServer) (nutClient, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	client := &fakeClient{}
+	d.clients = append(d.clients, client)
+	return client, nil
+}
+
+func (d *fakeDialer) dialed() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.clients)
+}
+
+func newTestPool(idleTimeout time.Duration) (*PooledRepository, *fakeDialer) {
+	dialer := &fakeDialer{}
+	repo := NewPooledRepository(afero.NewMemMapFs(), idleTimeout)
+	repo.dial = dialer.dial
+	return repo, dialer
+}
+
+var testServer = &entity.NutServer{
+	Host:     "127.0.0.1",
+	Port:     entity.DefaultNUTServerPort,
+	Username: "upsmon",
+	Password: "upsmon",
+}
+
+const wantUPSJSON = `[{"Name":"cyberpower900","Description":"","Master":false,"NumberOfLogins":0,"Clients":null,"Variables":null,"Commands":null}]`
+
+func TestNewPooledRepository(t *testing.T) {
+	assert.Equal(t, DefaultIdleTimeout, NewPooledRepository(afero.NewMemMapFs(), 0).idleTimeout)
+	assert.Equal(t, time.Second, NewPooledRepository(afero.NewMemMapFs(), time.Second).idleTimeout)
+}
+
+func TestPooledRepository_GetJSON(t *testing.T) {
+	t.Run("reuses session", func(t *testing.T) {
+		repo, dialer := newTestPool(time.Minute)
+		defer repo.Close()
+
+		for range 3 {
+			got, err := repo.GetJSON(testServer)
+			require.NoError(t, err)
+			assert.JSONEq(t, wantUPSJSON, got)
+		}
+		assert.Equal(t, 1, dialer.dialed())
+		assert.EqualValues(t, 3, dialer.clients[0].calls.Load())
+	})
+
+	t.Run("session per host and port", func(t *testing.T) {
+		repo, dialer := newTestPool(time.Minute)
+		defer repo.Close()
+
+		other := *testServer
+		other.Port = 3494
+		for _, server := range []*entity.NutServer{testServer, &other, testServer} {
+			_, err := repo.GetJSON(server)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, dialer.dialed())
+	})
+
+	t.Run("serialises commands", func(t *testing.T) {
+		repo, dialer := newTestPool(time.Minute)
+		defer repo.Close()
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				_, err := repo.GetJSON(testServer)
+				assert.NoError(t, err)
+			})
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, dialer.dialed())
+		assert.False(t, dialer.clients[0].concurrent.Load(), "commands ran concurrently on one session")
+	})
+
+	t.Run("reconnects dead session", func(t *testing.T) {
+		repo, dialer := newTestPool(time.Minute)
+		defer repo.Close()
+
+		_, err := repo.GetJSON(testServer)
+		require.NoError(t, err)
+		dialer.clients[0].broken.Store(true)
+
+		got, err := repo.GetJSON(testServer)
+		require.NoError(t, err)
+		assert.JSONEq(t, wantUPSJSON, got)
+		assert.Equal(t, 2, dialer.dialed())
+		assert.True(t, dialer.clients[0].disconnected.Load())
+	})
+
+	t.Run("reconnects when credentials change", func(t *testing.T) {
+		repo, dialer := newTestPool(time.Minute)
+		defer repo.Close()
+
+		_, err := repo.GetJSON(testServer)
+		require.NoError(t, err)
+
+		changed := *testServer
+		changed.Password = "changed"
+		_, err = repo.GetJSON(&changed)
+		require.NoError(t, err)
+		assert.Equal(t, 2, dialer.dialed())
+		assert.True(t, dialer.clients[0].disconnected.Load())
+	})
+
+	t.Run("backs off after failing to connect", func(t *testing.T) {
+		repo, dialer := newTestPool(time.Minute)
+		defer repo.Close()
+
+		dialer.err = ErrConnectionFailed
+		_, err := repo.GetJSON(testServer)
+		require.ErrorIs(t, err, ErrConnectionFailed)
+
+		dialer.err = nil
+		_, err = repo.GetJSON(testServer)
+		require.ErrorIs(t, err, ErrReconnectBackoff)
+		assert.ErrorIs(t, err, ErrConnectionFailed)
+		assert.Equal(t, 0, dialer.dialed())
+
+		s := repo.session(testServer)
+		s.mu.Lock()
+		s.retryAt = time.Now()
+		s.mu.Unlock()
+
+		_, err = repo.GetJSON(testServer)
+		require.NoError(t, err)
+		assert.Equal(t, 1, dialer.dialed())
+		assert.Zero(t, s.failures)
+	})
+
+	t.Run("closes idle session", func(t *testing.T) {
+		repo, dialer := newTestPool(10 * time.Millisecond)
+		defer repo.Close()
+
+		_, err := repo.GetJSON(testServer)
+		require.NoError(t, err)
+		assert.Eventually(t, dialer.clients[0].disconnected.Load, time.Second, 5*time.Millisecond)
+
+		_, err = repo.GetJSON(testServer)
+		require.NoError(t, err)
+		assert.Equal(t, 2, dialer.dialed())
+	})
+}
+
+func TestPooledRepository_GetVariables(t *testing.T) {
+	repo, dialer := newTestPool(time.Minute)
+	defer repo.Close()
+
+	_, err := repo.GetJSON(testServer)
+	require.NoError(t, err)
+	got, err := repo.GetVariables(testServer, []string{"ups.status"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"Name":"cyberpower900","Description":"","Master":false,"NumberOfLogins":0,"Clients":null,"Variables":[{"Name":"ups.status","Value":"OL","Type":"","Description":"","Writeable":false,"MaximumLength":0,"OriginalType":""}],"Commands":null}]`, got)
+	assert.Equal(t, 1, dialer.dialed(), "GetVariables reuses the session")
+
+	dialer.clients[0].broken.Store(true)
+	_, err = repo.GetVariables(testServer, []string{"ups.status"})
+	require.NoError(t, err, "a broken session is reopened")
+	assert.Equal(t, 2, dialer.dialed())
+}
+
+func TestPooledRepository_Close(t *testing.T) {
+	repo, dialer := newTestPool(time.Minute)
+
+	_, err := repo.GetJSON(testServer)
+	require.NoError(t, err)
+
+	repo.Close()
+	assert.True(t, dialer.clients[0].disconnected.Load())
+}
+
+func Test_reconnectBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 4, want: 8 * time.Second},
+		{failures: 7, want: time.Minute},
+		{failures: 100, want: time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, reconnectBackoff(tt.failures), "failures %d", tt.failures)
+	}
+}
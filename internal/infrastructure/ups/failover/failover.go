@@ -0,0 +1,205 @@
+package failoverups
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+)
+
+var ErrAllEndpointsFailed = errors.New("every endpoint of NUT server failed")
+
+// FailoverRepository wraps another UPSRepository, reading each NUT server
+// from the first of its endpoints that answers, in the order its endpoint
+// strategy picks. The health of every endpoint is tracked, and the endpoint
+// each NUT server was last read from is logged when it changes.
+// Sources that aren't read from a host and port are passed straight through.
+// Satisfies repository.UPSRepository, repository.UPSVariableRepository and
+// repository.EndpointHealthRepository.
+type FailoverRepository struct {
+	inner  repository.UPSRepository
+	logger *slog.Logger
+	now    func() time.Time
+	// servers holds the health of each NUT server's endpoints, keyed by
+	// NUT server name and then address
+	servers map[string]*serverHealth
+	mu      sync.Mutex
+}
+
+type serverHealth struct {
+	endpoints map[string]*entity.EndpointHealth
+	active    string
+}
+
+// NewFailoverRepository creates a FailoverRepository reading every endpoint
+// with inner.
+func NewFailoverRepository(inner repository.UPSRepository, logger *slog.Logger) *FailoverRepository {
+	return &FailoverRepository{
+		inner:   inner,
+		logger:  logger,
+		now:     time.Now,
+		servers: make(map[string]*serverHealth),
+	}
+}
+
+func (r *FailoverRepository) GetJSON(server *entity.NutServer) (string, error) {
+	return r.read(server, r.inner.GetJSON)
+}
+
+// GetVariables reads only the named variables if inner can, and every
+// variable otherwise, failing over in the same way as GetJSON.
+func (r *FailoverRepository) GetVariables(server *entity.NutServer, names []string) (string, error) {
+	return r.read(server, func(server *entity.NutServer) (string, error) {
+		if variables, ok := r.inner.(repository.UPSVariableRepository); ok {
+			return variables.GetVariables(server, names)
+		}
+		return r.inner.GetJSON(server)
+	})
+}
+
+// read reads the NUT server with get from the first of its endpoints that
+// answers.
+func (r *FailoverRepository) read(server *entity.NutServer, get func(server *entity.NutServer) (string, error)) (string, error) {
+	if !server.Type.NeedsAddress() {
+		return get(server)
+	}
+
+	endpoints := r.order(server)
+	var errs []error
+	for _, endpoint := range endpoints {
+		json, err := get(atEndpoint(server, endpoint))
+		if err != nil {
+			r.failed(server, endpoint, err)
+			if len(endpoints) == 1 {
+				// a NUT server without failover fails the way it always has
+				return "", err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint.Address(), err))
+			continue
+		}
+		r.succeeded(server, endpoint)
+		return json, nil
+	}
+	return "", fmt.Errorf("%w %s: %w", ErrAllEndpointsFailed, server.Name, errors.Join(errs...))
+}
+
+// Health returns the health of each of the NUT server's endpoints, in the
+// order they are configured. Endpoints that haven't been read yet are
+// reported healthy.
+func (r *FailoverRepository) Health(server *entity.NutServer) []*entity.EndpointHealth {
+	if !server.Type.NeedsAddress() {
+		return []*entity.EndpointHealth{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	health := r.serverHealth(server.Name)
+	endpoints := server.AllEndpoints()
+	result := make([]*entity.EndpointHealth, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpointHealth := *health.endpoint(server.Name, endpoint.Address())
+		endpointHealth.Active = health.active == endpointHealth.Address
+		result = append(result, &endpointHealth)
+	}
+	return result
+}
+
+// order returns the NUT server's endpoints in the order they should be
+// tried. With EndpointStrategyFirstHealthy, endpoints that failed less than
+// EndpointRecheckInterval ago are moved to the end, so they are only tried
+// if every other endpoint fails too.
+func (r *FailoverRepository) order(server *entity.NutServer) []entity.NutEndpoint {
+	endpoints := server.AllEndpoints()
+	if server.EndpointStrategy.Kind() != entity.EndpointStrategyFirstHealthy {
+		return endpoints
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	health := r.serverHealth(server.Name)
+	now := r.now()
+	ordered := make([]entity.NutEndpoint, 0, len(endpoints))
+	var failing []entity.NutEndpoint
+	for _, endpoint := range endpoints {
+		endpointHealth := health.endpoint(server.Name, endpoint.Address())
+		if !endpointHealth.Healthy && now.Sub(endpointHealth.LastFailure) < entity.EndpointRecheckInterval {
+			failing = append(failing, endpoint)
+			continue
+		}
+		ordered = append(ordered, endpoint)
+	}
+	return append(ordered, failing...)
+}
+
+func (r *FailoverRepository) succeeded(server *entity.NutServer, endpoint entity.NutEndpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	health := r.serverHealth(server.Name)
+	address := endpoint.Address()
+	endpointHealth := health.endpoint(server.Name, address)
+	endpointHealth.LastSuccess = r.now()
+	endpointHealth.ConsecutiveFailures = 0
+	endpointHealth.Healthy = true
+
+	previous := health.active
+	health.active = address
+	if previous == address || (previous == "" && address == server.AllEndpoints()[0].Address()) {
+		return
+	}
+	r.logger.Info("Reading NUT server from another endpoint",
+		slog.String("nut_server", server.Name),
+		slog.String("endpoint", address),
+		slog.String("previous_endpoint", previous))
+}
+
+func (r *FailoverRepository) failed(server *entity.NutServer, endpoint entity.NutEndpoint, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	address := endpoint.Address()
+	endpointHealth := r.serverHealth(server.Name).endpoint(server.Name, address)
+	endpointHealth.LastFailure = r.now()
+	endpointHealth.LastError = err.Error()
+	endpointHealth.ConsecutiveFailures++
+	endpointHealth.Healthy = false
+
+	if len(server.Endpoints) > 0 {
+		r.logger.Warn("NUT server endpoint failed",
+			slog.String("nut_server", server.Name),
+			slog.String("endpoint", address),
+			slog.Int("consecutive_failures", endpointHealth.ConsecutiveFailures),
+			slog.Any("error", err))
+	}
+}
+
+// serverHealth returns the health of the NUT server's endpoints. r.mu must
+// be held.
+func (r *FailoverRepository) serverHealth(name string) *serverHealth {
+	health, ok := r.servers[name]
+	if !ok {
+		health = &serverHealth{endpoints: make(map[string]*entity.EndpointHealth)}
+		r.servers[name] = health
+	}
+	return health
+}
+
+func (h *serverHealth) endpoint(nutServer, address string) *entity.EndpointHealth {
+	endpointHealth, ok := h.endpoints[address]
+	if !ok {
+		endpointHealth = &entity.EndpointHealth{NutServer: nutServer, Address: address, Healthy: true}
+		h.endpoints[address] = endpointHealth
+	}
+	return endpointHealth
+}
+
+// atEndpoint returns a copy of server reading from endpoint.
+func atEndpoint(server *entity.NutServer, endpoint entity.NutEndpoint) *entity.NutServer {
+	copied := *server
+	copied.Host = endpoint.Host
+	copied.Port = endpoint.Port
+	copied.Endpoints = nil
+	return &copied
+}
@@ -0,0 +1,217 @@
+package failoverups
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var (
+	_ repository.UPSRepository            = new(FailoverRepository)
+	_ repository.UPSVariableRepository    = new(FailoverRepository)
+	_ repository.EndpointHealthRepository = new(FailoverRepository)
+)
+
+var errRefused = errors.New("connection refused")
+
+// endpointRepo answers with the address it was asked for, unless that
+// address is down, and records the addresses it was asked for.
+type endpointRepo struct {
+	down  map[string]bool
+	calls []string
+	mu    sync.Mutex
+}
+
+func (r *endpointRepo) GetJSON(server *entity.NutServer) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	address := server.Address()
+	r.calls = append(r.calls, address)
+	if len(server.Endpoints) != 0 {
+		return "", errors.New("endpoints passed to the inner repository")
+	}
+	if r.down[address] {
+		return "", errRefused
+	}
+	return address, nil
+}
+
+// variableRepo is an endpointRepo that can read single variables.
+type variableRepo struct {
+	endpointRepo
+}
+
+func (r *variableRepo) GetVariables(server *entity.NutServer, names []string) (string, error) {
+	json, err := r.GetJSON(server)
+	if err != nil {
+		return "", err
+	}
+	return json + " " + strings.Join(names, ","), nil
+}
+
+func (r *endpointRepo) takeCalls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+func redundantServer(strategy entity.EndpointStrategy) *entity.NutServer {
+	return &entity.NutServer{
+		Name:             "redundant",
+		Host:             "10.0.0.1",
+		Port:             3493,
+		Endpoints:        []entity.NutEndpoint{{Host: "10.0.0.2"}, {Host: "10.0.0.3", Port: 3494}},
+		EndpointStrategy: strategy,
+	}
+}
+
+func newTestRepository(inner repository.UPSRepository) (*FailoverRepository, *bytes.Buffer, *time.Time) {
+	logBuf := new(bytes.Buffer)
+	r := NewFailoverRepository(inner, slog.New(slog.NewJSONHandler(logBuf, nil)))
+	now := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, logBuf, &now
+}
+
+func TestFailoverRepository_GetJSON_failover(t *testing.T) {
+	inner := &endpointRepo{down: map[string]bool{}}
+	r, logBuf, _ := newTestRepository(inner)
+	server := redundantServer("")
+
+	got, err := r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:3493", got)
+	assert.Equal(t, []string{"10.0.0.1:3493"}, inner.takeCalls())
+	assert.Empty(t, logBuf.String(), "reading the first endpoint isn't a change")
+
+	inner.down["10.0.0.1:3493"] = true
+	got, err = r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:3493", got, "the second endpoint uses the NUT server's port")
+	assert.Equal(t, []string{"10.0.0.1:3493", "10.0.0.2:3493"}, inner.takeCalls())
+	assert.Contains(t, logBuf.String(), `"msg":"NUT server endpoint failed"`)
+	assert.Contains(t, logBuf.String(), `"msg":"Reading NUT server from another endpoint","nut_server":"redundant","endpoint":"10.0.0.2:3493","previous_endpoint":"10.0.0.1:3493"`)
+
+	_, err = r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:3493", "10.0.0.2:3493"}, inner.takeCalls(), "failover tries the first endpoint every time")
+
+	inner.down["10.0.0.1:3493"] = false
+	got, err = r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:3493", got, "failover returns to the first endpoint once it recovers")
+}
+
+func TestFailoverRepository_GetJSON_firstHealthy(t *testing.T) {
+	inner := &endpointRepo{down: map[string]bool{"10.0.0.1:3493": true}}
+	r, _, now := newTestRepository(inner)
+	server := redundantServer(entity.EndpointStrategyFirstHealthy)
+
+	got, err := r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:3493", got)
+	assert.Equal(t, []string{"10.0.0.1:3493", "10.0.0.2:3493"}, inner.takeCalls())
+
+	_, err = r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:3493"}, inner.takeCalls(), "the failed endpoint is passed over")
+
+	inner.down["10.0.0.2:3493"] = true
+	got, err = r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.3:3494", got)
+	assert.Equal(t, []string{"10.0.0.2:3493", "10.0.0.3:3494"}, inner.takeCalls())
+
+	inner.down["10.0.0.1:3493"] = false
+	*now = now.Add(entity.EndpointRecheckInterval)
+	got, err = r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:3493", got, "failed endpoints are tried again after the recheck interval")
+}
+
+func TestFailoverRepository_GetJSON_allFailed(t *testing.T) {
+	inner := &endpointRepo{down: map[string]bool{"10.0.0.1:3493": true, "10.0.0.2:3493": true, "10.0.0.3:3494": true}}
+	r, _, _ := newTestRepository(inner)
+
+	_, err := r.GetJSON(redundantServer(""))
+	require.ErrorIs(t, err, ErrAllEndpointsFailed)
+	assert.ErrorIs(t, err, errRefused)
+	assert.ErrorContains(t, err, "10.0.0.3:3494: connection refused")
+}
+
+func TestFailoverRepository_GetJSON_singleEndpoint(t *testing.T) {
+	inner := &endpointRepo{down: map[string]bool{"10.0.0.1:3493": true}}
+	r, logBuf, _ := newTestRepository(inner)
+
+	_, err := r.GetJSON(&entity.NutServer{Name: "single", Host: "10.0.0.1", Port: 3493})
+	assert.Equal(t, errRefused, err, "a NUT server without endpoints returns the error unwrapped")
+	assert.Empty(t, logBuf.String())
+}
+
+func TestFailoverRepository_GetJSON_passthrough(t *testing.T) {
+	inner := &endpointRepo{down: map[string]bool{}}
+	r, _, _ := newTestRepository(inner)
+	server := &entity.NutServer{Name: "script", Type: entity.SourceTypeCommand, Plugin: entity.NutPlugin{Command: []string{"ups-status"}}}
+
+	got, err := r.GetJSON(server)
+	require.NoError(t, err)
+	assert.Equal(t, "ups-status", got)
+	assert.Empty(t, r.Health(server))
+}
+
+func TestFailoverRepository_GetVariables(t *testing.T) {
+	t.Run("fails over", func(t *testing.T) {
+		inner := &variableRepo{endpointRepo{down: map[string]bool{"10.0.0.1:3493": true}}}
+		r, _, _ := newTestRepository(inner)
+
+		got, err := r.GetVariables(redundantServer(""), []string{"ups.status", "battery.charge"})
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.2:3493 ups.status,battery.charge", got)
+		assert.Equal(t, []string{"10.0.0.1:3493", "10.0.0.2:3493"}, inner.takeCalls())
+		assert.False(t, r.Health(redundantServer(""))[0].Healthy)
+	})
+
+	t.Run("reads every variable if inner can't read single variables", func(t *testing.T) {
+		inner := &endpointRepo{down: map[string]bool{}}
+		r, _, _ := newTestRepository(inner)
+
+		got, err := r.GetVariables(redundantServer(""), []string{"ups.status"})
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.1:3493", got)
+	})
+}
+
+func TestFailoverRepository_Health(t *testing.T) {
+	inner := &endpointRepo{down: map[string]bool{"10.0.0.1:3493": true}}
+	r, _, now := newTestRepository(inner)
+	server := redundantServer("")
+
+	assert.Equal(t, []*entity.EndpointHealth{
+		{NutServer: "redundant", Address: "10.0.0.1:3493", Healthy: true},
+		{NutServer: "redundant", Address: "10.0.0.2:3493", Healthy: true},
+		{NutServer: "redundant", Address: "10.0.0.3:3494", Healthy: true},
+	}, r.Health(server), "endpoints are healthy until they fail")
+
+	_, err := r.GetJSON(server)
+	require.NoError(t, err)
+	_, err = r.GetJSON(server)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*entity.EndpointHealth{
+		{NutServer: "redundant", Address: "10.0.0.1:3493", LastFailure: *now, LastError: "connection refused", ConsecutiveFailures: 2},
+		{NutServer: "redundant", Address: "10.0.0.2:3493", LastSuccess: *now, Healthy: true, Active: true},
+		{NutServer: "redundant", Address: "10.0.0.3:3494", Healthy: true},
+	}, r.Health(server))
+}
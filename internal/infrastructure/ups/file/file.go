@@ -0,0 +1,92 @@
+package fileups
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	nut "github.com/robbiet480/go.nut"
+)
+
+var (
+	ErrInvalidDocument = errors.New("invalid UPS JSON document")
+	ErrServerNotFound  = errors.New("no UPS data for NUT server")
+)
+
+// FileRepository serves UPS data from a JSON document instead of a NUT
+// server, so rules can be evaluated offline.
+// Satisfies repository.UPSRepository.
+type FileRepository struct {
+	servers map[string]string
+	all     string
+}
+
+// NewFileRepository reads a UPS JSON document from r.
+//
+// The document is either a list of UPSes, as printed by 'upswake json',
+// which is returned for every NUT server, or an object mapping NUT server
+// names to lists of UPSes.
+func NewFileRepository(r io.Reader) (*FileRepository, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+	raw = bytes.TrimSpace(raw)
+
+	if len(raw) > 0 && raw[0] == '[' {
+		all, err := decodeUPSList(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &FileRepository{all: all}, nil
+	}
+
+	var servers map[string]json.RawMessage
+	if err = json.Unmarshal(raw, &servers); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+
+	repo := &FileRepository{servers: make(map[string]string, len(servers))}
+	for _, name := range slices.Sorted(maps.Keys(servers)) {
+		upsList, err := decodeUPSList(servers[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		repo.servers[name] = upsList
+	}
+	return repo, nil
+}
+
+// decodeUPSList checks raw is a list of UPSes in the shape produced by
+// DirectRepository.GetJSON and returns it compacted.
+func decodeUPSList(raw []byte) (string, error) {
+	var upses []nut.UPS
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&upses); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+
+	compacted := new(bytes.Buffer)
+	if err := json.Compact(compacted, raw); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+	return compacted.String(), nil
+}
+
+func (r *FileRepository) GetJSON(server *entity.NutServer) (string, error) {
+	if r.servers == nil {
+		return r.all, nil
+	}
+
+	upsList, ok := r.servers[server.Name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrServerNotFound, server.Name)
+	}
+	return upsList, nil
+}
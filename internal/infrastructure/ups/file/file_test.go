@@ -0,0 +1,86 @@
+package fileups
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var _ repository.UPSRepository = new(FileRepository)
+
+const upsList = `[{"Name":"cyberpower900","Description":"Unavailable","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[{"Name":"battery.charge","Value":100,"Type":"INTEGER","Description":"Battery charge (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"}]}]`
+
+func TestFileRepository_GetJSON(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		document string
+		server   string
+		want     string
+	}{
+		{
+			name:     "list is returned for every server",
+			document: upsList,
+			server:   "any",
+			want:     upsList,
+		},
+		{
+			name:     "list is compacted",
+			document: "[\n  {\"Name\": \"cyberpower900\"}\n]\n",
+			server:   "any",
+			want:     `[{"Name":"cyberpower900"}]`,
+		},
+		{
+			name:     "servers keyed by name",
+			document: `{"first": [], "second": ` + upsList + `}`,
+			server:   "second",
+			want:     upsList,
+		},
+		{
+			name:     "server missing from document",
+			document: `{"first": []}`,
+			server:   "second",
+			wantErr:  ErrServerNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := NewFileRepository(strings.NewReader(tt.document))
+			require.NoError(t, err)
+
+			got, err := repo.GetJSON(&entity.NutServer{Name: tt.server})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewFileRepository_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{name: "empty document", document: ""},
+		{name: "not json", document: "battery.charge: 100"},
+		{name: "list of the wrong shape", document: `[1, 2, 3]`},
+		{name: "unknown UPS field", document: `[{"Name":"cyberpower900","Charge":100}]`},
+		{name: "server with wrong shape", document: `{"first": {"Name":"cyberpower900"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFileRepository(strings.NewReader(tt.document))
+			assert.ErrorIs(t, err, ErrInvalidDocument)
+		})
+	}
+}
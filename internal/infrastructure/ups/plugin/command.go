@@ -0,0 +1,71 @@
+package pluginups
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+)
+
+var ErrCommandFailed = errors.New("UPS command failed")
+
+// CommandRepository runs a NUT server's command on every call and returns
+// the UPSes it prints to stdout, so any script can feed the rule engine.
+// The command is killed if it runs for longer than the NUT server's
+// timeout. Satisfies repository.UPSRepository.
+type CommandRepository struct{}
+
+// NewCommandRepository constructs a CommandRepository.
+func NewCommandRepository() *CommandRepository {
+	return &CommandRepository{}
+}
+
+func (r *CommandRepository) GetJSON(server *entity.NutServer) (string, error) {
+	if len(server.Plugin.Command) == 0 {
+		return "", entity.ErrCommandRequired
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), server.Plugin.RunTimeout())
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: MaxDocumentSize + 1}
+	stderr := &limitedBuffer{limit: 4096}
+	cmd := exec.CommandContext(ctx, server.Plugin.Command[0], server.Plugin.Command[1:]...) //nolint:gosec // the command comes from the config
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", server.Plugin.RunTimeout())
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s: %w: %s", ErrCommandFailed, server.Name, err, message)
+		}
+		return "", fmt.Errorf("%w: %s: %w", ErrCommandFailed, server.Name, err)
+	}
+
+	upsList, err := decodeUPSList(stdout.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", server.Name, err)
+	}
+	return upsList, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a runaway command can't exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,98 @@
+package pluginups
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var _ repository.UPSRepository = new(CommandRepository)
+
+func commandServer(script string, timeout time.Duration) *entity.NutServer {
+	return &entity.NutServer{
+		Name: "script",
+		Type: entity.SourceTypeCommand,
+		Plugin: entity.NutPlugin{
+			Command: []string{"sh", "-c", script},
+			Timeout: timeout,
+		},
+	}
+}
+
+func TestCommandRepository_GetJSON(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		server  *entity.NutServer
+		name    string
+		want    string
+		errMsg  string
+	}{
+		{
+			name:   "prints the UPSes",
+			server: commandServer("echo '"+upsList+"'", 0),
+			want:   upsList,
+		},
+		{
+			name: "arguments are passed without a shell",
+			server: &entity.NutServer{Name: "script", Type: entity.SourceTypeCommand, Plugin: entity.NutPlugin{
+				Command: []string{"printf", "%s", `[{"Name":"$HOME"}]`},
+			}},
+			want: `[{"Name":"$HOME","Description":"","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[],"Commands":[]}]`,
+		},
+		{
+			name:    "exits with an error",
+			server:  commandServer("echo 'no UPS attached' >&2; exit 3", 0),
+			wantErr: ErrCommandFailed,
+			errMsg:  "exit status 3: no UPS attached",
+		},
+		{
+			name:    "times out",
+			server:  commandServer("exec sleep 5", 50*time.Millisecond),
+			wantErr: ErrCommandFailed,
+			errMsg:  "timed out after 50ms",
+		},
+		{
+			name:    "not found",
+			server:  &entity.NutServer{Name: "script", Type: entity.SourceTypeCommand, Plugin: entity.NutPlugin{Command: []string{"/nonexistent/ups-status"}}},
+			wantErr: ErrCommandFailed,
+		},
+		{
+			name:    "prints something other than UPSes",
+			server:  commandServer("echo 'battery.charge: 100'", 0),
+			wantErr: ErrInvalidDocument,
+		},
+		{
+			name:    "no command",
+			server:  &entity.NutServer{Name: "script", Type: entity.SourceTypeCommand},
+			wantErr: entity.ErrCommandRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCommandRepository().GetJSON(tt.server)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, got)
+		})
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	buf := &limitedBuffer{limit: 4}
+	n, err := buf.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = buf.Write([]byte("def"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "abcd", buf.String())
+}
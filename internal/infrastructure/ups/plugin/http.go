@@ -0,0 +1,70 @@
+package pluginups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+)
+
+var (
+	ErrRequestFailed    = errors.New("UPS request failed")
+	ErrUnexpectedStatus = errors.New("UPS request returned an unexpected status")
+)
+
+// HTTPRepository GETs a NUT server's URL on every call and returns the
+// UPSes in the response body. If the NUT server has a username, the request
+// is sent with basic auth. Satisfies repository.UPSRepository.
+type HTTPRepository struct {
+	client *http.Client
+}
+
+// NewHTTPRepository constructs an HTTPRepository sending requests with
+// client, or http.DefaultClient if client is nil. Requests are bounded by
+// each NUT server's timeout rather than the client's.
+func NewHTTPRepository(client *http.Client) *HTTPRepository {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPRepository{client: client}
+}
+
+func (r *HTTPRepository) GetJSON(server *entity.NutServer) (string, error) {
+	if server.Plugin.URL == "" {
+		return "", entity.ErrURLRequired
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), server.Plugin.RunTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.Plugin.URL, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrRequestFailed, server.Name, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if server.Username != "" {
+		req.SetBasicAuth(server.Username, server.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrRequestFailed, server.Name, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w from %s: %s", ErrUnexpectedStatus, server.Name, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxDocumentSize+1))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrRequestFailed, server.Name, err)
+	}
+	upsList, err := decodeUPSList(body)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", server.Name, err)
+	}
+	return upsList, nil
+}
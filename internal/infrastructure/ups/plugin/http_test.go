@@ -0,0 +1,117 @@
+package pluginups
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var _ repository.UPSRepository = new(HTTPRepository)
+
+func TestHTTPRepository_GetJSON(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ups.json", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		_, _ = w.Write([]byte(upsList))
+	})
+	mux.HandleFunc("GET /private.json", func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "upsmon" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(upsList))
+	})
+	mux.HandleFunc("GET /slow.json", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("GET /invalid.json", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"battery.charge":100}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		wantErr  error
+		name     string
+		path     string
+		username string
+		password string
+		want     string
+		timeout  time.Duration
+	}{
+		{
+			name: "returns the UPSes",
+			path: "/ups.json",
+			want: upsList,
+		},
+		{
+			name:     "basic auth",
+			path:     "/private.json",
+			username: "upsmon",
+			password: "secret",
+			want:     upsList,
+		},
+		{
+			name:     "wrong credentials",
+			path:     "/private.json",
+			username: "upsmon",
+			password: "wrong",
+			wantErr:  ErrUnexpectedStatus,
+		},
+		{
+			name:    "not found",
+			path:    "/missing.json",
+			wantErr: ErrUnexpectedStatus,
+		},
+		{
+			name:    "times out",
+			path:    "/slow.json",
+			timeout: 50 * time.Millisecond,
+			wantErr: ErrRequestFailed,
+		},
+		{
+			name:    "invalid document",
+			path:    "/invalid.json",
+			wantErr: ErrInvalidDocument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewHTTPRepository(server.Client()).GetJSON(&entity.NutServer{
+				Name:     "http",
+				Type:     entity.SourceTypeHTTP,
+				Username: tt.username,
+				Password: tt.password,
+				Plugin:   entity.NutPlugin{URL: server.URL + tt.path, Timeout: tt.timeout},
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, got)
+		})
+	}
+
+	t.Run("connection refused", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		_, err := NewHTTPRepository(nil).GetJSON(&entity.NutServer{
+			Name:   "http",
+			Type:   entity.SourceTypeHTTP,
+			Plugin: entity.NutPlugin{URL: closed.URL + "/ups.json"},
+		})
+		assert.ErrorIs(t, err, ErrRequestFailed)
+	})
+}
@@ -0,0 +1,110 @@
+package pluginups
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	nut "github.com/robbiet480/go.nut"
+)
+
+// MaxDocumentSize is the largest UPS JSON document a command or HTTP source
+// may return.
+const MaxDocumentSize = 1 << 20
+
+var (
+	ErrInvalidDocument  = errors.New("invalid UPS JSON document")
+	ErrDocumentTooLarge = fmt.Errorf("UPS JSON document is larger than %d bytes", MaxDocumentSize)
+)
+
+// decodeUPSList checks raw is a list of UPSes in the shape printed by
+// 'upswake json', and returns it with the fields a NUT server always sets
+// filled in. Unknown fields are rejected, so a typo such as "Varaibles"
+// doesn't silently leave rules without their input. Every UPS and variable
+// must be named, and variable values must be strings, numbers or booleans.
+// Variables without a Type are typed the way NUT types them.
+func decodeUPSList(raw []byte) (string, error) {
+	if len(raw) > MaxDocumentSize {
+		return "", ErrDocumentTooLarge
+	}
+
+	var upses []nut.UPS
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.DisallowUnknownFields()
+	d.UseNumber()
+	if err := d.Decode(&upses); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+	if d.More() {
+		return "", fmt.Errorf("%w: unexpected data after the list of UPSes", ErrInvalidDocument)
+	}
+	if upses == nil {
+		return "", fmt.Errorf("%w: expected a list of UPSes", ErrInvalidDocument)
+	}
+
+	for i := range upses {
+		if err := normaliseUPS(&upses[i]); err != nil {
+			return "", fmt.Errorf("%w: UPS %d: %w", ErrInvalidDocument, i, err)
+		}
+	}
+
+	jsonData, err := json.Marshal(upses)
+	return string(jsonData), err
+}
+
+func normaliseUPS(ups *nut.UPS) error {
+	if ups.Name == "" {
+		return errors.New("name is required")
+	}
+	if ups.Clients == nil {
+		ups.Clients = []string{}
+	}
+	if ups.Variables == nil {
+		ups.Variables = []nut.Variable{}
+	}
+	if ups.Commands == nil {
+		ups.Commands = []nut.Command{}
+	}
+	for i, variable := range ups.Variables {
+		if variable.Name == "" {
+			return fmt.Errorf("variable %d: name is required", i)
+		}
+		typed, err := typeVariable(variable)
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", variable.Name, err)
+		}
+		ups.Variables[i] = typed
+	}
+	return nil
+}
+
+// typeVariable converts the variable's value from its JSON form, and types
+// it the way NUT does if the document doesn't give it a Type.
+func typeVariable(variable nut.Variable) (nut.Variable, error) {
+	var typed nut.Variable
+	switch value := variable.Value.(type) {
+	case string:
+		typed = nutclient.StringVariable(variable.Name, variable.Description, value)
+	case bool:
+		typed = nut.Variable{Value: value, Type: "BOOLEAN", OriginalType: "BOOLEAN"}
+	case json.Number:
+		number, err := value.Float64()
+		if err != nil {
+			return variable, err
+		}
+		typed = nutclient.NumberVariable(variable.Name, variable.Description, number)
+	default:
+		return variable, fmt.Errorf("value must be a string, number or boolean, not %T", variable.Value)
+	}
+
+	if variable.Type == "" {
+		variable.Type = typed.Type
+	}
+	if variable.OriginalType == "" {
+		variable.OriginalType = typed.OriginalType
+	}
+	variable.Value = typed.Value
+	return variable, nil
+}
@@ -0,0 +1,118 @@
+package pluginups
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const upsList = `[{"Name":"cyberpower900","Description":"Unavailable","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[{"Name":"battery.charge","Value":100,"Type":"INTEGER","Description":"Battery charge (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"}],"Commands":[]}]`
+
+func TestDecodeUPSList(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		document string
+		want     string
+		errMsg   string
+	}{
+		{
+			name:     "full document is unchanged",
+			document: upsList,
+			want:     upsList,
+		},
+		{
+			name:     "empty list",
+			document: ` [] `,
+			want:     `[]`,
+		},
+		{
+			name: "minimal document is filled in",
+			document: `[{"Name":"ups","Variables":[
+				{"Name":"ups.status","Value":"OL"},
+				{"Name":"battery.charge","Value":87},
+				{"Name":"battery.voltage","Value":13.5},
+				{"Name":"ups.beeper.enabled","Value":true}
+			]}]`,
+			want: `[{"Name":"ups","Description":"","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[` +
+				`{"Name":"ups.status","Value":"OL","Type":"STRING","Description":"","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},` +
+				`{"Name":"battery.charge","Value":87,"Type":"INTEGER","Description":"","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},` +
+				`{"Name":"battery.voltage","Value":13.5,"Type":"FLOAT_64","Description":"","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},` +
+				`{"Name":"ups.beeper.enabled","Value":true,"Type":"BOOLEAN","Description":"","Writeable":false,"MaximumLength":0,"OriginalType":"BOOLEAN"}` +
+				`],"Commands":[]}]`,
+		},
+		{
+			name:     "given types are kept",
+			document: `[{"Name":"ups","Variables":[{"Name":"ups.delay.shutdown","Value":20,"Type":"STRING","OriginalType":"STRING:10"}]}]`,
+			want:     `[{"Name":"ups","Description":"","Master":false,"NumberOfLogins":0,"Clients":[],"Variables":[{"Name":"ups.delay.shutdown","Value":20,"Type":"STRING","Description":"","Writeable":false,"MaximumLength":0,"OriginalType":"STRING:10"}],"Commands":[]}]`,
+		},
+		{
+			name:     "not JSON",
+			document: `battery.charge: 100`,
+			wantErr:  ErrInvalidDocument,
+		},
+		{
+			name:     "object instead of a list",
+			document: `{"Name":"ups"}`,
+			wantErr:  ErrInvalidDocument,
+		},
+		{
+			name:     "null",
+			document: `null`,
+			wantErr:  ErrInvalidDocument,
+			errMsg:   "expected a list of UPSes",
+		},
+		{
+			name:     "unknown field",
+			document: `[{"Name":"ups","Varaibles":[]}]`,
+			wantErr:  ErrInvalidDocument,
+			errMsg:   `unknown field "Varaibles"`,
+		},
+		{
+			name:     "trailing data",
+			document: `[] []`,
+			wantErr:  ErrInvalidDocument,
+		},
+		{
+			name:     "unnamed UPS",
+			document: `[{"Variables":[]}]`,
+			wantErr:  ErrInvalidDocument,
+			errMsg:   "UPS 0: name is required",
+		},
+		{
+			name:     "unnamed variable",
+			document: `[{"Name":"ups","Variables":[{"Value":1}]}]`,
+			wantErr:  ErrInvalidDocument,
+			errMsg:   "variable 0: name is required",
+		},
+		{
+			name:     "object value",
+			document: `[{"Name":"ups","Variables":[{"Name":"battery.charge","Value":{"percent":100}}]}]`,
+			wantErr:  ErrInvalidDocument,
+			errMsg:   "variable battery.charge: value must be a string, number or boolean",
+		},
+		{
+			name:     "null value",
+			document: `[{"Name":"ups","Variables":[{"Name":"battery.charge","Value":null}]}]`,
+			wantErr:  ErrInvalidDocument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeUPSList([]byte(tt.document))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, got)
+		})
+	}
+
+	t.Run("too large", func(t *testing.T) {
+		_, err := decodeUPSList(make([]byte, MaxDocumentSize+1))
+		assert.ErrorIs(t, err, ErrDocumentTooLarge)
+	})
+}
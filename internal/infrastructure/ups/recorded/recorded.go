@@ -0,0 +1,91 @@
+package recordedups
+
+import (
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+)
+
+// RecordedRepository wraps another UPSRepository and records the chosen
+// variables of every UPS it reads in a HistoryRepository. Wrap it in a
+// CachedRepository, rather than the other way round, so a sample is only
+// recorded when the NUT server is actually read.
+type RecordedRepository struct {
+	inner     repository.UPSRepository
+	history   repository.HistoryRepository
+	logger    *slog.Logger
+	now       func() time.Time
+	variables []string
+}
+
+// nutUPS holds the parts of a UPS from the NUT server that are recorded.
+type nutUPS struct {
+	Name      string
+	Variables []struct {
+		Value any
+		Name  string
+	}
+}
+
+// NewRecordedRepository creates a RecordedRepository recording variables
+// from inner in history. Failures to record are logged to logger rather
+// than failing the read.
+func NewRecordedRepository(inner repository.UPSRepository, history repository.HistoryRepository, variables []string, logger *slog.Logger) *RecordedRepository {
+	return &RecordedRepository{
+		inner:     inner,
+		history:   history,
+		logger:    logger,
+		now:       time.Now,
+		variables: variables,
+	}
+}
+
+func (r *RecordedRepository) GetJSON(server *entity.NutServer) (string, error) {
+	upsJSON, err := r.inner.GetJSON(server)
+	if err != nil {
+		return upsJSON, err
+	}
+
+	if err := r.record(server.Name, upsJSON); err != nil {
+		r.logger.Warn("Failed to record UPS history",
+			slog.String("nut_server", server.Name),
+			slog.Any("error", err))
+	}
+	return upsJSON, nil
+}
+
+func (r *RecordedRepository) record(nutServer, upsJSON string) error {
+	var upses []nutUPS
+	// numbers are kept as json.Number so they are recorded as they were read
+	d := json.NewDecoder(strings.NewReader(upsJSON))
+	d.UseNumber()
+	if err := d.Decode(&upses); err != nil {
+		return err
+	}
+
+	now := r.now()
+	var samples []*entity.HistorySample
+	for _, ups := range upses {
+		for _, variable := range ups.Variables {
+			if !slices.Contains(r.variables, variable.Name) {
+				continue
+			}
+			samples = append(samples, &entity.HistorySample{
+				Time:      now,
+				NutServer: nutServer,
+				UPS:       ups.Name,
+				Variable:  variable.Name,
+				Value:     variable.Value,
+			})
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	return r.history.Record(samples...)
+}
@@ -0,0 +1,97 @@
+package recordedups
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// compile time interface checks
+var _ repository.UPSRepository = new(RecordedRepository)
+
+const upsJSON = `[{"Name":"cyberpower900","Variables":[` +
+	`{"Name":"battery.charge","Value":85},` +
+	`{"Name":"battery.voltage","Value":13.5},` +
+	`{"Name":"ups.status","Value":"OB DISCHRG"}]}]`
+
+func TestRecordedRepository_GetJSON(t *testing.T) {
+	now := time.Date(2026, time.January, 5, 17, 30, 0, 0, time.UTC)
+	server := &entity.NutServer{Name: "raspberrypi", Host: "127.0.0.1", Port: 3493}
+	errInner := errors.New("connection refused")
+
+	tests := []struct {
+		innerErr    error
+		recordErr   error
+		wantErr     error
+		name        string
+		json        string
+		wantLog     string
+		wantSamples []*entity.HistorySample
+	}{
+		{
+			name: "records chosen variables",
+			json: upsJSON,
+			wantSamples: []*entity.HistorySample{
+				{Time: now, NutServer: "raspberrypi", UPS: "cyberpower900", Variable: "battery.charge", Value: json.Number("85")},
+				{Time: now, NutServer: "raspberrypi", UPS: "cyberpower900", Variable: "ups.status", Value: "OB DISCHRG"},
+			},
+		},
+		{
+			name: "nothing to record",
+			json: `[{"Name":"cyberpower900","Variables":[{"Name":"battery.voltage","Value":13.5}]}]`,
+		},
+		{
+			name:     "read fails",
+			innerErr: errInner,
+			wantErr:  errInner,
+		},
+		{
+			name:    "invalid JSON is returned but not recorded",
+			json:    `{"not":"a list"}`,
+			wantLog: "Failed to record UPS history",
+		},
+		{
+			name:      "recording fails",
+			json:      upsJSON,
+			recordErr: errors.New("disk full"),
+			wantSamples: []*entity.HistorySample{
+				{Time: now, NutServer: "raspberrypi", UPS: "cyberpower900", Variable: "battery.charge", Value: json.Number("85")},
+				{Time: now, NutServer: "raspberrypi", UPS: "cyberpower900", Variable: "ups.status", Value: "OB DISCHRG"},
+			},
+			wantLog: "disk full",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			inner := mocks.NewMockUPSRepository(ctrl)
+			inner.EXPECT().GetJSON(server).Return(tt.json, tt.innerErr)
+			history := mocks.NewMockHistoryRepository(ctrl)
+			if tt.wantSamples != nil {
+				history.EXPECT().Record(tt.wantSamples).Return(tt.recordErr)
+			}
+			logBuf := new(bytes.Buffer)
+
+			r := NewRecordedRepository(inner, history, []string{"battery.charge", "ups.status"}, slog.New(slog.NewJSONHandler(logBuf, nil)))
+			r.now = func() time.Time { return now }
+			got, err := r.GetJSON(server)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.json, got, "the JSON is returned whether or not it is recorded")
+			if tt.wantLog != "" {
+				assert.Contains(t, logBuf.String(), tt.wantLog)
+			} else {
+				assert.Empty(t, logBuf.String())
+			}
+		})
+	}
+}
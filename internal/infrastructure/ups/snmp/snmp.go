@@ -0,0 +1,265 @@
+package snmpups
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/infrastructure/nutclient"
+	"github.com/gosnmp/gosnmp"
+	nut "github.com/robbiet480/go.nut"
+)
+
+// DefaultTimeout is how long each SNMP request may take before it is retried.
+const DefaultTimeout = 5 * time.Second
+
+var (
+	ErrConnectionFailed = errors.New("could not connect to SNMP agent")
+	ErrReadingUPSMIB    = errors.New("failed to read UPS-MIB from SNMP agent")
+	ErrNoUPSMIB         = errors.New("SNMP agent doesn't implement the UPS-MIB")
+)
+
+// upsMIB is the upsObjects subtree of the UPS-MIB, RFC 1628.
+const upsMIB = ".1.3.6.1.2.1.33.1"
+
+const (
+	oidIdentName     = upsMIB + ".1.5.0"
+	oidBatteryStatus = upsMIB + ".2.1.0"
+	oidOutputSource  = upsMIB + ".4.1.0"
+)
+
+// object is a UPS-MIB object and the NUT variable it is mapped to. Numbers
+// are multiplied by multiplier and divided by divisor to convert them to
+// the NUT variable's unit, if they are set.
+type object struct {
+	oid         string
+	name        string
+	description string
+	multiplier  float64
+	divisor     float64
+	text        bool
+}
+
+// objects are the UPS-MIB objects read for each UPS. The input and output
+// tables are only read for their first line.
+var objects = []object{
+	{oid: upsMIB + ".1.1.0", name: "ups.mfr", description: "UPS manufacturer", text: true},
+	{oid: upsMIB + ".1.2.0", name: "ups.model", description: "UPS model", text: true},
+	{oid: upsMIB + ".1.3.0", name: "ups.firmware", description: "UPS firmware", text: true},
+	{oid: upsMIB + ".2.3.0", name: "battery.runtime", description: "Battery runtime (seconds)", multiplier: 60},
+	{oid: upsMIB + ".2.4.0", name: "battery.charge", description: "Battery charge (percent of full)"},
+	{oid: upsMIB + ".2.5.0", name: "battery.voltage", description: "Battery voltage (V)", divisor: 10},
+	{oid: upsMIB + ".2.7.0", name: "battery.temperature", description: "Battery temperature (degrees C)"},
+	{oid: upsMIB + ".3.3.1.2.1", name: "input.frequency", description: "Input line frequency (Hz)", divisor: 10},
+	{oid: upsMIB + ".3.3.1.3.1", name: "input.voltage", description: "Input voltage (V)"},
+	{oid: upsMIB + ".4.2.0", name: "output.frequency", description: "Output frequency (Hz)", divisor: 10},
+	{oid: upsMIB + ".4.4.1.2.1", name: "output.voltage", description: "Output voltage (V)"},
+	{oid: upsMIB + ".4.4.1.5.1", name: "ups.load", description: "Load on UPS (percent of full)"},
+}
+
+// outputSources maps upsOutputSource to NUT's ups.status flags.
+var outputSources = map[int64]string{
+	2: "OFF",       // none
+	3: "OL",        // normal
+	4: "OL BYPASS", // bypass
+	5: "OB",        // battery
+	6: "OL BOOST",  // booster
+	7: "OL TRIM",   // reducer
+}
+
+// batteryStatuses maps upsBatteryStatus to NUT's ups.status flags.
+var batteryStatuses = map[int64]string{
+	3: "LB", // batteryLow
+	4: "LB", // batteryDepleted
+}
+
+// SNMPRepository reads a UPS from its SNMP management card with the
+// UPS-MIB on every call, and returns it in the same shape as a NUT server's
+// UPS list, with the UPS-MIB's objects mapped to NUT variable names.
+// Satisfies repository.UPSRepository.
+type SNMPRepository struct {
+	timeout time.Duration
+}
+
+// NewSNMPRepository constructs an SNMPRepository whose requests time out
+// after timeout. If timeout isn't positive, DefaultTimeout is used.
+func NewSNMPRepository(timeout time.Duration) *SNMPRepository {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &SNMPRepository{timeout: timeout}
+}
+
+func (r *SNMPRepository) GetJSON(server *entity.NutServer) (string, error) {
+	client := r.client(server)
+	if err := client.Connect(); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+	}
+	defer func() { _ = client.Conn.Close() }()
+
+	oids := []string{oidIdentName, oidBatteryStatus, oidOutputSource}
+	for _, o := range objects {
+		oids = append(oids, o.oid)
+	}
+	packet, err := client.Get(oids)
+	if err != nil {
+		return "", fmt.Errorf("%w at %s: %w", ErrReadingUPSMIB, server.Host, err)
+	}
+	if packet.Error != gosnmp.NoError {
+		return "", fmt.Errorf("%w at %s: %s", ErrReadingUPSMIB, server.Host, packet.Error)
+	}
+
+	ups, found := toUPS(server.Name, packet.Variables)
+	if !found {
+		return "", fmt.Errorf("%w at %s", ErrNoUPSMIB, server.Host)
+	}
+	jsonData, err := json.Marshal([]nut.UPS{ups})
+	return string(jsonData), err
+}
+
+// client returns the SNMP client for server. With SNMPv3, the security
+// level follows from which of the server's passwords are set.
+func (r *SNMPRepository) client(server *entity.NutServer) *gosnmp.GoSNMP {
+	client := &gosnmp.GoSNMP{
+		Target:             server.Host,
+		Port:               uint16(server.Port), //nolint:gosec // validated to be a port
+		Transport:          "udp",
+		Community:          server.SNMP.CommunityString(),
+		Version:            gosnmp.Version2c,
+		Timeout:            r.timeout,
+		Retries:            1,
+		ExponentialTimeout: false,
+		MaxOids:            gosnmp.MaxOids,
+	}
+	if server.SNMP.ProtocolVersion() != entity.SNMPVersion3 {
+		return client
+	}
+
+	params := &gosnmp.UsmSecurityParameters{
+		UserName:               server.Username,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
+	}
+	client.MsgFlags = gosnmp.NoAuthNoPriv
+	if server.Password != "" {
+		client.MsgFlags = gosnmp.AuthNoPriv
+		params.AuthenticationProtocol = authProtocols[server.SNMP.Auth()]
+		params.AuthenticationPassphrase = server.Password
+	}
+	if server.Password != "" && server.SNMP.PrivPassword != "" {
+		client.MsgFlags = gosnmp.AuthPriv
+		params.PrivacyProtocol = privProtocols[server.SNMP.Priv()]
+		params.PrivacyPassphrase = server.SNMP.PrivPassword
+	}
+	client.Version = gosnmp.Version3
+	client.SecurityModel = gosnmp.UserSecurityModel
+	client.SecurityParameters = params
+	return client
+}
+
+var authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
+}
+
+var privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES192":  gosnmp.AES192,
+	"AES256":  gosnmp.AES256,
+	"AES192C": gosnmp.AES192C,
+	"AES256C": gosnmp.AES256C,
+}
+
+// toUPS maps the UPS-MIB objects in pdus to a NUT UPS, returning false if
+// the agent had none of them. The UPS is named after upsIdentName, or
+// nutServer if the agent doesn't have one. ups.status is built from
+// upsOutputSource and upsBatteryStatus.
+func toUPS(nutServer string, pdus []gosnmp.SnmpPDU) (nut.UPS, bool) {
+	ups := nut.UPS{
+		Name:        nutServer,
+		Description: "SNMP UPS-MIB",
+		Clients:     []string{},
+		Variables:   []nut.Variable{},
+		Commands:    []nut.Command{},
+	}
+
+	values := make(map[string]gosnmp.SnmpPDU, len(pdus))
+	for _, pdu := range pdus {
+		switch pdu.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+			continue
+		}
+		values[normaliseOID(pdu.Name)] = pdu
+	}
+	if len(values) == 0 {
+		return ups, false
+	}
+
+	if pdu, ok := values[oidIdentName]; ok {
+		if name := text(pdu); name != "" {
+			ups.Name = name
+		}
+	}
+	if status := upsStatus(values); status != "" {
+		ups.Variables = append(ups.Variables, nutclient.StringVariable("ups.status", "UPS status", status))
+	}
+	for _, o := range objects {
+		pdu, ok := values[o.oid]
+		if !ok {
+			continue
+		}
+		if o.text {
+			ups.Variables = append(ups.Variables, nutclient.StringVariable(o.name, o.description, text(pdu)))
+			continue
+		}
+		number := float64(gosnmp.ToBigInt(pdu.Value).Int64())
+		if o.multiplier != 0 {
+			number *= o.multiplier
+		}
+		if o.divisor != 0 {
+			number /= o.divisor
+		}
+		ups.Variables = append(ups.Variables, nutclient.NumberVariable(o.name, o.description, number))
+	}
+	return ups, true
+}
+
+// upsStatus returns the ups.status flags for upsOutputSource and
+// upsBatteryStatus, or "" if the agent has neither.
+func upsStatus(values map[string]gosnmp.SnmpPDU) string {
+	var flags []string
+	if pdu, ok := values[oidOutputSource]; ok {
+		if flag, ok := outputSources[gosnmp.ToBigInt(pdu.Value).Int64()]; ok {
+			flags = append(flags, flag)
+		}
+	}
+	if pdu, ok := values[oidBatteryStatus]; ok {
+		if flag, ok := batteryStatuses[gosnmp.ToBigInt(pdu.Value).Int64()]; ok {
+			flags = append(flags, flag)
+		}
+	}
+	return strings.Join(flags, " ")
+}
+
+func text(pdu gosnmp.SnmpPDU) string {
+	if value, ok := pdu.Value.([]byte); ok {
+		return strings.TrimSpace(string(value))
+	}
+	return fmt.Sprint(pdu.Value)
+}
+
+// normaliseOID gives OIDs the leading dot gosnmp returns them with.
+func normaliseOID(oid string) string {
+	if strings.HasPrefix(oid, ".") {
+		return oid
+	}
+	return "." + oid
+}
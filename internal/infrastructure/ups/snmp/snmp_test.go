@@ -0,0 +1,275 @@
+package snmpups
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compile time interface checks
+var _ repository.UPSRepository = new(SNMPRepository)
+
+// smartUPS is the UPS-MIB of an APC network management card.
+var smartUPS = map[string]gosnmp.SnmpPDU{
+	upsMIB + ".1.1.0":     {Type: gosnmp.OctetString, Value: []byte("APC")},
+	upsMIB + ".1.2.0":     {Type: gosnmp.OctetString, Value: []byte("Smart-UPS 750")},
+	upsMIB + ".1.3.0":     {Type: gosnmp.OctetString, Value: []byte("UPS 09.3 / ID=18")},
+	upsMIB + ".1.5.0":     {Type: gosnmp.OctetString, Value: []byte("smartups750")},
+	upsMIB + ".2.1.0":     {Type: gosnmp.Integer, Value: 3},
+	upsMIB + ".2.3.0":     {Type: gosnmp.Integer, Value: 45},
+	upsMIB + ".2.4.0":     {Type: gosnmp.Integer, Value: 62},
+	upsMIB + ".2.5.0":     {Type: gosnmp.Integer, Value: 268},
+	upsMIB + ".2.7.0":     {Type: gosnmp.Integer, Value: 29},
+	upsMIB + ".3.3.1.2.1": {Type: gosnmp.Integer, Value: 500},
+	upsMIB + ".3.3.1.3.1": {Type: gosnmp.Integer, Value: 0},
+	upsMIB + ".4.1.0":     {Type: gosnmp.Integer, Value: 5},
+	upsMIB + ".4.2.0":     {Type: gosnmp.Integer, Value: 499},
+	upsMIB + ".4.4.1.2.1": {Type: gosnmp.Integer, Value: 230},
+	upsMIB + ".4.4.1.5.1": {Type: gosnmp.Integer, Value: 15},
+}
+
+// startAgent starts a fake SNMPv2c agent answering Get requests from mib,
+// and returns the NUT server config for it. Objects missing from mib are
+// answered with noSuchObject.
+func startAgent(t *testing.T, mib map[string]gosnmp.SnmpPDU) *entity.NutServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: entity.DefaultSNMPCommunity, Logger: gosnmp.NewLogger(nil)}
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := decoder.SnmpDecodePacket(buf[:n])
+			if err != nil || request.Community != entity.DefaultSNMPCommunity {
+				continue
+			}
+			response := &gosnmp.SnmpPacket{
+				Version:   gosnmp.Version2c,
+				Community: request.Community,
+				PDUType:   gosnmp.GetResponse,
+				RequestID: request.RequestID,
+			}
+			for _, variable := range request.Variables {
+				pdu, ok := mib[variable.Name]
+				if !ok {
+					pdu = gosnmp.SnmpPDU{Type: gosnmp.NoSuchObject}
+				}
+				pdu.Name = variable.Name
+				response.Variables = append(response.Variables, pdu)
+			}
+			reply, err := response.MarshalMsg()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(reply, addr)
+		}
+	}()
+
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	return &entity.NutServer{Name: "ups-card", Host: "127.0.0.1", Port: addr.Port, Type: entity.SourceTypeSNMP}
+}
+
+func TestSNMPRepository_GetJSON(t *testing.T) {
+	server := startAgent(t, smartUPS)
+
+	got, err := NewSNMPRepository(time.Second).GetJSON(server)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `[{
+		"Name": "smartups750",
+		"Description": "SNMP UPS-MIB",
+		"Master": false,
+		"NumberOfLogins": 0,
+		"Clients": [],
+		"Commands": [],
+		"Variables": [
+			{"Name":"ups.status","Value":"OB LB","Type":"STRING","Description":"UPS status","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"ups.mfr","Value":"APC","Type":"STRING","Description":"UPS manufacturer","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"ups.model","Value":"Smart-UPS 750","Type":"STRING","Description":"UPS model","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"ups.firmware","Value":"UPS 09.3 / ID=18","Type":"STRING","Description":"UPS firmware","Writeable":false,"MaximumLength":0,"OriginalType":"STRING"},
+			{"Name":"battery.runtime","Value":2700,"Type":"INTEGER","Description":"Battery runtime (seconds)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.charge","Value":62,"Type":"INTEGER","Description":"Battery charge (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.voltage","Value":26.8,"Type":"FLOAT_64","Description":"Battery voltage (V)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"battery.temperature","Value":29,"Type":"INTEGER","Description":"Battery temperature (degrees C)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"input.frequency","Value":50,"Type":"INTEGER","Description":"Input line frequency (Hz)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"input.voltage","Value":0,"Type":"INTEGER","Description":"Input voltage (V)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"output.frequency","Value":49.9,"Type":"FLOAT_64","Description":"Output frequency (Hz)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"output.voltage","Value":230,"Type":"INTEGER","Description":"Output voltage (V)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"},
+			{"Name":"ups.load","Value":15,"Type":"INTEGER","Description":"Load on UPS (percent of full)","Writeable":false,"MaximumLength":0,"OriginalType":"NUMBER"}
+		]
+	}]`, got)
+}
+
+func TestSNMPRepository_GetJSON_errors(t *testing.T) {
+	t.Run("no UPS-MIB", func(t *testing.T) {
+		server := startAgent(t, map[string]gosnmp.SnmpPDU{})
+
+		_, err := NewSNMPRepository(time.Second).GetJSON(server)
+		assert.ErrorIs(t, err, ErrNoUPSMIB)
+	})
+
+	t.Run("wrong community times out", func(t *testing.T) {
+		server := startAgent(t, smartUPS)
+		server.SNMP.Community = "private"
+
+		_, err := NewSNMPRepository(100 * time.Millisecond).GetJSON(server)
+		assert.ErrorIs(t, err, ErrReadingUPSMIB)
+	})
+}
+
+func TestToUPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		mib        map[string]gosnmp.SnmpPDU
+		wantName   string
+		wantStatus string
+	}{
+		{
+			name:       "no upsIdentName uses the NUT server name",
+			mib:        map[string]gosnmp.SnmpPDU{oidOutputSource: {Type: gosnmp.Integer, Value: 3}},
+			wantName:   "ups-card",
+			wantStatus: "OL",
+		},
+		{
+			name: "empty upsIdentName uses the NUT server name",
+			mib: map[string]gosnmp.SnmpPDU{
+				oidIdentName:    {Type: gosnmp.OctetString, Value: []byte(" ")},
+				oidOutputSource: {Type: gosnmp.Integer, Value: 7},
+			},
+			wantName:   "ups-card",
+			wantStatus: "OL TRIM",
+		},
+		{
+			name: "depleted battery on bypass",
+			mib: map[string]gosnmp.SnmpPDU{
+				oidIdentName:     {Type: gosnmp.OctetString, Value: []byte("rack")},
+				oidOutputSource:  {Type: gosnmp.Integer, Value: 4},
+				oidBatteryStatus: {Type: gosnmp.Integer, Value: 4},
+			},
+			wantName:   "rack",
+			wantStatus: "OL BYPASS LB",
+		},
+		{
+			name: "normal battery status adds no flags",
+			mib: map[string]gosnmp.SnmpPDU{
+				oidOutputSource:  {Type: gosnmp.Integer, Value: 6},
+				oidBatteryStatus: {Type: gosnmp.Integer, Value: 2},
+			},
+			wantName:   "ups-card",
+			wantStatus: "OL BOOST",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pdus []gosnmp.SnmpPDU
+			for oid, pdu := range tt.mib {
+				pdu.Name = oid
+				pdus = append(pdus, pdu)
+			}
+
+			ups, found := toUPS("ups-card", pdus)
+			require.True(t, found)
+			assert.Equal(t, tt.wantName, ups.Name)
+			require.Len(t, ups.Variables, 1)
+			assert.Equal(t, "ups.status", ups.Variables[0].Name)
+			assert.Equal(t, tt.wantStatus, ups.Variables[0].Value)
+		})
+	}
+
+	t.Run("missing objects are skipped", func(t *testing.T) {
+		_, found := toUPS("ups-card", []gosnmp.SnmpPDU{
+			{Name: oidOutputSource, Type: gosnmp.NoSuchObject},
+			{Name: oidBatteryStatus, Type: gosnmp.NoSuchInstance},
+		})
+		assert.False(t, found)
+	})
+}
+
+func TestSNMPRepository_client(t *testing.T) {
+	repo := NewSNMPRepository(0)
+	assert.Equal(t, DefaultTimeout, repo.timeout)
+
+	tests := []struct {
+		server       entity.NutServer
+		wantFlags    gosnmp.SnmpV3MsgFlags
+		wantAuth     gosnmp.SnmpV3AuthProtocol
+		wantPriv     gosnmp.SnmpV3PrivProtocol
+		name         string
+		wantAuthPass string
+		wantPrivPass string
+	}{
+		{
+			name:      "noAuthNoPriv",
+			server:    entity.NutServer{Username: "upsmon", SNMP: entity.NutSNMP{Version: entity.SNMPVersion3}},
+			wantFlags: gosnmp.NoAuthNoPriv,
+			wantAuth:  gosnmp.NoAuth,
+			wantPriv:  gosnmp.NoPriv,
+		},
+		{
+			name:         "authNoPriv with the default protocol",
+			server:       entity.NutServer{Username: "upsmon", Password: "authpass", SNMP: entity.NutSNMP{Version: entity.SNMPVersion3}},
+			wantFlags:    gosnmp.AuthNoPriv,
+			wantAuth:     gosnmp.SHA,
+			wantPriv:     gosnmp.NoPriv,
+			wantAuthPass: "authpass",
+		},
+		{
+			name: "authPriv",
+			server: entity.NutServer{Username: "upsmon", Password: "authpass", SNMP: entity.NutSNMP{
+				Version:      entity.SNMPVersion3,
+				AuthProtocol: "SHA256",
+				PrivProtocol: "AES256",
+				PrivPassword: "privpass",
+			}},
+			wantFlags:    gosnmp.AuthPriv,
+			wantAuth:     gosnmp.SHA256,
+			wantPriv:     gosnmp.AES256,
+			wantAuthPass: "authpass",
+			wantPrivPass: "privpass",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := repo.client(&tt.server)
+			assert.Equal(t, gosnmp.Version3, client.Version)
+			assert.Equal(t, gosnmp.UserSecurityModel, client.SecurityModel)
+			assert.Equal(t, tt.wantFlags, client.MsgFlags)
+
+			params, ok := client.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+			require.True(t, ok)
+			assert.Equal(t, "upsmon", params.UserName)
+			assert.Equal(t, tt.wantAuth, params.AuthenticationProtocol)
+			assert.Equal(t, tt.wantAuthPass, params.AuthenticationPassphrase)
+			assert.Equal(t, tt.wantPriv, params.PrivacyProtocol)
+			assert.Equal(t, tt.wantPrivPass, params.PrivacyPassphrase)
+		})
+	}
+
+	t.Run("v2c", func(t *testing.T) {
+		client := repo.client(&entity.NutServer{Host: "10.0.0.5", Port: 161, SNMP: entity.NutSNMP{Community: "private"}})
+		assert.Equal(t, gosnmp.Version2c, client.Version)
+		assert.Equal(t, "private", client.Community)
+		assert.Nil(t, client.SecurityParameters)
+	})
+
+	t.Run("every protocol is mapped", func(t *testing.T) {
+		for _, protocol := range entity.SNMPAuthProtocols {
+			assert.Contains(t, authProtocols, protocol)
+		}
+		for _, protocol := range entity.SNMPPrivProtocols {
+			assert.Contains(t, privProtocols, protocol)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package sourceups
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+)
+
+var ErrUnsupportedSource = errors.New("no UPS repository for source type")
+
+// SourceRepository reads each NUT server's UPSes with the UPSRepository for
+// its source type, so NUT servers and apcupsd can be mixed in one config.
+// Satisfies repository.UPSRepository and repository.UPSVariableRepository.
+type SourceRepository struct {
+	sources map[entity.SourceType]repository.UPSRepository
+}
+
+// NewSourceRepository creates a SourceRepository reading each source type
+// with its repository in sources.
+func NewSourceRepository(sources map[entity.SourceType]repository.UPSRepository) *SourceRepository {
+	return &SourceRepository{sources: sources}
+}
+
+func (r *SourceRepository) GetJSON(server *entity.NutServer) (string, error) {
+	source, err := r.source(server)
+	if err != nil {
+		return "", err
+	}
+	return source.GetJSON(server)
+}
+
+// GetVariables reads only the named variables if the NUT server's source can,
+// and every variable otherwise.
+func (r *SourceRepository) GetVariables(server *entity.NutServer, names []string) (string, error) {
+	source, err := r.source(server)
+	if err != nil {
+		return "", err
+	}
+	if variables, ok := source.(repository.UPSVariableRepository); ok {
+		return variables.GetVariables(server, names)
+	}
+	return source.GetJSON(server)
+}
+
+func (r *SourceRepository) source(server *entity.NutServer) (repository.UPSRepository, error) {
+	source, ok := r.sources[server.Type.Kind()]
+	if !ok {
+		return nil, fmt.Errorf("%w %q of %s", ErrUnsupportedSource, server.Type.Kind(), server.Name)
+	}
+	return source, nil
+}
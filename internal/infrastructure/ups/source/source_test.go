@@ -0,0 +1,99 @@
+package sourceups
+
+import (
+	"testing"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// compile time interface checks
+var (
+	_ repository.UPSRepository         = new(SourceRepository)
+	_ repository.UPSVariableRepository = new(SourceRepository)
+)
+
+func TestSourceRepository_GetJSON(t *testing.T) {
+	tests := []struct {
+		wantErr    error
+		name       string
+		sourceType entity.SourceType
+		wantJSON   string
+	}{
+		{
+			name:     "no type reads NUT",
+			wantJSON: `"nut"`,
+		},
+		{
+			name:       "nut",
+			sourceType: entity.SourceTypeNUT,
+			wantJSON:   `"nut"`,
+		},
+		{
+			name:       "apcupsd",
+			sourceType: entity.SourceTypeApcupsd,
+			wantJSON:   `"apcupsd"`,
+		},
+		{
+			name:       "unsupported",
+			sourceType: "snmp",
+			wantErr:    ErrUnsupportedSource,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			nut := mocks.NewMockUPSRepository(ctrl)
+			apcupsd := mocks.NewMockUPSRepository(ctrl)
+			server := &entity.NutServer{Name: "raspberrypi", Type: tt.sourceType}
+			switch tt.wantJSON {
+			case `"nut"`:
+				nut.EXPECT().GetJSON(server).Return(tt.wantJSON, nil)
+			case `"apcupsd"`:
+				apcupsd.EXPECT().GetJSON(server).Return(tt.wantJSON, nil)
+			}
+
+			r := NewSourceRepository(map[entity.SourceType]repository.UPSRepository{
+				entity.SourceTypeNUT:     nut,
+				entity.SourceTypeApcupsd: apcupsd,
+			})
+			got, err := r.GetJSON(server)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantJSON, got)
+		})
+	}
+}
+
+func TestSourceRepository_GetVariables(t *testing.T) {
+	names := []string{"ups.status", "battery.charge"}
+	ctrl := gomock.NewController(t)
+	nut := struct {
+		*mocks.MockUPSRepository
+		*mocks.MockUPSVariableRepository
+	}{mocks.NewMockUPSRepository(ctrl), mocks.NewMockUPSVariableRepository(ctrl)}
+	apcupsd := mocks.NewMockUPSRepository(ctrl)
+	r := NewSourceRepository(map[entity.SourceType]repository.UPSRepository{
+		entity.SourceTypeNUT:     nut,
+		entity.SourceTypeApcupsd: apcupsd,
+	})
+
+	nutServer := &entity.NutServer{Name: "raspberrypi"}
+	nut.MockUPSVariableRepository.EXPECT().GetVariables(nutServer, names).Return(`"variables"`, nil)
+	got, err := r.GetVariables(nutServer, names)
+	require.NoError(t, err)
+	assert.Equal(t, `"variables"`, got)
+
+	apcupsdServer := &entity.NutServer{Name: "apc", Type: entity.SourceTypeApcupsd}
+	apcupsd.EXPECT().GetJSON(apcupsdServer).Return(`"apcupsd"`, nil)
+	got, err = r.GetVariables(apcupsdServer, names)
+	require.NoError(t, err)
+	assert.Equal(t, `"apcupsd"`, got, "sources that can't read single variables are read in full")
+
+	_, err = r.GetVariables(&entity.NutServer{Name: "unknown", Type: "snmp"}, names)
+	assert.ErrorIs(t, err, ErrUnsupportedSource)
+}
@@ -15,7 +15,7 @@ const MagicPacketSize = 102
 var (
 	ErrFailedCreateMagicPacket = errors.New("failed to create magic packet")
 	ErrFailedSendWoLPacket     = errors.New("failed to send WoL packet")
-	ErrExpectedPacketSize      = fmt.Errorf("magic packet sent was expected to be of size %d", MagicPacketSize)
+	ErrExpectedPacketSize      = errors.New("magic packet sent was not the expected size")
 )
 
 type WakeOnLan struct {
@@ -39,11 +39,11 @@ func (tgt *WakeOnLan) Wake() error {
 		return err
 	}
 	defer conn.Close()
-	return wakeInternal(conn, tgt.MacAddress)
+	return wakeInternal(conn, tgt.MacAddress, tgt.SecureOn)
 }
 
-func wakeInternal(dst io.ReadWriteCloser, mac *entity.MacAddress) error {
-	mp, err := newMagicPacket(mac.MAC)
+func wakeInternal(dst io.ReadWriteCloser, mac *entity.MacAddress, secureOn entity.SecureOn) error {
+	mp, err := newMagicPacket(mac.MAC, secureOn)
 	if err != nil {
 		return err
 	}
@@ -53,18 +53,31 @@ func wakeInternal(dst io.ReadWriteCloser, mac *entity.MacAddress) error {
 		return fmt.Errorf("%w: %w", ErrFailedSendWoLPacket, err)
 	}
 
-	if size != MagicPacketSize {
-		return fmt.Errorf("%w: magic packet sent was %d bytes long", ErrExpectedPacketSize, size)
+	// the packet is MagicPacketSize bytes long, plus 4 or 6 bytes if it has
+	// a SecureOn password
+	if size != len(mp) {
+		return fmt.Errorf("%w of %d bytes: sent %d bytes", ErrExpectedPacketSize, len(mp), size)
 	}
 
 	return nil
 }
 
-func newMagicPacket(mac string) ([]byte, error) {
+// newMagicPacket returns the magic packet waking mac, followed by the
+// SecureOn password if there is one.
+func newMagicPacket(mac string, secureOn entity.SecureOn) ([]byte, error) {
+	password, err := secureOn.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedCreateMagicPacket, err)
+	}
+
 	mp, err := wol.New(mac)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrFailedCreateMagicPacket, err)
 	}
 
-	return mp.Marshal()
+	packet, err := mp.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return append(packet, password...), nil
 }
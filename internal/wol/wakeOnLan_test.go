@@ -2,6 +2,7 @@ package wol
 
 import (
 	"io"
+	"slices"
 	"testing"
 	"time"
 
@@ -32,6 +33,10 @@ var validMagicPacket = []byte{
 	0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
 }
 
+const validSecureOn = "0a:0b:0c:0d:0e:0f"
+
+var validSecureOnPacket = append(slices.Clone(validMagicPacket), 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f)
+
 type readWriteCloser struct {
 	BS []byte
 }
@@ -88,7 +93,8 @@ func newReadWriteCloserError() io.ReadWriteCloser {
 
 func Test_newMagicPacket(t *testing.T) {
 	type args struct {
-		mac string
+		mac      string
+		secureOn entity.SecureOn
 	}
 	tests := []struct {
 		wantErr error
@@ -136,10 +142,37 @@ func Test_newMagicPacket(t *testing.T) {
 			want:    validMagicPacket,
 			wantErr: nil,
 		},
+		{
+			name: "valid MAC with 6 byte SecureOn password",
+			args: args{
+				mac:      validMagicPacketMAC,
+				secureOn: validSecureOn,
+			},
+			want:    validSecureOnPacket,
+			wantErr: nil,
+		},
+		{
+			name: "valid MAC with 4 byte SecureOn password",
+			args: args{
+				mac:      validMagicPacketMAC,
+				secureOn: "10.11.12.13",
+			},
+			want:    append(slices.Clone(validMagicPacket), 10, 11, 12, 13),
+			wantErr: nil,
+		},
+		{
+			name: "invalid SecureOn password",
+			args: args{
+				mac:      validMagicPacketMAC,
+				secureOn: "password",
+			},
+			want:    nil,
+			wantErr: entity.ErrInvalidSecureOn,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newMagicPacket(tt.args.mac)
+			got, err := newMagicPacket(tt.args.mac, tt.args.secureOn)
 
 			assert.ErrorIs(t, err, tt.wantErr)
 			assert.Equal(t, tt.want, got)
@@ -149,14 +182,16 @@ func Test_newMagicPacket(t *testing.T) {
 
 func Test_wakeInternal(t *testing.T) {
 	type args struct {
-		dst io.ReadWriteCloser
-		mac *entity.MacAddress
+		dst      io.ReadWriteCloser
+		mac      *entity.MacAddress
+		secureOn entity.SecureOn
 	}
 	tests := []struct {
-		name     string
-		args     args
-		wantErr  error
-		wantSent []byte
+		name       string
+		args       args
+		wantErr    error
+		wantErrMsg string
+		wantSent   []byte
 	}{
 		{
 			name: "valid MAC",
@@ -173,20 +208,43 @@ func Test_wakeInternal(t *testing.T) {
 				dst: newReadWriteCloserError(),
 				mac: &entity.MacAddress{MAC: validMagicPacketMAC},
 			},
-			wantErr:  ErrExpectedPacketSize,
-			wantSent: validMagicPacket,
+			wantErr:    ErrExpectedPacketSize,
+			wantErrMsg: "of 102 bytes",
+			wantSent:   validMagicPacket,
+		},
+		{
+			name: "valid MAC with SecureOn password",
+			args: args{
+				dst:      newReadWriteCloser(),
+				mac:      &entity.MacAddress{MAC: validMagicPacketMAC},
+				secureOn: validSecureOn,
+			},
+			wantErr:  nil,
+			wantSent: validSecureOnPacket,
+		},
+		{
+			name: "invalid write length with SecureOn password",
+			args: args{
+				dst:      newReadWriteCloserError(),
+				mac:      &entity.MacAddress{MAC: validMagicPacketMAC},
+				secureOn: validSecureOn,
+			},
+			wantErr:    ErrExpectedPacketSize,
+			wantErrMsg: "of 108 bytes",
+			wantSent:   validSecureOnPacket,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := wakeInternal(tt.args.dst, tt.args.mac)
+			err := wakeInternal(tt.args.dst, tt.args.mac, tt.args.secureOn)
 			assert.ErrorIs(t, err, tt.wantErr)
 
 			if tt.wantErr != nil {
+				assert.ErrorContains(t, err, tt.wantErrMsg)
 				return
 			}
 
-			sent := make([]byte, MagicPacketSize)
+			sent := make([]byte, len(tt.wantSent))
 			_, err = tt.args.dst.Read(sent)
 			require.NoError(t, err)
 
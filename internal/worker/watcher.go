@@ -0,0 +1,217 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository"
+)
+
+const (
+	statusVariable = "ups.status"
+	chargeVariable = "battery.charge"
+)
+
+// watchedVariables are the only variables a Watcher reads.
+var watchedVariables = []string{statusVariable, chargeVariable}
+
+// Transition is a change to a UPS seen by a Watcher between two polls.
+type Transition struct {
+	UPS      string
+	Variable string
+	From     string
+	To       string
+}
+
+// TransitionFunc is called by a Watcher when UPSes on nutServer change.
+type TransitionFunc func(nutServer *entity.NutServer, transitions []Transition)
+
+// Watcher polls NUT servers more often than targets are evaluated, so that
+// a change such as the power coming back (OB to OL), a low battery (LB)
+// clearing or battery.charge crossing a threshold can be acted on straight
+// away rather than at the targets' next interval.
+type Watcher struct {
+	ups          repository.UPSVariableRepository
+	logger       *slog.Logger
+	onTransition TransitionFunc
+	wg           sync.WaitGroup
+	nutServers   []*entity.NutServer
+	thresholds   []float64
+	interval     time.Duration
+}
+
+// upsState is what a Watcher remembers of a UPS between polls.
+type upsState struct {
+	status    []string
+	charge    float64
+	hasCharge bool
+}
+
+// NewWatcher creates a Watcher polling the NUT servers in config that have
+// targets through ups, as configured by config.Watch, and calling
+// onTransition whenever one of their UPSes changes. Only ups.status and
+// battery.charge are read.
+func NewWatcher(config *entity.Config, ups repository.UPSVariableRepository, onTransition TransitionFunc, logger *slog.Logger) *Watcher {
+	var nutServers []*entity.NutServer
+	for _, nutServer := range config.NutServers {
+		if len(nutServer.Targets) > 0 {
+			nutServers = append(nutServers, nutServer)
+		}
+	}
+
+	return &Watcher{
+		ups:          ups,
+		logger:       logger.With(slog.String("type", "watcher")),
+		onTransition: onTransition,
+		nutServers:   nutServers,
+		thresholds:   config.Watch.Thresholds(),
+		interval:     config.Watch.PollInterval(),
+	}
+}
+
+// Start polls each NUT server in its own goroutine until ctx is done.
+func (w *Watcher) Start(ctx context.Context) {
+	for _, nutServer := range w.nutServers {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.watch(ctx, nutServer)
+		}()
+	}
+}
+
+func (w *Watcher) Wait() {
+	w.wg.Wait()
+}
+
+func (w *Watcher) watch(ctx context.Context, nutServer *entity.NutServer) {
+	logger := w.logger.With(slog.String("nut_server", nutServer.Name))
+	logger.Info("Watching NUT server for UPS status changes", slog.Duration("interval", w.interval))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	// the first poll only records the state to compare against
+	states := w.poll(logger, nutServer, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Gracefully stopping watcher")
+			return
+		case <-ticker.C:
+			states = w.poll(logger, nutServer, states)
+		}
+	}
+}
+
+// poll reads nutServer and calls onTransition if any of its UPSes changed
+// since previous. It returns the states to compare the next poll against,
+// which are still previous if nutServer couldn't be read.
+func (w *Watcher) poll(logger *slog.Logger, nutServer *entity.NutServer, previous map[string]upsState) map[string]upsState {
+	upsJSON, err := w.ups.GetVariables(nutServer, watchedVariables)
+	if err != nil {
+		logger.Warn("Failed to poll NUT server", slog.Any("error", err))
+		return previous
+	}
+	current, err := parseStates(upsJSON)
+	if err != nil {
+		logger.Warn("Failed to parse UPSes from NUT server", slog.Any("error", err))
+		return previous
+	}
+
+	transitions := w.transitions(previous, current)
+	if len(transitions) == 0 {
+		return current
+	}
+	for _, transition := range transitions {
+		logger.Info("UPS changed, evaluating targets",
+			slog.String("ups", transition.UPS),
+			slog.String("variable", transition.Variable),
+			slog.String("from", transition.From),
+			slog.String("to", transition.To))
+	}
+	w.onTransition(nutServer, transitions)
+	return current
+}
+
+// transitions compares the UPSes seen in both previous and current. A UPS
+// changes if any of its ups.status flags change or its battery.charge
+// crosses one of the thresholds, in either direction.
+func (w *Watcher) transitions(previous, current map[string]upsState) []Transition {
+	var transitions []Transition
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		before, ok := previous[name]
+		if !ok {
+			continue
+		}
+		after := current[name]
+
+		if !slices.Equal(before.status, after.status) {
+			transitions = append(transitions, Transition{
+				UPS:      name,
+				Variable: statusVariable,
+				From:     strings.Join(before.status, " "),
+				To:       strings.Join(after.status, " "),
+			})
+		}
+		if before.hasCharge && after.hasCharge && w.crossesThreshold(before.charge, after.charge) {
+			transitions = append(transitions, Transition{
+				UPS:      name,
+				Variable: chargeVariable,
+				From:     strconv.FormatFloat(before.charge, 'f', -1, 64),
+				To:       strconv.FormatFloat(after.charge, 'f', -1, 64),
+			})
+		}
+	}
+	return transitions
+}
+
+func (w *Watcher) crossesThreshold(before, after float64) bool {
+	for _, threshold := range w.thresholds {
+		if (before < threshold) != (after < threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStates returns the state of each UPS in upsJSON by name. The
+// ups.status flags are sorted so reordering them isn't a change.
+func parseStates(upsJSON string) (map[string]upsState, error) {
+	var upses []struct {
+		Name      string
+		Variables []struct {
+			Value any
+			Name  string
+		}
+	}
+	if err := json.Unmarshal([]byte(upsJSON), &upses); err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]upsState, len(upses))
+	for _, ups := range upses {
+		var state upsState
+		for _, variable := range ups.Variables {
+			switch variable.Name {
+			case statusVariable:
+				if status, ok := variable.Value.(string); ok {
+					state.status = strings.Fields(status)
+					slices.Sort(state.status)
+				}
+			case chargeVariable:
+				state.charge, state.hasCharge = variable.Value.(float64)
+			}
+		}
+		states[ups.Name] = state
+	}
+	return states, nil
+}
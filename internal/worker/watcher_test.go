@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/TheDarthMole/UPSWake/internal/domain/entity"
+	"github.com/TheDarthMole/UPSWake/internal/domain/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func upsJSON(status string, charge string) string {
+	return `[{"Name":"cyberpower900","Variables":[` +
+		`{"Name":"battery.charge","Value":` + charge + `},` +
+		`{"Name":"ups.status","Value":"` + status + `"}]}]`
+}
+
+func TestWatcher_poll(t *testing.T) {
+	server := &entity.NutServer{Name: "raspberrypi", Targets: []*entity.TargetServer{{Name: "Test Target"}}}
+	config := &entity.Config{
+		NutServers: []*entity.NutServer{server},
+		Watch:      &entity.Watch{ChargeThresholds: []float64{50, 80}},
+	}
+
+	tests := []struct {
+		readErr         error
+		name            string
+		previous        string
+		current         string
+		wantLog         string
+		wantTransitions []Transition
+	}{
+		{
+			name:     "no change",
+			previous: upsJSON("OL", "100"),
+			current:  upsJSON("OL", "100"),
+		},
+		{
+			name:     "power restored",
+			previous: upsJSON("OB DISCHRG", "62"),
+			current:  upsJSON("OL CHRG", "62"),
+			wantTransitions: []Transition{
+				{UPS: "cyberpower900", Variable: "ups.status", From: "DISCHRG OB", To: "CHRG OL"},
+			},
+		},
+		{
+			name:     "low battery cleared",
+			previous: upsJSON("OL LB", "10"),
+			current:  upsJSON("OL", "10"),
+			wantTransitions: []Transition{
+				{UPS: "cyberpower900", Variable: "ups.status", From: "LB OL", To: "OL"},
+			},
+		},
+		{
+			name:     "reordered flags aren't a change",
+			previous: upsJSON("OL CHRG", "62"),
+			current:  upsJSON("CHRG OL", "62"),
+		},
+		{
+			name:     "charge rises across a threshold",
+			previous: upsJSON("OL", "79"),
+			current:  upsJSON("OL", "80"),
+			wantTransitions: []Transition{
+				{UPS: "cyberpower900", Variable: "battery.charge", From: "79", To: "80"},
+			},
+		},
+		{
+			name:     "charge falls across a threshold",
+			previous: upsJSON("OB", "51.5"),
+			current:  upsJSON("OB", "49"),
+			wantTransitions: []Transition{
+				{UPS: "cyberpower900", Variable: "battery.charge", From: "51.5", To: "49"},
+			},
+		},
+		{
+			name:     "charge changes between thresholds",
+			previous: upsJSON("OL", "55"),
+			current:  upsJSON("OL", "79"),
+		},
+		{
+			name:     "new UPS",
+			previous: `[]`,
+			current:  upsJSON("OL", "100"),
+		},
+		{
+			name:     "read fails",
+			previous: upsJSON("OB", "62"),
+			readErr:  errors.New("connection refused"),
+			wantLog:  "Failed to poll NUT server",
+		},
+		{
+			name:     "invalid JSON",
+			previous: upsJSON("OB", "62"),
+			current:  `{"not":"a list"}`,
+			wantLog:  "Failed to parse UPSes from NUT server",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			ups := mocks.NewMockUPSVariableRepository(ctrl)
+			ups.EXPECT().GetVariables(server, watchedVariables).Return(tt.current, tt.readErr)
+			logBuf := new(bytes.Buffer)
+
+			var gotTransitions []Transition
+			w := NewWatcher(config, ups, func(nutServer *entity.NutServer, transitions []Transition) {
+				assert.Equal(t, server, nutServer)
+				gotTransitions = transitions
+			}, slog.New(slog.NewJSONHandler(logBuf, nil)))
+
+			previous, err := parseStates(tt.previous)
+			require.NoError(t, err)
+			got := w.poll(w.logger, server, previous)
+
+			assert.Equal(t, tt.wantTransitions, gotTransitions)
+			if tt.wantLog != "" {
+				assert.Contains(t, logBuf.String(), tt.wantLog)
+				assert.Equal(t, previous, got, "the previous state is kept when polling fails")
+			}
+		})
+	}
+}
+
+func TestWatcher_Start(t *testing.T) {
+	server := &entity.NutServer{Name: "raspberrypi", Targets: []*entity.TargetServer{{Name: "Test Target"}}}
+	config := &entity.Config{
+		NutServers: []*entity.NutServer{
+			server,
+			{Name: "no targets"},
+		},
+		Watch: &entity.Watch{Interval: 10 * time.Millisecond},
+	}
+
+	ctrl := gomock.NewController(t)
+	ups := mocks.NewMockUPSVariableRepository(ctrl)
+	gomock.InOrder(
+		ups.EXPECT().GetVariables(server, watchedVariables).Return(upsJSON("OB", "62"), nil),
+		ups.EXPECT().GetVariables(server, watchedVariables).Return(upsJSON("OL", "62"), nil).MinTimes(1),
+	)
+
+	triggered := make(chan []Transition, 1)
+	w := NewWatcher(config, ups, func(_ *entity.NutServer, transitions []Transition) {
+		triggered <- transitions
+	}, slog.New(slog.NewJSONHandler(new(bytes.Buffer), nil)))
+	assert.Equal(t, []*entity.NutServer{server}, w.nutServers, "NUT servers without targets aren't watched")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w.Start(ctx)
+
+	select {
+	case transitions := <-triggered:
+		assert.Equal(t, []Transition{{UPS: "cyberpower900", Variable: "ups.status", From: "OB", To: "OL"}}, transitions)
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher didn't see the power being restored")
+	}
+
+	cancel()
+	w.Wait()
+}
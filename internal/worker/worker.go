@@ -39,6 +39,7 @@ func NewWorkerPool(ctx context.Context, config *entity.Config, tlsConfig *tls.Co
 			if err != nil {
 				return nil, err
 			}
+			worker.nutServer = mapping.Name
 			workers = append(workers, worker)
 		}
 	}
@@ -49,12 +50,22 @@ func NewWorkerPool(ctx context.Context, config *entity.Config, tlsConfig *tls.Co
 	}, nil
 }
 
+// wakeResponse is the subset of the upswake endpoint's response that is logged by workers.
+type wakeResponse struct {
+	Message  string `json:"message"`
+	Reason   string `json:"reason"`
+	RuleMode string `json:"rule_mode"`
+	Woken    bool   `json:"woken"`
+}
+
 type Worker struct {
 	ctx         context.Context
 	wg          *sync.WaitGroup
 	logger      *slog.Logger
 	client      *http.Client
 	baseRequest *http.Request
+	trigger     chan struct{}
+	nutServer   string
 	body        []byte
 	interval    time.Duration
 }
@@ -70,6 +81,25 @@ func (w *Pool) Wait() {
 	w.wg.Wait()
 }
 
+// Trigger makes the workers for the targets of the named NUT server send a
+// wake request straight away, without waiting for their interval, and
+// returns how many were triggered. Triggers that arrive while a worker is
+// still busy with an earlier one are merged into a single request.
+func (w *Pool) Trigger(nutServer string) int {
+	triggered := 0
+	for _, worker := range w.workers {
+		if worker.nutServer != nutServer {
+			continue
+		}
+		select {
+		case worker.trigger <- struct{}{}:
+		default: // already triggered
+		}
+		triggered++
+	}
+	return triggered
+}
+
 func newWorker(ctx context.Context, targetServer *entity.TargetServer, client *http.Client, wg *sync.WaitGroup, logger *slog.Logger, url string) (*Worker, error) {
 	jobLogger := logger.With(
 		slog.String("type", "serveJob"),
@@ -96,6 +126,7 @@ func newWorker(ctx context.Context, targetServer *entity.TargetServer, client *h
 		body:        body,
 		interval:    targetServer.Interval,
 		baseRequest: req,
+		trigger:     make(chan struct{}, 1),
 	}, nil
 }
 
@@ -118,6 +149,9 @@ func (w *Worker) run() {
 				return
 			case <-ticker.C:
 				w.sendWakeRequest()
+			case <-w.trigger:
+				w.logger.Debug("Worker triggered by a UPS status change")
+				w.sendWakeRequest()
 			}
 		}
 	}()
@@ -154,5 +188,21 @@ func (w *Worker) sendWakeRequest() {
 			slog.String("status_code", resp.Status))
 		return
 	}
-	w.logger.Debug("Successfully sent upswake request")
+
+	var wakeResp wakeResponse
+	if err = json.NewDecoder(resp.Body).Decode(&wakeResp); err != nil {
+		w.logger.Debug("Successfully sent upswake request")
+		return
+	}
+	// most evaluations don't wake anything, so only those that do are
+	// logged at info level
+	level := slog.LevelDebug
+	if wakeResp.Woken {
+		level = slog.LevelInfo
+	}
+	w.logger.Log(w.ctx, level, "Wake evaluation complete",
+		slog.Bool("woken", wakeResp.Woken),
+		slog.String("message", wakeResp.Message),
+		slog.String("reason", wakeResp.Reason),
+		slog.String("rule_mode", wakeResp.RuleMode))
 }
@@ -141,6 +141,26 @@ func successHandler(t *testing.T) http.HandlerFunc {
 	}
 }
 
+func reasonHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestAssertion(t, r)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"message":"No rule evaluated to true","reason":"battery.charge 62 < 80 on cyberpower900","woken":false}`))
+		assert.NoError(t, err)
+	}
+}
+
+func wokenHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestAssertion(t, r)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"message":"Wake on LAN packet sent","reason":"battery.charge 85 >= 80 on cyberpower900","woken":true}`))
+		assert.NoError(t, err)
+	}
+}
+
 func slowHandler(t *testing.T) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		requestAssertion(t, r)
@@ -264,6 +284,30 @@ func TestPool_Start(t *testing.T) {
 				notWantLogOutputs: errorStrings,
 			},
 		},
+		{
+			name:        "decision reason is logged when woken",
+			handlerFunc: wokenHandler,
+			fields: fields{
+				config: oneServerOneTargetConfig,
+			},
+			attestations: attestations{
+				wantLogOutputs: []string{
+					`"level":"INFO","msg":"Wake evaluation complete","type":"serveJob","worker_name":"Test Target","woken":true,"message":"Wake on LAN packet sent","reason":"battery.charge 85 >= 80 on cyberpower900"`,
+				},
+				notWantLogOutputs: errorStrings,
+			},
+		},
+		{
+			name:        "evaluation that didn't wake isn't logged at info",
+			handlerFunc: reasonHandler,
+			fields: fields{
+				config: oneServerOneTargetConfig,
+			},
+			attestations: attestations{
+				wantLogOutputs:    []string{},
+				notWantLogOutputs: append([]string{`"msg":"Wake evaluation complete"`}, errorStrings...),
+			},
+		},
 		{
 			name:        "failed response from server",
 			handlerFunc: internalServerErrorHandler,
@@ -365,3 +409,56 @@ func TestPool_Start(t *testing.T) {
 		assert.Empty(t, workerPool)
 	})
 }
+
+func TestPool_Trigger(t *testing.T) {
+	requests := make(chan string, 10)
+	httpTest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests <- body["mac"]
+	}))
+	t.Cleanup(httpTest.Close)
+
+	config := &entity.Config{
+		NutServers: []*entity.NutServer{
+			{
+				Name: "Test Server 1",
+				Targets: []*entity.TargetServer{
+					{Name: "Test Target 1", MacAddress: &entity.MacAddress{MAC: "00:11:22:33:44:55"}, Interval: time.Hour},
+				},
+			},
+			{
+				Name: "Test Server 2",
+				Targets: []*entity.TargetServer{
+					{Name: "Test Target 2", MacAddress: &entity.MacAddress{MAC: "11:11:22:33:44:55"}, Interval: time.Hour},
+				},
+			},
+		},
+	}
+	logger := slog.New(slog.NewJSONHandler(&strings.Builder{}, &slog.HandlerOptions{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	workerPool, err := NewWorkerPool(ctx, config, &tls.Config{}, logger, httpTest.URL)
+	require.NoError(t, err)
+	workerPool.Start()
+
+	assert.Equal(t, 1, workerPool.Trigger("Test Server 2"))
+	assert.Equal(t, 1, workerPool.Trigger("Test Server 2"), "a pending trigger is merged with the next")
+	assert.Equal(t, 0, workerPool.Trigger("Unknown Server"))
+
+	select {
+	case mac := <-requests:
+		assert.Equal(t, "11:11:22:33:44:55", mac, "only the triggered server's targets are evaluated")
+	case <-time.After(2 * time.Second):
+		t.Fatal("triggered worker didn't send a wake request")
+	}
+	select {
+	case mac := <-requests:
+		t.Fatalf("unexpected wake request for %s", mac)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	workerPool.Wait()
+}
@@ -9,3 +9,7 @@ wake if {
 	input[i].Variables[k].Name == "ups.status"
 	input[i].Variables[k].Value == "OL" # On Line (mains is present)
 }
+
+reason := "cyberpower900 is on line power with at least 80% battery charge" if wake
+
+reason := "cyberpower900 is not on line power with at least 80% battery charge" if not wake
@@ -0,0 +1,17 @@
+package upswake
+
+test_wake_when_online_with_full_charge if {
+	wake with input as data.fixtures.online_full_charge
+}
+
+test_no_wake_when_on_battery if {
+	not wake with input as data.fixtures.on_battery
+}
+
+test_reason_when_on_battery if {
+	reason == "cyberpower900 is not on line power with at least 80% battery charge" with input as data.fixtures.on_battery
+}
+
+test_reason_when_online_with_full_charge if {
+	reason == "cyberpower900 is on line power with at least 80% battery charge" with input as data.fixtures.online_full_charge
+}